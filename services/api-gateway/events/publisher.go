@@ -6,37 +6,40 @@ import (
 	"fmt"
 	"log"
 
-	"ride-sharing/shared/events"
 	"ride-sharing/shared/contracts"
+	"ride-sharing/shared/events"
 )
 
 // GatewayEventPublisher API网关事件发布器
 type GatewayEventPublisher struct {
 	publisher events.Publisher
+	// locations 合并司机位置更新的批量发布器，每个WebSocket帧不再单独发布一条消息
+	locations *events.BatchPublisher
 }
 
 // NewGatewayEventPublisher 创建网关事件发布器
 func NewGatewayEventPublisher(publisher events.Publisher) *GatewayEventPublisher {
 	return &GatewayEventPublisher{
 		publisher: publisher,
+		locations: events.NewBatchPublisher(publisher, events.DefaultBatchOptions()),
 	}
 }
 
 // PublishDriverTripResponse 发布司机行程响应命令
-func (p *GatewayEventPublisher) PublishDriverTripResponse(ctx context.Context, response DriverTripResponse) error {
+func (p *GatewayEventPublisher) PublishDriverTripResponse(ctx context.Context, response contracts.DriverTripResponse) error {
 	var commandType string
 	if response.Accept {
 		commandType = contracts.DriverCmdTripAccept
 	} else {
 		commandType = contracts.DriverCmdTripDecline
 	}
-	
+
 	// 发布命令
-	err := p.publisher.PublishCommand(commandType, response)
+	err := p.publisher.PublishCommand(ctx, commandType, response)
 	if err != nil {
 		return fmt.Errorf("发布司机行程响应命令失败: %w", err)
 	}
-	
+
 	action := "接受"
 	if !response.Accept {
 		action = "拒绝"
@@ -45,35 +48,18 @@ func (p *GatewayEventPublisher) PublishDriverTripResponse(ctx context.Context, r
 	return nil
 }
 
-// PublishDriverLocationUpdate 发布司机位置更新命令
-func (p *GatewayEventPublisher) PublishDriverLocationUpdate(ctx context.Context, update DriverLocationUpdate) error {
-	// 发布命令
-	err := p.publisher.PublishCommand(contracts.DriverCmdLocation, update)
-	if err != nil {
+// PublishDriverLocationUpdate 将司机位置更新命令加入批次，批次在后台发布
+func (p *GatewayEventPublisher) PublishDriverLocationUpdate(ctx context.Context, update contracts.DriverLocationUpdate) error {
+	if err := p.locations.Add(ctx, contracts.DriverCmdLocation, update); err != nil {
 		return fmt.Errorf("发布司机位置更新命令失败: %w", err)
 	}
-	
-	log.Printf("成功发布司机位置更新命令: 司机ID=%s", update.DriverID)
 	return nil
 }
 
-// Close 关闭发布器
+// Close 发布剩余的位置更新后关闭发布器
 func (p *GatewayEventPublisher) Close() error {
+	if err := p.locations.Close(); err != nil {
+		log.Printf("发布剩余的司机位置更新失败: %v", err)
+	}
 	return p.publisher.Close()
 }
-
-// DriverTripResponse 司机行程响应
-type DriverTripResponse struct {
-	TripID   string `json:"tripID"`
-	RiderID  string `json:"riderID"`
-	DriverID string `json:"driverID"`
-	Accept   bool   `json:"accept"`
-}
-
-// DriverLocationUpdate 司机位置更新
-type DriverLocationUpdate struct {
-	DriverID string  `json:"driverID"`
-	Latitude float64 `json:"latitude"`
-	Longitude float64 `json:"longitude"`
-	Timestamp int64  `json:"timestamp"`
-}
\ No newline at end of file
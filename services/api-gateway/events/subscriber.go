@@ -2,26 +2,27 @@ package events
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 
 	"ride-sharing/api-gateway/websocket"
-	"ride-sharing/shared/events"
 	"ride-sharing/shared/contracts"
+	"ride-sharing/shared/events"
 	pb "ride-sharing/shared/proto/trip"
 )
 
 // GatewayEventSubscriber API网关事件订阅器
 type GatewayEventSubscriber struct {
 	subscriber events.Subscriber
+	requester  events.Requester
 	wsManager  *websocket.WebSocketManager
 }
 
-// NewGatewayEventSubscriber 创建网关事件订阅器
-func NewGatewayEventSubscriber(subscriber events.Subscriber, wsManager *websocket.WebSocketManager) *GatewayEventSubscriber {
+// NewGatewayEventSubscriber 创建网关事件订阅器，requester用于向trip-service查询行程所属的乘客
+func NewGatewayEventSubscriber(subscriber events.Subscriber, requester events.Requester, wsManager *websocket.WebSocketManager) *GatewayEventSubscriber {
 	return &GatewayEventSubscriber{
 		subscriber: subscriber,
+		requester:  requester,
 		wsManager:  wsManager,
 	}
 }
@@ -29,86 +30,101 @@ func NewGatewayEventSubscriber(subscriber events.Subscriber, wsManager *websocke
 // SubscribeToAllEvents 订阅所有事件
 func (s *GatewayEventSubscriber) SubscribeToAllEvents(ctx context.Context) error {
 	// 订阅行程创建事件
-	err := s.subscriber.Subscribe(
-		"notify_new_trip_queue",
-		contracts.TripEventCreated,
-		s.handleTripCreated,
+	err := events.SubscribeQueue(
+		s.subscriber,
+		contracts.NotifyNewTripQueue,
+		events.Typed(s.handleTripCreated),
 	)
 	if err != nil {
 		return fmt.Errorf("订阅行程创建事件失败: %w", err)
 	}
 
 	// 订阅司机分配事件
-	err = s.subscriber.Subscribe(
-		"notify_driver_assignment_queue",
-		contracts.TripEventDriverAssigned,
-		s.handleDriverAssigned,
+	err = events.SubscribeQueue(
+		s.subscriber,
+		contracts.NotifyDriverAssignmentQueue,
+		events.Typed(s.handleDriverAssigned),
 	)
 	if err != nil {
 		return fmt.Errorf("订阅司机分配事件失败: %w", err)
 	}
 
 	// 订阅未找到司机事件
-	err = s.subscriber.Subscribe(
-		"notify_no_drivers_found_queue",
-		contracts.TripEventNoDriversFound,
-		s.handleNoDriversFound,
+	err = events.SubscribeQueue(
+		s.subscriber,
+		contracts.NotifyNoDriversFoundQueue,
+		events.Typed(s.handleNoDriversFound),
 	)
 	if err != nil {
 		return fmt.Errorf("订阅未找到司机事件失败: %w", err)
 	}
 
 	// 订阅司机行程请求事件
-	err = s.subscriber.Subscribe(
-		"notify_drivers_queue",
-		contracts.DriverCmdTripRequest,
-		s.handleDriverTripRequest,
+	err = events.SubscribeQueue(
+		s.subscriber,
+		contracts.NotifyDriversQueue,
+		events.Typed(s.handleDriverTripRequest),
 	)
 	if err != nil {
 		return fmt.Errorf("订阅司机行程请求事件失败: %w", err)
 	}
 
 	// 订阅支付会话创建事件
-	err = s.subscriber.Subscribe(
-		"notify_payment_status_queue",
-		contracts.PaymentEventSessionCreated,
-		s.handlePaymentSessionCreated,
+	err = events.SubscribeQueue(
+		s.subscriber,
+		contracts.NotifyPaymentStatusQueue,
+		events.Typed(s.handlePaymentSessionCreated),
 	)
 	if err != nil {
 		return fmt.Errorf("订阅支付会话创建事件失败: %w", err)
 	}
 
 	// 订阅支付成功事件
-	err = s.subscriber.Subscribe(
-		"notify_payment_success_queue",
-		contracts.PaymentEventSuccess,
-		s.handlePaymentSuccess,
+	err = events.SubscribeQueue(
+		s.subscriber,
+		contracts.NotifyPaymentSuccessQueue,
+		events.Typed(s.handlePaymentSuccess),
 	)
 	if err != nil {
 		return fmt.Errorf("订阅支付成功事件失败: %w", err)
 	}
 
 	// 订阅支付失败事件
-	err = s.subscriber.Subscribe(
-		"notify_payment_failed_queue",
-		contracts.PaymentEventFailed,
-		s.handlePaymentFailed,
+	err = events.SubscribeQueue(
+		s.subscriber,
+		contracts.NotifyPaymentFailedQueue,
+		events.Typed(s.handlePaymentFailed),
 	)
 	if err != nil {
 		return fmt.Errorf("订阅支付失败事件失败: %w", err)
 	}
 
+	// 订阅行程取消事件
+	err = events.SubscribeQueue(
+		s.subscriber,
+		contracts.NotifyTripCancelledQueue,
+		events.Typed(s.handleTripCancelled),
+	)
+	if err != nil {
+		return fmt.Errorf("订阅行程取消事件失败: %w", err)
+	}
+
+	// 订阅行程完成事件
+	err = events.SubscribeQueue(
+		s.subscriber,
+		contracts.NotifyTripCompletedQueue,
+		events.Typed(s.handleTripCompleted),
+	)
+	if err != nil {
+		return fmt.Errorf("订阅行程完成事件失败: %w", err)
+	}
+
 	log.Println("成功订阅所有事件")
 	return nil
 }
 
 // handleTripCreated 处理行程创建事件
-func (s *GatewayEventSubscriber) handleTripCreated(data []byte) error {
-	var trip pb.Trip
-	if err := json.Unmarshal(data, &trip); err != nil {
-		return fmt.Errorf("解析行程创建事件失败: %w", err)
-	}
-
+func (s *GatewayEventSubscriber) handleTripCreated(ctx context.Context, env events.Envelope, trip *pb.Trip) error {
 	// 向乘客发送行程创建确认
 	message := contracts.WSMessage{
 		Type: contracts.TripEventCreated,
@@ -116,21 +132,16 @@ func (s *GatewayEventSubscriber) handleTripCreated(data []byte) error {
 	}
 
 	// 发送给特定乘客
-	if err := s.wsManager.SendToRider(trip.UserId, message); err != nil {
+	if err := s.wsManager.SendToRider(trip.UserID, message); err != nil {
 		log.Printf("向乘客发送行程创建事件失败: %v", err)
 	}
 
-	log.Printf("已向乘客发送行程创建事件: 乘客ID=%s, 行程ID=%s", trip.UserId, trip.Id)
+	log.Printf("已向乘客发送行程创建事件: 乘客ID=%s, 行程ID=%s", trip.UserID, trip.Id)
 	return nil
 }
 
 // handleDriverAssigned 处理司机分配事件
-func (s *GatewayEventSubscriber) handleDriverAssigned(data []byte) error {
-	var trip pb.Trip
-	if err := json.Unmarshal(data, &trip); err != nil {
-		return fmt.Errorf("解析司机分配事件失败: %w", err)
-	}
-
+func (s *GatewayEventSubscriber) handleDriverAssigned(ctx context.Context, env events.Envelope, trip *pb.Trip) error {
 	// 向乘客发送司机分配通知
 	message := contracts.WSMessage{
 		Type: contracts.TripEventDriverAssigned,
@@ -138,7 +149,7 @@ func (s *GatewayEventSubscriber) handleDriverAssigned(data []byte) error {
 	}
 
 	// 发送给特定乘客
-	if err := s.wsManager.SendToRider(trip.UserId, message); err != nil {
+	if err := s.wsManager.SendToRider(trip.UserID, message); err != nil {
 		log.Printf("向乘客发送司机分配事件失败: %v", err)
 	}
 
@@ -154,37 +165,32 @@ func (s *GatewayEventSubscriber) handleDriverAssigned(data []byte) error {
 		}
 	}
 
-	log.Printf("已发送司机分配事件: 乘客ID=%s, 司机ID=%s, 行程ID=%s", 
-		trip.UserId, trip.Driver.Id, trip.Id)
+	log.Printf("已发送司机分配事件: 乘客ID=%s, 司机ID=%s, 行程ID=%s",
+		trip.UserID, trip.Driver.Id, trip.Id)
 	return nil
 }
 
 // handleNoDriversFound 处理未找到司机事件
-func (s *GatewayEventSubscriber) handleNoDriversFound(data []byte) error {
-	var eventData map[string]string
-	if err := json.Unmarshal(data, &eventData); err != nil {
-		return fmt.Errorf("解析未找到司机事件失败: %w", err)
-	}
+func (s *GatewayEventSubscriber) handleNoDriversFound(ctx context.Context, env events.Envelope, eventData contracts.TripEventData) error {
+	tripID := eventData.TripID
 
-	tripID, ok := eventData["tripID"]
-	if !ok {
-		return fmt.Errorf("事件数据中缺少tripID")
-	}
-
-	// TODO: 需要获取乘客ID，这里简化处理
-	// 在实际实现中，可能需要从数据库查询行程信息获取乘客ID
-	
 	message := contracts.WSMessage{
 		Type: contracts.TripEventNoDriversFound,
 		Data: map[string]string{
-			"tripID": tripID,
+			"tripID":  tripID,
 			"message": "未找到可用司机，请稍后再试",
 		},
 	}
 
-	// 向所有乘客广播（简化处理）
-	if err := s.wsManager.BroadcastToRiders(message); err != nil {
-		log.Printf("广播未找到司机事件失败: %v", err)
+	// 向trip-service查询行程所属的乘客，查询失败时退回向所有乘客广播
+	trip, err := events.Call[contracts.TripSummary](ctx, s.requester, contracts.TripQueryGet, contracts.TripQuery{TripID: tripID})
+	if err != nil {
+		log.Printf("查询行程乘客失败，改为广播: 行程ID=%s, %v", tripID, err)
+		if err := s.wsManager.BroadcastToRiders(message); err != nil {
+			log.Printf("广播未找到司机事件失败: %v", err)
+		}
+	} else if err := s.wsManager.SendToRider(trip.UserID, message); err != nil {
+		log.Printf("向乘客发送未找到司机事件失败: %v", err)
 	}
 
 	log.Printf("已发送未找到司机事件: 行程ID=%s", tripID)
@@ -192,19 +198,11 @@ func (s *GatewayEventSubscriber) handleNoDriversFound(data []byte) error {
 }
 
 // handlePaymentSessionCreated 处理支付会话创建事件
-func (s *GatewayEventSubscriber) handlePaymentSessionCreated(data []byte) error {
-	var paymentData map[string]interface{}
-	if err := json.Unmarshal(data, &paymentData); err != nil {
-		return fmt.Errorf("解析支付会话创建事件失败: %w", err)
-	}
-
-	tripID, ok := paymentData["tripID"].(string)
-	if !ok {
-		return fmt.Errorf("事件数据中缺少tripID")
-	}
+func (s *GatewayEventSubscriber) handlePaymentSessionCreated(ctx context.Context, env events.Envelope, paymentData contracts.PaymentEventData) error {
+	tripID := paymentData.TripID
 
 	// TODO: 需要获取乘客ID，这里简化处理
-	
+
 	message := contracts.WSMessage{
 		Type: contracts.PaymentEventSessionCreated,
 		Data: paymentData,
@@ -220,16 +218,8 @@ func (s *GatewayEventSubscriber) handlePaymentSessionCreated(data []byte) error
 }
 
 // handleDriverTripRequest 处理司机行程请求事件
-func (s *GatewayEventSubscriber) handleDriverTripRequest(data []byte) error {
-	var requestData map[string]interface{}
-	if err := json.Unmarshal(data, &requestData); err != nil {
-		return fmt.Errorf("解析司机行程请求事件失败: %w", err)
-	}
-
-	driverID, ok := requestData["driverID"].(string)
-	if !ok {
-		return fmt.Errorf("事件数据中缺少driverID")
-	}
+func (s *GatewayEventSubscriber) handleDriverTripRequest(ctx context.Context, env events.Envelope, requestData contracts.DriverTripRequest) error {
+	driverID := requestData.DriverID
 
 	// 向特定司机发送行程请求
 	message := contracts.WSMessage{
@@ -241,22 +231,14 @@ func (s *GatewayEventSubscriber) handleDriverTripRequest(data []byte) error {
 		log.Printf("向司机发送行程请求失败: %v", err)
 	}
 
-	log.Printf("已向司机发送行程请求: 司机ID=%s, 行程ID=%v",
-		driverID, requestData["tripID"])
+	log.Printf("已向司机发送行程请求: 司机ID=%s, 行程ID=%s",
+		driverID, requestData.TripID)
 	return nil
 }
 
 // handlePaymentSuccess 处理支付成功事件
-func (s *GatewayEventSubscriber) handlePaymentSuccess(data []byte) error {
-	var paymentData map[string]interface{}
-	if err := json.Unmarshal(data, &paymentData); err != nil {
-		return fmt.Errorf("解析支付成功事件失败: %w", err)
-	}
-
-	tripID, ok := paymentData["tripID"].(string)
-	if !ok {
-		return fmt.Errorf("事件数据中缺少tripID")
-	}
+func (s *GatewayEventSubscriber) handlePaymentSuccess(ctx context.Context, env events.Envelope, paymentData contracts.PaymentEventData) error {
+	tripID := paymentData.TripID
 
 	message := contracts.WSMessage{
 		Type: contracts.PaymentEventSuccess,
@@ -273,16 +255,8 @@ func (s *GatewayEventSubscriber) handlePaymentSuccess(data []byte) error {
 }
 
 // handlePaymentFailed 处理支付失败事件
-func (s *GatewayEventSubscriber) handlePaymentFailed(data []byte) error {
-	var paymentData map[string]interface{}
-	if err := json.Unmarshal(data, &paymentData); err != nil {
-		return fmt.Errorf("解析支付失败事件失败: %w", err)
-	}
-
-	tripID, ok := paymentData["tripID"].(string)
-	if !ok {
-		return fmt.Errorf("事件数据中缺少tripID")
-	}
+func (s *GatewayEventSubscriber) handlePaymentFailed(ctx context.Context, env events.Envelope, paymentData contracts.PaymentEventData) error {
+	tripID := paymentData.TripID
 
 	message := contracts.WSMessage{
 		Type: contracts.PaymentEventFailed,
@@ -298,7 +272,47 @@ func (s *GatewayEventSubscriber) handlePaymentFailed(data []byte) error {
 	return nil
 }
 
+// handleTripCancelled 处理行程取消事件，通知乘客和已分配的司机
+func (s *GatewayEventSubscriber) handleTripCancelled(ctx context.Context, env events.Envelope, cancelled contracts.TripCancelled) error {
+	message := contracts.WSMessage{
+		Type: contracts.TripEventCancelled,
+		Data: cancelled,
+	}
+
+	if err := s.wsManager.SendToRider(cancelled.UserID, message); err != nil {
+		log.Printf("向乘客发送行程取消事件失败: %v", err)
+	}
+	if cancelled.DriverID != "" {
+		if err := s.wsManager.SendToDriver(cancelled.DriverID, message); err != nil {
+			log.Printf("向司机发送行程取消事件失败: %v", err)
+		}
+	}
+
+	log.Printf("已发送行程取消事件: 行程ID=%s, 取消方=%s", cancelled.TripID, cancelled.CancelledBy)
+	return nil
+}
+
+// handleTripCompleted 处理行程完成事件，通知乘客和司机
+func (s *GatewayEventSubscriber) handleTripCompleted(ctx context.Context, env events.Envelope, trip *pb.Trip) error {
+	message := contracts.WSMessage{
+		Type: contracts.TripEventCompleted,
+		Data: trip,
+	}
+
+	if err := s.wsManager.SendToRider(trip.UserID, message); err != nil {
+		log.Printf("向乘客发送行程完成事件失败: %v", err)
+	}
+	if trip.Driver != nil {
+		if err := s.wsManager.SendToDriver(trip.Driver.Id, message); err != nil {
+			log.Printf("向司机发送行程完成事件失败: %v", err)
+		}
+	}
+
+	log.Printf("已发送行程完成事件: 行程ID=%s", trip.Id)
+	return nil
+}
+
 // Close 关闭订阅器
 func (s *GatewayEventSubscriber) Close() error {
 	return s.subscriber.Close()
-}
\ No newline at end of file
+}
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"ride-sharing/shared/env"
+	"ride-sharing/shared/metrics"
 )
 
 var (
@@ -27,26 +28,41 @@ func main() {
 	defer wsManager.Close()
 
 	// 初始化事件订阅器
-	eventConfig := sharedEvents.NewTripExchangeConfig()
-	subscriber, err := sharedEvents.NewRabbitMQSubscriber(eventConfig.URL, eventConfig.Exchange)
+	eventConfig := sharedEvents.NewTripExchangeConfig().ForService("api-gateway")
+	if err := sharedEvents.ValidateTopology(eventConfig); err != nil {
+		log.Fatalf("启动校验失败: %v", err)
+	}
+	if err := sharedEvents.ConfigureSecurityFromEnv(); err != nil {
+		log.Fatalf("加载事件签名与加密配置失败: %v", err)
+	}
+	subscriber, err := sharedEvents.NewSubscriber(eventConfig)
 	if err != nil {
 		log.Fatalf("创建事件订阅器失败: %v", err)
 	}
 	defer subscriber.Close()
+	sharedEvents.LogConnectionState("事件订阅器", subscriber)
 
 	// 初始化事件发布器
-	publisher, err := sharedEvents.NewRabbitMQPublisher(eventConfig.URL, eventConfig.Exchange)
+	publisher, err := sharedEvents.NewPublisher(eventConfig)
 	if err != nil {
 		log.Fatalf("创建事件发布器失败: %v", err)
 	}
 	defer publisher.Close()
+	sharedEvents.LogConnectionState("事件发布器", publisher)
+
+	// 初始化请求器，用于向其他服务查询数据
+	requester, err := sharedEvents.NewRequester(eventConfig, sharedEvents.DefaultRequesterOptions())
+	if err != nil {
+		log.Fatalf("创建请求器失败: %v", err)
+	}
+	defer requester.Close()
 
 	// 创建事件订阅器并订阅事件
-	gatewayEventSubscriber := events.NewGatewayEventSubscriber(subscriber, wsManager)
+	gatewayEventSubscriber := events.NewGatewayEventSubscriber(subscriber, requester, wsManager)
 	if err := gatewayEventSubscriber.SubscribeToAllEvents(context.Background()); err != nil {
 		log.Fatalf("订阅事件失败: %v", err)
 	}
-	
+
 	// 创建API网关事件发布器
 	apiEventPublisher := events.NewAPIEventPublisher(publisher)
 
@@ -60,6 +76,8 @@ func main() {
 	mux.HandleFunc("/ws/drivers", func(w http.ResponseWriter, r *http.Request) {
 		websocket.HandleDriversWebSocket(wsManager, apiEventPublisher, w, r)
 	})
+	mux.Handle("GET /metrics", metrics.Handler())
+	mux.Handle("GET /healthz", metrics.HealthHandler())
 
 	server := &http.Server{
 		Addr:    httpAddr,
@@ -89,5 +107,10 @@ func main() {
 			log.Printf("无法优雅关闭服务器: %v", err)
 			server.Close()
 		}
+
+		// 等待在途事件处理完成后再关闭订阅器
+		if err := sharedEvents.Shutdown(ctx, subscriber); err != nil {
+			log.Printf("关闭事件订阅器失败: %v", err)
+		}
 	}
 }
@@ -31,8 +31,8 @@ type startTripRequest struct {
 	UserID     string `json:"userID"`
 }
 
-func (c *startTripRequest) toProto() *pb.CreteTripRequest {
-	return &pb.CreteTripRequest{
+func (c *startTripRequest) toProto() *pb.CreateTripRequest {
+	return &pb.CreateTripRequest{
 		RideFareID: c.RideFareID,
 		UserID:     c.UserID,
 	}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"time"
 	"github.com/gorilla/websocket"
 	"log"
 	"net/http"
@@ -13,6 +12,7 @@ import (
 	"ride-sharing/api-gateway/websocket"
 	"ride-sharing/shared/contracts"
 	"ride-sharing/shared/proto/driver"
+	"time"
 )
 
 var (
@@ -49,7 +49,7 @@ func HandleRidersWebSocket(wsManager *websocket.WebSocketManager, eventPublisher
 			log.Printf("读取乘客消息失败: %v", err)
 			break
 		}
-		
+
 		// 处理来自乘客的消息
 		if err := handleRiderMessage(userID, message); err != nil {
 			log.Printf("处理乘客消息失败: %v", err)
@@ -70,7 +70,7 @@ func HandleDriversWebSocket(wsManager *websocket.WebSocketManager, eventPublishe
 		conn.Close()
 		return
 	}
-	
+
 	packageSlug := r.URL.Query().Get("packageSlug")
 	if packageSlug == "" {
 		log.Println("未提供套餐类型")
@@ -118,7 +118,7 @@ func HandleDriversWebSocket(wsManager *websocket.WebSocketManager, eventPublishe
 			log.Printf("读取司机消息失败: %v", err)
 			break
 		}
-		
+
 		// 处理来自司机的消息
 		if err := handleDriverMessage(userID, message, eventPublisher); err != nil {
 			log.Printf("处理司机消息失败: %v", err)
@@ -143,12 +143,12 @@ func handleRiderMessage(userID string, message []byte) error {
 		log.Printf("解析乘客消息失败: 用户ID=%s, 错误=%v", userID, err)
 		return err
 	}
-	
+
 	log.Printf("收到乘客消息: 用户ID=%s, 类型=%s", userID, wsMessage.Type)
-	
+
 	// TODO: 根据消息类型处理不同的乘客消息
 	// 例如：取消行程、更新位置等
-	
+
 	return nil
 }
 
@@ -159,9 +159,9 @@ func handleDriverMessage(driverID string, message []byte, eventPublisher *events
 		log.Printf("解析司机消息失败: 司机ID=%s, 错误=%v", driverID, err)
 		return err
 	}
-	
+
 	log.Printf("收到司机消息: 司机ID=%s, 类型=%s", driverID, wsMessage.Type)
-	
+
 	switch wsMessage.Type {
 	case contracts.DriverCmdTripAccept, contracts.DriverCmdTripDecline:
 		return handleDriverTripResponse(driverID, wsMessage, eventPublisher)
@@ -170,7 +170,7 @@ func handleDriverMessage(driverID string, message []byte, eventPublisher *events
 	default:
 		log.Printf("未知的司机消息类型: %s", wsMessage.Type)
 	}
-	
+
 	return nil
 }
 
@@ -182,43 +182,43 @@ func handleDriverTripResponse(driverID string, wsMessage contracts.WSMessage, ev
 		log.Printf("解析司机响应数据失败: 司机ID=%s, 错误=%v", driverID, err)
 		return err
 	}
-	
+
 	tripID, ok := responseData["tripID"].(string)
 	if !ok {
 		log.Printf("司机响应中缺少行程ID: 司机ID=%s", driverID)
 		return fmt.Errorf("缺少行程ID")
 	}
-	
+
 	riderID, ok := responseData["riderID"].(string)
 	if !ok {
 		log.Printf("司机响应中缺少乘客ID: 司机ID=%s", driverID)
 		return fmt.Errorf("缺少乘客ID")
 	}
-	
+
 	accept := wsMessage.Type == contracts.DriverCmdTripAccept
-	
+
 	log.Printf("处理司机行程响应: 司机ID=%s, 行程ID=%s, 接受=%v", driverID, tripID, accept)
-	
+
 	// 发布司机响应命令到RabbitMQ
 	if eventPublisher != nil {
-		response := events.DriverTripResponse{
+		response := contracts.DriverTripResponse{
 			TripID:   tripID,
 			RiderID:  riderID,
 			DriverID: driverID,
 			Accept:   accept,
 		}
-		
+
 		if err := eventPublisher.PublishDriverTripResponse(context.Background(), response); err != nil {
 			log.Printf("发布司机响应命令失败: %v", err)
 			return err
 		}
-		
+
 		log.Printf("成功发布司机响应命令: 司机ID=%s, 行程ID=%s", driverID, tripID)
 	} else {
 		log.Printf("事件发布器未初始化，无法发布司机响应")
 		return fmt.Errorf("事件发布器未初始化")
 	}
-	
+
 	return nil
 }
 
@@ -230,40 +230,38 @@ func handleDriverLocationUpdate(driverID string, wsMessage contracts.WSMessage,
 		log.Printf("解析司机位置数据失败: 司机ID=%s, 错误=%v", driverID, err)
 		return err
 	}
-	
+
 	latitude, ok := locationData["latitude"].(float64)
 	if !ok {
 		log.Printf("司机位置更新中缺少纬度信息: 司机ID=%s", driverID)
 		return fmt.Errorf("缺少纬度信息")
 	}
-	
+
 	longitude, ok := locationData["longitude"].(float64)
 	if !ok {
 		log.Printf("司机位置更新中缺少经度信息: 司机ID=%s", driverID)
 		return fmt.Errorf("缺少经度信息")
 	}
-	
+
 	log.Printf("处理司机位置更新: 司机ID=%s, 位置=(%.6f, %.6f)", driverID, latitude, longitude)
-	
+
 	// 发布司机位置更新事件
 	if eventPublisher != nil {
-		locationUpdate := events.DriverLocationUpdate{
+		locationUpdate := contracts.DriverLocationUpdate{
 			DriverID:  driverID,
 			Latitude:  latitude,
 			Longitude: longitude,
 			Timestamp: time.Now().Unix(),
 		}
-		
+
 		if err := eventPublisher.PublishDriverLocationUpdate(context.Background(), locationUpdate); err != nil {
 			log.Printf("发布司机位置更新命令失败: %v", err)
 			return err
 		}
-		
-		log.Printf("成功发布司机位置更新命令: 司机ID=%s", driverID)
 	} else {
 		log.Printf("事件发布器未初始化，无法发布司机位置更新")
 		return fmt.Errorf("事件发布器未初始化")
 	}
-	
+
 	return nil
 }
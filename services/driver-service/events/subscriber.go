@@ -2,15 +2,15 @@ package events
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 
 	"github.com/mmcloughlin/geohash"
-	"ride-sharing/shared/events"
 	"ride-sharing/shared/contracts"
-	pb "ride-sharing/shared/proto/trip"
+	"ride-sharing/shared/events"
 	driverPb "ride-sharing/shared/proto/driver"
+	pb "ride-sharing/shared/proto/trip"
+	"ride-sharing/shared/types"
 )
 
 // DriverEventSubscriber Driver服务事件订阅器
@@ -32,36 +32,58 @@ func NewDriverEventSubscriber(subscriber events.Subscriber, service *Service, pu
 // SubscribeToTripEvents 订阅行程事件
 func (s *DriverEventSubscriber) SubscribeToTripEvents(ctx context.Context) error {
 	// 订阅行程创建事件
-	err := s.subscriber.Subscribe(
-		"find_available_drivers_queue",
-		contracts.TripEventCreated,
-		s.handleTripCreated,
+	err := events.SubscribeQueue(
+		s.subscriber,
+		contracts.FindAvailableDriversQueue,
+		events.Typed(s.handleTripCreated),
 	)
 	if err != nil {
 		return fmt.Errorf("订阅行程创建事件失败: %w", err)
 	}
 
 	// 订阅司机位置更新命令
-	err = s.subscriber.Subscribe(
-		"driver_location_update_queue",
-		contracts.DriverCmdLocation,
-		s.handleDriverLocationUpdate,
+	// 位置更新量大，使用独立通道和多个工作协程并行处理，同一司机的更新按到达顺序处理
+	err = events.SubscribeQueueWithOptions(
+		s.subscriber,
+		contracts.DriverLocationUpdateQueue,
+		events.Typed(s.handleDriverLocationUpdate),
+		func(opts *events.SubscribeOptions) {
+			opts.Prefetch = 64
+			opts.Workers = 8
+			opts.OrderingKey = events.OrderByField("driverID")
+			opts.DedicatedChannel = true
+		},
 	)
 	if err != nil {
 		return fmt.Errorf("订阅司机位置更新事件失败: %w", err)
 	}
 
+	// 订阅司机分配事件，已分配的司机不再收到新的行程请求
+	err = events.SubscribeQueue(
+		s.subscriber,
+		contracts.DriverAssignmentQueue,
+		events.Typed(s.handleDriverAssigned),
+	)
+	if err != nil {
+		return fmt.Errorf("订阅司机分配事件失败: %w", err)
+	}
+
+	// 订阅释放司机命令，由行程Saga在补偿时发出
+	err = events.SubscribeQueue(
+		s.subscriber,
+		contracts.DriverTripReleaseQueue,
+		events.Typed(s.handleDriverRelease),
+	)
+	if err != nil {
+		return fmt.Errorf("订阅释放司机命令失败: %w", err)
+	}
+
 	log.Println("成功订阅行程事件和司机位置更新事件")
 	return nil
 }
 
 // handleTripCreated 处理行程创建事件
-func (s *DriverEventSubscriber) handleTripCreated(data []byte) error {
-	var trip pb.Trip
-	if err := json.Unmarshal(data, &trip); err != nil {
-		return fmt.Errorf("解析行程创建事件失败: %w", err)
-	}
-
+func (s *DriverEventSubscriber) handleTripCreated(ctx context.Context, env events.Envelope, trip *pb.Trip) error {
 	// 获取行程起点坐标
 	if trip.Route == nil || len(trip.Route.Geometry) == 0 || len(trip.Route.Geometry[0].Coordinates) == 0 {
 		return fmt.Errorf("行程路线信息不完整")
@@ -72,7 +94,7 @@ func (s *DriverEventSubscriber) handleTripCreated(data []byte) error {
 
 	// 查找附近的司机
 	nearbyDrivers := s.service.FindNearbyDrivers(pickupGeohash, trip.SelectedFare.PackageSlug)
-	
+
 	if len(nearbyDrivers) == 0 {
 		log.Printf("未找到可用司机，行程ID: %s", trip.Id)
 		return nil
@@ -82,17 +104,17 @@ func (s *DriverEventSubscriber) handleTripCreated(data []byte) error {
 
 	// 向找到的司机发送行程请求
 	for _, driver := range nearbyDrivers {
-		tripRequest := DriverTripRequest{
-			TripID:     trip.Id,
-			DriverID:   driver.Driver.Id,
-			RiderID:    trip.UserId,
-			Pickup:     &Coordinate{Latitude: startLocation.Latitude, Longitude: startLocation.Longitude},
-			Fare:       trip.SelectedFare.TotalPriceInCents,
-			Package:    trip.SelectedFare.PackageSlug,
+		tripRequest := contracts.DriverTripRequest{
+			TripID:   trip.Id,
+			DriverID: driver.Driver.Id,
+			RiderID:  trip.UserID,
+			Pickup:   &types.Coordinate{Latitude: startLocation.Latitude, Longitude: startLocation.Longitude},
+			Fare:     trip.SelectedFare.TotalPriceInCents,
+			Package:  trip.SelectedFare.PackageSlug,
 		}
 
 		// 发布司机行程请求命令
-		if err := s.publishDriverTripRequest(tripRequest); err != nil {
+		if err := s.publishDriverTripRequest(ctx, tripRequest); err != nil {
 			log.Printf("发布司机行程请求失败: %v", err)
 			continue
 		}
@@ -103,56 +125,61 @@ func (s *DriverEventSubscriber) handleTripCreated(data []byte) error {
 	return nil
 }
 
-// handleDriverLocationUpdate 处理司机位置更新命令
-func (s *DriverEventSubscriber) handleDriverLocationUpdate(data []byte) error {
-	var locationUpdate DriverLocationUpdate
-	if err := json.Unmarshal(data, &locationUpdate); err != nil {
-		return fmt.Errorf("解析司机位置更新命令失败: %w", err)
+// handleDriverAssigned 处理司机分配事件
+func (s *DriverEventSubscriber) handleDriverAssigned(ctx context.Context, env events.Envelope, trip *pb.Trip) error {
+	if trip.Driver == nil || trip.Driver.Id == "" {
+		log.Printf("司机分配事件缺少司机信息，行程ID: %s", trip.Id)
+		return nil
 	}
 
+	if !s.service.AssignTrip(trip.Driver.Id, trip.Id) {
+		log.Printf("司机已下线，忽略分配: 司机ID=%s, 行程ID=%s", trip.Driver.Id, trip.Id)
+		return nil
+	}
+
+	log.Printf("司机已分配到行程: 司机ID=%s, 行程ID=%s", trip.Driver.Id, trip.Id)
+	return nil
+}
+
+// handleDriverRelease 处理释放司机命令，司机已被分配到其他行程时不做处理
+func (s *DriverEventSubscriber) handleDriverRelease(ctx context.Context, env events.Envelope, release contracts.DriverTripRelease) error {
+	if !s.service.ReleaseDriver(release.DriverID, release.TripID) {
+		log.Printf("司机未分配到该行程，忽略释放: 司机ID=%s, 行程ID=%s", release.DriverID, release.TripID)
+		return nil
+	}
+
+	log.Printf("已释放司机: 司机ID=%s, 行程ID=%s, 原因=%s", release.DriverID, release.TripID, release.Reason)
+	return nil
+}
+
+// handleDriverLocationUpdate 处理司机位置更新命令
+func (s *DriverEventSubscriber) handleDriverLocationUpdate(ctx context.Context, env events.Envelope, locationUpdate contracts.DriverLocationUpdate) error {
 	// 转换为服务层所需的位置格式
 	location := &driverPb.Location{
-		Latitude:  locationUpdate.Location.Latitude,
-		Longitude: locationUpdate.Location.Longitude,
+		Latitude:  locationUpdate.Latitude,
+		Longitude: locationUpdate.Longitude,
 	}
 
 	// 更新司机位置
 	s.service.UpdateDriverLocation(locationUpdate.DriverID, location)
-	
+
 	log.Printf("已更新司机位置: 司机ID=%s, 位置=(%.6f, %.6f)",
-		locationUpdate.DriverID, locationUpdate.Location.Latitude, locationUpdate.Location.Longitude)
-	
+		locationUpdate.DriverID, locationUpdate.Latitude, locationUpdate.Longitude)
+
 	return nil
 }
 
 // publishDriverTripRequest 发布司机行程请求命令
-func (s *DriverEventSubscriber) publishDriverTripRequest(request DriverTripRequest) error {
+func (s *DriverEventSubscriber) publishDriverTripRequest(ctx context.Context, request contracts.DriverTripRequest) error {
 	if s.publisher == nil {
 		log.Printf("事件发布器未初始化，无法发布司机行程请求命令: %+v", request)
 		return nil
 	}
-	
-	return s.publisher.PublishDriverTripRequest(context.Background(), request)
-}
 
-// DriverTripRequest 司机行程请求
-type DriverTripRequest struct {
-	TripID   string      `json:"tripID"`
-	DriverID string      `json:"driverID"`
-	RiderID  string      `json:"riderID"`
-	Pickup   *Coordinate `json:"pickup"`
-	Fare     float64     `json:"fare"`
-	Package  string      `json:"package"`
+	return s.publisher.PublishDriverTripRequest(ctx, request)
 }
 
-// Coordinate 坐标
-type Coordinate struct {
-	Latitude  float64 `json:"latitude"`
-	Longitude float64 `json:"longitude"`
-}
-
-
 // Close 关闭订阅器
 func (s *DriverEventSubscriber) Close() error {
 	return s.subscriber.Close()
-}
\ No newline at end of file
+}
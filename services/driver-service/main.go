@@ -8,33 +8,53 @@ import (
 	"os"
 	"os/signal"
 	"ride-sharing/driver-service/events"
+	"ride-sharing/shared/env"
 	sharedEvents "ride-sharing/shared/events"
+	"ride-sharing/shared/metrics"
 	"syscall"
 )
 
 var GrpcAddr = ":9092"
 
+var MetricsAddr = env.GetString("METRICS_ADDR", ":9192")
+
 func main() {
 	// 创建服务
 	svc := NewService()
 
 	// 初始化事件发布器
-	eventConfig := sharedEvents.NewTripExchangeConfig()
-	publisher, err := sharedEvents.NewRabbitMQPublisher(eventConfig.URL, eventConfig.Exchange)
+	eventConfig := sharedEvents.NewTripExchangeConfig().ForService("driver-service")
+	if err := sharedEvents.ValidateTopology(eventConfig); err != nil {
+		log.Fatalf("启动校验失败: %v", err)
+	}
+	if err := sharedEvents.ConfigureSecurityFromEnv(); err != nil {
+		log.Fatalf("加载事件签名与加密配置失败: %v", err)
+	}
+
+	// 导出事件指标
+	go func() {
+		log.Printf("指标服务正在监听端口 %s", MetricsAddr)
+		if err := metrics.ListenAndServe(MetricsAddr); err != nil {
+			log.Printf("指标服务启动失败: %v", err)
+		}
+	}()
+	publisher, err := sharedEvents.NewPublisher(eventConfig)
 	if err != nil {
 		log.Fatalf("创建事件发布器失败: %v", err)
 	}
 	defer publisher.Close()
+	sharedEvents.LogConnectionState("事件发布器", publisher)
 
 	// 创建Driver事件发布器
 	driverEventPublisher := events.NewDriverEventPublisher(publisher)
 
 	// 初始化事件订阅器
-	subscriber, err := sharedEvents.NewRabbitMQSubscriber(eventConfig.URL, eventConfig.Exchange)
+	subscriber, err := sharedEvents.NewSubscriber(eventConfig)
 	if err != nil {
 		log.Fatalf("创建事件订阅器失败: %v", err)
 	}
 	defer subscriber.Close()
+	sharedEvents.LogConnectionState("事件订阅器", subscriber)
 
 	// 创建事件订阅器并订阅事件
 	eventSubscriber := events.NewDriverEventSubscriber(subscriber, svc, driverEventPublisher)
@@ -75,4 +95,11 @@ func main() {
 
 	log.Println("正在关闭服务器...")
 	grpcServer.GracefulStop()
+
+	// 等待在途事件处理完成后再关闭订阅器
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), sharedEvents.DefaultShutdownTimeout)
+	defer shutdownCancel()
+	if err := sharedEvents.Shutdown(shutdownCtx, subscriber); err != nil {
+		log.Printf("关闭事件订阅器失败: %v", err)
+	}
 }
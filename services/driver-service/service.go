@@ -2,10 +2,10 @@ package main
 
 import (
 	"math/rand"
-	"strings"
 	math "math/rand/v2"
 	pb "ride-sharing/shared/proto/driver"
 	"ride-sharing/shared/util"
+	"strings"
 	"sync"
 
 	"github.com/mmcloughlin/geohash"
@@ -13,6 +13,8 @@ import (
 
 type driverInMap struct {
 	Driver *pb.Driver
+	// TripID 司机当前被分配的行程，为空时可以接受新的行程
+	TripID string
 	// Index int
 	// TODO: route
 }
@@ -77,40 +79,73 @@ func (s *Service) FindNearbyDrivers(pickupGeohash, packageSlug string) []*driver
 	defer s.mu.RUnlock()
 
 	var nearbyDrivers []*driverInMap
-	
+
 	// 获取geohash的前缀（精度为7，大约1.5km范围）
 	prefix := pickupGeohash[:7]
-	
+
 	for _, driver := range s.drivers {
 		// 检查司机类型是否匹配
 		if driver.Driver.PackageSlug != packageSlug {
 			continue
 		}
-		
+
+		// 跳过已分配行程的司机
+		if driver.TripID != "" {
+			continue
+		}
+
 		// 检查司机是否在附近（使用geohash前缀匹配）
 		if strings.HasPrefix(driver.Driver.Geohash, prefix) {
 			nearbyDrivers = append(nearbyDrivers, driver)
 		}
 	}
-	
+
 	// 随机打乱司机顺序，避免总是选择相同的司机
 	rand.Shuffle(len(nearbyDrivers), func(i, j int) {
 		nearbyDrivers[i], nearbyDrivers[j] = nearbyDrivers[j], nearbyDrivers[i]
 	})
-	
+
 	// 最多返回3个司机
 	if len(nearbyDrivers) > 3 {
 		nearbyDrivers = nearbyDrivers[:3]
 	}
-	
+
 	return nearbyDrivers
 }
 
+// AssignTrip 标记司机已被分配到行程
+func (s *Service) AssignTrip(driverID, tripID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, driver := range s.drivers {
+		if driver.Driver.Id == driverID {
+			driver.TripID = tripID
+			return true
+		}
+	}
+	return false
+}
+
+// ReleaseDriver 释放司机，只有司机仍被分配到该行程时才释放
+func (s *Service) ReleaseDriver(driverID, tripID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, driver := range s.drivers {
+		if driver.Driver.Id == driverID && driver.TripID == tripID {
+			driver.TripID = ""
+			return true
+		}
+	}
+	return false
+}
+
 // UpdateDriverLocation 更新司机位置
 func (s *Service) UpdateDriverLocation(driverID string, location *pb.Location) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	
+
 	for _, driver := range s.drivers {
 		if driver.Driver.Id == driverID {
 			// 更新位置
@@ -13,51 +13,76 @@ import (
 	"ride-sharing/payment-service/internal/infrastructure/repository"
 	"ride-sharing/payment-service/internal/service"
 	sharedEvents "ride-sharing/shared/events"
+	"ride-sharing/shared/metrics"
 	"syscall"
 )
 
 var (
-	grpcAddr = flag.String("grpc-addr", ":9094", "gRPC服务器地址")
+	grpcAddr    = flag.String("grpc-addr", ":9094", "gRPC服务器地址")
+	metricsAddr = flag.String("metrics-addr", ":9194", "指标HTTP服务地址")
 )
 
 func main() {
 	flag.Parse()
-	
+
 	log.Println("启动支付服务")
 
 	// 初始化事件发布器
-	eventConfig := sharedEvents.NewPaymentExchangeConfig()
-	publisher, err := sharedEvents.NewRabbitMQPublisher(eventConfig.URL, eventConfig.Exchange)
+	eventConfig := sharedEvents.NewPaymentExchangeConfig().ForService("payment-service")
+	if err := sharedEvents.ValidateTopology(eventConfig); err != nil {
+		log.Fatalf("启动校验失败: %v", err)
+	}
+	if err := sharedEvents.ConfigureSecurityFromEnv(); err != nil {
+		log.Fatalf("加载事件签名与加密配置失败: %v", err)
+	}
+
+	// 导出事件指标
+	go func() {
+		log.Printf("指标服务正在监听端口 %s", *metricsAddr)
+		if err := metrics.ListenAndServe(*metricsAddr); err != nil {
+			log.Printf("指标服务启动失败: %v", err)
+		}
+	}()
+	publisher, err := sharedEvents.NewPublisher(eventConfig)
 	if err != nil {
 		log.Fatalf("创建事件发布器失败: %v", err)
 	}
 	defer publisher.Close()
-
-	// 创建支付事件发布器
-	paymentEventPublisher := events.NewPaymentEventPublisher(publisher)
+	sharedEvents.LogConnectionState("事件发布器", publisher)
 
 	// 初始化事件订阅器
-	subscriber, err := sharedEvents.NewRabbitMQSubscriber(eventConfig.URL, "trip") // 订阅trip交换器
+	subscriber, err := sharedEvents.NewSubscriber(sharedEvents.NewTripExchangeConfig()) // 订阅trip交换器
 	if err != nil {
 		log.Fatalf("创建事件订阅器失败: %v", err)
 	}
 	defer subscriber.Close()
+	sharedEvents.LogConnectionState("事件订阅器", subscriber)
 
 	// 初始化存储库
 	repo := repository.NewInmemRepository()
 
 	// 创建支付服务
-	paymentService := service.NewPaymentService(repo, paymentEventPublisher)
+	paymentService := service.NewPaymentService(repo)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 启动发件箱中继，将支付事件投递到消息代理
+	outboxRelay := sharedEvents.NewOutboxRelay(repo, publisher, sharedEvents.DefaultOutboxRelayConfig())
+	go outboxRelay.Run(ctx)
+
+	// 幂等消费的去重存储
+	dedup, err := sharedEvents.NewDedupStore(eventConfig)
+	if err != nil {
+		log.Fatalf("创建去重存储失败: %v", err)
+	}
 
 	// 创建事件订阅器并订阅事件
-	eventSubscriber := events.NewPaymentEventSubscriber(subscriber, paymentService)
+	eventSubscriber := events.NewPaymentEventSubscriber(subscriber, paymentService, dedup)
 	if err := eventSubscriber.SubscribeToTripEvents(context.Background()); err != nil {
 		log.Fatalf("订阅行程事件失败: %v", err)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
 	go func() {
 		sigCh := make(chan os.Signal, 1)
 		signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
@@ -88,4 +113,14 @@ func main() {
 
 	log.Println("正在关闭服务器...")
 	grpcServer.GracefulStop()
-}
\ No newline at end of file
+
+	// 等待在途事件处理完成后再关闭订阅器，处理过程中写入发件箱的事件在关闭发布器前投递
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), sharedEvents.DefaultShutdownTimeout)
+	defer shutdownCancel()
+	if err := sharedEvents.Shutdown(shutdownCtx, subscriber); err != nil {
+		log.Printf("关闭事件订阅器失败: %v", err)
+	}
+	if _, err := outboxRelay.Flush(shutdownCtx); err != nil {
+		log.Printf("投递剩余发件箱消息失败: %v", err)
+	}
+}
@@ -3,28 +3,33 @@ package domain
 import (
 	"context"
 	"time"
+
+	"ride-sharing/shared/contracts"
+	"ride-sharing/shared/events"
 )
 
 // PaymentModel 支付模型
 type PaymentModel struct {
-	ID          string    `json:"id"`
-	TripID      string    `json:"tripID"`
-	UserID      string    `json:"userID"`
-	Amount      float64   `json:"amount"`
-	Currency    string    `json:"currency"`
-	Status      string    `json:"status"` // pending, succeeded, failed, cancelled
-	SessionID   string    `json:"sessionID"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ID        string    `json:"id"`
+	TripID    string    `json:"tripID"`
+	UserID    string    `json:"userID"`
+	Amount    float64   `json:"amount"`
+	Currency  string    `json:"currency"`
+	Status    string    `json:"status"` // pending, succeeded, failed, cancelled
+	SessionID string    `json:"sessionID"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
 }
 
 // PaymentRepository 支付存储库接口
+// 传入的发件箱消息与支付状态变更原子写入，由发件箱中继投递到消息代理
 type PaymentRepository interface {
-	CreatePayment(ctx context.Context, payment *PaymentModel) (*PaymentModel, error)
+	events.OutboxStore
+	CreatePayment(ctx context.Context, payment *PaymentModel, outbox ...*events.OutboxMessage) (*PaymentModel, error)
 	GetPaymentByID(ctx context.Context, id string) (*PaymentModel, error)
 	GetPaymentByTripID(ctx context.Context, tripID string) (*PaymentModel, error)
 	GetPaymentBySessionID(ctx context.Context, sessionID string) (*PaymentModel, error)
-	UpdatePaymentStatus(ctx context.Context, id, status string) error
+	UpdatePaymentStatus(ctx context.Context, id, status string, outbox ...*events.OutboxMessage) error
 }
 
 // PaymentService 支付服务接口
@@ -32,15 +37,19 @@ type PaymentService interface {
 	CreatePaymentSession(ctx context.Context, tripID, userID string, amount float64, currency string) (*PaymentModel, error)
 	ProcessPaymentWebhook(ctx context.Context, sessionID, status string) error
 	GetPaymentByTripID(ctx context.Context, tripID string) (*PaymentModel, error)
+	CancelPaymentSession(ctx context.Context, tripID, sessionID, reason string) error
 }
 
-// PaymentEventPublisher 支付事件发布器接口
-type PaymentEventPublisher interface {
-	PublishPaymentSessionCreated(ctx context.Context, payment *PaymentModel) error
-	PublishPaymentSuccess(ctx context.Context, payment *PaymentModel) error
-	PublishPaymentFailed(ctx context.Context, payment *PaymentModel) error
-	PublishPaymentCancelled(ctx context.Context, payment *PaymentModel) error
-	Close() error
+// EventData 返回支付事件的消息体
+func (p *PaymentModel) EventData() contracts.PaymentEventData {
+	return contracts.PaymentEventData{
+		TripID:    p.TripID,
+		SessionID: p.SessionID,
+		Amount:    p.Amount,
+		Currency:  p.Currency,
+		UserID:    p.UserID,
+		Status:    p.Status,
+	}
 }
 
 // 支付状态常量
@@ -53,16 +62,16 @@ const (
 
 // StripeSessionRequest Stripe会话请求
 type StripeSessionRequest struct {
-	TripID      string  `json:"tripID"`
-	UserID      string  `json:"userID"`
-	Amount      float64 `json:"amount"`
-	Currency    string  `json:"currency"`
-	SuccessURL  string  `json:"successURL"`
-	CancelURL   string  `json:"cancelURL"`
+	TripID     string  `json:"tripID"`
+	UserID     string  `json:"userID"`
+	Amount     float64 `json:"amount"`
+	Currency   string  `json:"currency"`
+	SuccessURL string  `json:"successURL"`
+	CancelURL  string  `json:"cancelURL"`
 }
 
 // StripeSessionResponse Stripe会话响应
 type StripeSessionResponse struct {
 	SessionID string `json:"sessionID"`
 	URL       string `json:"url"`
-}
\ No newline at end of file
+}
@@ -2,13 +2,12 @@ package events
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 
 	"ride-sharing/payment-service/internal/service"
-	"ride-sharing/shared/events"
 	"ride-sharing/shared/contracts"
+	"ride-sharing/shared/events"
 	pb "ride-sharing/shared/proto/trip"
 )
 
@@ -16,39 +15,56 @@ import (
 type PaymentEventSubscriber struct {
 	subscriber events.Subscriber
 	service    service.PaymentService
+	dedup      events.DedupStore
 }
 
-// NewPaymentEventSubscriber 创建支付事件订阅器
-func NewPaymentEventSubscriber(subscriber events.Subscriber, service service.PaymentService) *PaymentEventSubscriber {
+// NewPaymentEventSubscriber 创建支付事件订阅器，dedup用于幂等消费，避免重复投递创建多个支付会话
+func NewPaymentEventSubscriber(subscriber events.Subscriber, service service.PaymentService, dedup events.DedupStore) *PaymentEventSubscriber {
 	return &PaymentEventSubscriber{
 		subscriber: subscriber,
 		service:    service,
+		dedup:      dedup,
 	}
 }
 
 // SubscribeToTripEvents 订阅行程事件
 func (s *PaymentEventSubscriber) SubscribeToTripEvents(ctx context.Context) error {
 	// 订阅司机分配事件
-	err := s.subscriber.Subscribe(
-		"create_payment_session_queue",
-		contracts.TripEventDriverAssigned,
-		s.handleDriverAssigned,
+	err := events.SubscribeQueueWithOptions(
+		s.subscriber,
+		contracts.CreatePaymentSessionQueue,
+		events.Typed(s.handleDriverAssigned),
+		func(opts *events.SubscribeOptions) { opts.Dedup = s.dedup },
 	)
 	if err != nil {
 		return fmt.Errorf("订阅司机分配事件失败: %w", err)
 	}
 
+	// 订阅取消支付会话命令，由行程Saga在补偿时发出
+	err = events.SubscribeQueueWithOptions(
+		s.subscriber,
+		contracts.CancelPaymentSessionQueue,
+		events.Typed(s.handleCancelSession),
+		func(opts *events.SubscribeOptions) { opts.Dedup = s.dedup },
+	)
+	if err != nil {
+		return fmt.Errorf("订阅取消支付会话命令失败: %w", err)
+	}
+
 	log.Println("成功订阅行程事件")
 	return nil
 }
 
-// handleDriverAssigned 处理司机分配事件
-func (s *PaymentEventSubscriber) handleDriverAssigned(data []byte) error {
-	var trip pb.Trip
-	if err := json.Unmarshal(data, &trip); err != nil {
-		return fmt.Errorf("解析司机分配事件失败: %w", err)
+// handleCancelSession 处理取消支付会话命令
+func (s *PaymentEventSubscriber) handleCancelSession(ctx context.Context, env events.Envelope, cmd contracts.PaymentCancelSession) error {
+	if err := s.service.CancelPaymentSession(ctx, cmd.TripID, cmd.SessionID, cmd.Reason); err != nil {
+		return fmt.Errorf("取消支付会话失败: %w", err)
 	}
+	return nil
+}
 
+// handleDriverAssigned 处理司机分配事件
+func (s *PaymentEventSubscriber) handleDriverAssigned(ctx context.Context, env events.Envelope, trip *pb.Trip) error {
 	// 检查行程是否有费用信息
 	if trip.SelectedFare == nil {
 		log.Printf("行程缺少费用信息，跳过支付会话创建: 行程ID=%s", trip.Id)
@@ -57,9 +73,9 @@ func (s *PaymentEventSubscriber) handleDriverAssigned(data []byte) error {
 
 	// 创建支付会话
 	payment, err := s.service.CreatePaymentSession(
-		context.Background(),
+		ctx,
 		trip.Id,
-		trip.UserId,
+		trip.UserID,
 		trip.SelectedFare.TotalPriceInCents,
 		"usd", // 默认货币，实际应用中应该从配置获取
 	)
@@ -67,13 +83,13 @@ func (s *PaymentEventSubscriber) handleDriverAssigned(data []byte) error {
 		return fmt.Errorf("创建支付会话失败: %w", err)
 	}
 
-	log.Printf("成功创建支付会话: 行程ID=%s, 支付ID=%s, 金额=%.2f", 
+	log.Printf("成功创建支付会话: 行程ID=%s, 支付ID=%s, 金额=%.2f",
 		trip.Id, payment.ID, payment.Amount)
-	
+
 	return nil
 }
 
 // Close 关闭订阅器
 func (s *PaymentEventSubscriber) Close() error {
 	return s.subscriber.Close()
-}
\ No newline at end of file
+}
@@ -7,35 +7,39 @@ import (
 	"time"
 
 	"ride-sharing/payment-service/internal/domain"
+	"ride-sharing/shared/events"
 )
 
 // inmemRepository 内存存储库实现
 type inmemRepository struct {
-	payments   map[string]*domain.PaymentModel
-	tripIndex  map[string]string // tripID -> paymentID
+	*events.InMemoryOutbox
+
+	payments     map[string]*domain.PaymentModel
+	tripIndex    map[string]string // tripID -> paymentID
 	sessionIndex map[string]string // sessionID -> paymentID
-	mu         sync.RWMutex
+	mu           sync.RWMutex
 }
 
 // NewInmemRepository 创建新的内存存储库
 func NewInmemRepository() *inmemRepository {
 	return &inmemRepository{
-		payments:     make(map[string]*domain.PaymentModel),
-		tripIndex:    make(map[string]string),
-		sessionIndex: make(map[string]string),
+		InMemoryOutbox: events.NewInMemoryOutbox(),
+		payments:       make(map[string]*domain.PaymentModel),
+		tripIndex:      make(map[string]string),
+		sessionIndex:   make(map[string]string),
 	}
 }
 
 // CreatePayment 创建支付记录
-func (r *inmemRepository) CreatePayment(ctx context.Context, payment *domain.PaymentModel) (*domain.PaymentModel, error) {
+func (r *inmemRepository) CreatePayment(ctx context.Context, payment *domain.PaymentModel, outbox ...*events.OutboxMessage) (*domain.PaymentModel, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	// 检查是否已存在相同行程的支付记录
 	if existingPaymentID, exists := r.tripIndex[payment.TripID]; exists {
 		existingPayment := r.payments[existingPaymentID]
-		if existingPayment.Status != domain.PaymentStatusFailed && 
-		   existingPayment.Status != domain.PaymentStatusCancelled {
+		if existingPayment.Status != domain.PaymentStatusFailed &&
+			existingPayment.Status != domain.PaymentStatusCancelled {
 			return nil, fmt.Errorf("行程已存在未完成的支付记录")
 		}
 	}
@@ -43,11 +47,13 @@ func (r *inmemRepository) CreatePayment(ctx context.Context, payment *domain.Pay
 	// 保存支付记录
 	r.payments[payment.ID] = payment
 	r.tripIndex[payment.TripID] = payment.ID
-	
+
 	if payment.SessionID != "" {
 		r.sessionIndex[payment.SessionID] = payment.ID
 	}
 
+	r.Append(outbox...)
+
 	return payment, nil
 }
 
@@ -101,7 +107,7 @@ func (r *inmemRepository) GetPaymentBySessionID(ctx context.Context, sessionID s
 }
 
 // UpdatePaymentStatus 更新支付状态
-func (r *inmemRepository) UpdatePaymentStatus(ctx context.Context, id, status string) error {
+func (r *inmemRepository) UpdatePaymentStatus(ctx context.Context, id, status string, outbox ...*events.OutboxMessage) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -113,6 +119,8 @@ func (r *inmemRepository) UpdatePaymentStatus(ctx context.Context, id, status st
 	payment.Status = status
 	payment.UpdatedAt = time.Now()
 
+	r.Append(outbox...)
+
 	return nil
 }
 
@@ -124,10 +132,10 @@ func (r *inmemRepository) CleanupExpiredPayments(ctx context.Context, expiryDura
 	now := time.Now()
 	for id, payment := range r.payments {
 		// 清理超过24小时的失败或取消的支付记录
-		if (payment.Status == domain.PaymentStatusFailed || 
+		if (payment.Status == domain.PaymentStatusFailed ||
 			payment.Status == domain.PaymentStatusCancelled) &&
 			now.Sub(payment.UpdatedAt) > expiryDuration {
-			
+
 			delete(r.payments, id)
 			delete(r.tripIndex, payment.TripID)
 			if payment.SessionID != "" {
@@ -137,4 +145,4 @@ func (r *inmemRepository) CleanupExpiredPayments(ctx context.Context, expiryDura
 	}
 
 	return nil
-}
\ No newline at end of file
+}
@@ -7,56 +7,50 @@ import (
 	"time"
 
 	"ride-sharing/payment-service/internal/domain"
+	"ride-sharing/shared/contracts"
+	"ride-sharing/shared/events"
 )
 
 type service struct {
-	repo     domain.PaymentRepository
-	publisher domain.PaymentEventPublisher
+	repo domain.PaymentRepository
 }
 
 // NewService 创建新的支付服务
-func NewService(repo domain.PaymentRepository, publisher domain.PaymentEventPublisher) domain.PaymentService {
+// 支付事件写入存储库的发件箱，由发件箱中继负责发布
+func NewService(repo domain.PaymentRepository) domain.PaymentService {
 	return &service{
-		repo:     repo,
-		publisher: publisher,
+		repo: repo,
 	}
 }
 
 // CreatePaymentSession 创建支付会话
 func (s *service) CreatePaymentSession(ctx context.Context, tripID, userID string, amount float64, currency string) (*domain.PaymentModel, error) {
 	// 创建支付记录
+	paymentID := generatePaymentID()
 	payment := &domain.PaymentModel{
-		ID:        generatePaymentID(),
-		TripID:    tripID,
-		UserID:    userID,
-		Amount:    amount,
-		Currency:  currency,
-		Status:    domain.PaymentStatusPending,
+		ID:       paymentID,
+		TripID:   tripID,
+		UserID:   userID,
+		Amount:   amount,
+		Currency: currency,
+		Status:   domain.PaymentStatusPending,
+		// TODO: 调用Stripe API创建支付会话
+		// 这里暂时使用模拟数据
+		SessionID: fmt.Sprintf("cs_test_%s", paymentID),
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 
-	// 保存支付记录
-	savedPayment, err := s.repo.CreatePayment(ctx, payment)
+	// 支付会话创建事件与支付记录一起写入发件箱
+	sessionCreated, err := events.NewOutboxMessage(ctx, contracts.PaymentEventSessionCreated, payment.EventData())
 	if err != nil {
-		return nil, fmt.Errorf("创建支付记录失败: %w", err)
-	}
-
-	// TODO: 调用Stripe API创建支付会话
-	// 这里暂时使用模拟数据
-	stripeSessionID := fmt.Sprintf("cs_test_%s", savedPayment.ID)
-	savedPayment.SessionID = stripeSessionID
-
-	// 更新支付记录
-	if err := s.repo.UpdatePaymentStatus(ctx, savedPayment.ID, domain.PaymentStatusPending); err != nil {
-		log.Printf("更新支付状态失败: %v", err)
+		return nil, err
 	}
 
-	// 发布支付会话创建事件
-	if s.publisher != nil {
-		if err := s.publisher.PublishPaymentSessionCreated(ctx, savedPayment); err != nil {
-			log.Printf("发布支付会话创建事件失败: %v", err)
-		}
+	// 保存支付记录
+	savedPayment, err := s.repo.CreatePayment(ctx, payment, sessionCreated)
+	if err != nil {
+		return nil, fmt.Errorf("创建支付记录失败: %w", err)
 	}
 
 	log.Printf("成功创建支付会话: 支付ID=%s, 行程ID=%s", savedPayment.ID, tripID)
@@ -75,47 +69,77 @@ func (s *service) ProcessPaymentWebhook(ctx context.Context, sessionID, status s
 		return fmt.Errorf("支付记录不存在")
 	}
 
-	// 更新支付状态
-	var newStatus string
+	// 更新支付状态，并确定对应的事件
+	var newStatus, routingKey string
 	switch status {
 	case "payment_intent.succeeded":
 		newStatus = domain.PaymentStatusSucceeded
+		routingKey = contracts.PaymentEventSuccess
 	case "payment_intent.payment_failed":
 		newStatus = domain.PaymentStatusFailed
+		routingKey = contracts.PaymentEventFailed
 	case "checkout.session.expired":
 		newStatus = domain.PaymentStatusCancelled
+		routingKey = contracts.PaymentEventCancelled
 	default:
 		return fmt.Errorf("未知的支付状态: %s", status)
 	}
 
+	// 更新模型中的状态
+	payment.Status = newStatus
+	payment.UpdatedAt = time.Now()
+
+	// 支付结果事件与状态变更一起写入发件箱
+	statusEvent, err := events.NewOutboxMessage(ctx, routingKey, payment.EventData())
+	if err != nil {
+		return err
+	}
+
 	// 更新数据库中的状态
-	if err := s.repo.UpdatePaymentStatus(ctx, payment.ID, newStatus); err != nil {
+	if err := s.repo.UpdatePaymentStatus(ctx, payment.ID, newStatus, statusEvent); err != nil {
 		return fmt.Errorf("更新支付状态失败: %w", err)
 	}
 
-	// 更新模型中的状态
-	payment.Status = newStatus
+	log.Printf("成功处理支付Webhook: 支付ID=%s, 状态=%s", payment.ID, newStatus)
+	return nil
+}
+
+// CancelPaymentSession 取消行程待支付的支付会话
+// 只取消待支付的会话，已有支付结果或会话已被替换时不做处理，重复的命令是安全的
+func (s *service) CancelPaymentSession(ctx context.Context, tripID, sessionID, reason string) error {
+	payment, err := s.repo.GetPaymentByTripID(ctx, tripID)
+	if err != nil {
+		return fmt.Errorf("获取支付记录失败: %w", err)
+	}
+
+	if payment == nil {
+		log.Printf("行程没有支付记录，无需取消: 行程ID=%s", tripID)
+		return nil
+	}
+	if sessionID != "" && payment.SessionID != sessionID {
+		log.Printf("支付会话已变更，忽略取消: 行程ID=%s, 会话ID=%s", tripID, sessionID)
+		return nil
+	}
+	if payment.Status != domain.PaymentStatusPending {
+		log.Printf("支付状态为 %s，忽略取消: 行程ID=%s", payment.Status, tripID)
+		return nil
+	}
+
+	// TODO: 调用Stripe API使支付会话过期
+	payment.Status = domain.PaymentStatusCancelled
 	payment.UpdatedAt = time.Now()
 
-	// 根据状态发布相应事件
-	if s.publisher != nil {
-		switch newStatus {
-		case domain.PaymentStatusSucceeded:
-			if err := s.publisher.PublishPaymentSuccess(ctx, payment); err != nil {
-				log.Printf("发布支付成功事件失败: %v", err)
-			}
-		case domain.PaymentStatusFailed:
-			if err := s.publisher.PublishPaymentFailed(ctx, payment); err != nil {
-				log.Printf("发布支付失败事件失败: %v", err)
-			}
-		case domain.PaymentStatusCancelled:
-			if err := s.publisher.PublishPaymentCancelled(ctx, payment); err != nil {
-				log.Printf("发布支付取消事件失败: %v", err)
-			}
-		}
+	// 支付取消事件与状态变更一起写入发件箱
+	cancelled, err := events.NewOutboxMessage(ctx, contracts.PaymentEventCancelled, payment.EventData())
+	if err != nil {
+		return err
 	}
 
-	log.Printf("成功处理支付Webhook: 支付ID=%s, 状态=%s", payment.ID, newStatus)
+	if err := s.repo.UpdatePaymentStatus(ctx, payment.ID, domain.PaymentStatusCancelled, cancelled); err != nil {
+		return fmt.Errorf("更新支付状态失败: %w", err)
+	}
+
+	log.Printf("成功取消支付会话: 支付ID=%s, 行程ID=%s, 原因=%s", payment.ID, tripID, reason)
 	return nil
 }
 
@@ -133,4 +157,4 @@ func (s *service) GetPaymentByTripID(ctx context.Context, tripID string) (*domai
 func generatePaymentID() string {
 	// 简单实现，实际应用中可以使用更复杂的ID生成策略
 	return fmt.Sprintf("pay_%d", time.Now().UnixNano())
-}
\ No newline at end of file
+}
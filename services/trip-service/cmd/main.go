@@ -10,49 +10,108 @@ import (
 	"ride-sharing/services/trip-service/internal/infrastructure/events"
 	"ride-sharing/services/trip-service/internal/infrastructure/grpc"
 	"ride-sharing/services/trip-service/internal/infrastructure/repository"
+	"ride-sharing/services/trip-service/internal/infrastructure/routing"
 	"ride-sharing/services/trip-service/internal/service"
+	"ride-sharing/shared/env"
 	sharedEvents "ride-sharing/shared/events"
+	"ride-sharing/shared/metrics"
 	"syscall"
+	"time"
 )
 
 var GrpcAddr = ":9093"
 
+var MetricsAddr = env.GetString("METRICS_ADDR", ":9193")
+
+// GracefulStopTimeout 关闭时等待进行中的gRPC请求完成的时间，超时后强制关闭
+var GracefulStopTimeout = 10 * time.Second
+
 func main() {
 	// 初始化存储库
 	inmemRepo := repository.NewInmemRepository()
 
 	// 初始化事件发布器
-	eventConfig := sharedEvents.NewTripExchangeConfig()
-	publisher, err := sharedEvents.NewRabbitMQPublisher(eventConfig.URL, eventConfig.Exchange)
+	eventConfig := sharedEvents.NewTripExchangeConfig().ForService("trip-service")
+	if err := sharedEvents.ValidateTopology(eventConfig); err != nil {
+		log.Fatalf("启动校验失败: %v", err)
+	}
+	if err := sharedEvents.ConfigureSecurityFromEnv(); err != nil {
+		log.Fatalf("加载事件签名与加密配置失败: %v", err)
+	}
+
+	// 导出事件指标
+	go func() {
+		log.Printf("指标服务正在监听端口 %s", MetricsAddr)
+		if err := metrics.ListenAndServe(MetricsAddr); err != nil {
+			log.Printf("指标服务启动失败: %v", err)
+		}
+	}()
+	publisher, err := sharedEvents.NewPublisher(eventConfig)
 	if err != nil {
 		log.Fatalf("创建事件发布器失败: %v", err)
 	}
 	defer publisher.Close()
+	sharedEvents.LogConnectionState("事件发布器", publisher)
 
 	// 创建Trip事件发布器
 	tripEventPublisher := events.NewTripEventPublisher(publisher)
 
+	// 创建路线来源
+	router, err := routing.NewRouter(routing.ConfigFromEnv())
+	if err != nil {
+		log.Fatalf("创建路线来源失败: %v", err)
+	}
+
 	// 创建服务
-	svc := service.NewService(inmemRepo, tripEventPublisher)
+	svc := service.NewService(inmemRepo, tripEventPublisher, router)
+
+	// 创建行程Saga编排器，Saga状态与行程保存在同一存储库
+	saga := service.NewSagaOrchestrator(inmemRepo, inmemRepo, service.DefaultSagaConfig())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 启动发件箱中继，将存储库中的领域事件投递到消息代理
+	outboxRelay := sharedEvents.NewOutboxRelay(inmemRepo, publisher, sharedEvents.DefaultOutboxRelayConfig())
+	go outboxRelay.Run(ctx)
+
+	// 周期性补偿超时的Saga
+	go saga.Run(ctx)
+
+	// 周期性持久化路线缓存
+	go router.Run(ctx)
 
 	// 初始化事件订阅器
-	subscriber, err := sharedEvents.NewRabbitMQSubscriber(eventConfig.URL, eventConfig.Exchange)
+	subscriber, err := sharedEvents.NewSubscriber(eventConfig)
 	if err != nil {
 		log.Fatalf("创建事件订阅器失败: %v", err)
 	}
 	defer subscriber.Close()
+	sharedEvents.LogConnectionState("事件订阅器", subscriber)
+
+	// 幂等消费的去重存储
+	dedup, err := sharedEvents.NewDedupStore(eventConfig)
+	if err != nil {
+		log.Fatalf("创建去重存储失败: %v", err)
+	}
 
 	// 创建事件订阅器并订阅事件
-	eventSubscriber := events.NewTripEventSubscriber(subscriber, svc, inmemRepo)
+	eventSubscriber := events.NewTripEventSubscriber(subscriber, svc, inmemRepo, saga, dedup)
 	if err := eventSubscriber.SubscribeToDriverResponses(context.Background()); err != nil {
 		log.Fatalf("订阅司机响应事件失败: %v", err)
 	}
 	if err := eventSubscriber.SubscribeToPaymentEvents(context.Background()); err != nil {
 		log.Fatalf("订阅支付事件失败: %v", err)
 	}
-
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	if err := eventSubscriber.RespondToTripQueries(context.Background()); err != nil {
+		log.Fatalf("订阅行程查询请求失败: %v", err)
+	}
+	if err := eventSubscriber.SubscribeToSagaEvents(context.Background()); err != nil {
+		log.Fatalf("订阅行程Saga事件失败: %v", err)
+	}
+	if err := eventSubscriber.SubscribeToDriverLocations(context.Background()); err != nil {
+		log.Fatalf("订阅司机位置更新失败: %v", err)
+	}
 
 	go func() {
 		sigCh := make(chan os.Signal, 1)
@@ -83,5 +142,30 @@ func main() {
 	<-ctx.Done()
 
 	log.Println("正在关闭服务器...")
-	grpcServer.GracefulStop()
+	// 先结束行程订阅流，否则GracefulStop会一直等待这些流
+	inmemRepo.CloseWatches()
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(GracefulStopTimeout):
+		log.Printf("等待gRPC请求完成超时，强制关闭")
+		grpcServer.Stop()
+	}
+
+	// 等待在途事件处理完成后再关闭订阅器，处理过程中写入发件箱的事件在关闭发布器前投递
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), sharedEvents.DefaultShutdownTimeout)
+	defer shutdownCancel()
+	if err := sharedEvents.Shutdown(shutdownCtx, subscriber); err != nil {
+		log.Printf("关闭事件订阅器失败: %v", err)
+	}
+	if _, err := outboxRelay.Flush(shutdownCtx); err != nil {
+		log.Printf("投递剩余发件箱消息失败: %v", err)
+	}
+	if err := router.Save(); err != nil {
+		log.Printf("保存路线缓存失败: %v", err)
+	}
 }
@@ -0,0 +1,18 @@
+package domain
+
+import (
+	"context"
+	"errors"
+
+	tripTypes "ride-sharing/services/trip-service/pkg/types"
+	"ride-sharing/shared/types"
+)
+
+// ErrNoRoute 路线来源找不到两点之间的路线，例如坐标不在路网覆盖范围内
+var ErrNoRoute = errors.New("找不到路线")
+
+// Router 计算上车点到目的地的行驶路线
+// 返回的响应至少包含一条路线，距离单位为米，时长单位为秒
+type Router interface {
+	Route(ctx context.Context, pickup, destination *types.Coordinate) (*tripTypes.OsrmApiResponse, error)
+}
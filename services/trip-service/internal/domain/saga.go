@@ -0,0 +1,150 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"ride-sharing/shared/contracts"
+	"ride-sharing/shared/events"
+	pb "ride-sharing/shared/proto/trip"
+)
+
+// 行程Saga的步骤，每个行程从等待司机开始，以完成或补偿结束
+const (
+	SagaStepAwaitingDriver         = "awaiting_driver"
+	SagaStepAwaitingPaymentSession = "awaiting_payment_session"
+	SagaStepAwaitingPayment        = "awaiting_payment"
+	SagaStepCompleted              = "completed"
+	SagaStepCompensated            = "compensated"
+)
+
+// SagaTimeoutCause 超时引起的步骤变更在历史中记录的原因
+const SagaTimeoutCause = "timeout"
+
+var (
+	// ErrSagaNotFound 行程的Saga不存在
+	ErrSagaNotFound = errors.New("行程Saga不存在")
+	// ErrSagaConflict Saga在读取之后已被其他处理更新，需要重新读取后重试
+	ErrSagaConflict = errors.New("行程Saga版本冲突")
+)
+
+// SagaTransition Saga的一次步骤变更
+type SagaTransition struct {
+	From string
+	To   string
+	// Cause 引起变更的路由键，或超时时为 SagaTimeoutCause
+	Cause string
+	At    time.Time
+}
+
+// TripSagaModel 行程从创建、司机分配到支付的Saga
+// 已获得的资源（司机、支付会话）在Saga失败时按相反顺序补偿
+type TripSagaModel struct {
+	TripID    string
+	UserID    string
+	Step      string
+	DriverID  string
+	SessionID string
+	// PaymentStatus 支付结果，失败或取消的支付会话无需再取消
+	PaymentStatus string
+	// Deadline 当前步骤的超时时间，结束状态为零值
+	Deadline time.Time
+	// FailureReason 触发补偿的原因
+	FailureReason string
+	History       []SagaTransition
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	// Version 乐观并发控制的版本号，每次保存加一
+	Version int
+}
+
+// Done Saga是否已结束
+func (s *TripSagaModel) Done() bool {
+	return s.Step == SagaStepCompleted || s.Step == SagaStepCompensated
+}
+
+// Advance 进入下一步骤，timeout为0时清除超时时间
+func (s *TripSagaModel) Advance(step, cause string, timeout time.Duration, now time.Time) {
+	s.History = append(s.History, SagaTransition{From: s.Step, To: step, Cause: cause, At: now})
+	s.Step = step
+	s.Deadline = time.Time{}
+	if timeout > 0 {
+		s.Deadline = now.Add(timeout)
+	}
+	s.UpdatedAt = now
+}
+
+// Compensations 返回撤销已获得资源的命令，先取消支付会话再释放司机
+func (s *TripSagaModel) Compensations() []SagaCommand {
+	var commands []SagaCommand
+	if cmd, ok := s.CancelSessionCommand(); ok {
+		commands = append(commands, cmd)
+	}
+	if cmd, ok := s.ReleaseDriverCommand(); ok {
+		commands = append(commands, cmd)
+	}
+	return commands
+}
+
+// CancelSessionCommand 返回取消支付会话的命令，没有会话或支付已有结果时无需取消
+func (s *TripSagaModel) CancelSessionCommand() (SagaCommand, bool) {
+	if s.SessionID == "" || s.PaymentStatus != "" {
+		return SagaCommand{}, false
+	}
+	return SagaCommand{
+		RoutingKey: contracts.PaymentCmdCancelSession,
+		Data: contracts.PaymentCancelSession{
+			TripID:    s.TripID,
+			SessionID: s.SessionID,
+			Reason:    s.FailureReason,
+		},
+	}, true
+}
+
+// ReleaseDriverCommand 返回释放司机的命令，尚未分配司机时无需释放
+func (s *TripSagaModel) ReleaseDriverCommand() (SagaCommand, bool) {
+	if s.DriverID == "" {
+		return SagaCommand{}, false
+	}
+	return SagaCommand{
+		RoutingKey: contracts.DriverCmdTripRelease,
+		Data: contracts.DriverTripRelease{
+			TripID:   s.TripID,
+			DriverID: s.DriverID,
+			Reason:   s.FailureReason,
+		},
+	}, true
+}
+
+// SagaCommand Saga发出的命令，与Saga状态一起写入发件箱
+type SagaCommand struct {
+	RoutingKey string
+	Data       interface{}
+}
+
+// SagaRepository 行程Saga存储库接口
+// 传入的发件箱消息与Saga状态原子写入
+type SagaRepository interface {
+	// CreateSaga 保存新的Saga，行程已有Saga时返回false
+	CreateSaga(ctx context.Context, saga *TripSagaModel) (bool, error)
+	GetSaga(ctx context.Context, tripID string) (*TripSagaModel, error)
+	// UpdateSaga 保存Saga，存储的版本与saga.Version不一致时返回ErrSagaConflict
+	UpdateSaga(ctx context.Context, saga *TripSagaModel, outbox ...*events.OutboxMessage) error
+	// ExpiredSagas 返回未结束且已超时的Saga
+	ExpiredSagas(ctx context.Context, now time.Time, limit int) ([]*TripSagaModel, error)
+}
+
+// TripSagaOrchestrator 按行程和支付事件推进Saga
+type TripSagaOrchestrator interface {
+	OnTripCreated(ctx context.Context, trip *pb.Trip) error
+	OnDriverAssigned(ctx context.Context, trip *pb.Trip) error
+	OnPaymentSessionCreated(ctx context.Context, payment contracts.PaymentEventData) error
+	OnPaymentSucceeded(ctx context.Context, payment contracts.PaymentEventData) error
+	// OnPaymentAborted 处理支付失败或支付会话取消，cause为对应的路由键
+	OnPaymentAborted(ctx context.Context, cause string, payment contracts.PaymentEventData) error
+	// OnTripCancelled 处理乘客或司机取消行程
+	OnTripCancelled(ctx context.Context, cancelled contracts.TripCancelled) error
+	// CheckTimeouts 补偿已超时的Saga，返回处理的数量
+	CheckTimeouts(ctx context.Context) (int, error)
+}
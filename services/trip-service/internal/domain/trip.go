@@ -4,6 +4,7 @@ import (
 	"context"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	tripTypes "ride-sharing/services/trip-service/pkg/types"
+	"ride-sharing/shared/events"
 	pb "ride-sharing/shared/proto/trip"
 	"ride-sharing/shared/types"
 )
@@ -11,18 +12,61 @@ import (
 type TripModel struct {
 	ID       primitive.ObjectID // 避免与MongoDB冲突
 	UserID   string
-	Status   string
+	Status   TripStatus
 	RideFare *RideFareModel
 	Driver   *pb.TripDriver
+	// Paid 是否已收到支付成功，与状态分开记录：乘客支付前已开始乘车时状态不再变为已支付
+	Paid bool
+	// Version 乐观并发控制的版本号，每次保存加一
+	Version int
 }
 
+// TripRepository 行程存储库接口
+// 传入的发件箱消息与状态变更原子写入，由发件箱中继投递到消息代理；保存的每个版本推送给行程的订阅者
 type TripRepository interface {
-	CreateTrip(ctx context.Context, trip *TripModel) (*TripModel, error)
+	events.OutboxStore
+	TripFeed
+	CreateTrip(ctx context.Context, trip *TripModel, outbox ...*events.OutboxMessage) (*TripModel, error)
 	SaveRideFare(ctx context.Context, f *RideFareModel) error
 	GetRideFareByID(ctx context.Context, id string) (*RideFareModel, error)
+	// GetTripByID 返回行程的副本，行程不存在时返回ErrTripNotFound
 	GetTripByID(ctx context.Context, id string) (*TripModel, error)
-	UpdateTripStatus(ctx context.Context, tripID string, status string) error
-	AssignDriver(ctx context.Context, tripID string, driver *pb.TripDriver) error
+	// UpdateTrip 保存行程，存储的版本与trip.Version不一致时返回ErrTripConflict
+	UpdateTrip(ctx context.Context, trip *TripModel, outbox ...*events.OutboxMessage) error
+	// ListTrips 按ID从新到旧返回符合条件的行程，只返回ID小于filter.PageToken的行程，最多limit个
+	ListTrips(ctx context.Context, filter TripFilter, limit int) ([]*TripModel, error)
+}
+
+// TripFilter 查询行程列表的条件
+type TripFilter struct {
+	// UserID 和 DriverID 只设置一个，分别查询乘客或司机的行程
+	UserID   string
+	DriverID string
+	// Statuses 为空时不按状态过滤
+	Statuses []TripStatus
+	// PageSize 每页的行程数量，0时使用默认值
+	PageSize int
+	// PageToken 上一页返回的翻页标记，即上一页最后一个行程的ID
+	PageToken string
+}
+
+// Matches 行程是否符合乘客、司机和状态条件
+func (f TripFilter) Matches(trip *TripModel) bool {
+	if f.UserID != "" && trip.UserID != f.UserID {
+		return false
+	}
+	if f.DriverID != "" && (trip.Driver == nil || trip.Driver.Id != f.DriverID) {
+		return false
+	}
+	if len(f.Statuses) == 0 {
+		return true
+	}
+	for _, status := range f.Statuses {
+		if trip.Status == status {
+			return true
+		}
+	}
+	return false
 }
 
 type TripService interface {
@@ -33,13 +77,28 @@ type TripService interface {
 	GetAndValidateFare(ctx context.Context, fareID, userID string) (*RideFareModel, error)
 	AcceptTrip(ctx context.Context, tripID, driverID string) error
 	DeclineTrip(ctx context.Context, tripID, driverID string) error
-	UpdatePaymentStatus(ctx context.Context, tripID, status string) error
+	// UpdatePaymentStatus 按支付结果变更行程状态，cause为支付事件的路由键
+	UpdatePaymentStatus(ctx context.Context, tripID string, status TripStatus, cause string) error
+	GetTrip(ctx context.Context, tripID string) (*TripModel, error)
+	// ListTrips 分页查询乘客或司机的行程，返回下一页的翻页标记，最后一页为空
+	ListTrips(ctx context.Context, filter TripFilter) ([]*TripModel, string, error)
+	// CancelTrip 由乘客（userID）或已分配的司机（driverID）取消行程，两者只设置一个
+	CancelTrip(ctx context.Context, tripID, userID, driverID, reason string) (*TripModel, error)
+	// ArriveAtPickup 司机到达上车地点
+	ArriveAtPickup(ctx context.Context, tripID, driverID string) (*TripModel, error)
+	// StartRide 司机接到乘客，开始行程
+	StartRide(ctx context.Context, tripID, driverID string) (*TripModel, error)
+	// CompleteTrip 司机送达乘客，完成行程并释放司机
+	CompleteTrip(ctx context.Context, tripID, driverID string) (*TripModel, error)
+	// WatchTrip 订阅行程的快照和后续的状态、司机与位置更新，fromVersion见TripFeed
+	WatchTrip(ctx context.Context, tripID string, fromVersion int) (TripWatch, error)
+	// UpdateDriverLocation 将司机位置推送给其行程的订阅者
+	UpdateDriverLocation(ctx context.Context, driverID string, location types.Coordinate)
 }
 
 // TripEventPublisher 行程事件发布器接口
+// 行程创建和司机分配事件通过存储库的发件箱发布
 type TripEventPublisher interface {
-	PublishTripCreated(ctx context.Context, trip *TripModel) error
-	PublishDriverAssigned(ctx context.Context, trip *TripModel) error
 	PublishNoDriversFound(ctx context.Context, tripID string) error
 	PublishDriverNotInterested(ctx context.Context, tripID, driverID string) error
 	Close() error
@@ -52,17 +111,17 @@ func (t *TripModel) ToProto() *pb.Trip {
 	if t.RideFare != nil {
 		rideFare = t.RideFare.ToProto()
 	}
-	
+
 	// 转换路线
 	var route *pb.Route
 	if t.RideFare != nil && t.RideFare.Route != nil {
 		route = t.RideFare.Route.ToProto()
 	}
-	
+
 	return &pb.Trip{
 		Id:           t.ID.Hex(),
 		UserID:       t.UserID,
-		Status:       t.Status,
+		Status:       string(t.Status),
 		SelectedFare: rideFare,
 		Route:        route,
 		Driver:       t.Driver,
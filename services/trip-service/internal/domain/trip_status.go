@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// TripStatus 行程状态，取值与 trip.proto 中 TripStatus 的小写名称一致，
+// 通过 Trip.status 字段以文本形式传递
+type TripStatus string
+
+const (
+	TripStatusRequested      TripStatus = "requested"
+	TripStatusSearching      TripStatus = "searching"
+	TripStatusDriverAssigned TripStatus = "driver_assigned"
+	TripStatusDriverArrived  TripStatus = "driver_arrived"
+	TripStatusInProgress     TripStatus = "in_progress"
+	TripStatusCompleted      TripStatus = "completed"
+	TripStatusCancelled      TripStatus = "cancelled"
+	TripStatusNoDriversFound TripStatus = "no_drivers_found"
+	TripStatusPaid           TripStatus = "paid"
+	TripStatusPaymentFailed  TripStatus = "payment_failed"
+)
+
+var (
+	// ErrInvalidTripTransition 行程当前状态不允许变更到目标状态
+	ErrInvalidTripTransition = errors.New("行程状态变更不合法")
+	// ErrTripNotFound 行程不存在
+	ErrTripNotFound = errors.New("行程不存在")
+	// ErrTripConflict 行程在读取之后已被其他处理更新，需要重新读取后重试
+	ErrTripConflict = errors.New("行程版本冲突")
+	// ErrTripForbidden 操作者不是行程的乘客或已分配的司机
+	ErrTripForbidden = errors.New("无权操作该行程")
+	// ErrInvalidTripRequest 行程操作的参数不合法
+	ErrInvalidTripRequest = errors.New("行程请求参数不合法")
+)
+
+// tripTransitions 每个状态允许变更到的状态，未列出的状态为结束状态
+var tripTransitions = map[TripStatus][]TripStatus{
+	TripStatusRequested:      {TripStatusSearching, TripStatusCancelled},
+	TripStatusSearching:      {TripStatusDriverAssigned, TripStatusNoDriversFound, TripStatusCancelled},
+	TripStatusDriverAssigned: {TripStatusDriverArrived, TripStatusPaid, TripStatusPaymentFailed, TripStatusCancelled},
+	TripStatusPaid:           {TripStatusDriverArrived, TripStatusInProgress, TripStatusCancelled},
+	TripStatusDriverArrived:  {TripStatusPaid, TripStatusPaymentFailed, TripStatusInProgress, TripStatusCancelled},
+	TripStatusInProgress:     {TripStatusCompleted},
+}
+
+// ParseTripStatus 解析行程状态，未知的取值返回错误
+func ParseTripStatus(s string) (TripStatus, error) {
+	status := TripStatus(s)
+	if _, ok := tripTransitions[status]; ok || status.Final() {
+		return status, nil
+	}
+	return "", fmt.Errorf("未知的行程状态: %q", s)
+}
+
+// Final 是否为结束状态
+func (s TripStatus) Final() bool {
+	switch s {
+	case TripStatusCompleted, TripStatusCancelled, TripStatusNoDriversFound, TripStatusPaymentFailed:
+		return true
+	}
+	return false
+}
+
+// CanTransitionTo 当前状态是否允许变更到目标状态
+func (s TripStatus) CanTransitionTo(to TripStatus) bool {
+	for _, next := range tripTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TripTransition 行程的一次状态变更
+type TripTransition struct {
+	From TripStatus
+	To   TripStatus
+	// Cause 引起变更的路由键或RPC方法
+	Cause string
+}
+
+// Transition 按状态表变更行程状态
+// 目标状态与当前状态相同时不做变更并返回false，重复投递的事件因此不会报错；
+// 不允许的变更返回ErrInvalidTripTransition
+func (t *TripModel) Transition(to TripStatus, cause string) (TripTransition, bool, error) {
+	if t.Status == to {
+		return TripTransition{}, false, nil
+	}
+	if !t.Status.CanTransitionTo(to) {
+		return TripTransition{}, false, fmt.Errorf("%w: 行程ID=%s, %s -> %s", ErrInvalidTripTransition, t.ID.Hex(), t.Status, to)
+	}
+
+	transition := TripTransition{From: t.Status, To: to, Cause: cause}
+	t.Status = to
+	return transition, true, nil
+}
@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTripStatusCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from TripStatus
+		to   TripStatus
+		want bool
+	}{
+		{TripStatusRequested, TripStatusSearching, true},
+		{TripStatusRequested, TripStatusCancelled, true},
+		{TripStatusRequested, TripStatusDriverAssigned, false},
+		{TripStatusSearching, TripStatusDriverAssigned, true},
+		{TripStatusSearching, TripStatusNoDriversFound, true},
+		{TripStatusSearching, TripStatusPaid, false},
+		{TripStatusDriverAssigned, TripStatusPaid, true},
+		{TripStatusDriverAssigned, TripStatusPaymentFailed, true},
+		{TripStatusDriverAssigned, TripStatusInProgress, false},
+		{TripStatusDriverArrived, TripStatusPaid, true},
+		{TripStatusDriverArrived, TripStatusInProgress, true},
+		{TripStatusPaid, TripStatusInProgress, true},
+		{TripStatusPaid, TripStatusPaymentFailed, false},
+		{TripStatusInProgress, TripStatusCompleted, true},
+		{TripStatusInProgress, TripStatusCancelled, false},
+		{TripStatusCompleted, TripStatusCancelled, false},
+		{TripStatusCancelled, TripStatusSearching, false},
+		{TripStatusNoDriversFound, TripStatusDriverAssigned, false},
+		{TripStatusPaymentFailed, TripStatusPaid, false},
+		{TripStatus("unknown"), TripStatusSearching, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+				t.Errorf("CanTransitionTo = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTripStatus(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    TripStatus
+		wantErr bool
+	}{
+		{"requested", TripStatusRequested, false},
+		{"in_progress", TripStatusInProgress, false},
+		{"completed", TripStatusCompleted, false},
+		{"payment_failed", TripStatusPaymentFailed, false},
+		{"pending", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseTripStatus(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTripModelTransition(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    TripStatus
+		to      TripStatus
+		changed bool
+		err     error
+	}{
+		{"allowed", TripStatusSearching, TripStatusDriverAssigned, true, nil},
+		{"same status is a no-op", TripStatusDriverAssigned, TripStatusDriverAssigned, false, nil},
+		{"not in table", TripStatusSearching, TripStatusCompleted, false, ErrInvalidTripTransition},
+		{"from final status", TripStatusCancelled, TripStatusSearching, false, ErrInvalidTripTransition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trip := &TripModel{ID: primitive.NewObjectID(), Status: tt.from}
+
+			transition, changed, err := trip.Transition(tt.to, "test.cause")
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if changed != tt.changed {
+				t.Errorf("got changed %v, want %v", changed, tt.changed)
+			}
+
+			wantStatus := tt.from
+			if tt.changed {
+				wantStatus = tt.to
+				want := TripTransition{From: tt.from, To: tt.to, Cause: "test.cause"}
+				if transition != want {
+					t.Errorf("got transition %+v, want %+v", transition, want)
+				}
+			}
+			if trip.Status != wantStatus {
+				t.Errorf("got status %q, want %q", trip.Status, wantStatus)
+			}
+		})
+	}
+}
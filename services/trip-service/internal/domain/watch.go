@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"context"
+	"errors"
+
+	"ride-sharing/shared/types"
+)
+
+// TripUpdateKind 行程更新的类型
+type TripUpdateKind string
+
+const (
+	// TripUpdateSnapshot 行程当前的完整状态，订阅开始或无法从指定版本续传时发送
+	TripUpdateSnapshot TripUpdateKind = "snapshot"
+	TripUpdateStatus   TripUpdateKind = "status"
+	TripUpdateDriver   TripUpdateKind = "driver"
+	// TripUpdateLocation 已分配司机的位置，不改变行程版本
+	TripUpdateLocation TripUpdateKind = "location"
+)
+
+var (
+	// ErrTripWatchLagged 订阅者接收更新过慢被断开，需要从最后收到的版本重新订阅
+	ErrTripWatchLagged = errors.New("行程订阅处理过慢已断开")
+	// ErrTripWatchClosed 服务正在关闭，订阅者需要从最后收到的版本向其他实例重新订阅
+	ErrTripWatchClosed = errors.New("行程订阅源已关闭")
+)
+
+// TripUpdate 推送给行程订阅者的一次更新
+type TripUpdate struct {
+	Kind TripUpdateKind
+	// Version 更新后的行程版本，位置更新沿用行程当前的版本
+	Version int
+	// Trip 更新后的行程，调用方不能修改
+	Trip *TripModel
+	// Location 司机位置，仅位置更新设置
+	Location *types.Coordinate
+}
+
+// TripWatch 一个行程的订阅
+type TripWatch interface {
+	// Updates 按版本顺序返回更新，订阅结束时关闭
+	Updates() <-chan TripUpdate
+	// Err 返回订阅结束的原因：行程已结束时为nil，接收过慢时为ErrTripWatchLagged，
+	// 服务关闭时为ErrTripWatchClosed，ctx取消时为ctx的错误
+	Err() error
+}
+
+// TripFeed 行程更新的订阅源，由存储库在保存行程时推送
+type TripFeed interface {
+	// WatchTrip 订阅行程，fromVersion为订阅者已收到的最后版本，0表示从快照开始
+	// fromVersion之后的更新仍在缓冲中时依次重放，否则先发送行程当前的快照；ctx取消时结束订阅
+	WatchTrip(ctx context.Context, tripID string, fromVersion int) (TripWatch, error)
+	// PublishDriverLocation 将司机位置推送给该司机进行中行程的订阅者
+	PublishDriverLocation(driverID string, location types.Coordinate)
+	// CloseWatches 以ErrTripWatchClosed结束全部订阅并拒绝新的订阅，服务关闭时在停止gRPC服务器之前调用
+	CloseWatches()
+}
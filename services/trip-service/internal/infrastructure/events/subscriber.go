@@ -2,62 +2,60 @@ package events
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 
 	"ride-sharing/services/trip-service/internal/domain"
-	"ride-sharing/shared/events"
 	"ride-sharing/shared/contracts"
+	"ride-sharing/shared/events"
+	pb "ride-sharing/shared/proto/trip"
+	"ride-sharing/shared/types"
 )
 
-// DriverTripResponse 司机行程响应
-type DriverTripResponse struct {
-	TripID   string `json:"tripID"`
-	RiderID  string `json:"riderID"`
-	DriverID string `json:"driverID"`
-	Accept   bool   `json:"accept"`
-}
-
-// PaymentEvent 支付事件
-type PaymentEvent struct {
-	TripID string `json:"tripID"`
-	Status string `json:"status"`
-}
-
 // TripEventSubscriber Trip服务事件订阅器
 type TripEventSubscriber struct {
 	subscriber events.Subscriber
 	service    domain.TripService
 	repo       domain.TripRepository
+	saga       domain.TripSagaOrchestrator
+	dedup      events.DedupStore
 }
 
-// NewTripEventSubscriber 创建Trip事件订阅器
-func NewTripEventSubscriber(subscriber events.Subscriber, service domain.TripService, repo domain.TripRepository) *TripEventSubscriber {
+// NewTripEventSubscriber 创建Trip事件订阅器，dedup用于有副作用的订阅的幂等消费
+func NewTripEventSubscriber(subscriber events.Subscriber, service domain.TripService, repo domain.TripRepository, saga domain.TripSagaOrchestrator, dedup events.DedupStore) *TripEventSubscriber {
 	return &TripEventSubscriber{
 		subscriber: subscriber,
 		service:    service,
 		repo:       repo,
+		saga:       saga,
+		dedup:      dedup,
 	}
 }
 
+// idempotent 为订阅启用幂等消费
+func (s *TripEventSubscriber) idempotent(opts *events.SubscribeOptions) {
+	opts.Dedup = s.dedup
+}
+
 // SubscribeToDriverResponses 订阅司机响应
 func (s *TripEventSubscriber) SubscribeToDriverResponses(ctx context.Context) error {
 	// 订阅司机接受行程的响应
-	err := s.subscriber.Subscribe(
-		"driver_trip_response_queue",
-		contracts.DriverCmdTripAccept,
-		s.handleDriverAcceptTrip,
+	err := events.SubscribeQueueWithOptions(
+		s.subscriber,
+		contracts.DriverTripResponseQueue,
+		events.Typed(s.handleDriverAcceptTrip),
+		s.idempotent,
 	)
 	if err != nil {
 		return fmt.Errorf("订阅司机接受行程事件失败: %w", err)
 	}
 
 	// 订阅司机拒绝行程的响应
-	err = s.subscriber.Subscribe(
-		"driver_trip_decline_queue",
-		contracts.DriverCmdTripDecline,
-		s.handleDriverDeclineTrip,
+	err = events.SubscribeQueue(
+		s.subscriber,
+		contracts.DriverTripDeclineQueue,
+		events.Typed(s.handleDriverDeclineTrip),
 	)
 	if err != nil {
 		return fmt.Errorf("订阅司机拒绝行程事件失败: %w", err)
@@ -70,20 +68,22 @@ func (s *TripEventSubscriber) SubscribeToDriverResponses(ctx context.Context) er
 // SubscribeToPaymentEvents 订阅支付事件
 func (s *TripEventSubscriber) SubscribeToPaymentEvents(ctx context.Context) error {
 	// 订阅支付成功事件
-	err := s.subscriber.Subscribe(
-		"payment_success_queue",
-		contracts.PaymentEventSuccess,
-		s.handlePaymentSuccess,
+	err := events.SubscribeQueueWithOptions(
+		s.subscriber,
+		contracts.PaymentSuccessQueue,
+		events.Typed(s.handlePaymentSuccess),
+		s.idempotent,
 	)
 	if err != nil {
 		return fmt.Errorf("订阅支付成功事件失败: %w", err)
 	}
 
-	// 订阅支付失败事件
-	err = s.subscriber.Subscribe(
-		"payment_failed_queue",
-		contracts.PaymentEventFailed,
-		s.handlePaymentFailed,
+	// 订阅支付失败事件，失败时补偿Saga会发出释放司机的命令
+	err = events.SubscribeQueueWithOptions(
+		s.subscriber,
+		contracts.PaymentFailedQueue,
+		events.Typed(s.handlePaymentFailed),
+		s.idempotent,
 	)
 	if err != nil {
 		return fmt.Errorf("订阅支付失败事件失败: %w", err)
@@ -93,15 +93,121 @@ func (s *TripEventSubscriber) SubscribeToPaymentEvents(ctx context.Context) erro
 	return nil
 }
 
-// handleDriverAcceptTrip 处理司机接受行程事件
-func (s *TripEventSubscriber) handleDriverAcceptTrip(data []byte) error {
-	var response DriverTripResponse
-	if err := json.Unmarshal(data, &response); err != nil {
-		return fmt.Errorf("解析司机接受行程响应失败: %w", err)
+// SubscribeToDriverLocations 订阅司机位置更新，推送给行程的订阅者
+func (s *TripEventSubscriber) SubscribeToDriverLocations(ctx context.Context) error {
+	// 同一司机的位置按到达顺序处理
+	err := events.SubscribeQueueWithOptions(
+		s.subscriber,
+		contracts.TripDriverLocationQueue,
+		events.Typed(s.handleDriverLocation),
+		func(opts *events.SubscribeOptions) {
+			opts.Prefetch = 64
+			opts.Workers = 4
+			opts.OrderingKey = events.OrderByField("driverID")
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("订阅司机位置更新失败: %w", err)
+	}
+
+	log.Println("成功订阅司机位置更新")
+	return nil
+}
+
+// handleDriverLocation 将司机位置推送给行程的订阅者
+func (s *TripEventSubscriber) handleDriverLocation(ctx context.Context, env events.Envelope, update contracts.DriverLocationUpdate) error {
+	s.service.UpdateDriverLocation(ctx, update.DriverID, types.Coordinate{
+		Latitude:  update.Latitude,
+		Longitude: update.Longitude,
+	})
+	return nil
+}
+
+// SubscribeToSagaEvents 订阅推进行程Saga的事件
+// 支付成功和失败事件由支付事件的订阅一并处理
+func (s *TripEventSubscriber) SubscribeToSagaEvents(ctx context.Context) error {
+	subscriptions := []struct {
+		queue   string
+		handler events.Handler
+	}{
+		{contracts.TripSagaCreatedQueue, events.Typed(s.handleSagaTripCreated)},
+		{contracts.TripSagaDriverAssignedQueue, events.Typed(s.handleSagaDriverAssigned)},
+		{contracts.TripSagaPaymentSessionQueue, events.Typed(s.handleSagaPaymentSession)},
+		{contracts.TripSagaPaymentCancelledQueue, events.Typed(s.handleSagaPaymentCancelled)},
+		{contracts.TripSagaCancelledQueue, events.Typed(s.handleSagaTripCancelled)},
+	}
+	for _, sub := range subscriptions {
+		if err := events.SubscribeQueueWithOptions(s.subscriber, sub.queue, sub.handler, s.idempotent); err != nil {
+			return fmt.Errorf("订阅Saga事件失败: 队列=%s: %w", sub.queue, err)
+		}
+	}
+
+	log.Println("成功订阅行程Saga事件")
+	return nil
+}
+
+// handleSagaTripCreated 为新行程开始Saga
+func (s *TripEventSubscriber) handleSagaTripCreated(ctx context.Context, env events.Envelope, trip *pb.Trip) error {
+	return s.saga.OnTripCreated(ctx, trip)
+}
+
+// handleSagaDriverAssigned 推进Saga到等待支付会话
+func (s *TripEventSubscriber) handleSagaDriverAssigned(ctx context.Context, env events.Envelope, trip *pb.Trip) error {
+	return s.saga.OnDriverAssigned(ctx, trip)
+}
+
+// handleSagaPaymentSession 推进Saga到等待支付
+func (s *TripEventSubscriber) handleSagaPaymentSession(ctx context.Context, env events.Envelope, paymentEvent contracts.PaymentEventData) error {
+	return s.saga.OnPaymentSessionCreated(ctx, paymentEvent)
+}
+
+// handleSagaPaymentCancelled 支付会话被取消，补偿Saga
+func (s *TripEventSubscriber) handleSagaPaymentCancelled(ctx context.Context, env events.Envelope, paymentEvent contracts.PaymentEventData) error {
+	return s.saga.OnPaymentAborted(ctx, env.RoutingKey, paymentEvent)
+}
+
+// handleSagaTripCancelled 行程被取消，补偿Saga
+func (s *TripEventSubscriber) handleSagaTripCancelled(ctx context.Context, env events.Envelope, cancelled contracts.TripCancelled) error {
+	return s.saga.OnTripCancelled(ctx, cancelled)
+}
+
+// RespondToTripQueries 响应行程查询请求
+func (s *TripEventSubscriber) RespondToTripQueries(ctx context.Context) error {
+	err := events.RespondQueue(
+		s.subscriber,
+		contracts.TripQueryQueue,
+		events.TypedResponse(s.handleTripQuery),
+	)
+	if err != nil {
+		return fmt.Errorf("订阅行程查询请求失败: %w", err)
+	}
+
+	log.Println("成功订阅行程查询请求")
+	return nil
+}
+
+// handleTripQuery 查询行程的乘客和状态
+func (s *TripEventSubscriber) handleTripQuery(ctx context.Context, env events.Envelope, query contracts.TripQuery) (interface{}, error) {
+	trip, err := s.repo.GetTripByID(ctx, query.TripID)
+	if err != nil {
+		return nil, fmt.Errorf("查询行程失败: %w", err)
 	}
 
+	summary := contracts.TripSummary{
+		TripID: query.TripID,
+		UserID: trip.UserID,
+		Status: string(trip.Status),
+	}
+	if trip.Driver != nil {
+		summary.DriverID = trip.Driver.Id
+	}
+	return summary, nil
+}
+
+// handleDriverAcceptTrip 处理司机接受行程事件
+func (s *TripEventSubscriber) handleDriverAcceptTrip(ctx context.Context, env events.Envelope, response contracts.DriverTripResponse) error {
 	// 处理司机接受行程的业务逻辑
-	if err := s.service.AcceptTrip(context.Background(), response.TripID, response.DriverID); err != nil {
+	if err := s.service.AcceptTrip(ctx, response.TripID, response.DriverID); err != nil {
 		return fmt.Errorf("处理司机接受行程失败: %w", err)
 	}
 
@@ -110,14 +216,9 @@ func (s *TripEventSubscriber) handleDriverAcceptTrip(data []byte) error {
 }
 
 // handleDriverDeclineTrip 处理司机拒绝行程事件
-func (s *TripEventSubscriber) handleDriverDeclineTrip(data []byte) error {
-	var response DriverTripResponse
-	if err := json.Unmarshal(data, &response); err != nil {
-		return fmt.Errorf("解析司机拒绝行程响应失败: %w", err)
-	}
-
+func (s *TripEventSubscriber) handleDriverDeclineTrip(ctx context.Context, env events.Envelope, response contracts.DriverTripResponse) error {
 	// 处理司机拒绝行程的业务逻辑
-	if err := s.service.DeclineTrip(context.Background(), response.TripID, response.DriverID); err != nil {
+	if err := s.service.DeclineTrip(ctx, response.TripID, response.DriverID); err != nil {
 		return fmt.Errorf("处理司机拒绝行程失败: %w", err)
 	}
 
@@ -126,31 +227,29 @@ func (s *TripEventSubscriber) handleDriverDeclineTrip(data []byte) error {
 }
 
 // handlePaymentSuccess 处理支付成功事件
-func (s *TripEventSubscriber) handlePaymentSuccess(data []byte) error {
-	var paymentEvent PaymentEvent
-	if err := json.Unmarshal(data, &paymentEvent); err != nil {
-		return fmt.Errorf("解析支付成功事件失败: %w", err)
-	}
-
+func (s *TripEventSubscriber) handlePaymentSuccess(ctx context.Context, env events.Envelope, paymentEvent contracts.PaymentEventData) error {
 	// 处理支付成功的业务逻辑
-	if err := s.service.UpdatePaymentStatus(context.Background(), paymentEvent.TripID, "paid"); err != nil {
+	err := s.service.UpdatePaymentStatus(ctx, paymentEvent.TripID, domain.TripStatusPaid, env.RoutingKey)
+	if errors.Is(err, domain.ErrInvalidTripTransition) {
+		// 行程已取消或未乘车就结束，退款由Saga记录后人工处理；已开始的行程只记录支付结果，不会返回此错误
+		log.Printf("行程状态不接受支付成功: %v", err)
+	} else if err != nil {
 		return fmt.Errorf("处理支付成功失败: %w", err)
 	}
+	if err := s.saga.OnPaymentSucceeded(ctx, paymentEvent); err != nil {
+		return fmt.Errorf("推进行程Saga失败: %w", err)
+	}
 
 	log.Printf("成功处理支付成功事件: 行程ID=%s", paymentEvent.TripID)
 	return nil
 }
 
 // handlePaymentFailed 处理支付失败事件
-func (s *TripEventSubscriber) handlePaymentFailed(data []byte) error {
-	var paymentEvent PaymentEvent
-	if err := json.Unmarshal(data, &paymentEvent); err != nil {
-		return fmt.Errorf("解析支付失败事件失败: %w", err)
-	}
-
-	// 处理支付失败的业务逻辑
-	if err := s.service.UpdatePaymentStatus(context.Background(), paymentEvent.TripID, "payment_failed"); err != nil {
-		return fmt.Errorf("处理支付失败失败: %w", err)
+// 行程状态由Saga补偿时与释放司机一起变更，行程不会在Saga补偿之前进入支付失败的结束状态；
+// Saga已结束时迟到的支付失败事件不会覆盖已完成或已取消的行程
+func (s *TripEventSubscriber) handlePaymentFailed(ctx context.Context, env events.Envelope, paymentEvent contracts.PaymentEventData) error {
+	if err := s.saga.OnPaymentAborted(ctx, env.RoutingKey, paymentEvent); err != nil {
+		return fmt.Errorf("补偿行程Saga失败: %w", err)
 	}
 
 	log.Printf("成功处理支付失败事件: 行程ID=%s", paymentEvent.TripID)
@@ -160,4 +259,4 @@ func (s *TripEventSubscriber) handlePaymentFailed(data []byte) error {
 // Close 关闭订阅器
 func (s *TripEventSubscriber) Close() error {
 	return s.subscriber.Close()
-}
\ No newline at end of file
+}
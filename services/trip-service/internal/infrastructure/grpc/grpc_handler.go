@@ -2,6 +2,7 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -9,6 +10,7 @@ import (
 	"ride-sharing/services/trip-service/internal/domain"
 	pb "ride-sharing/shared/proto/trip"
 	"ride-sharing/shared/types"
+	"strings"
 )
 
 type gRPCHandler struct {
@@ -82,3 +84,145 @@ func (h *gRPCHandler) PreviewTrip(ctx context.Context, req *pb.PreviewTripReques
 		RideFares: domain.ToRideFaresProto(fares),
 	}, nil
 }
+
+func (h *gRPCHandler) GetTrip(ctx context.Context, req *pb.GetTripRequest) (*pb.GetTripResponse, error) {
+	trip, err := h.service.GetTrip(ctx, req.GetTripID())
+	if err != nil {
+		return nil, toStatusError("failed to get trip", err)
+	}
+
+	return &pb.GetTripResponse{Trip: trip.ToProto()}, nil
+}
+
+func (h *gRPCHandler) ListTrips(ctx context.Context, req *pb.ListTripsRequest) (*pb.ListTripsResponse, error) {
+	filter := domain.TripFilter{
+		UserID:    req.GetUserID(),
+		DriverID:  req.GetDriverID(),
+		PageSize:  int(req.GetPageSize()),
+		PageToken: req.GetPageToken(),
+	}
+	for _, s := range req.GetStatuses() {
+		filter.Statuses = append(filter.Statuses, statusFromProto(s))
+	}
+
+	trips, nextPageToken, err := h.service.ListTrips(ctx, filter)
+	if err != nil {
+		return nil, toStatusError("failed to list trips", err)
+	}
+
+	resp := &pb.ListTripsResponse{NextPageToken: nextPageToken}
+	for _, trip := range trips {
+		resp.Trips = append(resp.Trips, trip.ToProto())
+	}
+	return resp, nil
+}
+
+func (h *gRPCHandler) CancelTrip(ctx context.Context, req *pb.CancelTripRequest) (*pb.CancelTripResponse, error) {
+	trip, err := h.service.CancelTrip(ctx, req.GetTripID(), req.GetUserID(), req.GetDriverID(), req.GetReason())
+	if err != nil {
+		return nil, toStatusError("failed to cancel trip", err)
+	}
+
+	return &pb.CancelTripResponse{Trip: trip.ToProto()}, nil
+}
+
+func (h *gRPCHandler) ArriveAtPickup(ctx context.Context, req *pb.ArriveAtPickupRequest) (*pb.ArriveAtPickupResponse, error) {
+	trip, err := h.service.ArriveAtPickup(ctx, req.GetTripID(), req.GetDriverID())
+	if err != nil {
+		return nil, toStatusError("failed to mark driver arrival", err)
+	}
+
+	return &pb.ArriveAtPickupResponse{Trip: trip.ToProto()}, nil
+}
+
+func (h *gRPCHandler) StartRide(ctx context.Context, req *pb.StartRideRequest) (*pb.StartRideResponse, error) {
+	trip, err := h.service.StartRide(ctx, req.GetTripID(), req.GetDriverID())
+	if err != nil {
+		return nil, toStatusError("failed to start ride", err)
+	}
+
+	return &pb.StartRideResponse{Trip: trip.ToProto()}, nil
+}
+
+func (h *gRPCHandler) CompleteTrip(ctx context.Context, req *pb.CompleteTripRequest) (*pb.CompleteTripResponse, error) {
+	trip, err := h.service.CompleteTrip(ctx, req.GetTripID(), req.GetDriverID())
+	if err != nil {
+		return nil, toStatusError("failed to complete trip", err)
+	}
+
+	return &pb.CompleteTripResponse{Trip: trip.ToProto()}, nil
+}
+
+// WatchTrip 推送行程的更新，行程结束时正常结束流；接收过慢时返回Aborted，客户端可从最后收到的版本续传
+func (h *gRPCHandler) WatchTrip(req *pb.WatchTripRequest, stream pb.TripService_WatchTripServer) error {
+	ctx := stream.Context()
+	watch, err := h.service.WatchTrip(ctx, req.GetTripID(), int(req.GetFromVersion()))
+	if err != nil {
+		return toStatusError("failed to watch trip", err)
+	}
+
+	for update := range watch.Updates() {
+		if err := stream.Send(updateToProto(update)); err != nil {
+			return err
+		}
+	}
+
+	err = watch.Err()
+	switch {
+	case err == nil || ctx.Err() != nil:
+		return nil
+	case errors.Is(err, domain.ErrTripWatchLagged):
+		return status.Errorf(codes.Aborted, "trip watch lagged behind, resume from the last received version: %v", err)
+	case errors.Is(err, domain.ErrTripWatchClosed):
+		return status.Errorf(codes.Unavailable, "trip service is shutting down, resume from the last received version: %v", err)
+	}
+	return toStatusError("trip watch failed", err)
+}
+
+// toStatusError 将领域错误转换为对应的gRPC状态码
+func toStatusError(msg string, err error) error {
+	code := codes.Internal
+	switch {
+	case errors.Is(err, domain.ErrInvalidTripRequest):
+		code = codes.InvalidArgument
+	case errors.Is(err, domain.ErrTripNotFound):
+		code = codes.NotFound
+	case errors.Is(err, domain.ErrTripForbidden):
+		code = codes.PermissionDenied
+	case errors.Is(err, domain.ErrInvalidTripTransition):
+		code = codes.FailedPrecondition
+	case errors.Is(err, domain.ErrTripConflict):
+		code = codes.Aborted
+	case errors.Is(err, domain.ErrTripWatchClosed):
+		code = codes.Unavailable
+	}
+	return status.Errorf(code, "%s: %v", msg, err)
+}
+
+// statusFromProto 将proto枚举转换为行程状态，例如 TRIP_STATUS_IN_PROGRESS 对应 in_progress
+func statusFromProto(s pb.TripStatus) domain.TripStatus {
+	return domain.TripStatus(strings.ToLower(strings.TrimPrefix(s.String(), "TRIP_STATUS_")))
+}
+
+// updateToProto 将行程更新转换为protobuf格式
+func updateToProto(update domain.TripUpdate) *pb.TripUpdate {
+	msg := &pb.TripUpdate{
+		Kind:    updateKinds[update.Kind],
+		Version: int64(update.Version),
+		Trip:    update.Trip.ToProto(),
+	}
+	if update.Location != nil {
+		msg.DriverLocation = &pb.Coordinate{
+			Latitude:  update.Location.Latitude,
+			Longitude: update.Location.Longitude,
+		}
+	}
+	return msg
+}
+
+var updateKinds = map[domain.TripUpdateKind]pb.TripUpdateKind{
+	domain.TripUpdateSnapshot: pb.TripUpdateKind_TRIP_UPDATE_KIND_SNAPSHOT,
+	domain.TripUpdateStatus:   pb.TripUpdateKind_TRIP_UPDATE_KIND_STATUS,
+	domain.TripUpdateDriver:   pb.TripUpdateKind_TRIP_UPDATE_KIND_DRIVER,
+	domain.TripUpdateLocation: pb.TripUpdateKind_TRIP_UPDATE_KIND_LOCATION,
+}
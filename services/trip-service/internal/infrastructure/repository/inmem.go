@@ -3,36 +3,47 @@ package repository
 import (
 	"context"
 	"fmt"
-	"sync"
 	"ride-sharing/services/trip-service/internal/domain"
-	pb "ride-sharing/shared/proto/trip"
+	"ride-sharing/shared/events"
+	"sort"
+	"sync"
+	"time"
 )
 
 type inmemRepository struct {
+	*events.InMemoryOutbox
+	hub *tripHub
+
 	mu        sync.RWMutex
 	trips     map[string]*domain.TripModel
 	rideFares map[string]*domain.RideFareModel
+	sagas     map[string]*domain.TripSagaModel
 }
 
 func NewInmemRepository() *inmemRepository {
 	return &inmemRepository{
-		trips:     make(map[string]*domain.TripModel),
-		rideFares: make(map[string]*domain.RideFareModel),
+		InMemoryOutbox: events.NewInMemoryOutbox(),
+		hub:            newTripHub(),
+		trips:          make(map[string]*domain.TripModel),
+		rideFares:      make(map[string]*domain.RideFareModel),
+		sagas:          make(map[string]*domain.TripSagaModel),
 	}
 }
 
-func (r *inmemRepository) CreateTrip(ctx context.Context, trip *domain.TripModel) (*domain.TripModel, error) {
+func (r *inmemRepository) CreateTrip(ctx context.Context, trip *domain.TripModel, outbox ...*events.OutboxMessage) (*domain.TripModel, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	
-	r.trips[trip.ID.Hex()] = trip
+
+	trip.Version = 1
+	r.trips[trip.ID.Hex()] = cloneTrip(trip)
+	r.Append(outbox...)
 	return trip, nil
 }
 
 func (r *inmemRepository) SaveRideFare(ctx context.Context, f *domain.RideFareModel) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	
+
 	r.rideFares[f.ID.Hex()] = f
 	return nil
 }
@@ -40,7 +51,7 @@ func (r *inmemRepository) SaveRideFare(ctx context.Context, f *domain.RideFareMo
 func (r *inmemRepository) GetRideFareByID(ctx context.Context, id string) (*domain.RideFareModel, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	
+
 	res, ok := r.rideFares[id]
 	if !ok {
 		return nil, fmt.Errorf("failed to get Fare by ID")
@@ -51,36 +62,121 @@ func (r *inmemRepository) GetRideFareByID(ctx context.Context, id string) (*doma
 func (r *inmemRepository) GetTripByID(ctx context.Context, id string) (*domain.TripModel, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	
+
 	res, ok := r.trips[id]
 	if !ok {
-		return nil, fmt.Errorf("failed to get Trip by ID")
+		return nil, domain.ErrTripNotFound
 	}
-	return res, nil
+	return cloneTrip(res), nil
 }
 
-func (r *inmemRepository) UpdateTripStatus(ctx context.Context, tripID string, status string) error {
+func (r *inmemRepository) UpdateTrip(ctx context.Context, trip *domain.TripModel, outbox ...*events.OutboxMessage) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	
-	trip, ok := r.trips[tripID]
+
+	stored, ok := r.trips[trip.ID.Hex()]
 	if !ok {
-		return fmt.Errorf("trip not found")
+		return domain.ErrTripNotFound
 	}
-	
-	trip.Status = status
+	if stored.Version != trip.Version {
+		return domain.ErrTripConflict
+	}
+
+	trip.Version++
+	r.trips[trip.ID.Hex()] = cloneTrip(trip)
+	r.Append(outbox...)
+	r.hub.publish(stored, trip)
 	return nil
 }
 
-func (r *inmemRepository) AssignDriver(ctx context.Context, tripID string, driver *pb.TripDriver) error {
+func (r *inmemRepository) CreateSaga(ctx context.Context, saga *domain.TripSagaModel) (bool, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	
-	trip, ok := r.trips[tripID]
+
+	if _, ok := r.sagas[saga.TripID]; ok {
+		return false, nil
+	}
+	saga.Version = 1
+	r.sagas[saga.TripID] = cloneSaga(saga)
+	return true, nil
+}
+
+func (r *inmemRepository) GetSaga(ctx context.Context, tripID string) (*domain.TripSagaModel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	saga, ok := r.sagas[tripID]
 	if !ok {
-		return fmt.Errorf("trip not found")
+		return nil, domain.ErrSagaNotFound
 	}
-	
-	trip.Driver = driver
+	return cloneSaga(saga), nil
+}
+
+func (r *inmemRepository) UpdateSaga(ctx context.Context, saga *domain.TripSagaModel, outbox ...*events.OutboxMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.sagas[saga.TripID]
+	if !ok {
+		return domain.ErrSagaNotFound
+	}
+	if stored.Version != saga.Version {
+		return domain.ErrSagaConflict
+	}
+
+	saga.Version++
+	r.sagas[saga.TripID] = cloneSaga(saga)
+	r.Append(outbox...)
 	return nil
 }
+
+func (r *inmemRepository) ExpiredSagas(ctx context.Context, now time.Time, limit int) ([]*domain.TripSagaModel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var expired []*domain.TripSagaModel
+	for _, saga := range r.sagas {
+		if !saga.Done() && !saga.Deadline.IsZero() && !saga.Deadline.After(now) {
+			expired = append(expired, cloneSaga(saga))
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].Deadline.Before(expired[j].Deadline) })
+	if limit > 0 && len(expired) > limit {
+		expired = expired[:limit]
+	}
+	return expired, nil
+}
+
+func (r *inmemRepository) ListTrips(ctx context.Context, filter domain.TripFilter, limit int) ([]*domain.TripModel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var trips []*domain.TripModel
+	for id, trip := range r.trips {
+		if filter.PageToken != "" && id >= filter.PageToken {
+			continue
+		}
+		if filter.Matches(trip) {
+			trips = append(trips, cloneTrip(trip))
+		}
+	}
+	// ObjectID以创建时间开头，按十六进制ID倒序即从新到旧
+	sort.Slice(trips, func(i, j int) bool { return trips[i].ID.Hex() > trips[j].ID.Hex() })
+	if limit > 0 && len(trips) > limit {
+		trips = trips[:limit]
+	}
+	return trips, nil
+}
+
+// cloneTrip 复制行程，调用方修改返回值不会影响存储的状态
+func cloneTrip(trip *domain.TripModel) *domain.TripModel {
+	c := *trip
+	return &c
+}
+
+// cloneSaga 复制Saga，调用方修改返回值不会影响存储的状态
+func cloneSaga(saga *domain.TripSagaModel) *domain.TripSagaModel {
+	c := *saga
+	c.History = append([]domain.SagaTransition(nil), saga.History...)
+	return &c
+}
@@ -0,0 +1,308 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"ride-sharing/services/trip-service/internal/domain"
+	"ride-sharing/shared/types"
+)
+
+const (
+	// tripHistorySize 每个行程为续传保留的最近更新数量
+	tripHistorySize = 32
+	// watcherBuffer 每个订阅者的更新缓冲，缓冲已满时断开订阅者，位置更新则直接丢弃
+	watcherBuffer = 64
+	// feedRetention 订阅者全部离开后为续传保留订阅源的时间
+	feedRetention = 5 * time.Minute
+)
+
+// tripHub 将行程的每个新版本推送给订阅者，并为续传保留最近的更新
+// 只有被订阅过的行程才有订阅源，订阅者全部离开retention之后删除
+// 存储库在持有自己的锁时调用publish和watch，保证快照与后续更新之间不会遗漏版本
+type tripHub struct {
+	mu     sync.Mutex
+	feeds  map[string]*tripFeed
+	closed bool
+	// retention 订阅者全部离开后保留订阅源的时间
+	retention time.Duration
+}
+
+// tripFeed 一个行程的订阅者和最近的更新
+type tripFeed struct {
+	current  *domain.TripModel
+	history  []domain.TripUpdate
+	watchers map[*tripWatcher]struct{}
+	// idle 订阅者全部离开后删除订阅源的定时器
+	idle *time.Timer
+}
+
+func newTripHub() *tripHub {
+	return &tripHub{feeds: make(map[string]*tripFeed), retention: feedRetention}
+}
+
+// feed 返回行程的订阅源，不存在时创建，已有的订阅源不再过期
+func (h *tripHub) feed(tripID string) *tripFeed {
+	feed, ok := h.feeds[tripID]
+	if !ok {
+		feed = &tripFeed{watchers: make(map[*tripWatcher]struct{})}
+		h.feeds[tripID] = feed
+	}
+	if feed.idle != nil {
+		feed.idle.Stop()
+		feed.idle = nil
+	}
+	return feed
+}
+
+// remove 移除并结束订阅者，最后一个订阅者离开后订阅源在retention之后删除，调用方持有锁
+func (h *tripHub) remove(tripID string, feed *tripFeed, w *tripWatcher, err error) {
+	delete(feed.watchers, w)
+	w.close(err)
+	if len(feed.watchers) > 0 || feed.idle != nil {
+		return
+	}
+	feed.idle = time.AfterFunc(h.retention, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.feeds[tripID] == feed && len(feed.watchers) == 0 {
+			delete(h.feeds, tripID)
+		}
+	})
+}
+
+// watch 注册订阅者并放入快照或需要重放的更新，行程已结束时发送后立即结束订阅
+func (h *tripHub) watch(current *domain.TripModel, fromVersion int) (*tripWatcher, error) {
+	if fromVersion < 0 || fromVersion > current.Version {
+		return nil, fmt.Errorf("%w: 版本 %d 超出行程的版本范围 0-%d", domain.ErrInvalidTripRequest, fromVersion, current.Version)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, domain.ErrTripWatchClosed
+	}
+	tripID := current.ID.Hex()
+	w := newTripWatcher()
+	if !h.replay(w, tripID, current, fromVersion) {
+		w.updates <- domain.TripUpdate{Kind: domain.TripUpdateSnapshot, Version: current.Version, Trip: cloneTrip(current)}
+	}
+
+	if current.Status.Final() {
+		w.close(nil)
+		return w, nil
+	}
+
+	feed := h.feed(tripID)
+	feed.current = cloneTrip(current)
+	feed.watchers[w] = struct{}{}
+	return w, nil
+}
+
+// replay 放入fromVersion之后的更新，更新已不在缓冲中时返回false
+func (h *tripHub) replay(w *tripWatcher, tripID string, current *domain.TripModel, fromVersion int) bool {
+	if fromVersion == 0 {
+		return false
+	}
+	if fromVersion == current.Version {
+		return true
+	}
+
+	feed, ok := h.feeds[tripID]
+	if !ok || len(feed.history) == 0 || feed.history[0].Version > fromVersion+1 {
+		return false
+	}
+	for _, update := range feed.history {
+		if update.Version > fromVersion {
+			w.updates <- update
+		}
+	}
+	return true
+}
+
+// publish 推送行程的新版本，行程结束后结束全部订阅并丢弃缓冲；没有订阅源的行程不保留更新
+func (h *tripHub) publish(previous, current *domain.TripModel) {
+	kind := domain.TripUpdateSnapshot
+	switch {
+	case previous.Status != current.Status:
+		kind = domain.TripUpdateStatus
+	case driverID(previous) != driverID(current):
+		kind = domain.TripUpdateDriver
+	}
+	update := domain.TripUpdate{Kind: kind, Version: current.Version, Trip: cloneTrip(current)}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	tripID := current.ID.Hex()
+	if current.Status.Final() {
+		if feed, ok := h.feeds[tripID]; ok {
+			for w := range feed.watchers {
+				if w.send(update) {
+					w.close(nil)
+				} else {
+					w.close(domain.ErrTripWatchLagged)
+				}
+			}
+			if feed.idle != nil {
+				feed.idle.Stop()
+			}
+			delete(h.feeds, tripID)
+		}
+		return
+	}
+
+	feed, ok := h.feeds[tripID]
+	if !ok {
+		return
+	}
+	feed.current = update.Trip
+	feed.history = append(feed.history, update)
+	if len(feed.history) > tripHistorySize {
+		feed.history = feed.history[len(feed.history)-tripHistorySize:]
+	}
+	for w := range feed.watchers {
+		if !w.send(update) {
+			h.remove(tripID, feed, w, domain.ErrTripWatchLagged)
+		}
+	}
+}
+
+// publishLocation 将司机位置推送给该司机进行中行程的订阅者，缓冲已满的订阅者跳过这次位置
+func (h *tripHub) publishLocation(driverID string, location types.Coordinate) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, feed := range h.feeds {
+		trip := feed.current
+		if len(feed.watchers) == 0 || trip == nil || trip.Driver == nil || trip.Driver.Id != driverID || !tracksDriver(trip.Status) {
+			continue
+		}
+
+		loc := location
+		update := domain.TripUpdate{Kind: domain.TripUpdateLocation, Version: trip.Version, Trip: trip, Location: &loc}
+		for w := range feed.watchers {
+			w.send(update)
+		}
+	}
+}
+
+// cancel 结束订阅，订阅已结束时不做处理
+func (h *tripHub) cancel(tripID string, w *tripWatcher, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	feed, ok := h.feeds[tripID]
+	if !ok {
+		return
+	}
+	if _, ok := feed.watchers[w]; !ok {
+		return
+	}
+	h.remove(tripID, feed, w, err)
+}
+
+// close 结束全部订阅并拒绝新的订阅，服务关闭时调用
+func (h *tripHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for tripID, feed := range h.feeds {
+		for w := range feed.watchers {
+			w.close(domain.ErrTripWatchClosed)
+		}
+		if feed.idle != nil {
+			feed.idle.Stop()
+		}
+		delete(h.feeds, tripID)
+	}
+}
+
+// tracksDriver 行程在该状态下是否推送司机位置
+func tracksDriver(status domain.TripStatus) bool {
+	switch status {
+	case domain.TripStatusDriverAssigned, domain.TripStatusPaid, domain.TripStatusDriverArrived, domain.TripStatusInProgress:
+		return true
+	}
+	return false
+}
+
+func driverID(trip *domain.TripModel) string {
+	if trip.Driver == nil {
+		return ""
+	}
+	return trip.Driver.Id
+}
+
+// tripWatcher 一个订阅者，由tripHub在持有锁时发送和关闭
+type tripWatcher struct {
+	updates chan domain.TripUpdate
+	closed  chan struct{}
+	err     error
+}
+
+func newTripWatcher() *tripWatcher {
+	return &tripWatcher{
+		updates: make(chan domain.TripUpdate, watcherBuffer),
+		closed:  make(chan struct{}),
+	}
+}
+
+func (w *tripWatcher) Updates() <-chan domain.TripUpdate { return w.updates }
+
+// Err 在Updates关闭之后返回订阅结束的原因
+func (w *tripWatcher) Err() error { return w.err }
+
+// send 不阻塞地发送更新，缓冲已满时返回false
+func (w *tripWatcher) send(update domain.TripUpdate) bool {
+	select {
+	case w.updates <- update:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *tripWatcher) close(err error) {
+	w.err = err
+	close(w.updates)
+	close(w.closed)
+}
+
+// WatchTrip 订阅行程的更新，ctx取消时结束订阅
+func (r *inmemRepository) WatchTrip(ctx context.Context, tripID string, fromVersion int) (domain.TripWatch, error) {
+	r.mu.RLock()
+	trip, ok := r.trips[tripID]
+	if !ok {
+		r.mu.RUnlock()
+		return nil, domain.ErrTripNotFound
+	}
+	w, err := r.hub.watch(trip, fromVersion)
+	r.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			r.hub.cancel(tripID, w, ctx.Err())
+		case <-w.closed:
+		}
+	}()
+	return w, nil
+}
+
+// PublishDriverLocation 将司机位置推送给订阅者
+func (r *inmemRepository) PublishDriverLocation(driverID string, location types.Coordinate) {
+	r.hub.publishLocation(driverID, location)
+}
+
+// CloseWatches 结束全部订阅
+func (r *inmemRepository) CloseWatches() {
+	r.hub.close()
+}
@@ -0,0 +1,294 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"ride-sharing/services/trip-service/internal/domain"
+	pb "ride-sharing/shared/proto/trip"
+	"ride-sharing/shared/types"
+)
+
+// watchFixture 一个已保存的行程和对它的更新操作
+type watchFixture struct {
+	t      *testing.T
+	repo   *inmemRepository
+	tripID string
+}
+
+func newWatchFixture(t *testing.T, status domain.TripStatus) *watchFixture {
+	t.Helper()
+	repo := NewInmemRepository()
+	trip := &domain.TripModel{ID: primitive.NewObjectID(), UserID: "rider-1", Status: status}
+	if _, err := repo.CreateTrip(context.Background(), trip); err != nil {
+		t.Fatalf("CreateTrip: %v", err)
+	}
+	return &watchFixture{t: t, repo: repo, tripID: trip.ID.Hex()}
+}
+
+// update 修改并保存行程，返回保存后的版本
+func (f *watchFixture) update(change func(trip *domain.TripModel)) int {
+	f.t.Helper()
+	trip, err := f.repo.GetTripByID(context.Background(), f.tripID)
+	if err != nil {
+		f.t.Fatalf("GetTripByID: %v", err)
+	}
+	if change != nil {
+		change(trip)
+	}
+	if err := f.repo.UpdateTrip(context.Background(), trip); err != nil {
+		f.t.Fatalf("UpdateTrip: %v", err)
+	}
+	return trip.Version
+}
+
+// touch 保存n次不改变状态和司机的更新，返回最后的版本
+func (f *watchFixture) touch(n int) int {
+	f.t.Helper()
+	var version int
+	for i := 0; i < n; i++ {
+		version = f.update(nil)
+	}
+	return version
+}
+
+func (f *watchFixture) watch(ctx context.Context, fromVersion int) domain.TripWatch {
+	f.t.Helper()
+	w, err := f.repo.WatchTrip(ctx, f.tripID, fromVersion)
+	if err != nil {
+		f.t.Fatalf("WatchTrip(%d): %v", fromVersion, err)
+	}
+	return w
+}
+
+// hasFeed 行程的订阅源是否仍然保留
+func (f *watchFixture) hasFeed() bool {
+	f.repo.hub.mu.Lock()
+	defer f.repo.hub.mu.Unlock()
+	_, ok := f.repo.hub.feeds[f.tripID]
+	return ok
+}
+
+// receive 读取n个已缓冲的更新
+func receive(t *testing.T, w domain.TripWatch, n int) []domain.TripUpdate {
+	t.Helper()
+	var updates []domain.TripUpdate
+	for len(updates) < n {
+		select {
+		case update, ok := <-w.Updates():
+			if !ok {
+				t.Fatalf("watch ended after %d updates, want %d: %v", len(updates), n, w.Err())
+			}
+			updates = append(updates, update)
+		case <-time.After(time.Second):
+			t.Fatalf("got %d updates, want %d", len(updates), n)
+		}
+	}
+	return updates
+}
+
+// waitClosed 读完剩余的更新直到订阅结束，返回读到的更新数量
+func waitClosed(t *testing.T, w domain.TripWatch) int {
+	t.Helper()
+	count := 0
+	for {
+		select {
+		case _, ok := <-w.Updates():
+			if !ok {
+				return count
+			}
+			count++
+		case <-time.After(time.Second):
+			t.Fatal("watch was not closed")
+		}
+	}
+}
+
+func TestWatchTripResume(t *testing.T) {
+	tests := []struct {
+		name string
+		// missed 订阅者断开期间保存的版本数量
+		missed   int
+		snapshot bool
+	}{
+		{name: "resume inside history", missed: 5},
+		{name: "resume at the oldest buffered version", missed: tripHistorySize},
+		{name: "resume outside history", missed: tripHistorySize + 1, snapshot: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newWatchFixture(t, domain.TripStatusSearching)
+			ctx, cancel := context.WithCancel(context.Background())
+			w := f.watch(ctx, 0)
+			if got := receive(t, w, 1)[0]; got.Kind != domain.TripUpdateSnapshot || got.Version != 1 {
+				t.Fatalf("got first update %s v%d, want snapshot v1", got.Kind, got.Version)
+			}
+			from := f.touch(1)
+			receive(t, w, 1)
+			cancel()
+			waitClosed(t, w)
+
+			last := f.touch(tt.missed)
+			resumed := f.watch(context.Background(), from)
+			if tt.snapshot {
+				got := receive(t, resumed, 1)[0]
+				if got.Kind != domain.TripUpdateSnapshot || got.Version != last {
+					t.Fatalf("got %s v%d, want snapshot v%d", got.Kind, got.Version, last)
+				}
+				return
+			}
+			for i, got := range receive(t, resumed, tt.missed) {
+				if got.Version != from+i+1 {
+					t.Fatalf("update %d: got v%d, want v%d", i, got.Version, from+i+1)
+				}
+			}
+
+			// 续传之后继续收到新的版本
+			next := f.update(func(trip *domain.TripModel) { trip.Status = domain.TripStatusDriverAssigned })
+			got := receive(t, resumed, 1)[0]
+			if got.Kind != domain.TripUpdateStatus || got.Version != next {
+				t.Errorf("got %s v%d, want status v%d", got.Kind, got.Version, next)
+			}
+		})
+	}
+}
+
+func TestWatchTripCurrentVersion(t *testing.T) {
+	f := newWatchFixture(t, domain.TripStatusSearching)
+	version := f.touch(2)
+
+	// 已收到当前版本的订阅者不再收到快照，只收到后续更新
+	w := f.watch(context.Background(), version)
+	next := f.update(func(trip *domain.TripModel) { trip.Driver = &pb.TripDriver{Id: "driver-1"} })
+	got := receive(t, w, 1)[0]
+	if got.Kind != domain.TripUpdateDriver || got.Version != next {
+		t.Errorf("got %s v%d, want driver v%d", got.Kind, got.Version, next)
+	}
+
+	if _, err := f.repo.WatchTrip(context.Background(), f.tripID, next+1); !errors.Is(err, domain.ErrInvalidTripRequest) {
+		t.Errorf("watch from a future version: got %v, want ErrInvalidTripRequest", err)
+	}
+	if _, err := f.repo.WatchTrip(context.Background(), primitive.NewObjectID().Hex(), 0); !errors.Is(err, domain.ErrTripNotFound) {
+		t.Errorf("watch unknown trip: got %v, want ErrTripNotFound", err)
+	}
+}
+
+func TestWatchTripLagged(t *testing.T) {
+	f := newWatchFixture(t, domain.TripStatusSearching)
+	slow := f.watch(context.Background(), 0)
+	fast := f.watch(context.Background(), 0)
+	receive(t, fast, 1)
+
+	// 慢订阅者的缓冲被快照和后续更新填满后被断开，其他订阅者不受影响
+	for i := 0; i < watcherBuffer; i++ {
+		f.touch(1)
+		receive(t, fast, 1)
+	}
+	if got := waitClosed(t, slow); got != watcherBuffer {
+		t.Errorf("lagged watcher got %d buffered updates, want %d", got, watcherBuffer)
+	}
+	if !errors.Is(slow.Err(), domain.ErrTripWatchLagged) {
+		t.Errorf("got %v, want ErrTripWatchLagged", slow.Err())
+	}
+
+	f.touch(1)
+	receive(t, fast, 1)
+
+	// 位置更新在缓冲已满时直接丢弃，不断开订阅者
+	f.update(func(trip *domain.TripModel) {
+		trip.Status = domain.TripStatusDriverAssigned
+		trip.Driver = &pb.TripDriver{Id: "driver-1"}
+	})
+	receive(t, fast, 1)
+	for i := 0; i < watcherBuffer+1; i++ {
+		f.repo.PublishDriverLocation("driver-1", types.Coordinate{Latitude: float64(i)})
+	}
+	if got := receive(t, fast, watcherBuffer); got[0].Kind != domain.TripUpdateLocation || got[0].Location.Latitude != 0 {
+		t.Errorf("got first location %+v", got[0])
+	}
+	f.touch(1)
+	if got := receive(t, fast, 1)[0]; got.Kind != domain.TripUpdateSnapshot {
+		t.Errorf("got %s after dropped locations, want snapshot", got.Kind)
+	}
+}
+
+func TestWatchTripFinalStatus(t *testing.T) {
+	f := newWatchFixture(t, domain.TripStatusSearching)
+	w := f.watch(context.Background(), 0)
+	receive(t, w, 1)
+
+	final := f.update(func(trip *domain.TripModel) { trip.Status = domain.TripStatusNoDriversFound })
+	got := receive(t, w, 1)[0]
+	if got.Kind != domain.TripUpdateStatus || got.Version != final || got.Trip.Status != domain.TripStatusNoDriversFound {
+		t.Errorf("got %s v%d %q, want final status v%d", got.Kind, got.Version, got.Trip.Status, final)
+	}
+	if n := waitClosed(t, w); n != 0 || w.Err() != nil {
+		t.Errorf("got %d more updates and %v after final status, want a clean close", n, w.Err())
+	}
+	if f.hasFeed() {
+		t.Error("feed of the finished trip is kept")
+	}
+
+	// 订阅已结束的行程只收到快照
+	late := f.watch(context.Background(), 0)
+	if got := receive(t, late, 1)[0]; got.Kind != domain.TripUpdateSnapshot || got.Version != final {
+		t.Errorf("got %s v%d, want snapshot v%d", got.Kind, got.Version, final)
+	}
+	if n := waitClosed(t, late); n != 0 || late.Err() != nil {
+		t.Errorf("got %d more updates and %v, want a clean close", n, late.Err())
+	}
+}
+
+func TestWatchTripFeedExpiry(t *testing.T) {
+	f := newWatchFixture(t, domain.TripStatusSearching)
+	f.repo.hub.retention = 20 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := f.watch(ctx, 0)
+	receive(t, w, 1)
+	from := f.touch(1)
+	receive(t, w, 1)
+	cancel()
+	waitClosed(t, w)
+	if !errors.Is(w.Err(), context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", w.Err())
+	}
+
+	// 订阅者全部离开之后订阅源在保留时间内可以续传，之后删除
+	if !f.hasFeed() {
+		t.Fatal("feed removed before retention")
+	}
+	deadline := time.Now().Add(time.Second)
+	for f.hasFeed() {
+		if time.Now().After(deadline) {
+			t.Fatal("feed was not removed after retention")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// 过期后保存的版本不再保留，续传改为发送快照
+	last := f.touch(1)
+	resumed := f.watch(context.Background(), from)
+	if got := receive(t, resumed, 1)[0]; got.Kind != domain.TripUpdateSnapshot || got.Version != last {
+		t.Errorf("got %s v%d, want snapshot v%d", got.Kind, got.Version, last)
+	}
+}
+
+func TestCloseWatches(t *testing.T) {
+	f := newWatchFixture(t, domain.TripStatusSearching)
+	w := f.watch(context.Background(), 0)
+
+	f.repo.CloseWatches()
+	waitClosed(t, w)
+	if !errors.Is(w.Err(), domain.ErrTripWatchClosed) {
+		t.Errorf("got %v, want ErrTripWatchClosed", w.Err())
+	}
+	if _, err := f.repo.WatchTrip(context.Background(), f.tripID, 0); !errors.Is(err, domain.ErrTripWatchClosed) {
+		t.Errorf("watch after close: got %v, want ErrTripWatchClosed", err)
+	}
+}
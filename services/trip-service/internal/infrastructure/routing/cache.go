@@ -0,0 +1,319 @@
+package routing
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mmcloughlin/geohash"
+
+	"ride-sharing/services/trip-service/internal/domain"
+	tripTypes "ride-sharing/services/trip-service/pkg/types"
+	"ride-sharing/shared/metrics"
+	"ride-sharing/shared/types"
+)
+
+// 路线缓存的指标，标签profile为路线来源，例如 osrm:driving
+var (
+	cacheRequestsTotal = metrics.NewCounterVec("route_cache_requests_total",
+		"Route lookups by result (hit, miss, or coalesced into an in-flight miss).", "profile", "result")
+	cacheEvictionsTotal = metrics.NewCounterVec("route_cache_evictions_total",
+		"Cached routes removed, by reason (capacity or expired).", "profile", "reason")
+	cacheEntries = metrics.NewGaugeVec("route_cache_entries",
+		"Routes currently cached.", "profile")
+)
+
+// 查询结果，作为route_cache_requests_total的result标签
+const (
+	cacheHit       = "hit"
+	cacheMiss      = "miss"
+	cacheCoalesced = "coalesced"
+)
+
+// CacheConfig 路线缓存的配置
+type CacheConfig struct {
+	// Size 最多缓存的路线数量，超出时淘汰最久未使用的路线，0表示不缓存
+	Size int
+	// TTL 路线的有效期，过期后重新请求路线来源
+	TTL time.Duration
+	// Precision 起终点取geohash的字符数，同一格内的起终点共用路线，7约为150米见方
+	Precision uint
+	// File 持久化缓存的文件，服务重启后仍可命中；为空时只缓存在内存中
+	File string
+	// SaveInterval 缓存有变化时写入文件的间隔
+	SaveInterval time.Duration
+}
+
+// DefaultCacheConfig 返回默认的路线缓存配置
+func DefaultCacheConfig() CacheConfig {
+	return CacheConfig{
+		Size:         10000,
+		TTL:          30 * time.Minute,
+		Precision:    7,
+		SaveInterval: time.Minute,
+	}
+}
+
+// cacheEntry 缓存的一条路线，同时是持久化文件中的一项
+type cacheEntry struct {
+	Key       string                     `json:"key"`
+	Route     *tripTypes.OsrmApiResponse `json:"route"`
+	ExpiresAt time.Time                  `json:"expiresAt"`
+}
+
+// routeCall 正在请求路线来源的一次查询，相同的并发查询等待它的结果
+type routeCall struct {
+	done  chan struct{}
+	route *tripTypes.OsrmApiResponse
+	err   error
+}
+
+// routeCache 按吸附到geohash格子的起终点缓存路线，LRU淘汰并按TTL过期
+// 缓存未命中时相同的并发查询只请求一次路线来源；只缓存成功的路线
+type routeCache struct {
+	next    domain.Router
+	profile string
+	cfg     CacheConfig
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// lru 从最近使用到最久未使用排列
+	lru   *list.List
+	calls map[string]*routeCall
+	// dirty 上次写入文件之后缓存是否有变化
+	dirty bool
+	// saveMu 保证同一时间只有一次写入文件，较早的快照不会覆盖较新的
+	saveMu sync.Mutex
+}
+
+// newRouteCache 创建路线缓存，配置了持久化文件时加载其中未过期的路线
+func newRouteCache(next domain.Router, profile string, cfg CacheConfig) (*routeCache, error) {
+	if cfg.Precision < 1 || cfg.Precision > 12 {
+		return nil, fmt.Errorf("路线缓存的geohash精度应为1-12: %d", cfg.Precision)
+	}
+	if cfg.TTL <= 0 {
+		return nil, fmt.Errorf("路线缓存的有效期必须大于0: %v", cfg.TTL)
+	}
+	if cfg.File != "" && cfg.SaveInterval <= 0 {
+		cfg.SaveInterval = DefaultCacheConfig().SaveInterval
+	}
+
+	c := &routeCache{
+		next:    next,
+		profile: profile,
+		cfg:     cfg,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		calls:   make(map[string]*routeCall),
+	}
+	if cfg.File != "" {
+		// 缓存文件损坏不影响启动，只是从空缓存开始
+		if err := c.load(); err != nil {
+			log.Printf("加载路线缓存失败，从空缓存开始: %v", err)
+		}
+	}
+	return c, nil
+}
+
+// Route 返回缓存的路线或请求路线来源，返回的路线由所有查询共用，调用方不能修改
+func (c *routeCache) Route(ctx context.Context, pickup, destination *types.Coordinate) (*tripTypes.OsrmApiResponse, error) {
+	key := c.key(pickup, destination)
+
+	c.mu.Lock()
+	if route, ok := c.get(key, time.Now()); ok {
+		c.mu.Unlock()
+		cacheRequestsTotal.WithLabelValues(c.profile, cacheHit).Inc()
+		return route, nil
+	}
+	call, ok := c.calls[key]
+	if ok {
+		cacheRequestsTotal.WithLabelValues(c.profile, cacheCoalesced).Inc()
+	} else {
+		cacheRequestsTotal.WithLabelValues(c.profile, cacheMiss).Inc()
+		call = &routeCall{done: make(chan struct{})}
+		c.calls[key] = call
+		// 请求不随发起者的ctx取消，等待同一结果的其他查询仍能拿到路线
+		go c.fetch(context.WithoutCancel(ctx), key, call, pickup, destination)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.route, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetch 请求路线来源并缓存成功的路线
+func (c *routeCache) fetch(ctx context.Context, key string, call *routeCall, pickup, destination *types.Coordinate) {
+	call.route, call.err = c.next.Route(ctx, pickup, destination)
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	if call.err == nil {
+		c.put(&cacheEntry{Key: key, Route: call.route, ExpiresAt: time.Now().Add(c.cfg.TTL)})
+	}
+	c.mu.Unlock()
+	close(call.done)
+}
+
+// key 返回起终点吸附到geohash格子后的缓存键
+func (c *routeCache) key(pickup, destination *types.Coordinate) string {
+	return fmt.Sprintf("%s|%s|%s",
+		c.profile,
+		geohash.EncodeWithPrecision(pickup.Latitude, pickup.Longitude, c.cfg.Precision),
+		geohash.EncodeWithPrecision(destination.Latitude, destination.Longitude, c.cfg.Precision),
+	)
+}
+
+// get 返回未过期的路线并标记为最近使用，调用方持有锁
+func (c *routeCache) get(key string, now time.Time) (*tripTypes.OsrmApiResponse, bool) {
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if now.After(entry.ExpiresAt) {
+		c.remove(el, "expired")
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return entry.Route, true
+}
+
+// put 缓存路线，超出容量时淘汰最久未使用的路线，调用方持有锁
+func (c *routeCache) put(entry *cacheEntry) {
+	if el, ok := c.entries[entry.Key]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+	} else {
+		c.entries[entry.Key] = c.lru.PushFront(entry)
+	}
+	for c.lru.Len() > c.cfg.Size {
+		c.remove(c.lru.Back(), "capacity")
+	}
+	c.dirty = true
+	cacheEntries.WithLabelValues(c.profile).Set(float64(c.lru.Len()))
+}
+
+// remove 移除一条路线，调用方持有锁
+func (c *routeCache) remove(el *list.Element, reason string) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).Key)
+	c.dirty = true
+	cacheEvictionsTotal.WithLabelValues(c.profile, reason).Inc()
+	cacheEntries.WithLabelValues(c.profile).Set(float64(c.lru.Len()))
+}
+
+// load 从持久化文件加载未过期的路线，文件不存在时不做处理
+func (c *routeCache) load() error {
+	data, err := os.ReadFile(c.cfg.File)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// 文件中的路线从最近使用到最久未使用排列
+	var entries []*cacheEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("解析路线缓存文件 %s 失败: %w", c.cfg.File, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for _, entry := range entries {
+		if c.lru.Len() >= c.cfg.Size {
+			break
+		}
+		if entry.Route == nil || len(entry.Route.Routes) == 0 || now.After(entry.ExpiresAt) {
+			continue
+		}
+		if _, ok := c.entries[entry.Key]; !ok {
+			c.entries[entry.Key] = c.lru.PushBack(entry)
+		}
+	}
+	cacheEntries.WithLabelValues(c.profile).Set(float64(c.lru.Len()))
+	log.Printf("已加载路线缓存: %d 条", c.lru.Len())
+	return nil
+}
+
+// Save 将未过期的路线写入持久化文件，先写临时文件再替换，缓存没有变化时不做处理
+func (c *routeCache) Save() error {
+	if c.cfg.File == "" {
+		return nil
+	}
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	c.mu.Lock()
+	if !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	entries := make([]*cacheEntry, 0, c.lru.Len())
+	for el := c.lru.Front(); el != nil; el = el.Next() {
+		if entry := el.Value.(*cacheEntry); !now.After(entry.ExpiresAt) {
+			entries = append(entries, entry)
+		}
+	}
+	c.dirty = false
+	c.mu.Unlock()
+
+	if err := writeFileAtomic(c.cfg.File, entries); err != nil {
+		c.mu.Lock()
+		c.dirty = true
+		c.mu.Unlock()
+		return fmt.Errorf("写入路线缓存文件 %s 失败: %w", c.cfg.File, err)
+	}
+	return nil
+}
+
+// Run 周期性将路线缓存写入持久化文件，直到ctx取消；最后一次写入由调用方在关闭时调用Save
+func (c *routeCache) Run(ctx context.Context) {
+	if c.cfg.File == "" {
+		return
+	}
+
+	ticker := time.NewTicker(c.cfg.SaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Save(); err != nil {
+				log.Printf("保存路线缓存失败: %v", err)
+			}
+		}
+	}
+}
+
+// writeFileAtomic 将v编码为JSON写入同目录的临时文件后替换path，写入中断时不会留下不完整的文件
+func writeFileAtomic(path string, v any) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := json.NewEncoder(tmp).Encode(v); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
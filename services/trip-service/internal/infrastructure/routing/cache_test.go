@@ -0,0 +1,332 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	tripTypes "ride-sharing/services/trip-service/pkg/types"
+	"ride-sharing/shared/types"
+)
+
+// countingRouter 记录被请求的次数，release不为nil时阻塞到它关闭
+type countingRouter struct {
+	release chan struct{}
+	err     error
+
+	mu    sync.Mutex
+	calls int
+	// cancelled 请求结束时ctx已被取消的次数
+	cancelled int
+}
+
+func (r *countingRouter) Route(ctx context.Context, pickup, destination *types.Coordinate) (*tripTypes.OsrmApiResponse, error) {
+	r.mu.Lock()
+	r.calls++
+	r.mu.Unlock()
+
+	if r.release != nil {
+		<-r.release
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ctx.Err() != nil {
+		r.cancelled++
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return tripTypes.NewRouteResponse(1000, 60, nil), nil
+}
+
+func (r *countingRouter) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls
+}
+
+// 测试用的起终点，彼此不在同一个geohash格子内
+var (
+	placeA = &types.Coordinate{Latitude: 52.5200, Longitude: 13.4000}
+	placeB = &types.Coordinate{Latitude: 52.5300, Longitude: 13.4100}
+	placeC = &types.Coordinate{Latitude: 52.5400, Longitude: 13.4200}
+	placeD = &types.Coordinate{Latitude: 52.5500, Longitude: 13.4300}
+)
+
+func newTestCache(t *testing.T, next *countingRouter, cfg CacheConfig) *routeCache {
+	t.Helper()
+	if cfg.TTL == 0 {
+		cfg.TTL = time.Hour
+	}
+	if cfg.Precision == 0 {
+		cfg.Precision = 7
+	}
+	// 每个测试使用自己的profile，指标互不影响
+	c, err := newRouteCache(next, t.Name(), cfg)
+	if err != nil {
+		t.Fatalf("newRouteCache: %v", err)
+	}
+	return c
+}
+
+// route 查询路线，返回时请求路线来源的累计次数
+func route(t *testing.T, c *routeCache, next *countingRouter, pickup, destination *types.Coordinate) int {
+	t.Helper()
+	got, err := c.Route(context.Background(), pickup, destination)
+	if err != nil {
+		t.Fatalf("Route: %v", err)
+	}
+	if len(got.Routes) != 1 {
+		t.Fatalf("got %d routes, want 1", len(got.Routes))
+	}
+	return next.count()
+}
+
+// expire 将缓存的路线标记为已过期
+func expire(c *routeCache, pickup, destination *types.Coordinate) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[c.key(pickup, destination)].Value.(*cacheEntry).ExpiresAt = time.Now().Add(-time.Second)
+}
+
+func requests(c *routeCache, result string) float64 {
+	return cacheRequestsTotal.WithLabelValues(c.profile, result).Value()
+}
+
+func evictions(c *routeCache, reason string) float64 {
+	return cacheEvictionsTotal.WithLabelValues(c.profile, reason).Value()
+}
+
+func entries(c *routeCache) float64 {
+	return cacheEntries.WithLabelValues(c.profile).Value()
+}
+
+func TestRouteCacheSnapsToGeohash(t *testing.T) {
+	next := &countingRouter{}
+	c := newTestCache(t, next, CacheConfig{Size: 10})
+
+	route(t, c, next, placeA, placeB)
+	nearby := &types.Coordinate{Latitude: placeA.Latitude + 0.00001, Longitude: placeA.Longitude + 0.00001}
+	if calls := route(t, c, next, nearby, placeB); calls != 1 {
+		t.Errorf("nearby pickup: got %d router calls, want 1", calls)
+	}
+	// 起终点互换是另一条路线
+	if calls := route(t, c, next, placeB, placeA); calls != 2 {
+		t.Errorf("reversed route: got %d router calls, want 2", calls)
+	}
+	if hits, misses := requests(c, cacheHit), requests(c, cacheMiss); hits != 1 || misses != 2 {
+		t.Errorf("got %v hits and %v misses, want 1 and 2", hits, misses)
+	}
+}
+
+func TestRouteCacheEviction(t *testing.T) {
+	next := &countingRouter{}
+	c := newTestCache(t, next, CacheConfig{Size: 2})
+
+	route(t, c, next, placeA, placeB)
+	route(t, c, next, placeB, placeC)
+	// 读取A-B使其成为最近使用，写入C-D时淘汰B-C
+	route(t, c, next, placeA, placeB)
+	route(t, c, next, placeC, placeD)
+	if got := evictions(c, "capacity"); got != 1 {
+		t.Errorf("got %v capacity evictions, want 1", got)
+	}
+	if got := entries(c); got != 2 {
+		t.Errorf("got %v cached entries, want 2", got)
+	}
+
+	if calls := route(t, c, next, placeA, placeB); calls != 3 {
+		t.Errorf("recently used route: got %d router calls, want 3", calls)
+	}
+	if calls := route(t, c, next, placeB, placeC); calls != 4 {
+		t.Errorf("evicted route: got %d router calls, want 4", calls)
+	}
+
+	// 过期的路线重新请求路线来源
+	expire(c, placeB, placeC)
+	if calls := route(t, c, next, placeB, placeC); calls != 5 {
+		t.Errorf("expired route: got %d router calls, want 5", calls)
+	}
+	if got := evictions(c, "expired"); got != 1 {
+		t.Errorf("got %v expired evictions, want 1", got)
+	}
+	if hits, misses := requests(c, cacheHit), requests(c, cacheMiss); hits != 2 || misses != 5 {
+		t.Errorf("got %v hits and %v misses, want 2 and 5", hits, misses)
+	}
+}
+
+func TestRouteCacheErrorsNotCached(t *testing.T) {
+	errRouter := errors.New("router unavailable")
+	next := &countingRouter{err: errRouter}
+	c := newTestCache(t, next, CacheConfig{Size: 10})
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Route(context.Background(), placeA, placeB); !errors.Is(err, errRouter) {
+			t.Fatalf("got %v, want %v", err, errRouter)
+		}
+	}
+	if calls := next.count(); calls != 2 {
+		t.Errorf("got %d router calls, want 2", calls)
+	}
+	if got := entries(c); got != 0 {
+		t.Errorf("got %v cached entries, want 0", got)
+	}
+}
+
+// waitFor 等待cond成立
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRouteCacheCoalescing(t *testing.T) {
+	next := &countingRouter{release: make(chan struct{})}
+	c := newTestCache(t, next, CacheConfig{Size: 10})
+
+	// 第一个查询请求路线来源，其余相同的查询等待它的结果
+	const callers = 5
+	results := make(chan *tripTypes.OsrmApiResponse, callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			got, err := c.Route(context.Background(), placeA, placeB)
+			if err != nil {
+				t.Errorf("Route: %v", err)
+			}
+			results <- got
+		}()
+	}
+	waitFor(t, "callers to coalesce", func() bool { return requests(c, cacheCoalesced) == callers-1 })
+	close(next.release)
+
+	first := <-results
+	for i := 1; i < callers; i++ {
+		if got := <-results; got != first {
+			t.Errorf("caller %d got a different route", i)
+		}
+	}
+	if calls := next.count(); calls != 1 {
+		t.Errorf("got %d router calls, want 1", calls)
+	}
+	if got := requests(c, cacheMiss); got != 1 {
+		t.Errorf("got %v misses, want 1", got)
+	}
+}
+
+func TestRouteCacheCallerCancel(t *testing.T) {
+	next := &countingRouter{release: make(chan struct{})}
+	c := newTestCache(t, next, CacheConfig{Size: 10})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := c.Route(ctx, placeA, placeB)
+		first <- err
+	}()
+	waitFor(t, "the router call", func() bool { return next.count() == 1 })
+
+	waiter := make(chan error, 1)
+	go func() {
+		_, err := c.Route(context.Background(), placeA, placeB)
+		waiter <- err
+	}()
+	waitFor(t, "the second caller to coalesce", func() bool { return requests(c, cacheCoalesced) == 1 })
+
+	// 发起者取消后立即返回，请求路线来源不随之取消，等待的查询仍拿到路线
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled caller: got %v, want context.Canceled", err)
+	}
+	close(next.release)
+	if err := <-waiter; err != nil {
+		t.Fatalf("waiting caller: %v", err)
+	}
+	next.mu.Lock()
+	cancelled := next.cancelled
+	next.mu.Unlock()
+	if cancelled != 0 {
+		t.Error("router call was cancelled with the first caller")
+	}
+
+	if calls := route(t, c, next, placeA, placeB); calls != 1 {
+		t.Errorf("got %d router calls after the fetch finished, want 1", calls)
+	}
+}
+
+func TestRouteCachePersistence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "routes.json")
+	next := &countingRouter{}
+	c := newTestCache(t, next, CacheConfig{Size: 10, File: file})
+
+	route(t, c, next, placeA, placeB)
+	route(t, c, next, placeB, placeC)
+	route(t, c, next, placeC, placeD)
+	route(t, c, next, placeA, placeB)
+	expire(c, placeB, placeC)
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// 没有变化时不重写文件
+	if err := os.Remove(file); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save without changes: %v", err)
+	}
+	if _, err := os.Stat(file); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("unchanged cache was written again: %v", err)
+	}
+	route(t, c, next, placeD, placeA)
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// 重启后按最近使用的顺序加载未过期的路线，超出容量的旧路线不加载
+	reloaded := &countingRouter{}
+	restarted := newTestCache(t, reloaded, CacheConfig{Size: 2, File: file})
+	if got := entries(restarted); got != 2 {
+		t.Fatalf("got %v entries after reload, want 2", got)
+	}
+	if calls := route(t, restarted, reloaded, placeD, placeA); calls != 0 {
+		t.Errorf("most recent route: got %d router calls, want 0", calls)
+	}
+	if calls := route(t, restarted, reloaded, placeA, placeB); calls != 0 {
+		t.Errorf("second most recent route: got %d router calls, want 0", calls)
+	}
+	if calls := route(t, restarted, reloaded, placeC, placeD); calls != 1 {
+		t.Errorf("route beyond capacity: got %d router calls, want 1", calls)
+	}
+	if calls := route(t, restarted, reloaded, placeB, placeC); calls != 2 {
+		t.Errorf("expired route: got %d router calls, want 2", calls)
+	}
+}
+
+func TestRouteCacheCorruptFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "routes.json")
+	if err := os.WriteFile(file, []byte(`[{"key":`), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	// 缓存文件损坏时从空缓存开始，之后的写入覆盖损坏的文件
+	next := &countingRouter{}
+	c := newTestCache(t, next, CacheConfig{Size: 10, File: file})
+	route(t, c, next, placeA, placeB)
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	restarted := newTestCache(t, &countingRouter{}, CacheConfig{Size: 10, File: file})
+	if got := entries(restarted); got != 1 {
+		t.Errorf("got %v entries after reload, want 1", got)
+	}
+}
@@ -0,0 +1,101 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	tripTypes "ride-sharing/services/trip-service/pkg/types"
+	"ride-sharing/shared/types"
+)
+
+// 估算路线的距离算法
+const (
+	// MetricGreatCircle 两点之间的大圆距离乘以绕行系数
+	MetricGreatCircle = "great_circle"
+	// MetricManhattan 先南北后东西的折线距离，适合棋盘式路网
+	MetricManhattan = "manhattan"
+)
+
+// earthRadius 地球平均半径，单位为米
+const earthRadius = 6371000.0
+
+// EstimatorConfig 估算路线的配置
+type EstimatorConfig struct {
+	Metric string
+	// SpeedKmh 平均行驶速度，单位为千米每小时
+	SpeedKmh float64
+	// DetourFactor 大圆距离的绕行系数，实际道路通常比直线长三成左右
+	DetourFactor float64
+}
+
+// DefaultEstimatorConfig 返回默认的估算配置
+func DefaultEstimatorConfig() EstimatorConfig {
+	return EstimatorConfig{
+		Metric:       MetricGreatCircle,
+		SpeedKmh:     30,
+		DetourFactor: 1.3,
+	}
+}
+
+// estimator 不依赖路网，按直线或折线距离和平均速度估算路线，总能返回结果
+type estimator struct {
+	cfg EstimatorConfig
+}
+
+// NewEstimator 创建估算路线的路线来源
+func NewEstimator(cfg EstimatorConfig) (*estimator, error) {
+	if cfg.Metric != MetricGreatCircle && cfg.Metric != MetricManhattan {
+		return nil, fmt.Errorf("不支持的估算距离算法: %s", cfg.Metric)
+	}
+	if cfg.SpeedKmh <= 0 {
+		return nil, fmt.Errorf("平均行驶速度必须大于0: %v", cfg.SpeedKmh)
+	}
+	if cfg.DetourFactor < 1 {
+		cfg.DetourFactor = 1
+	}
+	return &estimator{cfg: cfg}, nil
+}
+
+func (e *estimator) Route(ctx context.Context, pickup, destination *types.Coordinate) (*tripTypes.OsrmApiResponse, error) {
+	var (
+		distance float64
+		path     []types.Coordinate
+	)
+	switch e.cfg.Metric {
+	case MetricManhattan:
+		corner := types.Coordinate{Latitude: destination.Latitude, Longitude: pickup.Longitude}
+		distance = haversine(*pickup, corner) + haversine(corner, *destination)
+		path = []types.Coordinate{*pickup, corner, *destination}
+	default:
+		distance = haversine(*pickup, *destination) * e.cfg.DetourFactor
+		path = []types.Coordinate{*pickup, *destination}
+	}
+
+	return tripTypes.NewRouteResponse(distance, travelTime(distance, e.cfg.SpeedKmh), geoJSON(path)), nil
+}
+
+// haversine 返回两点之间的大圆距离，单位为米
+func haversine(a, b types.Coordinate) float64 {
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// travelTime 返回按速度行驶距离所需的秒数
+func travelTime(distance, speedKmh float64) float64 {
+	return distance / (speedKmh / 3.6)
+}
+
+// geoJSON 将坐标转换为GeoJSON顺序的[经度, 纬度]，与OSRM的返回一致
+func geoJSON(path []types.Coordinate) [][]float64 {
+	coordinates := make([][]float64, len(path))
+	for i, c := range path {
+		coordinates[i] = []float64{c.Longitude, c.Latitude}
+	}
+	return coordinates
+}
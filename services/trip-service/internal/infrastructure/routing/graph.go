@@ -0,0 +1,300 @@
+package routing
+
+import (
+	"container/heap"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"ride-sharing/services/trip-service/internal/domain"
+	tripTypes "ride-sharing/services/trip-service/pkg/types"
+	"ride-sharing/shared/types"
+)
+
+// snapSpeedKmh 上车点和目的地到最近路网节点这一段按低速估算
+const snapSpeedKmh = 15
+
+// highwaySpeeds 可通行的OSM道路类型及没有maxspeed标签时的默认速度，单位为千米每小时
+var highwaySpeeds = map[string]float64{
+	"motorway":       100,
+	"motorway_link":  60,
+	"trunk":          80,
+	"trunk_link":     50,
+	"primary":        60,
+	"primary_link":   40,
+	"secondary":      50,
+	"secondary_link": 40,
+	"tertiary":       40,
+	"tertiary_link":  30,
+	"unclassified":   30,
+	"residential":    25,
+	"living_street":  10,
+	"service":        15,
+}
+
+// GraphConfig 本地路网的配置
+type GraphConfig struct {
+	// File Overpass API导出的JSON文件，例如查询
+	// [out:json]; way["highway"](bbox); out body; >; out skel qt;
+	File string
+	// MaxSnapDistance 坐标到最近路网节点的最大距离，单位为米，超出时视为不在路网覆盖范围内
+	MaxSnapDistance float64
+}
+
+// DefaultGraphConfig 返回默认的本地路网配置
+func DefaultGraphConfig() GraphConfig {
+	return GraphConfig{MaxSnapDistance: 500}
+}
+
+// osmFile Overpass API的JSON输出，只使用其中的节点和道路
+type osmFile struct {
+	Elements []osmElement `json:"elements"`
+}
+
+type osmElement struct {
+	Type  string            `json:"type"`
+	ID    int64             `json:"id"`
+	Lat   float64           `json:"lat"`
+	Lon   float64           `json:"lon"`
+	Nodes []int64           `json:"nodes"`
+	Tags  map[string]string `json:"tags"`
+}
+
+type graphNode struct {
+	coord types.Coordinate
+	edges []graphEdge
+}
+
+type graphEdge struct {
+	to       int
+	distance float64
+	duration float64
+}
+
+// graphRouter 在内存中的小型路网上按行驶时间计算最短路线，不依赖外部服务
+type graphRouter struct {
+	nodes   []graphNode
+	maxSnap float64
+}
+
+// NewGraphRouter 加载路网文件并创建本地路线来源
+func NewGraphRouter(cfg GraphConfig) (*graphRouter, error) {
+	if cfg.File == "" {
+		return nil, errors.New("缺少路网文件")
+	}
+	f, err := os.Open(cfg.File)
+	if err != nil {
+		return nil, fmt.Errorf("打开路网文件失败: %w", err)
+	}
+	defer f.Close()
+
+	nodes, err := loadGraph(f)
+	if err != nil {
+		return nil, fmt.Errorf("加载路网文件 %s 失败: %w", cfg.File, err)
+	}
+	return &graphRouter{nodes: nodes, maxSnap: cfg.MaxSnapDistance}, nil
+}
+
+// loadGraph 将OSM道路按相邻节点拆分为路段，只保留道路引用的节点
+func loadGraph(r io.Reader) ([]graphNode, error) {
+	var file osmFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("解析路网失败: %w", err)
+	}
+
+	coords := make(map[int64]types.Coordinate)
+	for _, el := range file.Elements {
+		if el.Type == "node" {
+			coords[el.ID] = types.Coordinate{Latitude: el.Lat, Longitude: el.Lon}
+		}
+	}
+
+	var nodes []graphNode
+	index := make(map[int64]int)
+	nodeIndex := func(id int64) (int, bool) {
+		if i, ok := index[id]; ok {
+			return i, true
+		}
+		coord, ok := coords[id]
+		if !ok {
+			return 0, false
+		}
+		index[id] = len(nodes)
+		nodes = append(nodes, graphNode{coord: coord})
+		return len(nodes) - 1, true
+	}
+
+	for _, el := range file.Elements {
+		if el.Type != "way" {
+			continue
+		}
+		speed, ok := highwaySpeeds[el.Tags["highway"]]
+		if !ok {
+			continue
+		}
+		if maxspeed, ok := parseMaxspeed(el.Tags["maxspeed"]); ok {
+			speed = maxspeed
+		}
+		forward, backward := wayDirections(el.Tags)
+
+		for i := 1; i < len(el.Nodes); i++ {
+			from, ok := nodeIndex(el.Nodes[i-1])
+			if !ok {
+				return nil, fmt.Errorf("道路 %d 引用了不存在的节点 %d", el.ID, el.Nodes[i-1])
+			}
+			to, ok := nodeIndex(el.Nodes[i])
+			if !ok {
+				return nil, fmt.Errorf("道路 %d 引用了不存在的节点 %d", el.ID, el.Nodes[i])
+			}
+
+			distance := haversine(nodes[from].coord, nodes[to].coord)
+			duration := travelTime(distance, speed)
+			if forward {
+				nodes[from].edges = append(nodes[from].edges, graphEdge{to: to, distance: distance, duration: duration})
+			}
+			if backward {
+				nodes[to].edges = append(nodes[to].edges, graphEdge{to: from, distance: distance, duration: duration})
+			}
+		}
+	}
+
+	if len(nodes) == 0 {
+		return nil, errors.New("路网中没有可通行的道路")
+	}
+	return nodes, nil
+}
+
+// wayDirections 按oneway标签返回道路能否沿节点顺序和逆序通行
+func wayDirections(tags map[string]string) (forward, backward bool) {
+	switch tags["oneway"] {
+	case "yes", "true", "1":
+		return true, false
+	case "-1", "reverse":
+		return false, true
+	case "no", "false", "0":
+		return true, true
+	}
+	// 高速公路和环岛默认单向
+	if tags["highway"] == "motorway" || tags["junction"] == "roundabout" {
+		return true, false
+	}
+	return true, true
+}
+
+// parseMaxspeed 解析maxspeed标签，例如 "50" 或 "25 mph"，返回千米每小时
+func parseMaxspeed(value string) (float64, bool) {
+	value = strings.TrimSpace(value)
+	factor := 1.0
+	if v, ok := strings.CutSuffix(value, "mph"); ok {
+		value, factor = strings.TrimSpace(v), 1.609344
+	}
+	speed, err := strconv.ParseFloat(value, 64)
+	if err != nil || speed <= 0 {
+		return 0, false
+	}
+	return speed * factor, true
+}
+
+func (g *graphRouter) Route(ctx context.Context, pickup, destination *types.Coordinate) (*tripTypes.OsrmApiResponse, error) {
+	start, startSnap := g.nearest(*pickup)
+	end, endSnap := g.nearest(*destination)
+	if startSnap > g.maxSnap || endSnap > g.maxSnap {
+		return nil, fmt.Errorf("%w: 坐标距离路网超过 %.0f 米", domain.ErrNoRoute, g.maxSnap)
+	}
+
+	path, distance, duration, ok := g.shortestPath(start, end)
+	if !ok {
+		return nil, fmt.Errorf("%w: 路网中两点之间不连通", domain.ErrNoRoute)
+	}
+
+	coords := make([]types.Coordinate, 0, len(path)+2)
+	coords = append(coords, *pickup)
+	for _, i := range path {
+		coords = append(coords, g.nodes[i].coord)
+	}
+	coords = append(coords, *destination)
+
+	distance += startSnap + endSnap
+	duration += travelTime(startSnap+endSnap, snapSpeedKmh)
+	return tripTypes.NewRouteResponse(distance, duration, geoJSON(coords)), nil
+}
+
+// nearest 返回距离坐标最近的节点及距离，路网较小，逐个比较即可
+func (g *graphRouter) nearest(c types.Coordinate) (int, float64) {
+	best, bestDistance := 0, math.Inf(1)
+	for i, node := range g.nodes {
+		if d := haversine(c, node.coord); d < bestDistance {
+			best, bestDistance = i, d
+		}
+	}
+	return best, bestDistance
+}
+
+// shortestPath 按行驶时间计算最短路径（Dijkstra），返回途经的节点、距离和时长
+func (g *graphRouter) shortestPath(start, end int) ([]int, float64, float64, bool) {
+	durations := make([]float64, len(g.nodes))
+	distances := make([]float64, len(g.nodes))
+	prev := make([]int, len(g.nodes))
+	for i := range durations {
+		durations[i] = math.Inf(1)
+		prev[i] = -1
+	}
+	durations[start] = 0
+
+	queue := &nodeQueue{{node: start}}
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(nodeItem)
+		if item.duration > durations[item.node] {
+			continue
+		}
+		if item.node == end {
+			break
+		}
+		for _, edge := range g.nodes[item.node].edges {
+			duration := durations[item.node] + edge.duration
+			if duration < durations[edge.to] {
+				durations[edge.to] = duration
+				distances[edge.to] = distances[item.node] + edge.distance
+				prev[edge.to] = item.node
+				heap.Push(queue, nodeItem{node: edge.to, duration: duration})
+			}
+		}
+	}
+
+	if math.IsInf(durations[end], 1) {
+		return nil, 0, 0, false
+	}
+	var path []int
+	for i := end; i != -1; i = prev[i] {
+		path = append(path, i)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, distances[end], durations[end], true
+}
+
+type nodeItem struct {
+	node     int
+	duration float64
+}
+
+// nodeQueue 按行驶时间排序的最小堆
+type nodeQueue []nodeItem
+
+func (q nodeQueue) Len() int           { return len(q) }
+func (q nodeQueue) Less(i, j int) bool { return q[i].duration < q[j].duration }
+func (q nodeQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *nodeQueue) Push(x any)        { *q = append(*q, x.(nodeItem)) }
+func (q *nodeQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,240 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"ride-sharing/services/trip-service/internal/domain"
+	"ride-sharing/shared/types"
+)
+
+// testNetwork 一条限速10的双向小路1-2-4、一条限速80的单向主路1-3-4，
+// 以及只能从5驶向4的逆向单行道4-5
+//
+// 节点按在道路中首次出现的顺序编号：1->0, 2->1, 4->2, 3->3, 5->4
+const testNetwork = `{"elements": [
+	{"type": "node", "id": 1, "lat": 52.5200, "lon": 13.4000},
+	{"type": "node", "id": 2, "lat": 52.5200, "lon": 13.4100},
+	{"type": "node", "id": 3, "lat": 52.5300, "lon": 13.4100},
+	{"type": "node", "id": 4, "lat": 52.5200, "lon": 13.4200},
+	{"type": "node", "id": 5, "lat": 52.5200, "lon": 13.4300},
+	{"type": "node", "id": 6, "lat": 52.5100, "lon": 13.4000},
+	{"type": "way", "id": 10, "nodes": [1, 2, 4], "tags": {"highway": "residential", "maxspeed": "10"}},
+	{"type": "way", "id": 11, "nodes": [1, 3, 4], "tags": {"highway": "primary", "maxspeed": "80", "oneway": "yes"}},
+	{"type": "way", "id": 12, "nodes": [4, 5], "tags": {"highway": "residential", "oneway": "-1"}},
+	{"type": "way", "id": 13, "nodes": [1, 6], "tags": {"highway": "footway"}}
+]}`
+
+func loadTestNetwork(t *testing.T) []graphNode {
+	t.Helper()
+	nodes, err := loadGraph(strings.NewReader(testNetwork))
+	if err != nil {
+		t.Fatalf("loadGraph: %v", err)
+	}
+	return nodes
+}
+
+func TestParseMaxspeed(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+		ok    bool
+	}{
+		{"50", 50, true},
+		{" 30 ", 30, true},
+		{"25 mph", 25 * 1.609344, true},
+		{"25mph", 25 * 1.609344, true},
+		{"7.5", 7.5, true},
+		{"", 0, false},
+		{"none", 0, false},
+		{"signals", 0, false},
+		{"0", 0, false},
+		{"-20", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := parseMaxspeed(tt.value)
+			if ok != tt.ok || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("parseMaxspeed(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestWayDirections(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     map[string]string
+		forward  bool
+		backward bool
+	}{
+		{"two-way by default", map[string]string{"highway": "residential"}, true, true},
+		{"oneway yes", map[string]string{"highway": "residential", "oneway": "yes"}, true, false},
+		{"oneway 1", map[string]string{"highway": "residential", "oneway": "1"}, true, false},
+		{"oneway reverse", map[string]string{"highway": "residential", "oneway": "-1"}, false, true},
+		{"motorway is oneway", map[string]string{"highway": "motorway"}, true, false},
+		{"roundabout is oneway", map[string]string{"highway": "primary", "junction": "roundabout"}, true, false},
+		{"explicit two-way motorway", map[string]string{"highway": "motorway", "oneway": "no"}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forward, backward := wayDirections(tt.tags)
+			if forward != tt.forward || backward != tt.backward {
+				t.Errorf("got %v, %v, want %v, %v", forward, backward, tt.forward, tt.backward)
+			}
+		})
+	}
+}
+
+func TestLoadGraph(t *testing.T) {
+	nodes := loadTestNetwork(t)
+
+	// 人行道不可通行，只被人行道引用的节点6不在路网中
+	if len(nodes) != 5 {
+		t.Fatalf("got %d nodes, want 5", len(nodes))
+	}
+
+	edges := func(from int) []int {
+		var to []int
+		for _, e := range nodes[from].edges {
+			to = append(to, e.to)
+		}
+		return to
+	}
+	tests := []struct {
+		node int
+		want []int
+	}{
+		{0, []int{1, 3}}, // 1: 小路到2，单行主路到3
+		{1, []int{0, 2}}, // 2: 双向小路
+		{2, []int{1}},    // 4: 主路单行不能驶回3，也不能驶入逆向单行道
+		{3, []int{2}},    // 3: 主路只能继续驶向4
+		{4, []int{2}},    // 5: 逆向单行道只能驶向4
+	}
+	for _, tt := range tests {
+		if got := edges(tt.node); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("edges of node %d: got %v, want %v", tt.node, got, tt.want)
+		}
+	}
+
+	// 有maxspeed标签时按限速计算时长，没有时使用道路类型的默认速度
+	speeds := []struct {
+		edge  graphEdge
+		speed float64
+	}{
+		{nodes[0].edges[0], 10},
+		{nodes[0].edges[1], 80},
+		{nodes[4].edges[0], highwaySpeeds["residential"]},
+	}
+	for _, s := range speeds {
+		if want := travelTime(s.edge.distance, s.speed); math.Abs(s.edge.duration-want) > 1e-9 {
+			t.Errorf("edge to %d: got duration %v, want %v", s.edge.to, s.edge.duration, want)
+		}
+	}
+}
+
+func TestLoadGraphErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+	}{
+		{"invalid json", `{"elements": [`},
+		{"no drivable roads", `{"elements": [
+			{"type": "node", "id": 1, "lat": 52.52, "lon": 13.40},
+			{"type": "node", "id": 2, "lat": 52.52, "lon": 13.41},
+			{"type": "way", "id": 10, "nodes": [1, 2], "tags": {"highway": "footway"}}
+		]}`},
+		{"missing node", `{"elements": [
+			{"type": "node", "id": 1, "lat": 52.52, "lon": 13.40},
+			{"type": "way", "id": 10, "nodes": [1, 2], "tags": {"highway": "residential"}}
+		]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadGraph(strings.NewReader(tt.file)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestShortestPath(t *testing.T) {
+	g := &graphRouter{nodes: loadTestNetwork(t), maxSnap: 500}
+
+	tests := []struct {
+		name       string
+		start, end int
+		path       []int
+		ok         bool
+	}{
+		{"faster main road over shorter side road", 0, 2, []int{0, 3, 2}, true},
+		{"oneway main road is not used backwards", 2, 0, []int{2, 1, 0}, true},
+		{"reverse oneway is entered from its end", 4, 0, []int{4, 2, 1, 0}, true},
+		{"reverse oneway is not entered from its start", 2, 4, nil, false},
+		{"start is the destination", 1, 1, []int{1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, distance, duration, ok := g.shortestPath(tt.start, tt.end)
+			if ok != tt.ok {
+				t.Fatalf("got ok %v, want %v", ok, tt.ok)
+			}
+			if !reflect.DeepEqual(path, tt.path) {
+				t.Errorf("got path %v, want %v", path, tt.path)
+			}
+
+			// 距离为途经路段之和，时长与路径一致
+			var wantDistance float64
+			for i := 1; i < len(path); i++ {
+				wantDistance += haversine(g.nodes[path[i-1]].coord, g.nodes[path[i]].coord)
+			}
+			if math.Abs(distance-wantDistance) > 1e-6 {
+				t.Errorf("got distance %v, want %v", distance, wantDistance)
+			}
+			if (duration > 0) != (len(path) > 1) {
+				t.Errorf("got duration %v for path %v", duration, path)
+			}
+		})
+	}
+}
+
+func TestGraphRouterRoute(t *testing.T) {
+	g := &graphRouter{nodes: loadTestNetwork(t), maxSnap: 500}
+
+	pickup := &types.Coordinate{Latitude: 52.5201, Longitude: 13.4001}
+	destination := &types.Coordinate{Latitude: 52.5201, Longitude: 13.4199}
+	route, err := g.Route(context.Background(), pickup, destination)
+	if err != nil {
+		t.Fatalf("Route: %v", err)
+	}
+	coords := route.Routes[0].Geometry.Coordinates
+	if len(coords) != 5 {
+		t.Fatalf("got %d coordinates, want pickup, 3 nodes and destination", len(coords))
+	}
+	if coords[0][0] != pickup.Longitude || coords[len(coords)-1][1] != destination.Latitude {
+		t.Errorf("route does not start at pickup and end at destination: %v", coords)
+	}
+
+	tests := []struct {
+		name                string
+		pickup, destination *types.Coordinate
+	}{
+		{"outside the network", &types.Coordinate{Latitude: 52.60, Longitude: 13.40}, destination},
+		{"not connected", destination, &types.Coordinate{Latitude: 52.5200, Longitude: 13.4300}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := g.Route(context.Background(), tt.pickup, tt.destination); !errors.Is(err, domain.ErrNoRoute) {
+				t.Errorf("got %v, want ErrNoRoute", err)
+			}
+		})
+	}
+}
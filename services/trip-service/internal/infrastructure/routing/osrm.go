@@ -0,0 +1,143 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"ride-sharing/services/trip-service/internal/domain"
+	tripTypes "ride-sharing/services/trip-service/pkg/types"
+	"ride-sharing/shared/retry"
+	"ride-sharing/shared/types"
+)
+
+// maxOSRMResponseSize OSRM响应的最大字节数，完整几何的长途路线也远小于该值
+const maxOSRMResponseSize = 8 << 20
+
+// OSRMConfig OSRM路线服务的配置
+type OSRMConfig struct {
+	BaseURL string
+	// Profile 出行方式，对应URL中的 /route/v1/{profile}
+	Profile string
+	// Timeout 每次请求的超时时间
+	Timeout time.Duration
+	// Retry 网络错误、429和5xx响应的重试策略
+	Retry retry.Config
+}
+
+// DefaultOSRMConfig 返回使用公共OSRM演示服务的配置
+func DefaultOSRMConfig() OSRMConfig {
+	return OSRMConfig{
+		BaseURL: "http://router.project-osrm.org",
+		Profile: "driving",
+		Timeout: 5 * time.Second,
+		Retry: retry.Config{
+			MaxRetries:  2,
+			InitialWait: 200 * time.Millisecond,
+			MaxWait:     2 * time.Second,
+		},
+	}
+}
+
+// osrmRouter 通过OSRM的HTTP接口计算路线
+type osrmRouter struct {
+	cfg    OSRMConfig
+	client *http.Client
+}
+
+// NewOSRMRouter 创建OSRM路线来源
+func NewOSRMRouter(cfg OSRMConfig) (*osrmRouter, error) {
+	if cfg.BaseURL == "" {
+		return nil, errors.New("缺少OSRM服务地址")
+	}
+	if cfg.Profile == "" {
+		cfg.Profile = "driving"
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	return &osrmRouter{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout}}, nil
+}
+
+// osrmResponse OSRM的响应，code不为Ok时message说明原因
+type osrmResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	tripTypes.OsrmApiResponse
+}
+
+// permanentError OSRM拒绝了请求，重试也不会成功
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+func (r *osrmRouter) Route(ctx context.Context, pickup, destination *types.Coordinate) (*tripTypes.OsrmApiResponse, error) {
+	url := fmt.Sprintf(
+		"%s/route/v1/%s/%f,%f;%f,%f?overview=full&geometries=geojson",
+		r.cfg.BaseURL,
+		r.cfg.Profile,
+		pickup.Longitude,
+		pickup.Latitude,
+		destination.Longitude,
+		destination.Latitude,
+	)
+
+	var (
+		route     *tripTypes.OsrmApiResponse
+		permanent error
+	)
+	err := retry.WithBackoff(ctx, r.cfg.Retry, func() error {
+		resp, err := r.fetch(ctx, url)
+		var p *permanentError
+		if errors.As(err, &p) {
+			// 请求被拒绝时结束重试
+			permanent = p.err
+			return nil
+		}
+		route = resp
+		return err
+	})
+	if err == nil {
+		err = permanent
+	}
+	if err != nil {
+		return nil, fmt.Errorf("请求OSRM路线失败: %w", err)
+	}
+	return route, nil
+}
+
+// fetch 请求一次路线，请求被拒绝或没有路线时返回permanentError
+func (r *osrmRouter) fetch(ctx context.Context, url string) (*tripTypes.OsrmApiResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, &permanentError{err: err}
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxOSRMResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("读取OSRM响应失败: %w", err)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+		return nil, fmt.Errorf("OSRM返回状态码 %d", resp.StatusCode)
+	}
+
+	var result osrmResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, &permanentError{err: fmt.Errorf("解析OSRM响应失败: %w", err)}
+	}
+	if resp.StatusCode != http.StatusOK || result.Code != "Ok" || len(result.Routes) == 0 {
+		return nil, &permanentError{err: fmt.Errorf("%w: OSRM返回 %s %s", domain.ErrNoRoute, result.Code, result.Message)}
+	}
+	return &result.OsrmApiResponse, nil
+}
@@ -0,0 +1,156 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"ride-sharing/services/trip-service/internal/domain"
+	tripTypes "ride-sharing/services/trip-service/pkg/types"
+	"ride-sharing/shared/env"
+	"ride-sharing/shared/types"
+)
+
+// 路线来源
+const (
+	ProviderOSRM     = "osrm"
+	ProviderGraph    = "graph"
+	ProviderEstimate = "estimate"
+)
+
+// Config 路线来源的配置
+type Config struct {
+	// Provider osrm、graph（本地路网）或 estimate（估算）
+	Provider string
+	// Fallback 主路线来源失败时是否改用估算，预览行程因此在离线时仍可用
+	Fallback  bool
+	OSRM      OSRMConfig
+	Graph     GraphConfig
+	Estimator EstimatorConfig
+	Cache     CacheConfig
+}
+
+// ConfigFromEnv 从环境变量读取路线来源的配置
+//
+//	ROUTER_PROVIDER           osrm（默认）、graph 或 estimate
+//	ROUTER_FALLBACK           主路线来源失败时是否改用估算，默认true
+//	OSRM_BASE_URL             OSRM服务地址
+//	OSRM_TIMEOUT_MS           每次请求OSRM的超时时间
+//	OSRM_MAX_RETRIES          请求OSRM的最大重试次数
+//	ROUTER_GRAPH_FILE         本地路网文件，Overpass API导出的JSON
+//	ROUTER_ESTIMATE_METRIC    估算的距离算法，great_circle（默认）或 manhattan
+//	ROUTER_ESTIMATE_SPEED_KMH 估算的平均行驶速度
+//	ROUTE_CACHE_SIZE          最多缓存的路线数量，0表示不缓存
+//	ROUTE_CACHE_TTL_SECONDS   缓存路线的有效期
+//	ROUTE_CACHE_PRECISION     起终点吸附的geohash字符数
+//	ROUTE_CACHE_FILE          持久化路线缓存的文件
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Provider:  env.GetString("ROUTER_PROVIDER", ProviderOSRM),
+		Fallback:  env.GetBool("ROUTER_FALLBACK", true),
+		OSRM:      DefaultOSRMConfig(),
+		Graph:     DefaultGraphConfig(),
+		Estimator: DefaultEstimatorConfig(),
+		Cache:     DefaultCacheConfig(),
+	}
+	cfg.OSRM.BaseURL = env.GetString("OSRM_BASE_URL", cfg.OSRM.BaseURL)
+	cfg.OSRM.Timeout = time.Duration(env.GetInt("OSRM_TIMEOUT_MS", int(cfg.OSRM.Timeout/time.Millisecond))) * time.Millisecond
+	cfg.OSRM.Retry.MaxRetries = env.GetInt("OSRM_MAX_RETRIES", cfg.OSRM.Retry.MaxRetries)
+	cfg.Graph.File = env.GetString("ROUTER_GRAPH_FILE", "")
+	cfg.Estimator.Metric = env.GetString("ROUTER_ESTIMATE_METRIC", cfg.Estimator.Metric)
+	cfg.Estimator.SpeedKmh = float64(env.GetInt("ROUTER_ESTIMATE_SPEED_KMH", int(cfg.Estimator.SpeedKmh)))
+	cfg.Cache.Size = env.GetInt("ROUTE_CACHE_SIZE", cfg.Cache.Size)
+	cfg.Cache.TTL = time.Duration(env.GetInt("ROUTE_CACHE_TTL_SECONDS", int(cfg.Cache.TTL/time.Second))) * time.Second
+	cfg.Cache.Precision = uint(env.GetInt("ROUTE_CACHE_PRECISION", int(cfg.Cache.Precision)))
+	cfg.Cache.File = env.GetString("ROUTE_CACHE_FILE", "")
+	return cfg
+}
+
+// Router 按配置组合的路线来源：主路线来源、路线缓存和估算回退
+// 估算结果不进入缓存，主路线来源恢复后立即使用真实路线
+type Router struct {
+	route domain.Router
+	cache *routeCache
+}
+
+// NewRouter 按配置创建路线来源，启用Fallback时主路线来源失败后改用估算
+func NewRouter(cfg Config) (*Router, error) {
+	estimate, err := NewEstimator(cfg.Estimator)
+	if err != nil {
+		return nil, err
+	}
+
+	var primary domain.Router
+	switch cfg.Provider {
+	case ProviderEstimate:
+		return &Router{route: estimate}, nil
+	case ProviderOSRM, "":
+		primary, err = NewOSRMRouter(cfg.OSRM)
+	case ProviderGraph:
+		primary, err = NewGraphRouter(cfg.Graph)
+	default:
+		return nil, fmt.Errorf("不支持的路线来源: %s", cfg.Provider)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Router{route: primary}
+	if cfg.Cache.Size > 0 {
+		if r.cache, err = newRouteCache(primary, cfg.profile(), cfg.Cache); err != nil {
+			return nil, err
+		}
+		r.route = r.cache
+	}
+	if cfg.Fallback {
+		r.route = &fallbackRouter{primary: r.route, fallback: estimate}
+	}
+	return r, nil
+}
+
+func (r *Router) Route(ctx context.Context, pickup, destination *types.Coordinate) (*tripTypes.OsrmApiResponse, error) {
+	return r.route.Route(ctx, pickup, destination)
+}
+
+// Run 周期性持久化路线缓存，直到ctx取消；未启用缓存或持久化时直接返回
+func (r *Router) Run(ctx context.Context) {
+	if r.cache != nil {
+		r.cache.Run(ctx)
+	}
+}
+
+// Save 持久化路线缓存，服务关闭前调用
+func (r *Router) Save() error {
+	if r.cache == nil {
+		return nil
+	}
+	return r.cache.Save()
+}
+
+// profile 返回路线缓存键中的路线来源，来源或出行方式不同的路线不会互相命中
+func (c Config) profile() string {
+	if c.Provider == ProviderOSRM || c.Provider == "" {
+		return ProviderOSRM + ":" + c.OSRM.Profile
+	}
+	return c.Provider
+}
+
+// fallbackRouter 主路线来源失败时改用备用路线来源
+type fallbackRouter struct {
+	primary  domain.Router
+	fallback domain.Router
+}
+
+func (r *fallbackRouter) Route(ctx context.Context, pickup, destination *types.Coordinate) (*tripTypes.OsrmApiResponse, error) {
+	route, err := r.primary.Route(ctx, pickup, destination)
+	if err == nil {
+		return route, nil
+	}
+	if ctx.Err() != nil {
+		return nil, err
+	}
+
+	log.Printf("获取路线失败，改用估算路线: %v", err)
+	return r.fallback.Route(ctx, pickup, destination)
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"ride-sharing/services/trip-service/internal/domain"
+	"ride-sharing/shared/contracts"
+	"ride-sharing/shared/events"
+	"ride-sharing/shared/types"
+)
+
+// 行程列表的分页大小
+const (
+	defaultTripPageSize = 20
+	maxTripPageSize     = 100
+)
+
+// GetTrip 查询行程
+func (s *service) GetTrip(ctx context.Context, tripID string) (*domain.TripModel, error) {
+	if tripID == "" {
+		return nil, fmt.Errorf("%w: 缺少行程ID", domain.ErrInvalidTripRequest)
+	}
+	return s.repo.GetTripByID(ctx, tripID)
+}
+
+// ListTrips 分页查询乘客或司机的行程，从新到旧排列
+func (s *service) ListTrips(ctx context.Context, filter domain.TripFilter) ([]*domain.TripModel, string, error) {
+	if (filter.UserID == "") == (filter.DriverID == "") {
+		return nil, "", fmt.Errorf("%w: 乘客ID和司机ID需要且只能设置一个", domain.ErrInvalidTripRequest)
+	}
+	for _, status := range filter.Statuses {
+		if _, err := domain.ParseTripStatus(string(status)); err != nil {
+			return nil, "", fmt.Errorf("%w: %v", domain.ErrInvalidTripRequest, err)
+		}
+	}
+	if filter.PageToken != "" {
+		if _, err := primitive.ObjectIDFromHex(filter.PageToken); err != nil {
+			return nil, "", fmt.Errorf("%w: 翻页标记无效", domain.ErrInvalidTripRequest)
+		}
+	}
+
+	switch {
+	case filter.PageSize <= 0:
+		filter.PageSize = defaultTripPageSize
+	case filter.PageSize > maxTripPageSize:
+		filter.PageSize = maxTripPageSize
+	}
+
+	// 多查询一个行程判断是否还有下一页
+	trips, err := s.repo.ListTrips(ctx, filter, filter.PageSize+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("查询行程列表失败: %w", err)
+	}
+	if len(trips) <= filter.PageSize {
+		return trips, "", nil
+	}
+	trips = trips[:filter.PageSize]
+	return trips, trips[len(trips)-1].ID.Hex(), nil
+}
+
+// CancelTrip 由乘客或已分配的司机取消行程，Saga收到取消事件后释放司机并取消支付会话
+func (s *service) CancelTrip(ctx context.Context, tripID, userID, driverID, reason string) (*domain.TripModel, error) {
+	if (userID == "") == (driverID == "") {
+		return nil, fmt.Errorf("%w: 乘客ID和司机ID需要且只能设置一个", domain.ErrInvalidTripRequest)
+	}
+
+	cancelledBy, check := contracts.CancelledByRider, riderCheck(userID)
+	if driverID != "" {
+		cancelledBy, check = contracts.CancelledByDriver, driverCheck(driverID)
+	}
+
+	cancel := func(t *domain.TripModel) ([]*events.OutboxMessage, error) {
+		data := contracts.TripCancelled{
+			TripID:      tripID,
+			UserID:      t.UserID,
+			CancelledBy: cancelledBy,
+			Reason:      reason,
+		}
+		if t.Driver != nil {
+			data.DriverID = t.Driver.Id
+		}
+		cancelled, err := events.NewOutboxMessage(ctx, contracts.TripEventCancelled, data)
+		if err != nil {
+			return nil, err
+		}
+		return []*events.OutboxMessage{cancelled}, nil
+	}
+
+	t, _, err := transitionTrip(ctx, s.repo, tripID, domain.TripStatusCancelled, "CancelTrip", check, cancel)
+	if err != nil {
+		return nil, fmt.Errorf("取消行程失败: %w", err)
+	}
+	return t, nil
+}
+
+// ArriveAtPickup 司机到达上车地点
+func (s *service) ArriveAtPickup(ctx context.Context, tripID, driverID string) (*domain.TripModel, error) {
+	t, _, err := transitionTrip(ctx, s.repo, tripID, domain.TripStatusDriverArrived, "ArriveAtPickup", driverCheck(driverID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("更新司机到达失败: %w", err)
+	}
+	return t, nil
+}
+
+// StartRide 司机接到乘客，开始行程
+func (s *service) StartRide(ctx context.Context, tripID, driverID string) (*domain.TripModel, error) {
+	t, _, err := transitionTrip(ctx, s.repo, tripID, domain.TripStatusInProgress, "StartRide", driverCheck(driverID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("开始行程失败: %w", err)
+	}
+	return t, nil
+}
+
+// CompleteTrip 司机送达乘客，行程完成事件与释放司机的命令一起写入发件箱
+func (s *service) CompleteTrip(ctx context.Context, tripID, driverID string) (*domain.TripModel, error) {
+	complete := func(t *domain.TripModel) ([]*events.OutboxMessage, error) {
+		completed, err := events.NewOutboxMessage(ctx, contracts.TripEventCompleted, t.ToProto())
+		if err != nil {
+			return nil, err
+		}
+		release, err := events.NewOutboxMessage(ctx, contracts.DriverCmdTripRelease, contracts.DriverTripRelease{
+			TripID:   tripID,
+			DriverID: driverID,
+			Reason:   "行程已完成",
+		})
+		if err != nil {
+			return nil, err
+		}
+		return []*events.OutboxMessage{completed, release}, nil
+	}
+
+	t, _, err := transitionTrip(ctx, s.repo, tripID, domain.TripStatusCompleted, "CompleteTrip", driverCheck(driverID), complete)
+	if err != nil {
+		return nil, fmt.Errorf("完成行程失败: %w", err)
+	}
+	return t, nil
+}
+
+// WatchTrip 订阅行程的快照和后续更新
+func (s *service) WatchTrip(ctx context.Context, tripID string, fromVersion int) (domain.TripWatch, error) {
+	if tripID == "" {
+		return nil, fmt.Errorf("%w: 缺少行程ID", domain.ErrInvalidTripRequest)
+	}
+	return s.repo.WatchTrip(ctx, tripID, fromVersion)
+}
+
+// UpdateDriverLocation 将司机位置推送给其行程的订阅者，位置不写入行程
+func (s *service) UpdateDriverLocation(ctx context.Context, driverID string, location types.Coordinate) {
+	s.repo.PublishDriverLocation(driverID, location)
+}
+
+// riderCheck 校验操作者是行程的乘客
+func riderCheck(userID string) tripCheck {
+	return func(t *domain.TripModel) error {
+		if t.UserID != userID {
+			return fmt.Errorf("%w: 行程ID=%s, 乘客ID=%s", domain.ErrTripForbidden, t.ID.Hex(), userID)
+		}
+		return nil
+	}
+}
+
+// driverCheck 校验操作者是行程已分配的司机
+func driverCheck(driverID string) tripCheck {
+	return func(t *domain.TripModel) error {
+		if driverID == "" {
+			return fmt.Errorf("%w: 缺少司机ID", domain.ErrInvalidTripRequest)
+		}
+		if t.Driver == nil || t.Driver.Id != driverID {
+			return fmt.Errorf("%w: 行程ID=%s, 司机ID=%s", domain.ErrTripForbidden, t.ID.Hex(), driverID)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,351 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"ride-sharing/services/trip-service/internal/domain"
+	"ride-sharing/shared/contracts"
+	"ride-sharing/shared/events"
+	pb "ride-sharing/shared/proto/trip"
+)
+
+// SagaConfig 行程Saga各步骤的超时配置
+type SagaConfig struct {
+	// DriverTimeout 等待司机接单的时间，超时后通知乘客未找到司机
+	DriverTimeout time.Duration
+	// PaymentSessionTimeout 司机分配后等待支付会话创建的时间
+	PaymentSessionTimeout time.Duration
+	// PaymentTimeout 支付会话创建后等待乘客支付的时间
+	PaymentTimeout time.Duration
+	// SweepInterval 检查超时Saga的间隔
+	SweepInterval time.Duration
+	// SweepBatch 每次检查最多处理的Saga数量
+	SweepBatch int
+}
+
+// DefaultSagaConfig 返回默认的Saga配置
+func DefaultSagaConfig() SagaConfig {
+	return SagaConfig{
+		DriverTimeout:         2 * time.Minute,
+		PaymentSessionTimeout: time.Minute,
+		PaymentTimeout:        15 * time.Minute,
+		SweepInterval:         5 * time.Second,
+		SweepBatch:            100,
+	}
+}
+
+type sagaOrchestrator struct {
+	trips domain.TripRepository
+	sagas domain.SagaRepository
+	cfg   SagaConfig
+}
+
+// NewSagaOrchestrator 创建行程Saga编排器
+// Saga状态与补偿命令一起写入发件箱，由发件箱中继投递
+func NewSagaOrchestrator(trips domain.TripRepository, sagas domain.SagaRepository, cfg SagaConfig) *sagaOrchestrator {
+	return &sagaOrchestrator{
+		trips: trips,
+		sagas: sagas,
+		cfg:   cfg,
+	}
+}
+
+// OnTripCreated 为新行程开始Saga，重复的创建事件不会重置已有的Saga
+func (o *sagaOrchestrator) OnTripCreated(ctx context.Context, trip *pb.Trip) error {
+	now := time.Now()
+	saga := &domain.TripSagaModel{
+		TripID:    trip.Id,
+		UserID:    trip.UserID,
+		CreatedAt: now,
+	}
+	saga.Advance(domain.SagaStepAwaitingDriver, contracts.TripEventCreated, o.cfg.DriverTimeout, now)
+
+	created, err := o.sagas.CreateSaga(ctx, saga)
+	if err != nil {
+		return fmt.Errorf("创建行程Saga失败: %w", err)
+	}
+	if created {
+		log.Printf("开始行程Saga: 行程ID=%s, 等待司机截止=%s", trip.Id, saga.Deadline.Format(time.RFC3339))
+	}
+
+	// 重复的创建事件也尝试变更，上次保存Saga之后变更失败的行程不会停留在已请求状态
+	_, _, err = transitionTrip(ctx, o.trips, trip.Id, domain.TripStatusSearching, contracts.TripEventCreated, nil, nil)
+	if errors.Is(err, domain.ErrInvalidTripTransition) {
+		log.Printf("行程已离开已请求状态，不再开始寻找司机: %v", err)
+		return nil
+	}
+	return err
+}
+
+// OnDriverAssigned 司机已分配，等待支付会话创建
+func (o *sagaOrchestrator) OnDriverAssigned(ctx context.Context, trip *pb.Trip) error {
+	saga, err := o.sagas.GetSaga(ctx, trip.Id)
+	if err != nil {
+		return fmt.Errorf("获取行程Saga失败: %w", err)
+	}
+	driverID := trip.GetDriver().GetId()
+
+	if saga.Step != domain.SagaStepAwaitingDriver {
+		// Saga已补偿后才分配的司机不会再有行程，直接释放
+		if saga.Step == domain.SagaStepCompensated && driverID != "" && saga.DriverID == "" {
+			saga.DriverID = driverID
+			release, _ := saga.ReleaseDriverCommand()
+			return o.save(ctx, saga, []domain.SagaCommand{release})
+		}
+		log.Printf("忽略司机分配事件: 行程ID=%s, Saga步骤=%s", trip.Id, saga.Step)
+		return nil
+	}
+
+	saga.DriverID = driverID
+	saga.Advance(domain.SagaStepAwaitingPaymentSession, contracts.TripEventDriverAssigned, o.cfg.PaymentSessionTimeout, time.Now())
+	return o.save(ctx, saga, nil)
+}
+
+// OnPaymentSessionCreated 支付会话已创建，等待乘客支付
+func (o *sagaOrchestrator) OnPaymentSessionCreated(ctx context.Context, payment contracts.PaymentEventData) error {
+	saga, err := o.sagas.GetSaga(ctx, payment.TripID)
+	if err != nil {
+		return fmt.Errorf("获取行程Saga失败: %w", err)
+	}
+
+	switch saga.Step {
+	case domain.SagaStepAwaitingDriver:
+		// 支付事件与司机分配事件经由不同队列到达，等待司机分配事件先处理
+		return fmt.Errorf("行程Saga尚未处理司机分配: 行程ID=%s", payment.TripID)
+	case domain.SagaStepAwaitingPaymentSession:
+		saga.SessionID = payment.SessionID
+		saga.Advance(domain.SagaStepAwaitingPayment, contracts.PaymentEventSessionCreated, o.cfg.PaymentTimeout, time.Now())
+		return o.save(ctx, saga, nil)
+	case domain.SagaStepCompensated:
+		// Saga补偿之后才创建的支付会话需要取消
+		if saga.SessionID == "" {
+			saga.SessionID = payment.SessionID
+			if cancel, ok := saga.CancelSessionCommand(); ok {
+				return o.save(ctx, saga, []domain.SagaCommand{cancel})
+			}
+		}
+	}
+
+	log.Printf("忽略支付会话创建事件: 行程ID=%s, Saga步骤=%s", payment.TripID, saga.Step)
+	return nil
+}
+
+// OnPaymentSucceeded 乘客已支付，Saga完成
+func (o *sagaOrchestrator) OnPaymentSucceeded(ctx context.Context, payment contracts.PaymentEventData) error {
+	saga, err := o.sagas.GetSaga(ctx, payment.TripID)
+	if err != nil {
+		return fmt.Errorf("获取行程Saga失败: %w", err)
+	}
+
+	switch saga.Step {
+	case domain.SagaStepAwaitingDriver:
+		return fmt.Errorf("行程Saga尚未处理司机分配: 行程ID=%s", payment.TripID)
+	case domain.SagaStepAwaitingPaymentSession, domain.SagaStepAwaitingPayment:
+		if saga.SessionID == "" {
+			saga.SessionID = payment.SessionID
+		}
+		saga.PaymentStatus = payment.Status
+		saga.Advance(domain.SagaStepCompleted, contracts.PaymentEventSuccess, 0, time.Now())
+		if err := o.save(ctx, saga, nil); err != nil {
+			return err
+		}
+		log.Printf("行程Saga完成: 行程ID=%s", payment.TripID)
+		return nil
+	case domain.SagaStepCompensated:
+		// 退款不在Saga的范围内，记录下来由人工处理
+		log.Printf("行程Saga已补偿但支付成功，需要退款: 行程ID=%s, 会话ID=%s", payment.TripID, payment.SessionID)
+	}
+	return nil
+}
+
+// OnPaymentAborted 支付失败或支付会话被取消，将行程变更为支付失败或已取消并释放司机
+func (o *sagaOrchestrator) OnPaymentAborted(ctx context.Context, cause string, payment contracts.PaymentEventData) error {
+	saga, err := o.sagas.GetSaga(ctx, payment.TripID)
+	if err != nil {
+		return fmt.Errorf("获取行程Saga失败: %w", err)
+	}
+
+	switch {
+	case saga.Step == domain.SagaStepAwaitingDriver:
+		return fmt.Errorf("行程Saga尚未处理司机分配: 行程ID=%s", payment.TripID)
+	case saga.Done():
+		// 包括Saga自己取消支付会话后收到的取消事件
+		log.Printf("忽略支付事件 %s: 行程ID=%s, Saga步骤=%s", cause, payment.TripID, saga.Step)
+		return nil
+	}
+
+	if saga.SessionID == "" {
+		saga.SessionID = payment.SessionID
+	}
+	saga.PaymentStatus = payment.Status
+	reason, tripStatus := "支付失败", domain.TripStatusPaymentFailed
+	if cause == contracts.PaymentEventCancelled {
+		reason, tripStatus = "支付会话已取消", domain.TripStatusCancelled
+	}
+	return o.compensate(ctx, saga, cause, reason, tripStatus, nil)
+}
+
+// OnTripCancelled 乘客或司机取消了行程，补偿未结束的Saga
+// 已支付的行程只释放司机，退款不在Saga的范围内
+func (o *sagaOrchestrator) OnTripCancelled(ctx context.Context, cancelled contracts.TripCancelled) error {
+	saga, err := o.sagas.GetSaga(ctx, cancelled.TripID)
+	if err != nil {
+		return fmt.Errorf("获取行程Saga失败: %w", err)
+	}
+
+	reason := "行程已被乘客取消"
+	if cancelled.CancelledBy == contracts.CancelledByDriver {
+		reason = "行程已被司机取消"
+	}
+
+	switch saga.Step {
+	case domain.SagaStepCompensated:
+		log.Printf("忽略行程取消事件: 行程ID=%s, Saga步骤=%s", cancelled.TripID, saga.Step)
+		return nil
+	case domain.SagaStepCompleted:
+		log.Printf("已支付的行程被取消，需要退款: 行程ID=%s, 会话ID=%s", cancelled.TripID, saga.SessionID)
+		saga.FailureReason = reason
+		release, ok := saga.ReleaseDriverCommand()
+		if !ok {
+			return nil
+		}
+		return o.save(ctx, saga, []domain.SagaCommand{release})
+	}
+
+	return o.compensate(ctx, saga, contracts.TripEventCancelled, reason, domain.TripStatusCancelled, nil)
+}
+
+// CheckTimeouts 补偿已超时的Saga，单个Saga失败时记录错误并继续处理其余的Saga
+func (o *sagaOrchestrator) CheckTimeouts(ctx context.Context) (int, error) {
+	expired, err := o.sagas.ExpiredSagas(ctx, time.Now(), o.cfg.SweepBatch)
+	if err != nil {
+		return 0, fmt.Errorf("查询超时Saga失败: %w", err)
+	}
+
+	handled := 0
+	for _, saga := range expired {
+		if err := o.timeout(ctx, saga); err != nil {
+			log.Printf("补偿超时Saga失败: 行程ID=%s, 步骤=%s, 错误=%v", saga.TripID, saga.Step, err)
+			continue
+		}
+		handled++
+	}
+	return handled, nil
+}
+
+// Run 周期性检查超时的Saga，直到ctx被取消
+func (o *sagaOrchestrator) Run(ctx context.Context) {
+	ticker := time.NewTicker(o.cfg.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := o.CheckTimeouts(ctx); err != nil {
+				log.Printf("检查超时Saga失败: %v", err)
+			}
+		}
+	}
+}
+
+// timeout 按超时的步骤补偿Saga
+func (o *sagaOrchestrator) timeout(ctx context.Context, saga *domain.TripSagaModel) error {
+	switch saga.Step {
+	case domain.SagaStepAwaitingDriver:
+		trip, err := o.trips.GetTripByID(ctx, saga.TripID)
+		if err != nil {
+			return fmt.Errorf("获取行程信息失败: %w", err)
+		}
+		if driverID := trip.Driver.GetId(); driverID != "" && !trip.Status.Final() {
+			// 截止时间到达时司机已分配，司机分配事件仍在队列中，按行程上的司机继续Saga，之后到达的事件被忽略
+			saga.DriverID = driverID
+			saga.Advance(domain.SagaStepAwaitingPaymentSession, contracts.TripEventDriverAssigned, o.cfg.PaymentSessionTimeout, time.Now())
+			log.Printf("等待司机超时前司机已分配，继续等待支付会话: 行程ID=%s, 司机ID=%s", saga.TripID, driverID)
+			return o.save(ctx, saga, nil)
+		}
+
+		// 通知乘客未找到司机，通知与行程状态变更一起写入，行程未变更时不通知
+		noDrivers := func(trip *domain.TripModel) ([]*events.OutboxMessage, error) {
+			msg, err := events.NewOutboxMessage(ctx, contracts.TripEventNoDriversFound, contracts.TripEventData{TripID: saga.TripID})
+			if err != nil {
+				return nil, err
+			}
+			return []*events.OutboxMessage{msg}, nil
+		}
+		return o.compensate(ctx, saga, domain.SagaTimeoutCause, "等待司机超时", domain.TripStatusNoDriversFound, noDrivers)
+	case domain.SagaStepAwaitingPaymentSession, domain.SagaStepAwaitingPayment:
+		trip, err := o.trips.GetTripByID(ctx, saga.TripID)
+		if err != nil {
+			return fmt.Errorf("获取行程信息失败: %w", err)
+		}
+		if rideStarted(trip) {
+			// 乘客在支付之前已开始乘车，支付会话保持有效，不再按截止时间补偿
+			saga.Advance(saga.Step, domain.SagaTimeoutCause, 0, time.Now())
+			log.Printf("行程已开始，停止等待支付的截止时间: 行程ID=%s, 行程状态=%s", saga.TripID, trip.Status)
+			return o.save(ctx, saga, nil)
+		}
+		if saga.Step == domain.SagaStepAwaitingPaymentSession {
+			return o.compensate(ctx, saga, domain.SagaTimeoutCause, "创建支付会话超时", domain.TripStatusCancelled, nil)
+		}
+		return o.compensate(ctx, saga, domain.SagaTimeoutCause, "等待支付超时", domain.TripStatusCancelled, nil)
+	}
+	return nil
+}
+
+// rideStarted 行程是否已开始，开始之后司机在完成行程时释放，Saga不再释放司机
+func rideStarted(trip *domain.TripModel) bool {
+	return trip.Status == domain.TripStatusInProgress || trip.Status == domain.TripStatusCompleted
+}
+
+// compensate 先更新行程状态，再结束Saga并发出补偿命令
+// 保存Saga是补偿的提交点：行程状态更新失败或Saga保存失败时Saga仍未结束，重试的消息或下一次超时检查会重新补偿，
+// 已处于目标状态的行程不会重复变更；update返回的消息只在行程状态实际变更时与行程一起写入
+func (o *sagaOrchestrator) compensate(ctx context.Context, saga *domain.TripSagaModel, cause, reason string, tripStatus domain.TripStatus, update tripUpdate) error {
+	trip, _, err := transitionTrip(ctx, o.trips, saga.TripID, tripStatus, cause, nil, update)
+	switch {
+	case errors.Is(err, domain.ErrInvalidTripTransition):
+		// 已开始或已结束的行程保持不变
+		log.Printf("补偿时保留行程状态: %v", err)
+	case err != nil:
+		return fmt.Errorf("更新行程状态失败: %w", err)
+	}
+
+	saga.FailureReason = reason
+	commands := saga.Compensations()
+	if rideStarted(trip) {
+		// 司机由完成行程释放，只取消未完成的支付会话
+		commands = nil
+		if cancel, ok := saga.CancelSessionCommand(); ok {
+			commands = append(commands, cancel)
+		}
+	}
+	saga.Advance(domain.SagaStepCompensated, cause, 0, time.Now())
+	if err := o.save(ctx, saga, commands); err != nil {
+		return err
+	}
+
+	log.Printf("行程Saga已补偿: 行程ID=%s, 原因=%s, 补偿命令=%d", saga.TripID, reason, len(commands))
+	return nil
+}
+
+// save 将命令与Saga状态一起写入发件箱，版本冲突的错误由消息重试重新读取Saga后处理
+func (o *sagaOrchestrator) save(ctx context.Context, saga *domain.TripSagaModel, commands []domain.SagaCommand, outbox ...*events.OutboxMessage) error {
+	for _, cmd := range commands {
+		msg, err := events.NewOutboxMessage(ctx, cmd.RoutingKey, cmd.Data)
+		if err != nil {
+			return err
+		}
+		outbox = append(outbox, msg)
+	}
+
+	if err := o.sagas.UpdateSaga(ctx, saga, outbox...); err != nil {
+		return fmt.Errorf("保存行程Saga失败: %w", err)
+	}
+	return nil
+}
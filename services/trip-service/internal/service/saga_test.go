@@ -0,0 +1,471 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"ride-sharing/services/trip-service/internal/domain"
+	"ride-sharing/services/trip-service/internal/infrastructure/repository"
+	"ride-sharing/shared/contracts"
+	"ride-sharing/shared/events"
+	pb "ride-sharing/shared/proto/trip"
+)
+
+// sagaFixture 基于内存存储库的Saga编排器
+type sagaFixture struct {
+	t      *testing.T
+	repo   domain.TripRepository
+	sagas  domain.SagaRepository
+	orch   *sagaOrchestrator
+	tripID string
+}
+
+func newSagaFixture(t *testing.T) *sagaFixture {
+	t.Helper()
+
+	repo := repository.NewInmemRepository()
+	trip := &domain.TripModel{ID: primitive.NewObjectID(), UserID: "rider-1", Status: domain.TripStatusRequested}
+	if _, err := repo.CreateTrip(context.Background(), trip); err != nil {
+		t.Fatalf("CreateTrip: %v", err)
+	}
+
+	f := &sagaFixture{
+		t:      t,
+		repo:   repo,
+		sagas:  repo,
+		orch:   NewSagaOrchestrator(repo, repo, DefaultSagaConfig()),
+		tripID: trip.ID.Hex(),
+	}
+	if err := f.orch.OnTripCreated(context.Background(), trip.ToProto()); err != nil {
+		t.Fatalf("OnTripCreated: %v", err)
+	}
+	return f
+}
+
+// assignDriver 司机接受行程并将Saga推进到等待支付会话
+func (f *sagaFixture) assignDriver() {
+	f.t.Helper()
+	if _, _, err := transitionTrip(context.Background(), f.repo, f.tripID, domain.TripStatusDriverAssigned, contracts.DriverCmdTripAccept, nil, nil); err != nil {
+		f.t.Fatalf("transitionTrip: %v", err)
+	}
+	f.driverAssigned()
+}
+
+// driverAssigned 只向Saga投递司机分配事件，模拟Saga补偿之后才到达的事件
+func (f *sagaFixture) driverAssigned() {
+	f.t.Helper()
+	trip := &pb.Trip{Id: f.tripID, UserID: "rider-1", Driver: &pb.TripDriver{Id: "driver-1"}}
+	if err := f.orch.OnDriverAssigned(context.Background(), trip); err != nil {
+		f.t.Fatalf("OnDriverAssigned: %v", err)
+	}
+}
+
+// createSession 将Saga推进到等待支付
+func (f *sagaFixture) createSession() {
+	f.t.Helper()
+	if err := f.orch.OnPaymentSessionCreated(context.Background(), f.payment("")); err != nil {
+		f.t.Fatalf("OnPaymentSessionCreated: %v", err)
+	}
+}
+
+// startRide 司机到达上车点后开始行程
+func (f *sagaFixture) startRide() {
+	f.t.Helper()
+	for _, status := range []domain.TripStatus{domain.TripStatusDriverArrived, domain.TripStatusInProgress} {
+		if _, _, err := transitionTrip(context.Background(), f.repo, f.tripID, status, "test", nil, nil); err != nil {
+			f.t.Fatalf("transitionTrip: %v", err)
+		}
+	}
+}
+
+func (f *sagaFixture) payment(status string) contracts.PaymentEventData {
+	return contracts.PaymentEventData{TripID: f.tripID, SessionID: "session-1", UserID: "rider-1", Status: status}
+}
+
+func (f *sagaFixture) cancelled(by string) contracts.TripCancelled {
+	return contracts.TripCancelled{TripID: f.tripID, UserID: "rider-1", CancelledBy: by}
+}
+
+// expire 使当前步骤的超时时间已过
+func (f *sagaFixture) expire() {
+	f.t.Helper()
+	saga := f.saga()
+	saga.Deadline = time.Now().Add(-time.Second)
+	if err := f.sagas.UpdateSaga(context.Background(), saga); err != nil {
+		f.t.Fatalf("UpdateSaga: %v", err)
+	}
+}
+
+func (f *sagaFixture) saga() *domain.TripSagaModel {
+	f.t.Helper()
+	saga, err := f.sagas.GetSaga(context.Background(), f.tripID)
+	if err != nil {
+		f.t.Fatalf("GetSaga: %v", err)
+	}
+	return saga
+}
+
+func (f *sagaFixture) tripStatus() domain.TripStatus {
+	f.t.Helper()
+	trip, err := f.repo.GetTripByID(context.Background(), f.tripID)
+	if err != nil {
+		f.t.Fatalf("GetTripByID: %v", err)
+	}
+	return trip.Status
+}
+
+// outbox 按写入顺序返回发件箱中消息的路由键，状态变更事件由行程状态的断言覆盖
+func (f *sagaFixture) outbox() []string {
+	f.t.Helper()
+	pending, err := f.repo.PendingOutbox(context.Background(), 100)
+	if err != nil {
+		f.t.Fatalf("PendingOutbox: %v", err)
+	}
+	var keys []string
+	for _, msg := range pending {
+		if msg.RoutingKey == contracts.TripEventStatusChanged {
+			continue
+		}
+		keys = append(keys, msg.RoutingKey)
+	}
+	return keys
+}
+
+func TestSagaTimeouts(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(f *sagaFixture)
+		expire     bool
+		handled    int
+		step       string
+		tripStatus domain.TripStatus
+		outbox     []string
+	}{
+		{
+			name:       "waiting for driver is not expired yet",
+			setup:      func(f *sagaFixture) {},
+			step:       domain.SagaStepAwaitingDriver,
+			tripStatus: domain.TripStatusSearching,
+		},
+		{
+			name:       "no driver accepted",
+			setup:      func(f *sagaFixture) {},
+			expire:     true,
+			handled:    1,
+			step:       domain.SagaStepCompensated,
+			tripStatus: domain.TripStatusNoDriversFound,
+			outbox:     []string{contracts.TripEventNoDriversFound},
+		},
+		{
+			name: "trip cancelled while waiting for driver is not notified",
+			setup: func(f *sagaFixture) {
+				if _, _, err := transitionTrip(context.Background(), f.repo, f.tripID, domain.TripStatusCancelled, contracts.TripEventCancelled, nil, nil); err != nil {
+					f.t.Fatalf("transitionTrip: %v", err)
+				}
+			},
+			expire:     true,
+			handled:    1,
+			step:       domain.SagaStepCompensated,
+			tripStatus: domain.TripStatusCancelled,
+		},
+		{
+			name:       "payment session never created",
+			setup:      func(f *sagaFixture) { f.assignDriver() },
+			expire:     true,
+			handled:    1,
+			step:       domain.SagaStepCompensated,
+			tripStatus: domain.TripStatusCancelled,
+			outbox:     []string{contracts.DriverCmdTripRelease},
+		},
+		{
+			name:       "rider never paid",
+			setup:      func(f *sagaFixture) { f.assignDriver(); f.createSession() },
+			expire:     true,
+			handled:    1,
+			step:       domain.SagaStepCompensated,
+			tripStatus: domain.TripStatusCancelled,
+			outbox:     []string{contracts.PaymentCmdCancelSession, contracts.DriverCmdTripRelease},
+		},
+		{
+			name:       "ride started before payment keeps session open",
+			setup:      func(f *sagaFixture) { f.assignDriver(); f.createSession(); f.startRide() },
+			expire:     true,
+			handled:    1,
+			step:       domain.SagaStepAwaitingPayment,
+			tripStatus: domain.TripStatusInProgress,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newSagaFixture(t)
+			tt.setup(f)
+			if tt.expire {
+				f.expire()
+			}
+
+			handled, err := f.orch.CheckTimeouts(context.Background())
+			if err != nil {
+				t.Fatalf("CheckTimeouts: %v", err)
+			}
+			if handled != tt.handled {
+				t.Errorf("handled %d sagas, want %d", handled, tt.handled)
+			}
+
+			saga := f.saga()
+			if saga.Step != tt.step {
+				t.Errorf("got step %q, want %q", saga.Step, tt.step)
+			}
+			if tt.expire {
+				last := saga.History[len(saga.History)-1]
+				if last.Cause != domain.SagaTimeoutCause || !saga.Deadline.IsZero() {
+					t.Errorf("got cause %q and deadline %v after timeout", last.Cause, saga.Deadline)
+				}
+			}
+			if status := f.tripStatus(); status != tt.tripStatus {
+				t.Errorf("got trip status %q, want %q", status, tt.tripStatus)
+			}
+			if got := f.outbox(); !reflect.DeepEqual(got, tt.outbox) {
+				t.Errorf("got outbox %v, want %v", got, tt.outbox)
+			}
+		})
+	}
+}
+
+func TestSagaCompensation(t *testing.T) {
+	tests := []struct {
+		name       string
+		run        func(f *sagaFixture) error
+		step       string
+		tripStatus domain.TripStatus
+		outbox     []string
+	}{
+		{
+			name: "payment failed releases driver",
+			run: func(f *sagaFixture) error {
+				f.assignDriver()
+				f.createSession()
+				return f.orch.OnPaymentAborted(context.Background(), contracts.PaymentEventFailed, f.payment("failed"))
+			},
+			step:       domain.SagaStepCompensated,
+			tripStatus: domain.TripStatusPaymentFailed,
+			outbox:     []string{contracts.DriverCmdTripRelease},
+		},
+		{
+			name: "payment session cancelled releases driver",
+			run: func(f *sagaFixture) error {
+				f.assignDriver()
+				f.createSession()
+				return f.orch.OnPaymentAborted(context.Background(), contracts.PaymentEventCancelled, f.payment("cancelled"))
+			},
+			step:       domain.SagaStepCompensated,
+			tripStatus: domain.TripStatusCancelled,
+			outbox:     []string{contracts.DriverCmdTripRelease},
+		},
+		{
+			name: "payment failed after ride started keeps driver",
+			run: func(f *sagaFixture) error {
+				f.assignDriver()
+				f.createSession()
+				f.startRide()
+				return f.orch.OnPaymentAborted(context.Background(), contracts.PaymentEventFailed, f.payment("failed"))
+			},
+			step:       domain.SagaStepCompensated,
+			tripStatus: domain.TripStatusInProgress,
+		},
+		{
+			name: "payment succeeded completes saga",
+			run: func(f *sagaFixture) error {
+				f.assignDriver()
+				f.createSession()
+				return f.orch.OnPaymentSucceeded(context.Background(), f.payment("success"))
+			},
+			step:       domain.SagaStepCompleted,
+			tripStatus: domain.TripStatusDriverAssigned,
+		},
+		{
+			name: "driver assigned after compensation is released",
+			run: func(f *sagaFixture) error {
+				f.expire()
+				if _, err := f.orch.CheckTimeouts(context.Background()); err != nil {
+					return err
+				}
+				f.driverAssigned()
+				return nil
+			},
+			step:       domain.SagaStepCompensated,
+			tripStatus: domain.TripStatusNoDriversFound,
+			outbox:     []string{contracts.TripEventNoDriversFound, contracts.DriverCmdTripRelease},
+		},
+		{
+			name: "session created after compensation is cancelled",
+			run: func(f *sagaFixture) error {
+				f.assignDriver()
+				f.expire()
+				if _, err := f.orch.CheckTimeouts(context.Background()); err != nil {
+					return err
+				}
+				f.createSession()
+				return nil
+			},
+			step:       domain.SagaStepCompensated,
+			tripStatus: domain.TripStatusCancelled,
+			outbox:     []string{contracts.DriverCmdTripRelease, contracts.PaymentCmdCancelSession},
+		},
+		{
+			name: "rider cancelled while searching",
+			run: func(f *sagaFixture) error {
+				return f.orch.OnTripCancelled(context.Background(), f.cancelled(contracts.CancelledByRider))
+			},
+			step:       domain.SagaStepCompensated,
+			tripStatus: domain.TripStatusCancelled,
+		},
+		{
+			name: "driver cancelled before payment",
+			run: func(f *sagaFixture) error {
+				f.assignDriver()
+				f.createSession()
+				return f.orch.OnTripCancelled(context.Background(), f.cancelled(contracts.CancelledByDriver))
+			},
+			step:       domain.SagaStepCompensated,
+			tripStatus: domain.TripStatusCancelled,
+			outbox:     []string{contracts.PaymentCmdCancelSession, contracts.DriverCmdTripRelease},
+		},
+		{
+			name: "paid trip cancelled only releases driver",
+			run: func(f *sagaFixture) error {
+				f.assignDriver()
+				f.createSession()
+				if err := f.orch.OnPaymentSucceeded(context.Background(), f.payment("success")); err != nil {
+					return err
+				}
+				return f.orch.OnTripCancelled(context.Background(), f.cancelled(contracts.CancelledByRider))
+			},
+			step:       domain.SagaStepCompleted,
+			tripStatus: domain.TripStatusDriverAssigned,
+			outbox:     []string{contracts.DriverCmdTripRelease},
+		},
+		{
+			name: "cancel event for own cancellation is ignored",
+			run: func(f *sagaFixture) error {
+				f.assignDriver()
+				f.createSession()
+				f.expire()
+				if _, err := f.orch.CheckTimeouts(context.Background()); err != nil {
+					return err
+				}
+				return f.orch.OnPaymentAborted(context.Background(), contracts.PaymentEventCancelled, f.payment("cancelled"))
+			},
+			step:       domain.SagaStepCompensated,
+			tripStatus: domain.TripStatusCancelled,
+			outbox:     []string{contracts.PaymentCmdCancelSession, contracts.DriverCmdTripRelease},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newSagaFixture(t)
+			if err := tt.run(f); err != nil {
+				t.Fatalf("run: %v", err)
+			}
+
+			if saga := f.saga(); saga.Step != tt.step {
+				t.Errorf("got step %q, want %q", saga.Step, tt.step)
+			}
+			if status := f.tripStatus(); status != tt.tripStatus {
+				t.Errorf("got trip status %q, want %q", status, tt.tripStatus)
+			}
+			if got := f.outbox(); !reflect.DeepEqual(got, tt.outbox) {
+				t.Errorf("got outbox %v, want %v", got, tt.outbox)
+			}
+		})
+	}
+}
+
+// 等待司机的截止时间与司机分配事件竞争：行程已分配司机而事件仍在队列中时，Saga按行程上的司机继续
+func TestSagaDriverAssignedBeforeTimeout(t *testing.T) {
+	f := newSagaFixture(t)
+	accept := func(trip *domain.TripModel) ([]*events.OutboxMessage, error) {
+		trip.Driver = &pb.TripDriver{Id: "driver-1"}
+		return nil, nil
+	}
+	if _, _, err := transitionTrip(context.Background(), f.repo, f.tripID, domain.TripStatusDriverAssigned, contracts.DriverCmdTripAccept, nil, accept); err != nil {
+		t.Fatalf("transitionTrip: %v", err)
+	}
+	f.expire()
+
+	if handled, err := f.orch.CheckTimeouts(context.Background()); err != nil || handled != 1 {
+		t.Fatalf("CheckTimeouts = %d, %v", handled, err)
+	}
+	saga := f.saga()
+	if saga.Step != domain.SagaStepAwaitingPaymentSession || saga.DriverID != "driver-1" {
+		t.Errorf("got step %q with driver %q, want %q with driver-1", saga.Step, saga.DriverID, domain.SagaStepAwaitingPaymentSession)
+	}
+	if !saga.Deadline.After(time.Now()) {
+		t.Errorf("got deadline %v, want payment session deadline", saga.Deadline)
+	}
+	if status := f.tripStatus(); status != domain.TripStatusDriverAssigned {
+		t.Errorf("got trip status %q, want %q", status, domain.TripStatusDriverAssigned)
+	}
+
+	// 随后到达的司机分配事件被忽略，Saga继续等待支付会话
+	f.driverAssigned()
+	f.createSession()
+	if saga := f.saga(); saga.Step != domain.SagaStepAwaitingPayment {
+		t.Errorf("got step %q, want %q", saga.Step, domain.SagaStepAwaitingPayment)
+	}
+	if got := f.outbox(); got != nil {
+		t.Errorf("got outbox %v, want no notification or compensation", got)
+	}
+}
+
+// 支付事件先于司机分配事件到达时返回错误，由消息重试等待司机分配事件
+func TestSagaPaymentBeforeDriverAssigned(t *testing.T) {
+	f := newSagaFixture(t)
+
+	if err := f.orch.OnPaymentSessionCreated(context.Background(), f.payment("")); err == nil {
+		t.Error("expected session created before driver assignment to fail")
+	}
+	if err := f.orch.OnPaymentAborted(context.Background(), contracts.PaymentEventFailed, f.payment("failed")); err == nil {
+		t.Error("expected payment failure before driver assignment to fail")
+	}
+	if saga := f.saga(); saga.Step != domain.SagaStepAwaitingDriver {
+		t.Errorf("got step %q, want %q", saga.Step, domain.SagaStepAwaitingDriver)
+	}
+}
+
+// 行程状态更新失败时Saga保持未结束，重试时重新补偿
+func TestSagaCompensationRetriedAfterTripUpdateFails(t *testing.T) {
+	f := newSagaFixture(t)
+	f.assignDriver()
+	f.expire()
+
+	trips := &conflictRepository{TripRepository: f.repo, conflicts: maxTripUpdateAttempts}
+	orch := NewSagaOrchestrator(trips, f.sagas, DefaultSagaConfig())
+	if handled, _ := orch.CheckTimeouts(context.Background()); handled != 0 {
+		t.Fatalf("handled %d sagas, want 0", handled)
+	}
+	if saga := f.saga(); saga.Step != domain.SagaStepAwaitingPaymentSession {
+		t.Fatalf("got step %q after failed compensation", saga.Step)
+	}
+	if got := f.outbox(); got != nil {
+		t.Fatalf("got outbox %v after failed compensation", got)
+	}
+
+	if handled, err := f.orch.CheckTimeouts(context.Background()); err != nil || handled != 1 {
+		t.Fatalf("CheckTimeouts = %d, %v", handled, err)
+	}
+	if saga := f.saga(); saga.Step != domain.SagaStepCompensated {
+		t.Errorf("got step %q, want %q", saga.Step, domain.SagaStepCompensated)
+	}
+	if status := f.tripStatus(); status != domain.TripStatusCancelled {
+		t.Errorf("got trip status %q, want %q", status, domain.TripStatusCancelled)
+	}
+	if got := f.outbox(); !reflect.DeepEqual(got, []string{contracts.DriverCmdTripRelease}) {
+		t.Errorf("got outbox %v", got)
+	}
+}
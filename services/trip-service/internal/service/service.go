@@ -2,79 +2,65 @@ package service
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"io"
-	"net/http"
+	"log"
 	"ride-sharing/services/trip-service/internal/domain"
 	tripTypes "ride-sharing/services/trip-service/pkg/types"
+	"ride-sharing/shared/contracts"
+	"ride-sharing/shared/events"
 	"ride-sharing/shared/proto/trip"
 	"ride-sharing/shared/types"
 )
 
 type service struct {
-	repo     domain.TripRepository
+	repo      domain.TripRepository
 	publisher domain.TripEventPublisher
+	router    domain.Router
 }
 
-func NewService(repo domain.TripRepository, publisher domain.TripEventPublisher) *service {
+func NewService(repo domain.TripRepository, publisher domain.TripEventPublisher, router domain.Router) *service {
 	return &service{
-		repo:     repo,
+		repo:      repo,
 		publisher: publisher,
+		router:    router,
 	}
 }
 func (s *service) CreateTrip(ctx context.Context, fare *domain.RideFareModel) (*domain.TripModel, error) {
 	t := &domain.TripModel{
 		ID:       primitive.NewObjectID(),
 		UserID:   fare.UserID,
-		Status:   "pending",
+		Status:   domain.TripStatusRequested,
 		RideFare: fare,
 		Driver:   &trip.TripDriver{},
 	}
-	
-	// 保存行程到数据库
-	trip, err := s.repo.CreateTrip(ctx, t)
+
+	// 行程创建事件与行程一起写入发件箱，保证事件一定会被发布
+	created, err := events.NewOutboxMessage(ctx, contracts.TripEventCreated, t.ToProto())
 	if err != nil {
 		return nil, err
 	}
-	
-	// 发布行程创建事件
-	if s.publisher != nil {
-		if err := s.publisher.PublishTripCreated(ctx, trip); err != nil {
-			// 记录错误但不中断流程
-			fmt.Printf("发布行程创建事件失败: %v\n", err)
-		}
+
+	// 保存行程到数据库
+	trip, err := s.repo.CreateTrip(ctx, t, created)
+	if err != nil {
+		return nil, err
 	}
-	
+
 	return trip, nil
 }
 
 func (s *service) GetRoute(ctx context.Context, pickup, destination *types.Coordinate) (*tripTypes.OsrmApiResponse, error) {
-	url := fmt.Sprintf(
-		"http://router.project-osrm.org/route/v1/driving/%f,%f;%f,%f?overview=full&geometries=geojson",
-		pickup.Longitude,
-		pickup.Latitude,
-		destination.Longitude,
-		destination.Latitude,
-	)
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch route from OSRM API: %v", err)
+	if pickup == nil || destination == nil {
+		return nil, fmt.Errorf("%w: 缺少上车点或目的地", domain.ErrInvalidTripRequest)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	route, err := s.router.Route(ctx, pickup, destination)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read the response: %v", err)
+		return nil, fmt.Errorf("failed to get route: %w", err)
 	}
-
-	var routeResp tripTypes.OsrmApiResponse
-	if err := json.Unmarshal(body, &routeResp); err != nil {
-		return nil, fmt.Errorf("failed to parse respnse: %v", err)
-	}
-	return &routeResp, nil
-
+	return route, nil
 }
 
 func (s *service) EstimatePackagesPriceWithRoute(route *tripTypes.OsrmApiResponse) []*domain.RideFareModel {
@@ -147,42 +133,34 @@ func estimateFareRoute(f *domain.RideFareModel, route *tripTypes.OsrmApiResponse
 
 // AcceptTrip 司机接受行程
 func (s *service) AcceptTrip(ctx context.Context, tripID, driverID string) error {
-	// 获取行程信息
-	trip, err := s.repo.GetTripByID(ctx, tripID)
-	if err != nil {
-		return fmt.Errorf("获取行程信息失败: %w", err)
-	}
-	
-	if trip == nil {
-		return fmt.Errorf("行程不存在")
-	}
-	
-	// 创建司机信息
-	driver := &trip.TripDriver{
-		Id: driverID,
-	}
-	
-	// 分配司机并更新行程状态
-	if err := s.repo.AssignDriver(ctx, tripID, driver); err != nil {
-		return fmt.Errorf("分配司机失败: %w", err)
-	}
-	
-	if err := s.repo.UpdateTripStatus(ctx, tripID, "driver_assigned"); err != nil {
-		return fmt.Errorf("更新行程状态失败: %w", err)
-	}
-	
-	// 发布司机分配事件
-	if s.publisher != nil {
-		// 更新行程信息
-		trip.Driver = driver
-		trip.Status = "driver_assigned"
-		
-		if err := s.publisher.PublishDriverAssigned(ctx, trip); err != nil {
-			// 记录错误但不中断流程
-			fmt.Printf("发布司机分配事件失败: %v\n", err)
+	// 分配司机，司机分配事件与状态变更一起写入发件箱
+	assign := func(t *domain.TripModel) ([]*events.OutboxMessage, error) {
+		t.Driver = &trip.TripDriver{
+			Id: driverID,
+		}
+		assigned, err := events.NewOutboxMessage(ctx, contracts.TripEventDriverAssigned, t.ToProto())
+		if err != nil {
+			return nil, err
 		}
+		return []*events.OutboxMessage{assigned}, nil
+	}
+
+	t, changed, err := transitionTrip(ctx, s.repo, tripID, domain.TripStatusDriverAssigned, contracts.DriverCmdTripAccept, nil, assign)
+	switch {
+	case errors.Is(err, domain.ErrInvalidTripTransition) && t.Status == domain.TripStatusRequested:
+		// 司机请求与Saga经由不同队列处理行程创建事件，等待Saga开始寻找司机
+		return fmt.Errorf("行程尚未开始寻找司机: 行程ID=%s", tripID)
+	case errors.Is(err, domain.ErrInvalidTripTransition):
+		// 行程已被Saga取消或已结束
+		log.Printf("行程状态为 %s，忽略司机接受: 行程ID=%s, 司机ID=%s", t.Status, tripID, driverID)
+		return nil
+	case err != nil:
+		return fmt.Errorf("分配司机失败: %w", err)
+	case !changed:
+		// 行程已被其他司机接受
+		log.Printf("行程已分配司机，忽略司机接受: 行程ID=%s, 司机ID=%s", tripID, driverID)
 	}
-	
+
 	return nil
 }
 
@@ -195,20 +173,58 @@ func (s *service) DeclineTrip(ctx context.Context, tripID, driverID string) erro
 			fmt.Printf("发布司机不感兴趣事件失败: %v\n", err)
 		}
 	}
-	
+
 	return nil
 }
 
 // UpdatePaymentStatus 更新支付状态
-func (s *service) UpdatePaymentStatus(ctx context.Context, tripID, status string) error {
-	// 更新行程状态
-	if err := s.repo.UpdateTripStatus(ctx, tripID, status); err != nil {
+func (s *service) UpdatePaymentStatus(ctx context.Context, tripID string, status domain.TripStatus, cause string) error {
+	var update tripUpdate
+	if status == domain.TripStatusPaid {
+		update = func(trip *domain.TripModel) ([]*events.OutboxMessage, error) {
+			trip.Paid = true
+			return nil, nil
+		}
+	}
+
+	// 更新行程状态，已取消或未乘车就结束的行程拒绝迟到的支付事件
+	trip, _, err := transitionTrip(ctx, s.repo, tripID, status, cause, nil, update)
+	if errors.Is(err, domain.ErrInvalidTripTransition) && status == domain.TripStatusPaid && rideStarted(trip) {
+		// 乘客支付前司机已开始行程，只记录支付结果，不回退行程状态
+		err = recordPayment(ctx, s.repo, tripID)
+	}
+	if err != nil {
 		return fmt.Errorf("更新支付状态失败: %w", err)
 	}
-	
+
 	return nil
 }
 
+// recordPayment 在不变更状态的情况下将行程标记为已支付，版本冲突时重新读取后重试
+func recordPayment(ctx context.Context, repo domain.TripRepository, tripID string) error {
+	for attempt := 1; ; attempt++ {
+		trip, err := repo.GetTripByID(ctx, tripID)
+		if err != nil {
+			return fmt.Errorf("获取行程信息失败: %w", err)
+		}
+		if trip.Paid {
+			return nil
+		}
+
+		trip.Paid = true
+		err = repo.UpdateTrip(ctx, trip)
+		if errors.Is(err, domain.ErrTripConflict) && attempt < maxTripUpdateAttempts {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("保存行程失败: %w", err)
+		}
+
+		log.Printf("行程已开始，记录支付结果: 行程ID=%s, 状态=%s", tripID, trip.Status)
+		return nil
+	}
+}
+
 func getBaseFares() []*domain.RideFareModel {
 	return []*domain.RideFareModel{
 		{
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"ride-sharing/services/trip-service/internal/domain"
+	"ride-sharing/shared/contracts"
+	"ride-sharing/shared/events"
+)
+
+// maxTripUpdateAttempts 行程版本冲突时最多尝试保存的次数
+const maxTripUpdateAttempts = 3
+
+// tripCheck 在变更状态之前校验行程，例如操作者是否为行程的司机
+type tripCheck func(trip *domain.TripModel) error
+
+// tripUpdate 在行程状态变更之后修改行程，返回需要与行程一起写入发件箱的消息
+type tripUpdate func(trip *domain.TripModel) ([]*events.OutboxMessage, error)
+
+// transitionTrip 读取行程并按状态表变更到目标状态，状态变更事件与行程一起写入发件箱
+// 行程已处于目标状态时不做变更并返回false；不允许的变更返回ErrInvalidTripTransition和当前的行程；
+// 保存时版本冲突则重新读取行程后重试
+func transitionTrip(ctx context.Context, repo domain.TripRepository, tripID string, to domain.TripStatus, cause string, check tripCheck, update tripUpdate) (*domain.TripModel, bool, error) {
+	for attempt := 1; ; attempt++ {
+		trip, err := repo.GetTripByID(ctx, tripID)
+		if err != nil {
+			return nil, false, fmt.Errorf("获取行程信息失败: %w", err)
+		}
+		if check != nil {
+			if err := check(trip); err != nil {
+				return nil, false, err
+			}
+		}
+
+		transition, changed, err := trip.Transition(to, cause)
+		if err != nil || !changed {
+			return trip, false, err
+		}
+
+		var messages []*events.OutboxMessage
+		if update != nil {
+			if messages, err = update(trip); err != nil {
+				return nil, false, err
+			}
+		}
+		changedEvent, err := events.NewOutboxMessage(ctx, contracts.TripEventStatusChanged, statusChangedEvent(trip, transition))
+		if err != nil {
+			return nil, false, err
+		}
+		outbox := append([]*events.OutboxMessage{changedEvent}, messages...)
+
+		err = repo.UpdateTrip(ctx, trip, outbox...)
+		if errors.Is(err, domain.ErrTripConflict) && attempt < maxTripUpdateAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("保存行程失败: %w", err)
+		}
+
+		log.Printf("行程状态变更: 行程ID=%s, %s -> %s, 原因=%s", tripID, transition.From, transition.To, cause)
+		return trip, true, nil
+	}
+}
+
+// statusChangedEvent 返回行程状态变更事件的消息体
+func statusChangedEvent(trip *domain.TripModel, transition domain.TripTransition) contracts.TripStatusChanged {
+	event := contracts.TripStatusChanged{
+		TripID: trip.ID.Hex(),
+		UserID: trip.UserID,
+		From:   string(transition.From),
+		To:     string(transition.To),
+		Cause:  transition.Cause,
+	}
+	if trip.Driver != nil {
+		event.DriverID = trip.Driver.Id
+	}
+	return event
+}
@@ -0,0 +1,535 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"ride-sharing/services/trip-service/internal/domain"
+	"ride-sharing/services/trip-service/internal/infrastructure/repository"
+	"ride-sharing/shared/contracts"
+	"ride-sharing/shared/events"
+	pb "ride-sharing/shared/proto/trip"
+)
+
+// conflictRepository 前conflicts次保存返回版本冲突
+type conflictRepository struct {
+	domain.TripRepository
+	conflicts int
+	saves     int
+}
+
+func (r *conflictRepository) UpdateTrip(ctx context.Context, trip *domain.TripModel, outbox ...*events.OutboxMessage) error {
+	r.saves++
+	if r.saves <= r.conflicts {
+		return domain.ErrTripConflict
+	}
+	return r.TripRepository.UpdateTrip(ctx, trip, outbox...)
+}
+
+func TestTransitionTrip(t *testing.T) {
+	assignDriver := func(trip *domain.TripModel) ([]*events.OutboxMessage, error) {
+		trip.Driver = &pb.TripDriver{Id: "driver-1"}
+		msg, err := events.NewOutboxMessage(context.Background(), contracts.TripEventDriverAssigned, trip.ToProto())
+		return []*events.OutboxMessage{msg}, err
+	}
+
+	tests := []struct {
+		name      string
+		from      domain.TripStatus
+		to        domain.TripStatus
+		check     tripCheck
+		update    tripUpdate
+		conflicts int
+		changed   bool
+		err       error
+		status    domain.TripStatus
+		outbox    []string
+	}{
+		{
+			name:    "allowed transition publishes status change",
+			from:    domain.TripStatusRequested,
+			to:      domain.TripStatusSearching,
+			changed: true,
+			status:  domain.TripStatusSearching,
+			outbox:  []string{contracts.TripEventStatusChanged},
+		},
+		{
+			name:    "update messages follow status change",
+			from:    domain.TripStatusSearching,
+			to:      domain.TripStatusDriverAssigned,
+			update:  assignDriver,
+			changed: true,
+			status:  domain.TripStatusDriverAssigned,
+			outbox:  []string{contracts.TripEventStatusChanged, contracts.TripEventDriverAssigned},
+		},
+		{
+			name:   "already in target status",
+			from:   domain.TripStatusDriverAssigned,
+			to:     domain.TripStatusDriverAssigned,
+			update: assignDriver,
+			status: domain.TripStatusDriverAssigned,
+		},
+		{
+			name:   "invalid transition",
+			from:   domain.TripStatusCancelled,
+			to:     domain.TripStatusDriverAssigned,
+			update: assignDriver,
+			err:    domain.ErrInvalidTripTransition,
+			status: domain.TripStatusCancelled,
+		},
+		{
+			name:   "check rejects before transition",
+			from:   domain.TripStatusDriverAssigned,
+			to:     domain.TripStatusDriverArrived,
+			check:  driverCheck("driver-2"),
+			err:    domain.ErrTripForbidden,
+			status: domain.TripStatusDriverAssigned,
+		},
+		{
+			name:      "conflict is retried",
+			from:      domain.TripStatusSearching,
+			to:        domain.TripStatusNoDriversFound,
+			conflicts: maxTripUpdateAttempts - 1,
+			changed:   true,
+			status:    domain.TripStatusNoDriversFound,
+			outbox:    []string{contracts.TripEventStatusChanged},
+		},
+		{
+			name:      "conflict after last attempt",
+			from:      domain.TripStatusSearching,
+			to:        domain.TripStatusNoDriversFound,
+			conflicts: maxTripUpdateAttempts,
+			err:       domain.ErrTripConflict,
+			status:    domain.TripStatusSearching,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			inmem := repository.NewInmemRepository()
+			trip := &domain.TripModel{ID: primitive.NewObjectID(), UserID: "rider-1", Status: tt.from}
+			if _, err := inmem.CreateTrip(ctx, trip); err != nil {
+				t.Fatalf("CreateTrip: %v", err)
+			}
+			repo := &conflictRepository{TripRepository: inmem, conflicts: tt.conflicts}
+
+			got, changed, err := transitionTrip(ctx, repo, trip.ID.Hex(), tt.to, "test.cause", tt.check, tt.update)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if changed != tt.changed {
+				t.Errorf("got changed %v, want %v", changed, tt.changed)
+			}
+			// 不合法的变更返回当前的行程，调用方据此判断如何处理
+			if errors.Is(err, domain.ErrInvalidTripTransition) && (got == nil || got.Status != tt.from) {
+				t.Errorf("got trip %+v, want current trip", got)
+			}
+
+			stored, err := inmem.GetTripByID(ctx, trip.ID.Hex())
+			if err != nil {
+				t.Fatalf("GetTripByID: %v", err)
+			}
+			if stored.Status != tt.status {
+				t.Errorf("got stored status %q, want %q", stored.Status, tt.status)
+			}
+
+			pending, _ := inmem.PendingOutbox(ctx, 10)
+			var keys []string
+			for _, msg := range pending {
+				keys = append(keys, msg.RoutingKey)
+			}
+			if !reflect.DeepEqual(keys, tt.outbox) {
+				t.Fatalf("got outbox %v, want %v", keys, tt.outbox)
+			}
+			if len(pending) == 0 {
+				return
+			}
+
+			var event contracts.TripStatusChanged
+			if err := json.Unmarshal(pending[0].Payload, &event); err != nil {
+				t.Fatalf("decode status change: %v", err)
+			}
+			if event.From != string(tt.from) || event.To != string(tt.to) || event.Cause != "test.cause" || event.UserID != "rider-1" {
+				t.Errorf("unexpected status change %+v", event)
+			}
+		})
+	}
+}
+
+func TestTransitionTripNotFound(t *testing.T) {
+	repo := repository.NewInmemRepository()
+
+	_, _, err := transitionTrip(context.Background(), repo, primitive.NewObjectID().Hex(), domain.TripStatusSearching, "test.cause", nil, nil)
+	if !errors.Is(err, domain.ErrTripNotFound) {
+		t.Errorf("got %v, want ErrTripNotFound", err)
+	}
+}
+
+func TestUpdatePaymentStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		from      domain.TripStatus
+		conflicts int
+		err       error
+		status    domain.TripStatus
+		paid      bool
+		outbox    []string
+	}{
+		{
+			name:   "payment before ride starts",
+			from:   domain.TripStatusDriverAssigned,
+			status: domain.TripStatusPaid,
+			paid:   true,
+			outbox: []string{contracts.TripEventStatusChanged},
+		},
+		{
+			name:   "ride started before payment keeps status",
+			from:   domain.TripStatusInProgress,
+			status: domain.TripStatusInProgress,
+			paid:   true,
+		},
+		{
+			name:   "ride completed before payment keeps status",
+			from:   domain.TripStatusCompleted,
+			status: domain.TripStatusCompleted,
+			paid:   true,
+		},
+		{
+			name:      "conflict while recording payment is retried",
+			from:      domain.TripStatusInProgress,
+			conflicts: maxTripUpdateAttempts - 1,
+			status:    domain.TripStatusInProgress,
+			paid:      true,
+		},
+		{
+			name:   "cancelled trip rejects payment",
+			from:   domain.TripStatusCancelled,
+			err:    domain.ErrInvalidTripTransition,
+			status: domain.TripStatusCancelled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			inmem := repository.NewInmemRepository()
+			trip := &domain.TripModel{ID: primitive.NewObjectID(), UserID: "rider-1", Status: tt.from}
+			if _, err := inmem.CreateTrip(ctx, trip); err != nil {
+				t.Fatalf("CreateTrip: %v", err)
+			}
+			svc := NewService(&conflictRepository{TripRepository: inmem, conflicts: tt.conflicts}, nil, nil)
+
+			err := svc.UpdatePaymentStatus(ctx, trip.ID.Hex(), domain.TripStatusPaid, contracts.PaymentEventSuccess)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+
+			stored, err := inmem.GetTripByID(ctx, trip.ID.Hex())
+			if err != nil {
+				t.Fatalf("GetTripByID: %v", err)
+			}
+			if stored.Status != tt.status || stored.Paid != tt.paid {
+				t.Errorf("got status %q paid %v, want %q paid %v", stored.Status, stored.Paid, tt.status, tt.paid)
+			}
+
+			if keys := outboxKeys(inmem); !reflect.DeepEqual(keys, tt.outbox) {
+				t.Errorf("got outbox %v, want %v", keys, tt.outbox)
+			}
+		})
+	}
+}
+
+// outboxKeys 返回发件箱中待投递消息的路由键
+func outboxKeys(repo domain.TripRepository) []string {
+	pending, _ := repo.PendingOutbox(context.Background(), 10)
+	var keys []string
+	for _, msg := range pending {
+		keys = append(keys, msg.RoutingKey)
+	}
+	return keys
+}
+
+// createTrip 保存乘客的行程，driverID不为空时分配司机
+func createTrip(t *testing.T, repo domain.TripRepository, userID, driverID string, status domain.TripStatus) *domain.TripModel {
+	t.Helper()
+	trip := &domain.TripModel{ID: primitive.NewObjectID(), UserID: userID, Status: status}
+	if driverID != "" {
+		trip.Driver = &pb.TripDriver{Id: driverID}
+	}
+	if _, err := repo.CreateTrip(context.Background(), trip); err != nil {
+		t.Fatalf("CreateTrip: %v", err)
+	}
+	return trip
+}
+
+func TestCancelTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     domain.TripStatus
+		driver   string
+		userID   string
+		driverID string
+		err      error
+		status   domain.TripStatus
+		by       string
+	}{
+		{
+			name:   "rider cancels while searching",
+			from:   domain.TripStatusSearching,
+			userID: "rider-1",
+			status: domain.TripStatusCancelled,
+			by:     contracts.CancelledByRider,
+		},
+		{
+			name:     "assigned driver cancels",
+			from:     domain.TripStatusDriverArrived,
+			driver:   "driver-1",
+			driverID: "driver-1",
+			status:   domain.TripStatusCancelled,
+			by:       contracts.CancelledByDriver,
+		},
+		{
+			name:   "another rider is forbidden",
+			from:   domain.TripStatusSearching,
+			userID: "rider-2",
+			err:    domain.ErrTripForbidden,
+			status: domain.TripStatusSearching,
+		},
+		{
+			name:     "unassigned driver is forbidden",
+			from:     domain.TripStatusDriverAssigned,
+			driver:   "driver-1",
+			driverID: "driver-2",
+			err:      domain.ErrTripForbidden,
+			status:   domain.TripStatusDriverAssigned,
+		},
+		{
+			name:     "driver cannot cancel before assignment",
+			from:     domain.TripStatusSearching,
+			driverID: "driver-1",
+			err:      domain.ErrTripForbidden,
+			status:   domain.TripStatusSearching,
+		},
+		{
+			name:     "rider and driver both set",
+			from:     domain.TripStatusSearching,
+			userID:   "rider-1",
+			driverID: "driver-1",
+			err:      domain.ErrInvalidTripRequest,
+			status:   domain.TripStatusSearching,
+		},
+		{
+			name:   "cancel after ride started",
+			from:   domain.TripStatusInProgress,
+			driver: "driver-1",
+			userID: "rider-1",
+			err:    domain.ErrInvalidTripTransition,
+			status: domain.TripStatusInProgress,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := repository.NewInmemRepository()
+			trip := createTrip(t, repo, "rider-1", tt.driver, tt.from)
+			svc := NewService(repo, nil, nil)
+
+			_, err := svc.CancelTrip(ctx, trip.ID.Hex(), tt.userID, tt.driverID, "changed plans")
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			stored, _ := repo.GetTripByID(ctx, trip.ID.Hex())
+			if stored.Status != tt.status {
+				t.Errorf("got status %q, want %q", stored.Status, tt.status)
+			}
+
+			keys := outboxKeys(repo)
+			if tt.err != nil {
+				if len(keys) != 0 {
+					t.Errorf("rejected cancel wrote outbox %v", keys)
+				}
+				return
+			}
+			want := []string{contracts.TripEventStatusChanged, contracts.TripEventCancelled}
+			if !reflect.DeepEqual(keys, want) {
+				t.Fatalf("got outbox %v, want %v", keys, want)
+			}
+
+			// 取消事件携带取消方和已分配的司机，Saga据此释放司机
+			pending, _ := repo.PendingOutbox(ctx, 10)
+			var event contracts.TripCancelled
+			if err := json.Unmarshal(pending[1].Payload, &event); err != nil {
+				t.Fatalf("decode cancellation: %v", err)
+			}
+			wantEvent := contracts.TripCancelled{
+				TripID:      trip.ID.Hex(),
+				UserID:      "rider-1",
+				DriverID:    tt.driver,
+				CancelledBy: tt.by,
+				Reason:      "changed plans",
+			}
+			if event != wantEvent {
+				t.Errorf("got cancellation %+v, want %+v", event, wantEvent)
+			}
+		})
+	}
+}
+
+func TestCompleteTrip(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInmemRepository()
+	trip := createTrip(t, repo, "rider-1", "driver-1", domain.TripStatusInProgress)
+	svc := NewService(repo, nil, nil)
+
+	if _, err := svc.CompleteTrip(ctx, trip.ID.Hex(), "driver-2"); !errors.Is(err, domain.ErrTripForbidden) {
+		t.Fatalf("complete by another driver: got %v, want ErrTripForbidden", err)
+	}
+	if _, err := svc.CompleteTrip(ctx, trip.ID.Hex(), ""); !errors.Is(err, domain.ErrInvalidTripRequest) {
+		t.Fatalf("complete without driver: got %v, want ErrInvalidTripRequest", err)
+	}
+
+	completed, err := svc.CompleteTrip(ctx, trip.ID.Hex(), "driver-1")
+	if err != nil {
+		t.Fatalf("CompleteTrip: %v", err)
+	}
+	if completed.Status != domain.TripStatusCompleted {
+		t.Errorf("got status %q, want completed", completed.Status)
+	}
+
+	// 行程完成事件与释放司机的命令和状态变更一起写入发件箱
+	want := []string{contracts.TripEventStatusChanged, contracts.TripEventCompleted, contracts.DriverCmdTripRelease}
+	if keys := outboxKeys(repo); !reflect.DeepEqual(keys, want) {
+		t.Fatalf("got outbox %v, want %v", keys, want)
+	}
+	pending, _ := repo.PendingOutbox(ctx, 10)
+	var release contracts.DriverTripRelease
+	if err := json.Unmarshal(pending[2].Payload, &release); err != nil {
+		t.Fatalf("decode release: %v", err)
+	}
+	if release.TripID != trip.ID.Hex() || release.DriverID != "driver-1" {
+		t.Errorf("got release %+v", release)
+	}
+
+	// 已完成的行程不能再完成或取消
+	if _, err := svc.CompleteTrip(ctx, trip.ID.Hex(), "driver-1"); err != nil {
+		t.Errorf("complete again: %v", err)
+	}
+	if _, err := svc.CancelTrip(ctx, trip.ID.Hex(), "rider-1", "", ""); !errors.Is(err, domain.ErrInvalidTripTransition) {
+		t.Errorf("cancel completed trip: got %v, want ErrInvalidTripTransition", err)
+	}
+	if keys := outboxKeys(repo); len(keys) != len(want) {
+		t.Errorf("got outbox %v after completion, want %v", keys, want)
+	}
+}
+
+func TestListTrips(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInmemRepository()
+	svc := NewService(repo, nil, nil)
+
+	statuses := []domain.TripStatus{
+		domain.TripStatusCompleted,
+		domain.TripStatusCancelled,
+		domain.TripStatusCompleted,
+		domain.TripStatusInProgress,
+		domain.TripStatusSearching,
+	}
+	var riderTrips []string
+	for i, status := range statuses {
+		driver := ""
+		if i%2 == 0 {
+			driver = "driver-1"
+		}
+		trip := createTrip(t, repo, "rider-1", driver, status)
+		// 从新到旧排列
+		riderTrips = append([]string{trip.ID.Hex()}, riderTrips...)
+	}
+	createTrip(t, repo, "rider-2", "driver-1", domain.TripStatusCompleted)
+
+	// 按翻页标记逐页读取，行程不重复不遗漏，最后一页的标记为空
+	var got []string
+	filter := domain.TripFilter{UserID: "rider-1", PageSize: 2}
+	for pages := 0; ; pages++ {
+		if pages > len(statuses) {
+			t.Fatal("page token does not advance")
+		}
+		trips, next, err := svc.ListTrips(ctx, filter)
+		if err != nil {
+			t.Fatalf("ListTrips: %v", err)
+		}
+		if len(trips) > filter.PageSize {
+			t.Fatalf("got %d trips on a page of %d", len(trips), filter.PageSize)
+		}
+		for _, trip := range trips {
+			got = append(got, trip.ID.Hex())
+		}
+		if next == "" {
+			break
+		}
+		filter.PageToken = next
+	}
+	if !reflect.DeepEqual(got, riderTrips) {
+		t.Errorf("got trips %v, want %v", got, riderTrips)
+	}
+
+	tests := []struct {
+		name   string
+		filter domain.TripFilter
+		count  int
+		err    error
+	}{
+		{
+			name:   "rider trips by status",
+			filter: domain.TripFilter{UserID: "rider-1", Statuses: []domain.TripStatus{domain.TripStatusCompleted, domain.TripStatusCancelled}},
+			count:  3,
+		},
+		{
+			name:   "driver trips across riders",
+			filter: domain.TripFilter{DriverID: "driver-1"},
+			count:  4,
+		},
+		{
+			name:   "driver trips by status",
+			filter: domain.TripFilter{DriverID: "driver-1", Statuses: []domain.TripStatus{domain.TripStatusCompleted}},
+			count:  3,
+		},
+		{
+			name:   "rider and driver both set",
+			filter: domain.TripFilter{UserID: "rider-1", DriverID: "driver-1"},
+			err:    domain.ErrInvalidTripRequest,
+		},
+		{
+			name:   "unknown status",
+			filter: domain.TripFilter{UserID: "rider-1", Statuses: []domain.TripStatus{"lost"}},
+			err:    domain.ErrInvalidTripRequest,
+		},
+		{
+			name:   "malformed page token",
+			filter: domain.TripFilter{UserID: "rider-1", PageToken: "page-2"},
+			err:    domain.ErrInvalidTripRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trips, next, err := svc.ListTrips(ctx, tt.filter)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if len(trips) != tt.count || next != "" {
+				t.Errorf("got %d trips and token %q, want %d trips on one page", len(trips), next, tt.count)
+			}
+			for _, trip := range trips {
+				if !tt.filter.Matches(trip) {
+					t.Errorf("trip %s does not match the filter", trip.ID.Hex())
+				}
+			}
+		})
+	}
+}
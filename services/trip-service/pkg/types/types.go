@@ -3,13 +3,23 @@ package types
 import pb "ride-sharing/shared/proto/trip"
 
 type OsrmApiResponse struct {
-	Routes []struct {
-		Distance float64 `json:"distance"`
-		Duration float64 `json:"duration"`
-		Geometry struct {
-			Coordinates [][]float64 `json:"coordinates"`
-		} `json:"geometry"`
-	} `json:"routes"`
+	Routes []OsrmRoute `json:"routes"`
+}
+
+// OsrmRoute 一条路线，距离单位为米，时长单位为秒，坐标为GeoJSON顺序的[经度, 纬度]
+type OsrmRoute struct {
+	Distance float64 `json:"distance"`
+	Duration float64 `json:"duration"`
+	Geometry struct {
+		Coordinates [][]float64 `json:"coordinates"`
+	} `json:"geometry"`
+}
+
+// NewRouteResponse 返回只有一条路线的响应，供非OSRM的路线来源使用
+func NewRouteResponse(distance, duration float64, coordinates [][]float64) *OsrmApiResponse {
+	route := OsrmRoute{Distance: distance, Duration: duration}
+	route.Geometry.Coordinates = coordinates
+	return &OsrmApiResponse{Routes: []OsrmRoute{route}}
 }
 
 func (o *OsrmApiResponse) ToProto() *pb.Route {
@@ -0,0 +1,51 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"ride-sharing/shared/events"
+)
+
+// Archiver 将旁路队列收到的消息追加到归档日志
+type Archiver struct {
+	log        *Log
+	subscriber events.Subscriber
+}
+
+// NewArchiver 创建归档器
+func NewArchiver(l *Log, subscriber events.Subscriber) *Archiver {
+	return &Archiver{log: l, subscriber: subscriber}
+}
+
+// Start 订阅拓扑中声明的所有旁路队列
+// 归档逐条进行，保证日志中同一队列的记录与到达顺序一致
+func (a *Archiver) Start(topology *events.Topology) error {
+	for _, q := range topology.Queues {
+		if !q.Tap {
+			continue
+		}
+
+		exchange := q.Exchange
+		if err := events.SubscribeQueue(a.subscriber, q.Name, a.handler(exchange)); err != nil {
+			return fmt.Errorf("订阅归档队列 %s 失败: %w", q.Name, err)
+		}
+		log.Printf("开始归档交换器 %s 的消息: 队列=%s", exchange, q.Name)
+	}
+	return nil
+}
+
+// handler 返回归档指定交换器消息的处理函数，写入失败时消息按队列的重试策略重新投递
+func (a *Archiver) handler(exchange string) events.Handler {
+	return func(ctx context.Context, env events.Envelope) error {
+		record, err := NewRecord(exchange, env)
+		if err != nil {
+			return err
+		}
+		if _, err := a.log.Append(record); err != nil {
+			return fmt.Errorf("归档消息失败: %w", err)
+		}
+		return nil
+	}
+}
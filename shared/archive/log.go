@@ -0,0 +1,346 @@
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 段文件及其索引的扩展名，文件名为段内第一条记录的序号
+const (
+	segmentExt   = ".jsonl"
+	timeIndexExt = ".timeindex"
+	tripIndexExt = ".tripindex"
+)
+
+// Options 归档日志选项
+type Options struct {
+	// SegmentBytes 单个段文件的大小上限，超过后滚动到新段
+	SegmentBytes int64
+	// IndexInterval 时间索引的稀疏间隔，每隔多少条记录写入一个索引项
+	IndexInterval int
+	// SyncInterval 两次fsync之间的最长间隔，为0时每条记录都立即同步
+	SyncInterval time.Duration
+}
+
+// DefaultOptions 返回默认归档日志选项
+func DefaultOptions() Options {
+	return Options{
+		SegmentBytes:  64 << 20,
+		IndexInterval: 64,
+		SyncInterval:  1 * time.Second,
+	}
+}
+
+// Log 只追加的分段归档日志，同一目录同时只能有一个写入者
+type Log struct {
+	dir  string
+	opts Options
+
+	mu       sync.Mutex
+	active   *activeSegment
+	nextSeq  uint64
+	lastTime time.Time
+	lastSync time.Time
+	closed   bool
+}
+
+// activeSegment 正在写入的段
+type activeSegment struct {
+	base      uint64
+	data      *os.File
+	timeIndex *os.File
+	tripIndex *os.File
+	size      int64
+	// count 段内记录数，决定何时写入稀疏时间索引
+	count int
+}
+
+// Open 打开或创建目录中的归档日志
+// 最后一个段末尾因崩溃而不完整的记录会被截断，该段的索引根据数据重建
+func Open(dir string, opts Options) (*Log, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建归档目录失败: %w", err)
+	}
+
+	bases, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Log{dir: dir, opts: opts, nextSeq: 1, lastSync: time.Now()}
+	if len(bases) == 0 {
+		if err := l.roll(1); err != nil {
+			return nil, err
+		}
+		return l, nil
+	}
+
+	if err := l.recover(bases[len(bases)-1]); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// recover 重新打开最后一个段：截断不完整的记录并重建索引
+func (l *Log) recover(base uint64) error {
+	path := segmentPath(l.dir, base, segmentExt)
+	data, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return fmt.Errorf("打开归档段失败: %w", err)
+	}
+
+	seg := &activeSegment{base: base, data: data}
+	if seg.timeIndex, err = createFile(segmentPath(l.dir, base, timeIndexExt)); err != nil {
+		seg.close()
+		return err
+	}
+	if seg.tripIndex, err = createFile(segmentPath(l.dir, base, tripIndexExt)); err != nil {
+		seg.close()
+		return err
+	}
+
+	l.nextSeq = base
+	err = readSegment(data, 0, func(r Record, offset int64) error {
+		l.nextSeq = r.Seq + 1
+		l.lastTime = r.ArchivedAt
+		if err := l.index(seg, r, offset); err != nil {
+			return err
+		}
+		seg.count++
+		return nil
+	}, &seg.size)
+	if err != nil {
+		seg.close()
+		return fmt.Errorf("读取归档段失败: %w", err)
+	}
+
+	// 丢弃最后一条完整记录之后的内容
+	if err := data.Truncate(seg.size); err != nil {
+		seg.close()
+		return fmt.Errorf("截断归档段失败: %w", err)
+	}
+	if _, err := data.Seek(seg.size, io.SeekStart); err != nil {
+		seg.close()
+		return err
+	}
+
+	l.active = seg
+	return nil
+}
+
+// Append 追加一条记录，分配序号和归档时间后返回
+func (l *Log) Append(r Record) (Record, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return r, errors.New("归档日志已关闭")
+	}
+
+	r.Seq = l.nextSeq
+	r.ArchivedAt = time.Now().UTC()
+	// 时钟回拨时保持归档时间单调不减，时间索引依赖这一点
+	if r.ArchivedAt.Before(l.lastTime) {
+		r.ArchivedAt = l.lastTime
+	}
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return r, fmt.Errorf("序列化归档记录失败: %w", err)
+	}
+	line = append(line, '\n')
+
+	if l.active.size > 0 && l.active.size+int64(len(line)) > l.opts.SegmentBytes {
+		if err := l.roll(r.Seq); err != nil {
+			return r, err
+		}
+	}
+
+	seg := l.active
+	offset := seg.size
+	if _, err := seg.data.Write(line); err != nil {
+		return r, fmt.Errorf("写入归档段失败: %w", err)
+	}
+	seg.size += int64(len(line))
+
+	if err := l.index(seg, r, offset); err != nil {
+		return r, err
+	}
+	seg.count++
+
+	l.nextSeq++
+	l.lastTime = r.ArchivedAt
+
+	if time.Since(l.lastSync) >= l.opts.SyncInterval {
+		if err := seg.sync(); err != nil {
+			return r, err
+		}
+		l.lastSync = time.Now()
+	}
+	return r, nil
+}
+
+// index 为记录写入时间索引和行程索引
+func (l *Log) index(seg *activeSegment, r Record, offset int64) error {
+	if l.opts.IndexInterval <= 1 || seg.count%l.opts.IndexInterval == 0 {
+		if _, err := fmt.Fprintf(seg.timeIndex, "%d %d\n", r.ArchivedAt.UnixNano(), offset); err != nil {
+			return fmt.Errorf("写入时间索引失败: %w", err)
+		}
+	}
+	if r.TripID != "" {
+		if _, err := fmt.Fprintf(seg.tripIndex, "%s %d\n", r.TripID, offset); err != nil {
+			return fmt.Errorf("写入行程索引失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// roll 同步并关闭当前段，创建以base为起始序号的新段
+func (l *Log) roll(base uint64) error {
+	if l.active != nil {
+		if err := l.active.sync(); err != nil {
+			return err
+		}
+		l.active.close()
+		l.active = nil
+	}
+
+	seg := &activeSegment{base: base}
+	var err error
+	if seg.data, err = createFile(segmentPath(l.dir, base, segmentExt)); err != nil {
+		return err
+	}
+	if seg.timeIndex, err = createFile(segmentPath(l.dir, base, timeIndexExt)); err != nil {
+		seg.close()
+		return err
+	}
+	if seg.tripIndex, err = createFile(segmentPath(l.dir, base, tripIndexExt)); err != nil {
+		seg.close()
+		return err
+	}
+
+	l.active = seg
+	return nil
+}
+
+// Sync 将已追加的记录和索引刷到磁盘
+func (l *Log) Sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil
+	}
+	l.lastSync = time.Now()
+	return l.active.sync()
+}
+
+// Close 同步并关闭日志
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	err := l.active.sync()
+	l.active.close()
+	return err
+}
+
+func (s *activeSegment) sync() error {
+	for _, f := range []*os.File{s.data, s.timeIndex, s.tripIndex} {
+		if err := f.Sync(); err != nil {
+			return fmt.Errorf("同步归档文件失败: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *activeSegment) close() {
+	for _, f := range []*os.File{s.data, s.timeIndex, s.tripIndex} {
+		if f != nil {
+			f.Close()
+		}
+	}
+}
+
+// createFile 创建或清空文件并以追加方式打开
+func createFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("创建归档文件失败: %w", err)
+	}
+	return f, nil
+}
+
+// segmentPath 段文件或索引文件的路径
+func segmentPath(dir string, base uint64, ext string) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", base, ext))
+}
+
+// listSegments 按起始序号升序返回目录中的段
+func listSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取归档目录失败: %w", err)
+	}
+
+	var bases []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		base, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		bases = append(bases, base)
+	}
+	sort.Slice(bases, func(i, j int) bool { return bases[i] < bases[j] })
+	return bases, nil
+}
+
+// readSegment 从offset开始逐条读取段中的记录，遇到不完整或无法解析的行时停止
+// end记录最后一条完整记录之后的偏移量
+func readSegment(f *os.File, offset int64, fn func(r Record, offset int64) error, end *int64) error {
+	reader := bufio.NewReaderSize(io.NewSectionReader(f, offset, 1<<62), 64<<10)
+	if end != nil {
+		*end = offset
+	}
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// 末尾没有换行符的行是写入到一半的记录
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var r Record
+		if err := json.Unmarshal(line, &r); err != nil {
+			return nil
+		}
+		if err := fn(r, offset); err != nil {
+			return err
+		}
+		offset += int64(len(line))
+		if end != nil {
+			*end = offset
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package archive
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ride-sharing/shared/events"
+)
+
+// Filter 回放或查看时的记录过滤条件，零值字段不参与过滤
+type Filter struct {
+	// From、To 归档时间范围 [From, To)
+	From time.Time
+	To   time.Time
+	// TripID 只返回该行程的记录，使用行程索引定位
+	TripID string
+	// Exchange 消息发布到的交换器
+	Exchange string
+	// RoutingKey 路由键模式，支持 * 和 # 通配符
+	RoutingKey string
+	// Limit 最多返回的记录数
+	Limit int
+}
+
+// match 判断记录是否满足除Limit外的条件
+func (f Filter) match(r Record) bool {
+	switch {
+	case !f.From.IsZero() && r.ArchivedAt.Before(f.From):
+		return false
+	case !f.To.IsZero() && !r.ArchivedAt.Before(f.To):
+		return false
+	case f.TripID != "" && r.TripID != f.TripID:
+		return false
+	case f.Exchange != "" && r.Exchange != f.Exchange:
+		return false
+	case f.RoutingKey != "" && !events.MatchRoutingKey(f.RoutingKey, r.RoutingKey):
+		return false
+	}
+	return true
+}
+
+// errStop 终止扫描
+var errStop = errors.New("停止扫描")
+
+// Reader 归档日志的只读访问，可以在归档器写入的同时读取
+type Reader struct {
+	dir string
+}
+
+// OpenReader 打开目录中的归档日志用于读取
+func OpenReader(dir string) (*Reader, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("归档目录不可用: %w", err)
+	}
+	return &Reader{dir: dir}, nil
+}
+
+// Scan 按序号顺序对满足过滤条件的记录调用fn
+// 指定TripID时通过行程索引定位记录，否则通过时间索引跳过范围之外的段和段内位置
+func (r *Reader) Scan(filter Filter, fn func(Record) error) error {
+	bases, err := listSegments(r.dir)
+	if err != nil {
+		return err
+	}
+
+	matched := 0
+	visit := func(rec Record, _ int64) error {
+		if !filter.To.IsZero() && !rec.ArchivedAt.Before(filter.To) {
+			return errStop
+		}
+		if !filter.match(rec) {
+			return nil
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+		matched++
+		if filter.Limit > 0 && matched >= filter.Limit {
+			return errStop
+		}
+		return nil
+	}
+
+	for i, base := range bases {
+		var err error
+		if filter.TripID != "" {
+			err = r.scanTrip(base, filter.TripID, visit)
+		} else {
+			var next uint64
+			if i+1 < len(bases) {
+				next = bases[i+1]
+			}
+			err = r.scanTime(base, next, filter, visit)
+		}
+		if errors.Is(err, errStop) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanTime 从时间索引中不晚于From的最后一个位置开始顺序读取段
+// next为下一个段的起始序号，其第一条记录的归档时间是本段的上界
+func (r *Reader) scanTime(base, next uint64, filter Filter, visit func(Record, int64) error) error {
+	entries, err := readTimeIndex(segmentPath(r.dir, base, timeIndexExt))
+	if err != nil {
+		return err
+	}
+
+	if !filter.To.IsZero() && len(entries) > 0 && !entries[0].at.Before(filter.To) {
+		return errStop
+	}
+	if !filter.From.IsZero() && next != 0 {
+		nextEntries, err := readTimeIndex(segmentPath(r.dir, next, timeIndexExt))
+		if err != nil {
+			return err
+		}
+		if len(nextEntries) > 0 && !nextEntries[0].at.After(filter.From) {
+			return nil
+		}
+	}
+
+	var offset int64
+	if !filter.From.IsZero() {
+		// 第一个晚于From的索引项之前的位置
+		i := sort.Search(len(entries), func(i int) bool { return entries[i].at.After(filter.From) })
+		if i > 0 {
+			offset = entries[i-1].offset
+		}
+	}
+
+	f, err := os.Open(segmentPath(r.dir, base, segmentExt))
+	if err != nil {
+		return fmt.Errorf("打开归档段失败: %w", err)
+	}
+	defer f.Close()
+	return readSegment(f, offset, visit, nil)
+}
+
+// scanTrip 读取行程索引中记录的位置
+func (r *Reader) scanTrip(base uint64, tripID string, visit func(Record, int64) error) error {
+	offsets, err := readTripIndex(segmentPath(r.dir, base, tripIndexExt), tripID)
+	if err != nil || len(offsets) == 0 {
+		return err
+	}
+
+	f, err := os.Open(segmentPath(r.dir, base, segmentExt))
+	if err != nil {
+		return fmt.Errorf("打开归档段失败: %w", err)
+	}
+	defer f.Close()
+
+	for _, offset := range offsets {
+		// 每个位置只读取一条记录
+		err := readSegment(f, offset, func(rec Record, off int64) error {
+			if err := visit(rec, off); err != nil {
+				return err
+			}
+			return errStopRecord
+		}, nil)
+		if err != nil && !errors.Is(err, errStopRecord) {
+			return err
+		}
+	}
+	return nil
+}
+
+// errStopRecord 读取单条记录后停止
+var errStopRecord = errors.New("停止读取")
+
+// timeIndexEntry 时间索引项
+type timeIndexEntry struct {
+	at     time.Time
+	offset int64
+}
+
+// readTimeIndex 读取时间索引，文件不存在时返回空索引，此时从段的开头扫描
+func readTimeIndex(path string) ([]timeIndexEntry, error) {
+	var entries []timeIndexEntry
+	err := readIndex(path, func(key string, offset int64) {
+		nanos, err := strconv.ParseInt(key, 10, 64)
+		if err == nil {
+			entries = append(entries, timeIndexEntry{at: time.Unix(0, nanos), offset: offset})
+		}
+	})
+	return entries, err
+}
+
+// readTripIndex 返回行程在段中的记录位置
+func readTripIndex(path, tripID string) ([]int64, error) {
+	var offsets []int64
+	err := readIndex(path, func(key string, offset int64) {
+		if key == tripID {
+			offsets = append(offsets, offset)
+		}
+	})
+	return offsets, err
+}
+
+// readIndex 读取 "键 偏移量" 格式的索引文件，忽略不完整的行
+func readIndex(path string, fn func(key string, offset int64)) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("打开索引失败: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, rawOffset, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+		offset, err := strconv.ParseInt(rawOffset, 10, 64)
+		if err != nil {
+			continue
+		}
+		fn(key, offset)
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,156 @@
+/*
+Package archive 事件归档与回放
+
+归档器以旁路队列订阅交换器上的全部消息，按到达顺序追加到本地只追加的段日志中。
+每条记录一行JSON，段文件超过大小上限后滚动；每个段附带按归档时间的稀疏索引和按行程ID的索引，
+回放时按时间范围或行程ID定位记录，再通过发布器重新投递到指定的交换器或队列。
+
+归档器像其他消费者一样验证签名并解密负载，因此需要与消费者相同的EVENTS_SIGNING_KEYS和EVENTS_ENCRYPTION_KEYS。
+传输中加密的负载不会以明文落盘：写入前使用当前加密密钥重新加密，记录中保存密钥ID；
+行程ID在加密前提取，以明文写入记录和索引。回放时解密后交给发布器按安全策略重新签名和加密，
+因此回放工具需要同样的配置，并且密钥环中仍保留归档所用的密钥，退役密钥前需要先确认不再回放用它加密的记录。
+*/
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"ride-sharing/shared/events"
+)
+
+// Record 一条归档的消息
+type Record struct {
+	// Seq 归档日志内单调递增的序号
+	Seq uint64 `json:"seq"`
+	// ArchivedAt 写入归档的时间，在日志内单调不减，时间索引基于该字段
+	ArchivedAt time.Time `json:"archivedAt"`
+	Exchange   string    `json:"exchange"`
+	RoutingKey string    `json:"routingKey"`
+	// TripID 从负载中提取的行程ID，无法识别时为空
+	TripID string `json:"tripId,omitempty"`
+
+	EventID       string    `json:"eventId"`
+	EventType     string    `json:"eventType"`
+	SchemaVersion int       `json:"schemaVersion"`
+	Producer      string    `json:"producer,omitempty"`
+	CorrelationID string    `json:"correlationId,omitempty"`
+	CausationID   string    `json:"causationId,omitempty"`
+	OccurredAt    time.Time `json:"occurredAt"`
+	ContentType   string    `json:"contentType"`
+
+	// Data JSON负载原样保存，便于直接查看归档文件
+	Data json.RawMessage `json:"data,omitempty"`
+	// Binary 非JSON负载，例如二进制protobuf，以base64保存；加密的负载同样保存在这里
+	Binary []byte `json:"binary,omitempty"`
+	// EncryptionKeyID 负载在传输中加密时，归档使用该密钥重新加密保存
+	EncryptionKeyID string `json:"encryptionKeyId,omitempty"`
+}
+
+// NewRecord 由消费到的信封创建归档记录，序号和归档时间在追加时分配
+// 传输中加密的负载使用当前加密密钥重新加密后保存
+func NewRecord(exchange string, env events.Envelope) (Record, error) {
+	r := Record{
+		Exchange:      exchange,
+		RoutingKey:    env.RoutingKey,
+		EventID:       env.EventID,
+		EventType:     env.EventType,
+		SchemaVersion: env.SchemaVersion,
+		Producer:      env.Producer,
+		CorrelationID: env.CorrelationID,
+		CausationID:   env.CausationID,
+		OccurredAt:    env.OccurredAt,
+		ContentType:   env.ContentType,
+	}
+
+	if json.Valid(env.Data) {
+		r.TripID = extractTripID(env.RoutingKey, env.Data)
+	}
+
+	switch {
+	case env.EncryptionKeyID != "":
+		keyID, sealed, err := events.SealPayload(env.EventID, env.Data)
+		if err != nil {
+			return Record{}, fmt.Errorf("加密归档负载失败: %w", err)
+		}
+		r.Binary = sealed
+		r.EncryptionKeyID = keyID
+	case json.Valid(env.Data):
+		r.Data = append(json.RawMessage(nil), env.Data...)
+	default:
+		r.Binary = append([]byte(nil), env.Data...)
+	}
+	return r, nil
+}
+
+// Payload 返回原始负载，加密保存的负载先解密
+func (r Record) Payload() ([]byte, error) {
+	if r.EncryptionKeyID != "" {
+		data, err := events.OpenPayload(r.EncryptionKeyID, r.EventID, r.Binary)
+		if err != nil {
+			return nil, fmt.Errorf("解密归档负载失败: %w", err)
+		}
+		return data, nil
+	}
+	if r.Binary != nil {
+		return r.Binary, nil
+	}
+	return r.Data, nil
+}
+
+// Metadata 返回回放时沿用的信封元数据
+func (r Record) Metadata() events.Metadata {
+	return events.Metadata{
+		EventID:       r.EventID,
+		EventType:     r.EventType,
+		SchemaVersion: r.SchemaVersion,
+		Producer:      r.Producer,
+		CorrelationID: r.CorrelationID,
+		CausationID:   r.CausationID,
+		OccurredAt:    r.OccurredAt,
+		ContentType:   r.ContentType,
+	}
+}
+
+// tripIDFields 负载中表示行程ID的字段，依次为Go结构体、protojson和蛇形命名
+var tripIDFields = []string{"tripID", "tripId", "trip_id"}
+
+// extractTripID 从JSON负载中提取行程ID
+// 支持顶层的tripID字段、嵌套的trip.id，以及行程事件中直接以id表示的行程
+func extractTripID(routingKey string, data []byte) string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return ""
+	}
+
+	for _, name := range tripIDFields {
+		if id := jsonString(fields[name]); id != "" {
+			return id
+		}
+	}
+
+	if nested, ok := fields["trip"]; ok {
+		var trip map[string]json.RawMessage
+		if err := json.Unmarshal(nested, &trip); err == nil {
+			if id := jsonString(trip["id"]); id != "" {
+				return id
+			}
+		}
+	}
+
+	if strings.HasPrefix(routingKey, "trip.") {
+		return jsonString(fields["id"])
+	}
+	return ""
+}
+
+// jsonString 解析JSON字符串值，不是字符串时返回空
+func jsonString(raw json.RawMessage) string {
+	var s string
+	if len(raw) == 0 || json.Unmarshal(raw, &s) != nil {
+		return ""
+	}
+	return s
+}
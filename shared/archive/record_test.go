@@ -0,0 +1,68 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"ride-sharing/shared/events"
+)
+
+func TestNewRecordEncryptedPayload(t *testing.T) {
+	keys := events.NewKeyring()
+	keys.Add("enc-1", bytes.Repeat([]byte{2}, 32))
+	signing := events.NewKeyring()
+	signing.Add("sign-1", bytes.Repeat([]byte{1}, 32))
+	if err := events.SetSecurityPolicy(&events.SecurityPolicy{SigningKeys: signing, EncryptionKeys: keys}); err != nil {
+		t.Fatalf("SetSecurityPolicy: %v", err)
+	}
+	t.Cleanup(func() { events.SetSecurityPolicy(nil) })
+
+	data := []byte(`{"tripID":"trip-1","amount":1250}`)
+	env := events.Envelope{RoutingKey: "payment.event.success", Data: data}
+	env.EventID = "event-1"
+
+	// 传输中未加密的负载原样保存
+	plain, err := NewRecord("trip", env)
+	if err != nil {
+		t.Fatalf("NewRecord: %v", err)
+	}
+	if !bytes.Equal(plain.Data, data) || plain.EncryptionKeyID != "" {
+		t.Errorf("got plaintext record %+v", plain)
+	}
+
+	// 传输中加密的负载重新加密后落盘，行程ID仍可用于索引
+	env.EncryptionKeyID = "transport-key"
+	sealed, err := NewRecord("trip", env)
+	if err != nil {
+		t.Fatalf("NewRecord: %v", err)
+	}
+	line, _ := json.Marshal(sealed)
+	if bytes.Contains(line, []byte("amount")) || sealed.Data != nil {
+		t.Errorf("archived line contains the plaintext payload: %s", line)
+	}
+	if sealed.EncryptionKeyID != "enc-1" || sealed.TripID != "trip-1" {
+		t.Errorf("got key %q and trip %q", sealed.EncryptionKeyID, sealed.TripID)
+	}
+
+	var decoded Record
+	if err := json.Unmarshal(line, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	payload, err := decoded.Payload()
+	if err != nil {
+		t.Fatalf("Payload: %v", err)
+	}
+	if !bytes.Equal(payload, data) {
+		t.Errorf("got payload %s, want %s", payload, data)
+	}
+
+	// 没有加密密钥时拒绝写入明文，消息按重试策略重新投递
+	events.SetSecurityPolicy(nil)
+	if _, err := NewRecord("trip", env); err == nil {
+		t.Error("NewRecord without encryption keys succeeded")
+	}
+	if _, err := decoded.Payload(); err == nil {
+		t.Error("Payload without encryption keys succeeded")
+	}
+}
@@ -0,0 +1,107 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ride-sharing/shared/events"
+)
+
+// Replayer 将归档记录重新发布到交换器或直接投递到队列
+type Replayer struct {
+	// Publisher 返回发布到指定交换器的发布器
+	Publisher func(exchange string) (events.Publisher, error)
+	// Exchange 目标交换器，为空时发布回记录原来的交换器
+	Exchange string
+	// Queue 目标队列，非空时绕过交换器路由只投递到该队列，发布器需实现events.QueuePublisher
+	Queue string
+	// NewIDs 为回放的消息分配新的事件ID，因果ID指向原事件
+	// 默认沿用原事件ID，已成功处理过该事件的幂等消费者会将其视为重复消息
+	NewIDs bool
+	// Rate 每秒最多回放的消息数，为0时不限速
+	Rate float64
+	// OnRecord 每条记录回放成功后调用，可用于输出进度
+	OnRecord func(Record)
+}
+
+// ReplayStats 回放结果统计
+type ReplayStats struct {
+	Matched   int
+	Published int
+	// Duplicates 同一事件ID在归档中重复出现（例如归档器重新收到同一消息）时只回放第一次
+	Duplicates int
+}
+
+// Replay 按过滤条件回放记录，出错时停止并返回已完成的统计
+func (rp *Replayer) Replay(ctx context.Context, reader *Reader, filter Filter) (ReplayStats, error) {
+	var stats ReplayStats
+	seen := make(map[string]bool)
+
+	var ticker *time.Ticker
+	if rp.Rate > 0 {
+		ticker = time.NewTicker(time.Duration(float64(time.Second) / rp.Rate))
+		defer ticker.Stop()
+	}
+
+	err := reader.Scan(filter, func(r Record) error {
+		stats.Matched++
+		if r.EventID != "" && seen[r.EventID] {
+			stats.Duplicates++
+			return nil
+		}
+		seen[r.EventID] = true
+
+		if ticker != nil {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err := rp.publish(ctx, r); err != nil {
+			return fmt.Errorf("回放事件 %s(seq=%d) 失败: %w", r.EventID, r.Seq, err)
+		}
+
+		stats.Published++
+		if rp.OnRecord != nil {
+			rp.OnRecord(r)
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// publish 以原始元数据和负载发布一条记录
+func (rp *Replayer) publish(ctx context.Context, r Record) error {
+	exchange := rp.Exchange
+	if exchange == "" {
+		exchange = r.Exchange
+	}
+	publisher, err := rp.Publisher(exchange)
+	if err != nil {
+		return err
+	}
+
+	md := r.Metadata()
+	if rp.NewIDs {
+		md.CausationID = md.EventID
+		md.EventID = ""
+	}
+	data, err := r.Payload()
+	if err != nil {
+		return err
+	}
+	ctx = events.WithMetadata(ctx, md)
+	payload := events.RawPayload{ContentType: r.ContentType, Data: data}
+
+	if rp.Queue == "" {
+		return publisher.PublishConfirmed(ctx, r.RoutingKey, payload)
+	}
+
+	queuePublisher, ok := publisher.(events.QueuePublisher)
+	if !ok {
+		return fmt.Errorf("发布器 %T 不支持直接投递到队列", publisher)
+	}
+	return queuePublisher.PublishToQueue(ctx, rp.Queue, r.RoutingKey, payload)
+}
@@ -1,9 +1,48 @@
 package contracts
 
-// AmqpMessage is the message structure for AMQP.
+import (
+	"encoding/json"
+	"time"
+)
+
+// AmqpMessage is the versioned envelope for every AMQP message.
+// The metadata fields are mirrored into AMQP headers so that brokers and
+// tooling can route and trace messages without decoding the body.
 type AmqpMessage struct {
+	EventID       string `json:"eventId"`
+	EventType     string `json:"eventType"`
+	SchemaVersion int    `json:"schemaVersion"`
+	// OwnerID is the name of the service that produced the message.
 	OwnerID string `json:"ownerId"`
-	Data    []byte `json:"data"`
+	// CorrelationID is shared by every message caused by the same originating request.
+	CorrelationID string `json:"correlationId,omitempty"`
+	// CausationID is the EventID of the message whose handling produced this one.
+	CausationID string    `json:"causationId,omitempty"`
+	OccurredAt  time.Time `json:"occurredAt"`
+	// ContentType is the encoding of Data; empty means application/json.
+	ContentType string `json:"contentType,omitempty"`
+	// EncryptionKeyID names the key Data was encrypted with; empty means Data is plaintext.
+	EncryptionKeyID string `json:"encryptionKeyId,omitempty"`
+	// SigningKeyID names the key Signature was computed with.
+	SigningKeyID string `json:"signingKeyId,omitempty"`
+	// Signature is an HMAC over the envelope fields and the (possibly encrypted) Data.
+	Signature []byte `json:"signature,omitempty"`
+	Data      []byte `json:"data"`
+}
+
+// AmqpBatchItem is one message of a batch. A batch is an AmqpMessage whose
+// ContentType is application/vnd.ride-sharing.batch+json and whose Data is a
+// JSON array of items sharing the routing key of the batch.
+type AmqpBatchItem struct {
+	EventID       string    `json:"eventId"`
+	CorrelationID string    `json:"correlationId,omitempty"`
+	CausationID   string    `json:"causationId,omitempty"`
+	OccurredAt    time.Time `json:"occurredAt"`
+	ContentType   string    `json:"contentType,omitempty"`
+	// Data holds JSON and protojson payloads inline, without base64.
+	Data json.RawMessage `json:"data,omitempty"`
+	// Binary holds payloads of other content types, e.g. binary protobuf.
+	Binary []byte `json:"binary,omitempty"`
 }
 
 // Routing keys - using consistent event/command patterns
@@ -13,6 +52,9 @@ const (
 	TripEventDriverAssigned      = "trip.event.driver_assigned"
 	TripEventNoDriversFound      = "trip.event.no_drivers_found"
 	TripEventDriverNotInterested = "trip.event.driver_not_interested"
+	TripEventStatusChanged       = "trip.event.status_changed"
+	TripEventCancelled           = "trip.event.cancelled"
+	TripEventCompleted           = "trip.event.completed"
 
 	// Driver commands (driver.cmd.*)
 	DriverCmdTripRequest = "driver.cmd.trip_request"
@@ -20,6 +62,7 @@ const (
 	DriverCmdTripDecline = "driver.cmd.trip_decline"
 	DriverCmdLocation    = "driver.cmd.location"
 	DriverCmdRegister    = "driver.cmd.register"
+	DriverCmdTripRelease = "driver.cmd.trip_release"
 
 	// Payment events (payment.event.*)
 	PaymentEventSessionCreated = "payment.event.session_created"
@@ -29,4 +72,58 @@ const (
 
 	// Payment commands (payment.cmd.*)
 	PaymentCmdCreateSession = "payment.cmd.create_session"
+	PaymentCmdCancelSession = "payment.cmd.cancel_session"
+
+	// Trip queries (trip.query.*), answered over request/reply
+	TripQueryGet = "trip.query.get"
+)
+
+// Exchange names. Every routing key above is published on exactly one of these;
+// see events.DefaultTopology for the full declaration.
+const (
+	TripExchange    = "trip"
+	PaymentExchange = "payment"
+)
+
+// Queue names, grouped by the service that consumes them.
+const (
+	// driver-service
+	FindAvailableDriversQueue = "find_available_drivers_queue"
+	DriverLocationUpdateQueue = "driver_location_update_queue"
+	DriverAssignmentQueue     = "driver_assignment_queue"
+	DriverTripReleaseQueue    = "driver_trip_release_queue"
+
+	// trip-service
+	DriverTripResponseQueue = "driver_trip_response_queue"
+	DriverTripDeclineQueue  = "driver_trip_decline_queue"
+	PaymentSuccessQueue     = "payment_success_queue"
+	PaymentFailedQueue      = "payment_failed_queue"
+	TripQueryQueue          = "trip_query_queue"
+	TripDriverLocationQueue = "trip_driver_location_queue"
+
+	// trip-service saga, which also follows payment_success_queue and payment_failed_queue
+	TripSagaCreatedQueue          = "trip_saga_created_queue"
+	TripSagaDriverAssignedQueue   = "trip_saga_driver_assigned_queue"
+	TripSagaPaymentSessionQueue   = "trip_saga_payment_session_queue"
+	TripSagaPaymentCancelledQueue = "trip_saga_payment_cancelled_queue"
+	TripSagaCancelledQueue        = "trip_saga_cancelled_queue"
+
+	// payment-service
+	CreatePaymentSessionQueue = "create_payment_session_queue"
+	CancelPaymentSessionQueue = "cancel_payment_session_queue"
+
+	// api-gateway
+	NotifyNewTripQueue          = "notify_new_trip_queue"
+	NotifyDriverAssignmentQueue = "notify_driver_assignment_queue"
+	NotifyNoDriversFoundQueue   = "notify_no_drivers_found_queue"
+	NotifyDriversQueue          = "notify_drivers_queue"
+	NotifyPaymentStatusQueue    = "notify_payment_status_queue"
+	NotifyPaymentSuccessQueue   = "notify_payment_success_queue"
+	NotifyPaymentFailedQueue    = "notify_payment_failed_queue"
+	NotifyTripCancelledQueue    = "notify_trip_cancelled_queue"
+	NotifyTripCompletedQueue    = "notify_trip_completed_queue"
+
+	// event-archiver, bound with # to receive every message of its exchange
+	TripArchiveQueue    = "trip_archive_queue"
+	PaymentArchiveQueue = "payment_archive_queue"
 )
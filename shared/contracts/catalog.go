@@ -0,0 +1,111 @@
+package contracts
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// EventDescriptor documents one routing key of the event catalog.
+type EventDescriptor struct {
+	RoutingKey string
+	Summary    string
+	// Payload is the Go type carried in AmqpMessage.Data, nil if not registered.
+	Payload reflect.Type
+	// ProtoMessage is the fully qualified protobuf message of the payload,
+	// e.g. trip.Trip, for payloads defined in proto/ rather than in Go.
+	ProtoMessage string
+}
+
+var (
+	catalogMu sync.RWMutex
+	catalog   = map[string]EventDescriptor{}
+)
+
+// RegisterPayload records the payload type of a routing key. payload is a
+// zero value of the type, e.g. DriverTripRequest{}.
+func RegisterPayload(routingKey string, payload interface{}) {
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+
+	d := catalog[routingKey]
+	d.RoutingKey = routingKey
+	d.Payload = reflect.TypeOf(payload)
+	catalog[routingKey] = d
+}
+
+// RegisterProtoPayload records a protobuf message, defined in proto/, as the
+// payload of a routing key. It avoids importing the generated packages here.
+func RegisterProtoPayload(routingKey, messageName string) {
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+
+	d := catalog[routingKey]
+	d.RoutingKey = routingKey
+	d.ProtoMessage = messageName
+	catalog[routingKey] = d
+}
+
+// Catalog returns every known routing key sorted by name. Routing keys
+// without a registered payload have neither Payload nor ProtoMessage set.
+func Catalog() []EventDescriptor {
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+
+	out := make([]EventDescriptor, 0, len(catalog))
+	for _, d := range catalog {
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].RoutingKey < out[j].RoutingKey })
+	return out
+}
+
+// describe adds a routing key with a human readable summary.
+func describe(routingKey, summary string) {
+	catalog[routingKey] = EventDescriptor{RoutingKey: routingKey, Summary: summary}
+}
+
+func init() {
+	describe(TripEventCreated, "A rider created a trip and drivers should be searched.")
+	describe(TripEventDriverAssigned, "A driver accepted the trip and was assigned to it.")
+	describe(TripEventNoDriversFound, "No driver accepted the trip.")
+	describe(TripEventDriverNotInterested, "A driver declined the trip request.")
+	describe(TripEventStatusChanged, "A trip moved to another status of its lifecycle.")
+	describe(TripEventCancelled, "The rider or the assigned driver cancelled the trip.")
+	describe(TripEventCompleted, "The driver dropped the rider off and completed the trip.")
+	describe(DriverCmdTripRequest, "Offer a trip to a specific driver.")
+	describe(DriverCmdTripAccept, "The driver accepted a trip offer.")
+	describe(DriverCmdTripDecline, "The driver declined a trip offer.")
+	describe(DriverCmdLocation, "Periodic location update of a driver.")
+	describe(DriverCmdRegister, "A driver went online with a car package.")
+	describe(DriverCmdTripRelease, "Free a driver whose trip was completed or cancelled after assignment.")
+	describe(PaymentEventSessionCreated, "A checkout session was created for the rider.")
+	describe(PaymentEventSuccess, "The rider paid for the trip.")
+	describe(PaymentEventFailed, "The payment for the trip failed.")
+	describe(PaymentEventCancelled, "The rider cancelled the checkout session.")
+	describe(PaymentCmdCreateSession, "Create a checkout session for a trip.")
+	describe(PaymentCmdCancelSession, "Cancel the pending checkout session of a cancelled trip.")
+	describe(TripQueryGet, "Look up the rider and status of a trip; replied to with a TripSummary.")
+
+	RegisterProtoPayload(TripEventCreated, "trip.Trip")
+	RegisterProtoPayload(TripEventDriverAssigned, "trip.Trip")
+	RegisterProtoPayload(TripEventCompleted, "trip.Trip")
+	RegisterProtoPayload(DriverCmdRegister, "events.DriverRegister")
+	RegisterProtoPayload(PaymentCmdCreateSession, "events.PaymentCreateSession")
+
+	RegisterPayload(TripEventNoDriversFound, TripEventData{})
+	RegisterPayload(TripEventDriverNotInterested, TripEventData{})
+	RegisterPayload(TripEventStatusChanged, TripStatusChanged{})
+	RegisterPayload(TripEventCancelled, TripCancelled{})
+	RegisterPayload(DriverCmdTripRequest, DriverTripRequest{})
+	RegisterPayload(DriverCmdTripAccept, DriverTripResponse{})
+	RegisterPayload(DriverCmdTripDecline, DriverTripResponse{})
+	RegisterPayload(DriverCmdLocation, DriverLocationUpdate{})
+	RegisterPayload(DriverCmdTripRelease, DriverTripRelease{})
+	RegisterPayload(PaymentEventSessionCreated, PaymentEventData{})
+	RegisterPayload(PaymentEventSuccess, PaymentEventData{})
+	RegisterPayload(PaymentEventFailed, PaymentEventData{})
+	RegisterPayload(PaymentEventCancelled, PaymentEventData{})
+	RegisterPayload(PaymentCmdCancelSession, PaymentCancelSession{})
+	RegisterPayload(TripQueryGet, TripQuery{})
+}
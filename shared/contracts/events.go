@@ -0,0 +1,219 @@
+package contracts
+
+import (
+	"errors"
+	"fmt"
+
+	"ride-sharing/shared/types"
+)
+
+// TripEventData is the payload of trip.event.no_drivers_found and
+// trip.event.driver_not_interested.
+type TripEventData struct {
+	TripID   string `json:"tripID"`
+	DriverID string `json:"driverID,omitempty"`
+}
+
+// Validate checks the required fields of the payload.
+func (d TripEventData) Validate() error {
+	if d.TripID == "" {
+		return errors.New("tripID is required")
+	}
+	return nil
+}
+
+// TripQuery is the request payload of trip.query.get.
+type TripQuery struct {
+	TripID string `json:"tripID"`
+}
+
+// Validate checks the required fields of the payload.
+func (q TripQuery) Validate() error {
+	if q.TripID == "" {
+		return errors.New("tripID is required")
+	}
+	return nil
+}
+
+// TripSummary is the reply payload of trip.query.get.
+type TripSummary struct {
+	TripID   string `json:"tripID"`
+	UserID   string `json:"userID"`
+	Status   string `json:"status"`
+	DriverID string `json:"driverID,omitempty"`
+}
+
+// TripStatusChanged is the payload of trip.event.status_changed, published
+// by trip-service on every accepted status transition of a trip.
+type TripStatusChanged struct {
+	TripID   string `json:"tripID"`
+	UserID   string `json:"userID"`
+	DriverID string `json:"driverID,omitempty"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	// Cause is the routing key or RPC that moved the trip, e.g. payment.event.failed.
+	Cause string `json:"cause,omitempty"`
+}
+
+// Validate checks the required fields of the payload.
+func (c TripStatusChanged) Validate() error {
+	if c.TripID == "" {
+		return errors.New("tripID is required")
+	}
+	if c.To == "" {
+		return errors.New("to is required")
+	}
+	return nil
+}
+
+// Parties that can cancel a trip, see TripCancelled.CancelledBy.
+const (
+	CancelledByRider  = "rider"
+	CancelledByDriver = "driver"
+)
+
+// TripCancelled is the payload of trip.event.cancelled, published when the
+// rider or the assigned driver cancels a trip.
+type TripCancelled struct {
+	TripID   string `json:"tripID"`
+	UserID   string `json:"userID"`
+	DriverID string `json:"driverID,omitempty"`
+	// CancelledBy is CancelledByRider or CancelledByDriver.
+	CancelledBy string `json:"cancelledBy"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// Validate checks the required fields of the payload.
+func (c TripCancelled) Validate() error {
+	if c.TripID == "" {
+		return errors.New("tripID is required")
+	}
+	if c.CancelledBy != CancelledByRider && c.CancelledBy != CancelledByDriver {
+		return fmt.Errorf("cancelledBy must be %q or %q", CancelledByRider, CancelledByDriver)
+	}
+	return nil
+}
+
+// DriverTripRequest is the payload of driver.cmd.trip_request.
+type DriverTripRequest struct {
+	TripID   string            `json:"tripID"`
+	DriverID string            `json:"driverID"`
+	RiderID  string            `json:"riderID"`
+	Pickup   *types.Coordinate `json:"pickup"`
+	Fare     float64           `json:"fare"`
+	Package  string            `json:"package"`
+}
+
+// Validate checks the required fields of the payload.
+func (r DriverTripRequest) Validate() error {
+	if r.TripID == "" {
+		return errors.New("tripID is required")
+	}
+	if r.DriverID == "" {
+		return errors.New("driverID is required")
+	}
+	if r.Pickup != nil {
+		return validateCoordinate(r.Pickup.Latitude, r.Pickup.Longitude)
+	}
+	return nil
+}
+
+// DriverTripResponse is the payload of driver.cmd.trip_accept and
+// driver.cmd.trip_decline.
+type DriverTripResponse struct {
+	TripID   string `json:"tripID"`
+	RiderID  string `json:"riderID"`
+	DriverID string `json:"driverID"`
+	Accept   bool   `json:"accept"`
+}
+
+// Validate checks the required fields of the payload.
+func (r DriverTripResponse) Validate() error {
+	if r.TripID == "" {
+		return errors.New("tripID is required")
+	}
+	if r.DriverID == "" {
+		return errors.New("driverID is required")
+	}
+	return nil
+}
+
+// DriverTripRelease is the payload of driver.cmd.trip_release. trip-service
+// sends it to free a driver whose trip was completed, or cancelled after
+// assignment.
+type DriverTripRelease struct {
+	TripID   string `json:"tripID"`
+	DriverID string `json:"driverID"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Validate checks the required fields of the payload.
+func (r DriverTripRelease) Validate() error {
+	if r.TripID == "" {
+		return errors.New("tripID is required")
+	}
+	if r.DriverID == "" {
+		return errors.New("driverID is required")
+	}
+	return nil
+}
+
+// DriverLocationUpdate is the payload of driver.cmd.location.
+type DriverLocationUpdate struct {
+	DriverID  string  `json:"driverID"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+// Validate checks the required fields of the payload.
+func (u DriverLocationUpdate) Validate() error {
+	if u.DriverID == "" {
+		return errors.New("driverID is required")
+	}
+	return validateCoordinate(u.Latitude, u.Longitude)
+}
+
+// PaymentEventData is the payload of every payment.event.* message.
+type PaymentEventData struct {
+	TripID    string  `json:"tripID"`
+	SessionID string  `json:"sessionID"`
+	Amount    float64 `json:"amount"`
+	Currency  string  `json:"currency"`
+	UserID    string  `json:"userID"`
+	Status    string  `json:"status"`
+}
+
+// Validate checks the required fields of the payload.
+func (d PaymentEventData) Validate() error {
+	if d.TripID == "" {
+		return errors.New("tripID is required")
+	}
+	return nil
+}
+
+// PaymentCancelSession is the payload of payment.cmd.cancel_session. Only a
+// pending session is cancelled; settled payments are left untouched.
+type PaymentCancelSession struct {
+	TripID    string `json:"tripID"`
+	SessionID string `json:"sessionID,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// Validate checks the required fields of the payload.
+func (c PaymentCancelSession) Validate() error {
+	if c.TripID == "" {
+		return errors.New("tripID is required")
+	}
+	return nil
+}
+
+func validateCoordinate(latitude, longitude float64) error {
+	if latitude < -90 || latitude > 90 {
+		return fmt.Errorf("latitude %f out of range", latitude)
+	}
+	if longitude < -180 || longitude > 180 {
+		return fmt.Errorf("longitude %f out of range", longitude)
+	}
+	return nil
+}
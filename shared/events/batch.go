@@ -0,0 +1,421 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"ride-sharing/shared/contracts"
+)
+
+// 批量消息
+//
+// BatchPublisher 将同一路由键的高频小消息合并为一条批量消息发布，批量消息的内容类型为 ContentTypeBatch，
+// 负载为 contracts.AmqpBatchItem 数组，JSON负载直接内嵌，不再经过base64二次编码。
+// 订阅器解析信封时识别批量消息并逐条调用处理函数，每条消息保留自己的事件ID、关联ID和发生时间，
+// 处理函数与去重存储无需感知批量。配置了排序键时，批量消息按排序键拆分后分别交给各键的工作协程，
+// 全部处理完成后才确认整批消息。批量消息中任一条处理失败时整批按重试策略重投，已处理的消息会再次投递。
+
+// ContentTypeBatch 批量消息的内容类型
+const ContentTypeBatch = "application/vnd.ride-sharing.batch+json"
+
+// ErrBatchPublisherClosed 批量发布器已关闭
+var ErrBatchPublisherClosed = errors.New("批量发布器已关闭")
+
+// batchPublishTimeout 后台发布一个批次的超时时间
+const batchPublishTimeout = 10 * time.Second
+
+// batchItemOverhead 估算批次大小时每条消息元数据的字节数
+const batchItemOverhead = 160
+
+// batchQueueLimit 等待后台发布的批次数量上限，达到上限时Add在锁外等待，定时器、Flush和Close不受限制
+const batchQueueLimit = 64
+
+// BatchOptions 批量发布选项，任一条件满足时发布批次
+type BatchOptions struct {
+	// MaxMessages 每个批次最多的消息数量
+	MaxMessages int
+	// MaxBytes 每个批次负载的最大字节数（估算值）
+	MaxBytes int
+	// Window 批次中第一条消息最多等待的时间
+	Window time.Duration
+}
+
+// DefaultBatchOptions 返回默认的批量发布选项
+func DefaultBatchOptions() BatchOptions {
+	return BatchOptions{
+		MaxMessages: 100,
+		MaxBytes:    256 << 10,
+		Window:      50 * time.Millisecond,
+	}
+}
+
+// BatchPublisher 按路由键合并消息的批量发布器
+// 批次在后台按形成的顺序依次经底层发布器确认发布，发布失败只记录日志；需要确认结果时调用Flush
+type BatchPublisher struct {
+	publisher Publisher
+	opts      BatchOptions
+
+	mu      sync.Mutex
+	batches map[string]*pendingBatch
+	closed  bool
+	// queue 按形成顺序等待后台发布的批次，在持有mu时追加，不会因后台发布缓慢而阻塞持锁的调用方
+	queue []*batchFlush
+	// dequeued 后台取出批次时关闭并替换，用于Add等待队列空位
+	dequeued chan struct{}
+
+	// ready 通知后台有新的批次或已关闭
+	ready chan struct{}
+	done  chan struct{}
+}
+
+// pendingBatch 正在积累的批次
+type pendingBatch struct {
+	items []contracts.AmqpBatchItem
+	size  int
+	timer *time.Timer
+}
+
+// batchFlush 等待发布的批次，result不为nil时接收发布结果
+type batchFlush struct {
+	routingKey string
+	items      []contracts.AmqpBatchItem
+	result     chan error
+}
+
+// NewBatchPublisher 创建批量发布器，批次通过publisher发布
+func NewBatchPublisher(publisher Publisher, opts BatchOptions) *BatchPublisher {
+	defaults := DefaultBatchOptions()
+	if opts.MaxMessages <= 0 {
+		opts.MaxMessages = defaults.MaxMessages
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = defaults.MaxBytes
+	}
+	if opts.Window <= 0 {
+		opts.Window = defaults.Window
+	}
+
+	b := &BatchPublisher{
+		publisher: publisher,
+		opts:      opts,
+		batches:   make(map[string]*pendingBatch),
+		dequeued:  make(chan struct{}),
+		ready:     make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// Add 编码消息并加入路由键的批次，关联ID和因果ID取自ctx
+// 批次达到数量或大小上限时立即交给后台发布，否则在Window之后发布；
+// 等待发布的批次过多时先等待后台发布，ctx到期时返回错误
+func (b *BatchPublisher) Add(ctx context.Context, routingKey string, data interface{}) error {
+	item, size, err := newBatchItem(ctx, routingKey, data)
+	if err != nil {
+		return err
+	}
+	if err := b.waitQueue(ctx); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrBatchPublisherClosed
+	}
+
+	batch := b.batches[routingKey]
+	if batch == nil {
+		batch = &pendingBatch{}
+		b.batches[routingKey] = batch
+		batch.timer = time.AfterFunc(b.opts.Window, func() { b.expire(routingKey, batch) })
+	}
+	batch.items = append(batch.items, item)
+	batch.size += size
+
+	if len(batch.items) >= b.opts.MaxMessages || batch.size >= b.opts.MaxBytes {
+		b.enqueue(routingKey, nil)
+	}
+	return nil
+}
+
+// expire 发布等待时间已到的批次，批次已因达到上限而发布时忽略
+func (b *BatchPublisher) expire(routingKey string, batch *pendingBatch) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.batches[routingKey] == batch {
+		b.enqueue(routingKey, nil)
+	}
+}
+
+// waitQueue 等待发布的批次达到上限时等待后台取出批次，不持有b.mu
+func (b *BatchPublisher) waitQueue(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		full := len(b.queue) >= batchQueueLimit && !b.closed
+		dequeued := b.dequeued
+		b.mu.Unlock()
+		if !full {
+			return nil
+		}
+
+		select {
+		case <-dequeued:
+		case <-ctx.Done():
+			return fmt.Errorf("等待批量发布队列空位超时: %w", ctx.Err())
+		}
+	}
+}
+
+// enqueue 取出路由键的批次追加到发布队列，调用方需持有b.mu，保证批次按形成的顺序发布
+func (b *BatchPublisher) enqueue(routingKey string, result chan error) {
+	batch := b.batches[routingKey]
+	delete(b.batches, routingKey)
+	batch.timer.Stop()
+
+	b.queue = append(b.queue, &batchFlush{routingKey: routingKey, items: batch.items, result: result})
+	b.notify()
+}
+
+// notify 唤醒后台发布，调用方需持有b.mu
+func (b *BatchPublisher) notify() {
+	select {
+	case b.ready <- struct{}{}:
+	default:
+	}
+}
+
+// next 取出下一个等待发布的批次，队列为空时等待；已关闭且队列为空时返回false
+func (b *BatchPublisher) next() (*batchFlush, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for len(b.queue) == 0 {
+		if b.closed {
+			return nil, false
+		}
+		b.mu.Unlock()
+		<-b.ready
+		b.mu.Lock()
+	}
+
+	flush := b.queue[0]
+	b.queue[0] = nil
+	b.queue = b.queue[1:]
+	close(b.dequeued)
+	b.dequeued = make(chan struct{})
+	return flush, true
+}
+
+// Flush 立即发布所有未满的批次，并等待这些批次及之前的批次发布完成
+func (b *BatchPublisher) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrBatchPublisherClosed
+	}
+	results := b.enqueueAll()
+	b.mu.Unlock()
+
+	return waitFlushes(ctx, results)
+}
+
+// enqueueAll 将所有批次交给后台发布，调用方需持有b.mu
+func (b *BatchPublisher) enqueueAll() []chan error {
+	results := make([]chan error, 0, len(b.batches))
+	for routingKey := range b.batches {
+		result := make(chan error, 1)
+		b.enqueue(routingKey, result)
+		results = append(results, result)
+	}
+	return results
+}
+
+// waitFlushes 等待批次的发布结果
+func waitFlushes(ctx context.Context, results []chan error) error {
+	var errs []error
+	for _, result := range results {
+		select {
+		case err := <-result:
+			if err != nil {
+				errs = append(errs, err)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close 发布剩余的批次后停止后台发布，不会关闭底层发布器
+func (b *BatchPublisher) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	results := b.enqueueAll()
+	b.notify()
+	b.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), batchPublishTimeout)
+	defer cancel()
+	err := waitFlushes(ctx, results)
+	<-b.done
+	return err
+}
+
+// run 依次发布批次
+func (b *BatchPublisher) run() {
+	defer close(b.done)
+
+	for {
+		flush, ok := b.next()
+		if !ok {
+			return
+		}
+		err := b.publish(flush.routingKey, flush.items)
+		if err != nil {
+			log.Printf("发布批量消息失败: %s, 消息数=%d, %v", flush.routingKey, len(flush.items), err)
+		}
+		if flush.result != nil {
+			flush.result <- err
+		}
+	}
+}
+
+// publish 将批次编码为一条批量消息并等待代理确认
+func (b *BatchPublisher) publish(routingKey string, items []contracts.AmqpBatchItem) error {
+	body, err := json.Marshal(items)
+	if err != nil {
+		return fmt.Errorf("序列化批量消息失败: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), batchPublishTimeout)
+	defer cancel()
+	return b.publisher.PublishConfirmed(ctx, routingKey, RawPayload{ContentType: ContentTypeBatch, Data: body})
+}
+
+// newBatchItem 编码一条批量消息中的消息，返回估算的字节数
+func newBatchItem(ctx context.Context, routingKey string, data interface{}) (contracts.AmqpBatchItem, int, error) {
+	payload, contentType, err := encodePayload(ctx, data)
+	if err != nil {
+		return contracts.AmqpBatchItem{}, 0, err
+	}
+
+	md := newMetadata(ctx, "", routingKey)
+	item := contracts.AmqpBatchItem{
+		EventID:       md.EventID,
+		CorrelationID: md.CorrelationID,
+		CausationID:   md.CausationID,
+		OccurredAt:    md.OccurredAt,
+		ContentType:   contentType,
+	}
+	if contentType == ContentTypeJSON || contentType == ContentTypeProtoJSON {
+		item.Data = payload
+	} else {
+		item.Binary = payload
+	}
+	return item, len(payload) + batchItemOverhead, nil
+}
+
+// unpackBatch 将批量消息的信封拆分为每条消息的信封，投递相关的字段沿用批量消息
+func unpackBatch(env Envelope) ([]Envelope, error) {
+	var items []contracts.AmqpBatchItem
+	if err := json.Unmarshal(env.Data, &items); err != nil {
+		return nil, fmt.Errorf("解析批量消息失败: %w", err)
+	}
+
+	envs := make([]Envelope, len(items))
+	for i, item := range items {
+		e := env
+		e.EventID = item.EventID
+		e.CorrelationID = item.CorrelationID
+		e.CausationID = item.CausationID
+		e.OccurredAt = item.OccurredAt
+		e.ContentType = firstNonEmpty(item.ContentType, ContentTypeJSON)
+		e.Data = item.Data
+		if item.Binary != nil {
+			e.Data = item.Binary
+		}
+		e.ReplyTo, e.RequestID = "", ""
+		envs[i] = e
+	}
+	return envs, nil
+}
+
+// dispatchBatch 按顺序逐条调用处理函数，遇到失败时停止，整批消息按失败处理
+// 批量消息已由工作池按排序键分组处理时，直接返回各组合并的结果
+func dispatchBatch(ctx context.Context, env Envelope, handler Handler) error {
+	if result, ok := ctx.Value(batchResultKey{}).(*batchResult); ok {
+		return result.err
+	}
+
+	envs, err := unpackBatch(env)
+	if err != nil {
+		return Permanent(err)
+	}
+	return dispatchEnvelopes(ctx, envs, handler)
+}
+
+// dispatchEnvelopes 按顺序逐条调用处理函数，遇到失败时停止
+func dispatchEnvelopes(ctx context.Context, envs []Envelope, handler Handler) error {
+	for i, e := range envs {
+		started := time.Now()
+		err := handler(ContextWithEnvelope(ctx, e), e)
+		observeConsume(e, started, err)
+		if err != nil {
+			return fmt.Errorf("批量消息第%d/%d条处理失败: %w", i+1, len(envs), err)
+		}
+	}
+	return nil
+}
+
+// batchResultKey 在ctx中传递已按排序键分组处理完成的批量消息结果
+type batchResultKey struct{}
+
+// batchResult 批量消息各组处理结果的合并，err为nil表示全部成功
+type batchResult struct {
+	err error
+}
+
+// batchGroup 批量消息中排序键相同的消息，保持在批次中的先后顺序
+type batchGroup struct {
+	key  string
+	envs []Envelope
+}
+
+// batchGroups 按排序键拆分批量消息：所有消息的排序键相同时只返回该键，
+// 否则按排序键首次出现的顺序返回各组，由工作池分别交给各键的工作协程，保持每个键的顺序且不同键互不阻塞
+func batchGroups(opts SubscribeOptions, env Envelope) (string, []batchGroup) {
+	envs, err := unpackBatch(env)
+	if err != nil || len(envs) == 0 {
+		return "", nil
+	}
+
+	var groups []batchGroup
+	index := make(map[string]int)
+	for _, e := range envs {
+		key := opts.OrderingKey(e)
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, batchGroup{key: key})
+		}
+		groups[i].envs = append(groups[i].envs, e)
+	}
+	if len(groups) == 1 {
+		return groups[0].key, nil
+	}
+	return "", groups
+}
@@ -0,0 +1,92 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"ride-sharing/shared/contracts"
+)
+
+// blockingPublisher 在release关闭之前阻塞所有发布，记录每个批次的第一条消息
+type blockingPublisher struct {
+	Publisher
+	release chan struct{}
+
+	mu    sync.Mutex
+	first []int
+}
+
+func (p *blockingPublisher) PublishConfirmed(ctx context.Context, routingKey string, data interface{}) error {
+	select {
+	case <-p.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	var items []contracts.AmqpBatchItem
+	if err := json.Unmarshal(data.(RawPayload).Data, &items); err != nil {
+		return err
+	}
+	var seq int
+	if err := json.Unmarshal(items[0].Data, &seq); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.first = append(p.first, seq)
+	p.mu.Unlock()
+	return nil
+}
+
+func TestBatchPublisherSlowBroker(t *testing.T) {
+	pub := &blockingPublisher{release: make(chan struct{})}
+	b := NewBatchPublisher(pub, BatchOptions{MaxMessages: 1, Window: time.Millisecond})
+
+	// 第一个批次被后台取出后阻塞在发布中，其余批次填满发布队列
+	ctx := context.Background()
+	total := batchQueueLimit + 1
+	for i := 0; i < total; i++ {
+		if err := b.Add(ctx, "trip.event.created", i); err != nil {
+			t.Fatalf("Add(%d): %v", i, err)
+		}
+	}
+
+	// 队列已满时Add在锁外等待，ctx到期后返回错误
+	addCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := b.Add(addCtx, "trip.event.created", total); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Add on full queue: got %v, want DeadlineExceeded", err)
+	}
+
+	// 等待队列空位的Add不持有锁，其他调用照常进行
+	added := make(chan error, 1)
+	go func() { added <- b.Add(ctx, "trip.event.updated", total) }()
+	flushCtx, cancelFlush := context.WithTimeout(ctx, time.Second)
+	defer cancelFlush()
+	if err := b.Flush(flushCtx); err != nil {
+		t.Fatalf("Flush while Add is waiting: %v", err)
+	}
+
+	close(pub.release)
+	if err := <-added; err != nil {
+		t.Fatalf("Add after queue drained: %v", err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// 批次按形成的顺序发布
+	pub.mu.Lock()
+	defer pub.mu.Unlock()
+	if len(pub.first) != total+1 {
+		t.Fatalf("got %d batches, want %d", len(pub.first), total+1)
+	}
+	for i, seq := range pub.first {
+		if seq != i {
+			t.Fatalf("batch %d starts with message %d: %v", i, seq, pub.first)
+		}
+	}
+}
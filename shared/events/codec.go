@@ -0,0 +1,185 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// 事件负载的内容类型
+const (
+	ContentTypeJSON      = "application/json"
+	ContentTypeProtoJSON = "application/x-protobuf+json"
+	ContentTypeProtobuf  = "application/x-protobuf"
+)
+
+// ErrNotProtoMessage 负载不是protobuf消息，无法使用protobuf编解码器
+var ErrNotProtoMessage = errors.New("负载不是protobuf消息")
+
+// Codec 事件负载编解码器
+type Codec interface {
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec 使用encoding/json编解码负载
+type JSONCodec struct{}
+
+// ContentType 返回内容类型
+func (JSONCodec) ContentType() string { return ContentTypeJSON }
+
+// Marshal 序列化负载
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+// Unmarshal 反序列化负载
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// ProtoJSONCodec 使用protojson编解码protobuf消息，字段名与枚举、oneof遵循protobuf的JSON映射
+type ProtoJSONCodec struct{}
+
+// ContentType 返回内容类型
+func (ProtoJSONCodec) ContentType() string { return ContentTypeProtoJSON }
+
+// Marshal 序列化protobuf消息
+func (ProtoJSONCodec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrNotProtoMessage, v)
+	}
+	return protojson.Marshal(msg)
+}
+
+// Unmarshal 反序列化protobuf消息，未知字段会被忽略以兼容新版本生产者
+func (ProtoJSONCodec) Unmarshal(data []byte, v interface{}) error {
+	msg, err := protoTarget(v)
+	if err != nil {
+		return err
+	}
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, msg)
+}
+
+// ProtobufCodec 使用二进制protobuf编解码protobuf消息
+type ProtobufCodec struct{}
+
+// ContentType 返回内容类型
+func (ProtobufCodec) ContentType() string { return ContentTypeProtobuf }
+
+// Marshal 序列化protobuf消息
+func (ProtobufCodec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrNotProtoMessage, v)
+	}
+	return proto.Marshal(msg)
+}
+
+// Unmarshal 反序列化protobuf消息
+func (ProtobufCodec) Unmarshal(data []byte, v interface{}) error {
+	msg, err := protoTarget(v)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+// protoTarget 获取反序列化目标消息，支持*Msg和**Msg两种形式
+func protoTarget(v interface{}) (proto.Message, error) {
+	if msg, ok := v.(proto.Message); ok {
+		return msg, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() && rv.Elem().Kind() == reflect.Ptr {
+		elem := rv.Elem()
+		if elem.IsNil() {
+			elem.Set(reflect.New(elem.Type().Elem()))
+		}
+		if msg, ok := elem.Interface().(proto.Message); ok {
+			return msg, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %T", ErrNotProtoMessage, v)
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{
+		ContentTypeJSON:      JSONCodec{},
+		ContentTypeProtoJSON: ProtoJSONCodec{},
+		ContentTypeProtobuf:  ProtobufCodec{},
+	}
+)
+
+// RegisterCodec 注册编解码器，相同内容类型的编解码器会被替换
+func RegisterCodec(codec Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[codec.ContentType()] = codec
+}
+
+// CodecFor 根据内容类型查找编解码器，内容类型为空时使用JSON
+func CodecFor(contentType string) (Codec, error) {
+	if contentType == "" {
+		contentType = ContentTypeJSON
+	}
+
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	codec, ok := codecs[contentType]
+	if !ok {
+		return nil, fmt.Errorf("不支持的内容类型: %s", contentType)
+	}
+	return codec, nil
+}
+
+type contentTypeKey struct{}
+
+// WithContentType 为即将发布的消息指定负载内容类型
+func WithContentType(ctx context.Context, contentType string) context.Context {
+	return context.WithValue(ctx, contentTypeKey{}, contentType)
+}
+
+// RawPayload 已编码的负载，发布时不再重复编码
+type RawPayload struct {
+	ContentType string
+	Data        []byte
+}
+
+// encodePayload 编码业务数据，返回编码结果和内容类型
+// 优先使用ctx中指定的内容类型；未指定时protobuf消息使用protojson，其他数据使用JSON。
+// 指定了protobuf内容类型但数据不是protobuf消息时返回ErrNotProtoMessage，
+// 此时应发布shared/proto/events中的对应消息
+func encodePayload(ctx context.Context, data interface{}) ([]byte, string, error) {
+	if raw, ok := data.(RawPayload); ok {
+		return raw.Data, raw.ContentType, nil
+	}
+
+	contentType, _ := ctx.Value(contentTypeKey{}).(string)
+	if contentType == "" {
+		contentType = ContentTypeJSON
+		if _, ok := data.(proto.Message); ok {
+			contentType = ContentTypeProtoJSON
+		}
+	}
+
+	codec, err := CodecFor(contentType)
+	if err != nil {
+		return nil, "", err
+	}
+
+	payload, err := codec.Marshal(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("序列化消息数据失败: %w", err)
+	}
+
+	return payload, codec.ContentType(), nil
+}
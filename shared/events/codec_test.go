@@ -0,0 +1,141 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"ride-sharing/shared/contracts"
+	eventspb "ride-sharing/shared/proto/events"
+	"ride-sharing/shared/proto/trip"
+)
+
+func testTripRequest() *eventspb.DriverTripRequest {
+	return &eventspb.DriverTripRequest{
+		TripID:   "trip-1",
+		DriverID: "driver-1",
+		RiderID:  "rider-1",
+		Pickup:   &trip.Coordinate{Latitude: 52.52, Longitude: 13.405},
+		Fare:     1250,
+		Package:  "sedan",
+	}
+}
+
+func TestProtoCodecsRoundTrip(t *testing.T) {
+	for _, codec := range []Codec{ProtoJSONCodec{}, ProtobufCodec{}} {
+		t.Run(codec.ContentType(), func(t *testing.T) {
+			want := testTripRequest()
+			data, err := codec.Marshal(want)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+
+			got := &eventspb.DriverTripRequest{}
+			if err := codec.Unmarshal(data, got); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !proto.Equal(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+
+			// **Msg目标按需分配消息
+			var ptr *eventspb.DriverTripRequest
+			if err := codec.Unmarshal(data, &ptr); err != nil {
+				t.Fatalf("Unmarshal into **Msg: %v", err)
+			}
+			if !proto.Equal(ptr, want) {
+				t.Errorf("got %v, want %v", ptr, want)
+			}
+		})
+	}
+}
+
+func TestProtoCodecsRejectPlainStructs(t *testing.T) {
+	for _, codec := range []Codec{ProtoJSONCodec{}, ProtobufCodec{}} {
+		if _, err := codec.Marshal(contracts.DriverTripRequest{}); !errors.Is(err, ErrNotProtoMessage) {
+			t.Errorf("%s Marshal: got %v, want ErrNotProtoMessage", codec.ContentType(), err)
+		}
+		var payload contracts.DriverTripRequest
+		if err := codec.Unmarshal([]byte("{}"), &payload); !errors.Is(err, ErrNotProtoMessage) {
+			t.Errorf("%s Unmarshal: got %v, want ErrNotProtoMessage", codec.ContentType(), err)
+		}
+	}
+}
+
+// protojson使用proto文件中的字段名，与contracts中结构体的JSON标签一致
+func TestProtoJSONMatchesContractStructs(t *testing.T) {
+	data, err := ProtoJSONCodec{}.Marshal(testTripRequest())
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var got contracts.DriverTripRequest
+	if err := (JSONCodec{}).Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if got.TripID != "trip-1" || got.RiderID != "rider-1" || got.Package != "sedan" || got.Fare != 1250 {
+		t.Errorf("unexpected payload %+v", got)
+	}
+	if got.Pickup == nil || got.Pickup.Latitude != 52.52 || got.Pickup.Longitude != 13.405 {
+		t.Errorf("unexpected pickup %+v", got.Pickup)
+	}
+}
+
+func TestEncodePayload(t *testing.T) {
+	protobufCtx := WithContentType(context.Background(), ContentTypeProtobuf)
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		data        interface{}
+		contentType string
+		err         error
+	}{
+		{"struct defaults to json", context.Background(), contracts.TripEventData{TripID: "trip-1"}, ContentTypeJSON, nil},
+		{"proto defaults to protojson", context.Background(), testTripRequest(), ContentTypeProtoJSON, nil},
+		{"negotiated protobuf", protobufCtx, testTripRequest(), ContentTypeProtobuf, nil},
+		{"protobuf requires proto message", protobufCtx, contracts.TripEventData{TripID: "trip-1"}, "", ErrNotProtoMessage},
+		{"raw payload kept", protobufCtx, RawPayload{ContentType: ContentTypeJSON, Data: []byte("{}")}, ContentTypeJSON, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, contentType, err := encodePayload(tt.ctx, tt.data)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if contentType != tt.contentType {
+				t.Errorf("got content type %q, want %q", contentType, tt.contentType)
+			}
+		})
+	}
+}
+
+// 内容类型随信封和消息头传递，消费者据此选择编解码器
+func TestProtobufEnvelopeRoundTrip(t *testing.T) {
+	ctx := WithContentType(context.Background(), ContentTypeProtobuf)
+	md := newMetadata(ctx, "driver-service", contracts.DriverCmdTripRequest)
+
+	body, err := encodeMessage(ctx, &md, testTripRequest())
+	if err != nil {
+		t.Fatalf("encodeMessage: %v", err)
+	}
+
+	env, err := decodeEnvelope(body, contracts.DriverCmdTripRequest, md.headers(), false)
+	if err != nil {
+		t.Fatalf("decodeEnvelope: %v", err)
+	}
+	if env.ContentType != ContentTypeProtobuf {
+		t.Fatalf("got content type %q, want %q", env.ContentType, ContentTypeProtobuf)
+	}
+
+	got, err := decodePayload[*eventspb.DriverTripRequest](env)
+	if err != nil {
+		t.Fatalf("decodePayload: %v", err)
+	}
+	if !proto.Equal(got, testTripRequest()) {
+		t.Errorf("got %v, want %v", got, testTripRequest())
+	}
+}
@@ -0,0 +1,1054 @@
+/*
+Package conformance 事件后端一致性测试套件
+
+每个事件后端（RabbitMQ、Redis Streams、内存）都必须通过同一组用例，保证在Publisher/Subscriber接口背后
+路由、持久化消费、确认与重新投递、重试与死信、请求/响应、延迟消息、批量消息以及优雅关闭的语义一致。
+用例只通过公开接口访问后端，队列和路由键带有本次运行的唯一前缀，可以在共享的代理上重复运行。
+
+go test ./shared/events/conformance 对内存和内嵌Redis运行全部用例，设置RABBITMQ_URL时同时检查RabbitMQ；
+tools/conformance 命令行可以指定外部的Redis。
+*/
+package conformance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ride-sharing/shared/events"
+	"ride-sharing/shared/retry"
+)
+
+// Backend 被测的事件后端
+type Backend struct {
+	Name string
+	// NewPublisher、NewSubscriber和NewRequester 创建连接到同一代理的组件，每次调用返回新的实例
+	NewPublisher  func() (events.Publisher, error)
+	NewSubscriber func() (events.Subscriber, error)
+	NewRequester  func() (events.Requester, error)
+	// CrashRedelivery 后端是否会将已崩溃消费者未确认的消息重新投递给其他消费者
+	// 为false时跳过该用例；内存后端在订阅器Close时将未确认的消息重新入队，与代理断开连接等效
+	CrashRedelivery bool
+}
+
+// Result 单个用例的结果
+type Result struct {
+	Backend  string
+	Case     string
+	Err      error
+	Skipped  bool
+	Duration time.Duration
+}
+
+// Passed 用例是否通过，跳过的用例视为通过
+func (r Result) Passed() bool {
+	return r.Err == nil
+}
+
+// testCase 一个一致性用例
+type testCase struct {
+	name string
+	run  func(ctx context.Context, s *suite) error
+	// crash 用例需要后端支持崩溃后重新投递
+	crash bool
+}
+
+var cases = []testCase{
+	{name: "routing", run: testRouting},
+	{name: "wildcard", run: testWildcard},
+	{name: "fanout", run: testFanout},
+	{name: "competing-consumers", run: testCompetingConsumers},
+	{name: "durable-queue", run: testDurableQueue},
+	{name: "retry", run: testRetry},
+	{name: "dead-letter", run: testDeadLetter},
+	{name: "crash-redelivery", run: testCrashRedelivery, crash: true},
+	{name: "slow-handler", run: testSlowHandler},
+	{name: "request-reply", run: testRequestReply},
+	{name: "delayed", run: testDelayed},
+	{name: "delayed-cancel-restart", run: testDelayedCancelRestart},
+	{name: "batch", run: testBatch},
+	{name: "ordering", run: testOrdering},
+	{name: "batch-ordering", run: testBatchOrdering},
+	{name: "graceful-shutdown", run: testGracefulShutdown},
+}
+
+// Run 依次运行所有用例并返回结果
+func Run(ctx context.Context, b Backend) []Result {
+	s := &suite{backend: b, runID: fmt.Sprintf("%d", time.Now().UnixNano())}
+
+	results := make([]Result, 0, len(cases))
+	for _, c := range cases {
+		result := Result{Backend: b.Name, Case: c.name}
+		if c.crash && !b.CrashRedelivery {
+			result.Skipped = true
+			results = append(results, result)
+			continue
+		}
+
+		started := time.Now()
+		caseCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		result.Err = s.runCase(caseCtx, c)
+		cancel()
+		result.Duration = time.Since(started)
+
+		results = append(results, result)
+	}
+	return results
+}
+
+// suite 一次运行的共享状态
+type suite struct {
+	backend Backend
+	runID   string
+
+	mu      sync.Mutex
+	closers []func() error
+}
+
+// runCase 运行用例并关闭用例中创建的所有组件
+func (s *suite) runCase(ctx context.Context, c testCase) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("用例panic: %v", r)
+		}
+		s.closeAll()
+	}()
+	return c.run(ctx, s)
+}
+
+func (s *suite) closeAll() {
+	s.mu.Lock()
+	closers := s.closers
+	s.closers = nil
+	s.mu.Unlock()
+
+	for i := len(closers) - 1; i >= 0; i-- {
+		closers[i]()
+	}
+}
+
+func (s *suite) track(close func() error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closers = append(s.closers, close)
+}
+
+// queue 返回本次运行中唯一的队列名称
+func (s *suite) queue(name string) string {
+	return "conformance." + s.runID + "." + name
+}
+
+// key 返回本次运行中唯一的路由键
+func (s *suite) key(name string) string {
+	return "conformance." + s.runID + "." + name
+}
+
+func (s *suite) publisher() (events.Publisher, error) {
+	p, err := s.backend.NewPublisher()
+	if err != nil {
+		return nil, fmt.Errorf("创建发布器失败: %w", err)
+	}
+	s.track(p.Close)
+	return p, nil
+}
+
+func (s *suite) subscriber() (events.Subscriber, error) {
+	sub, err := s.backend.NewSubscriber()
+	if err != nil {
+		return nil, fmt.Errorf("创建订阅器失败: %w", err)
+	}
+	s.track(sub.Close)
+	return sub, nil
+}
+
+func (s *suite) requester() (events.Requester, error) {
+	r, err := s.backend.NewRequester()
+	if err != nil {
+		return nil, fmt.Errorf("创建请求器失败: %w", err)
+	}
+	s.track(r.Close)
+	return r, nil
+}
+
+// message 用例使用的负载
+type message struct {
+	Seq int    `json:"seq"`
+	Key string `json:"key,omitempty"`
+}
+
+// received 处理函数收到的消息
+type received struct {
+	env     events.Envelope
+	payload message
+	// consumer 收到消息的订阅者编号
+	consumer int
+}
+
+// collector 收集处理函数收到的消息
+type collector struct {
+	ch chan received
+}
+
+func newCollector() *collector {
+	return &collector{ch: make(chan received, 1024)}
+}
+
+// handler 返回记录消息的处理函数
+func (c *collector) handler(consumer int) events.Handler {
+	return events.Typed(func(ctx context.Context, env events.Envelope, payload message) error {
+		c.ch <- received{env: env, payload: payload, consumer: consumer}
+		return nil
+	})
+}
+
+// wait 等待收到n条消息
+func (c *collector) wait(ctx context.Context, n int) ([]received, error) {
+	out := make([]received, 0, n)
+	for len(out) < n {
+		select {
+		case r := <-c.ch:
+			out = append(out, r)
+		case <-ctx.Done():
+			return out, fmt.Errorf("等待消息超时: 期望 %d 条, 收到 %d 条", n, len(out))
+		}
+	}
+	return out, nil
+}
+
+// expectNone 确认在d时间内没有收到更多消息
+func (c *collector) expectNone(d time.Duration) error {
+	select {
+	case r := <-c.ch:
+		return fmt.Errorf("收到了不应收到的消息: %s seq=%d", r.env.RoutingKey, r.payload.Seq)
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// quietPeriod 确认没有多余消息时的等待时间
+const quietPeriod = 300 * time.Millisecond
+
+// noRetry 不重试也不进入死信队列的订阅选项
+func noRetry() events.SubscribeOptions {
+	return events.SubscribeOptions{}
+}
+
+// publishN 发布n条消息
+func publishN(ctx context.Context, p events.Publisher, routingKey string, n int) error {
+	for i := 0; i < n; i++ {
+		if err := p.PublishEvent(ctx, routingKey, message{Seq: i}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// testRouting 消息按路由键投递，元数据和负载完整保留，不匹配的路由键不会投递
+func testRouting(ctx context.Context, s *suite) error {
+	pub, err := s.publisher()
+	if err != nil {
+		return err
+	}
+	sub, err := s.subscriber()
+	if err != nil {
+		return err
+	}
+
+	c := newCollector()
+	rk := s.key("routing.created")
+	if err := sub.SubscribeWithOptions(s.queue("routing"), rk, c.handler(0), noRetry()); err != nil {
+		return err
+	}
+
+	ctx = events.WithCorrelationID(ctx, "conformance-correlation")
+	if err := pub.PublishEvent(ctx, s.key("routing.other"), message{Seq: 1}); err != nil {
+		return err
+	}
+	if err := pub.PublishEvent(ctx, rk, message{Seq: 42, Key: "payload"}); err != nil {
+		return err
+	}
+
+	got, err := c.wait(ctx, 1)
+	if err != nil {
+		return err
+	}
+	env, payload := got[0].env, got[0].payload
+	switch {
+	case payload != message{Seq: 42, Key: "payload"}:
+		return fmt.Errorf("负载不一致: %+v", payload)
+	case env.RoutingKey != rk || env.EventType != rk:
+		return fmt.Errorf("路由键不一致: routingKey=%s eventType=%s", env.RoutingKey, env.EventType)
+	case env.EventID == "":
+		return errors.New("缺少事件ID")
+	case env.CorrelationID != "conformance-correlation":
+		return fmt.Errorf("关联ID不一致: %q", env.CorrelationID)
+	case env.OccurredAt.IsZero():
+		return errors.New("缺少发生时间")
+	case env.RetryCount != 0 || env.Redelivered:
+		return fmt.Errorf("首次投递的消息标记为重试: retryCount=%d redelivered=%v", env.RetryCount, env.Redelivered)
+	}
+	return c.expectNone(quietPeriod)
+}
+
+// testWildcard 绑定支持 * 匹配一个单词、# 匹配零个或多个单词
+func testWildcard(ctx context.Context, s *suite) error {
+	pub, err := s.publisher()
+	if err != nil {
+		return err
+	}
+	sub, err := s.subscriber()
+	if err != nil {
+		return err
+	}
+
+	star, hash := newCollector(), newCollector()
+	if err := sub.SubscribeWithOptions(s.queue("wildcard.star"), s.key("wildcard.*"), star.handler(0), noRetry()); err != nil {
+		return err
+	}
+	if err := sub.SubscribeWithOptions(s.queue("wildcard.hash"), s.key("wildcard.#"), hash.handler(0), noRetry()); err != nil {
+		return err
+	}
+
+	for i, rk := range []string{"wildcard.a", "wildcard.a.b", "wildcard"} {
+		if err := pub.PublishEvent(ctx, s.key(rk), message{Seq: i}); err != nil {
+			return err
+		}
+	}
+
+	// * 只匹配 wildcard.a；# 匹配全部三条
+	if _, err := star.wait(ctx, 1); err != nil {
+		return fmt.Errorf("* 绑定: %w", err)
+	}
+	if err := star.expectNone(quietPeriod); err != nil {
+		return fmt.Errorf("* 绑定: %w", err)
+	}
+	if _, err := hash.wait(ctx, 3); err != nil {
+		return fmt.Errorf("# 绑定: %w", err)
+	}
+	return nil
+}
+
+// testFanout 绑定同一路由键的多个队列各收到一份消息
+func testFanout(ctx context.Context, s *suite) error {
+	pub, err := s.publisher()
+	if err != nil {
+		return err
+	}
+	sub, err := s.subscriber()
+	if err != nil {
+		return err
+	}
+
+	rk := s.key("fanout.event")
+	a, b := newCollector(), newCollector()
+	if err := sub.SubscribeWithOptions(s.queue("fanout.a"), rk, a.handler(0), noRetry()); err != nil {
+		return err
+	}
+	if err := sub.SubscribeWithOptions(s.queue("fanout.b"), rk, b.handler(0), noRetry()); err != nil {
+		return err
+	}
+
+	if err := publishN(ctx, pub, rk, 3); err != nil {
+		return err
+	}
+	for name, c := range map[string]*collector{"a": a, "b": b} {
+		if _, err := c.wait(ctx, 3); err != nil {
+			return fmt.Errorf("队列%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// testCompetingConsumers 同一队列的多个订阅者竞争消费，每条消息只处理一次
+func testCompetingConsumers(ctx context.Context, s *suite) error {
+	pub, err := s.publisher()
+	if err != nil {
+		return err
+	}
+
+	const n = 40
+	queue, rk := s.queue("competing"), s.key("competing.event")
+	c := newCollector()
+	for i := 0; i < 2; i++ {
+		sub, err := s.subscriber()
+		if err != nil {
+			return err
+		}
+		if err := sub.SubscribeWithOptions(queue, rk, c.handler(i), noRetry()); err != nil {
+			return err
+		}
+	}
+
+	if err := publishN(ctx, pub, rk, n); err != nil {
+		return err
+	}
+	got, err := c.wait(ctx, n)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[int]bool)
+	for _, r := range got {
+		if seen[r.payload.Seq] {
+			return fmt.Errorf("消息被重复处理: seq=%d", r.payload.Seq)
+		}
+		seen[r.payload.Seq] = true
+	}
+	return c.expectNone(quietPeriod)
+}
+
+// testDurableQueue 订阅者离线期间发布的消息在重新订阅后投递
+func testDurableQueue(ctx context.Context, s *suite) error {
+	pub, err := s.publisher()
+	if err != nil {
+		return err
+	}
+
+	queue, rk := s.queue("durable"), s.key("durable.event")
+	first, err := s.backend.NewSubscriber()
+	if err != nil {
+		return err
+	}
+	if err := first.SubscribeWithOptions(queue, rk, newCollector().handler(0), noRetry()); err != nil {
+		first.Close()
+		return err
+	}
+	if err := first.Close(); err != nil {
+		return fmt.Errorf("关闭订阅器失败: %w", err)
+	}
+
+	if err := publishN(ctx, pub, rk, 5); err != nil {
+		return err
+	}
+
+	sub, err := s.subscriber()
+	if err != nil {
+		return err
+	}
+	c := newCollector()
+	if err := sub.SubscribeWithOptions(queue, rk, c.handler(0), noRetry()); err != nil {
+		return err
+	}
+	if _, err := c.wait(ctx, 5); err != nil {
+		return err
+	}
+	return c.expectNone(quietPeriod)
+}
+
+// testRetry 处理失败的消息按重试策略重新投递，RetryCount逐次递增
+func testRetry(ctx context.Context, s *suite) error {
+	pub, err := s.publisher()
+	if err != nil {
+		return err
+	}
+	sub, err := s.subscriber()
+	if err != nil {
+		return err
+	}
+
+	attempts := make(chan events.Envelope, 16)
+	handler := func(ctx context.Context, env events.Envelope) error {
+		attempts <- env
+		if env.RetryCount < 2 {
+			return errors.New("模拟处理失败")
+		}
+		return nil
+	}
+	opts := events.SubscribeOptions{
+		Retry: retry.Config{MaxRetries: 3, InitialWait: 100 * time.Millisecond, MaxWait: 200 * time.Millisecond},
+	}
+	rk := s.key("retry.event")
+	if err := sub.SubscribeWithOptions(s.queue("retry"), rk, handler, opts); err != nil {
+		return err
+	}
+
+	if err := pub.PublishEvent(ctx, rk, message{Seq: 1}); err != nil {
+		return err
+	}
+
+	var eventID string
+	for want := 0; want <= 2; want++ {
+		select {
+		case env := <-attempts:
+			if env.RetryCount != want {
+				return fmt.Errorf("第%d次投递的RetryCount为%d", want+1, env.RetryCount)
+			}
+			if env.RoutingKey != rk {
+				return fmt.Errorf("重试消息的路由键变为 %s", env.RoutingKey)
+			}
+			if eventID == "" {
+				eventID = env.EventID
+			} else if env.EventID != eventID {
+				return fmt.Errorf("重试消息的事件ID变为 %s", env.EventID)
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("等待第%d次投递超时", want+1)
+		}
+	}
+
+	select {
+	case env := <-attempts:
+		return fmt.Errorf("处理成功后消息仍被投递: retryCount=%d", env.RetryCount)
+	case <-time.After(quietPeriod):
+		return nil
+	}
+}
+
+// testDeadLetter 重试耗尽的消息进入死信队列，可查看并重新投递
+func testDeadLetter(ctx context.Context, s *suite) error {
+	pub, err := s.publisher()
+	if err != nil {
+		return err
+	}
+	sub, err := s.subscriber()
+	if err != nil {
+		return err
+	}
+	manager, ok := sub.(events.DeadLetterManager)
+	if !ok {
+		return fmt.Errorf("订阅器 %T 不支持死信管理", sub)
+	}
+
+	var mu sync.Mutex
+	healthy := false
+	c := newCollector()
+	record := c.handler(0)
+	handler := func(ctx context.Context, env events.Envelope) error {
+		mu.Lock()
+		ok := healthy
+		mu.Unlock()
+		if !ok {
+			return errors.New("下游不可用")
+		}
+		return record(ctx, env)
+	}
+
+	queue, rk := s.queue("dead-letter"), s.key("dead-letter.event")
+	opts := events.SubscribeOptions{
+		Retry:      retry.Config{MaxRetries: 1, InitialWait: 50 * time.Millisecond, MaxWait: 50 * time.Millisecond},
+		DeadLetter: true,
+	}
+	if err := sub.SubscribeWithOptions(queue, rk, handler, opts); err != nil {
+		return err
+	}
+	if err := publishN(ctx, pub, rk, 2); err != nil {
+		return err
+	}
+
+	var letters []events.DeadLetter
+	for len(letters) < 2 {
+		if letters, err = manager.InspectDeadLetters(queue, 10); err != nil {
+			return fmt.Errorf("查看死信失败: %w", err)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("等待死信超时: 期望 2 条, 实际 %d 条", len(letters))
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	for _, l := range letters {
+		switch {
+		case l.RoutingKey != rk:
+			return fmt.Errorf("死信的原路由键为 %q", l.RoutingKey)
+		case !strings.Contains(l.Reason, "下游不可用"):
+			return fmt.Errorf("死信原因为 %q", l.Reason)
+		case l.RetryCount != 1:
+			return fmt.Errorf("死信的重试次数为 %d", l.RetryCount)
+		case l.EventID == "" || l.FailedAt.IsZero():
+			return errors.New("死信缺少事件ID或失败时间")
+		}
+	}
+
+	mu.Lock()
+	healthy = true
+	mu.Unlock()
+
+	n, err := manager.RedriveDeadLetters(queue, 10)
+	if err != nil {
+		return fmt.Errorf("重新投递死信失败: %w", err)
+	}
+	if n != 2 {
+		return fmt.Errorf("重新投递了 %d 条死信, 期望 2 条", n)
+	}
+	got, err := c.wait(ctx, 2)
+	if err != nil {
+		return err
+	}
+	for _, r := range got {
+		if r.env.RetryCount != 0 {
+			return fmt.Errorf("重新投递的消息RetryCount为 %d", r.env.RetryCount)
+		}
+	}
+
+	remaining, err := manager.InspectDeadLetters(queue, 10)
+	if err != nil {
+		return err
+	}
+	if len(remaining) != 0 {
+		return fmt.Errorf("重新投递后死信队列仍有 %d 条消息", len(remaining))
+	}
+	return nil
+}
+
+// testCrashRedelivery 消费者未确认就退出时，消息重新投递给其他消费者并标记为重新投递
+func testCrashRedelivery(ctx context.Context, s *suite) error {
+	pub, err := s.publisher()
+	if err != nil {
+		return err
+	}
+
+	queue, rk := s.queue("crash"), s.key("crash.event")
+
+	// 第一个订阅者收到消息后一直阻塞，模拟处理过程中进程崩溃
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{}, 1)
+	crashed, err := s.backend.NewSubscriber()
+	if err != nil {
+		return err
+	}
+	blocking := func(ctx context.Context, env events.Envelope) error {
+		started <- struct{}{}
+		<-release
+		return errors.New("消费者已崩溃")
+	}
+	if err := crashed.SubscribeWithOptions(queue, rk, blocking, noRetry()); err != nil {
+		crashed.Close()
+		return err
+	}
+
+	if err := pub.PublishEvent(ctx, rk, message{Seq: 7}); err != nil {
+		crashed.Close()
+		return err
+	}
+	select {
+	case <-started:
+	case <-ctx.Done():
+		crashed.Close()
+		return errors.New("等待第一个订阅者收到消息超时")
+	}
+
+	// Close不等待正在处理的消息，消息保持未确认
+	go crashed.Close()
+
+	sub, err := s.subscriber()
+	if err != nil {
+		return err
+	}
+	c := newCollector()
+	if err := sub.SubscribeWithOptions(queue, rk, c.handler(1), noRetry()); err != nil {
+		return err
+	}
+
+	got, err := c.wait(ctx, 1)
+	if err != nil {
+		return err
+	}
+	if got[0].payload.Seq != 7 {
+		return fmt.Errorf("重新投递的消息不一致: seq=%d", got[0].payload.Seq)
+	}
+	if !got[0].env.Redelivered {
+		return errors.New("重新投递的消息未标记为Redelivered")
+	}
+	return c.expectNone(quietPeriod)
+}
+
+// slowHandlerTime 慢处理用例中处理一条消息的时间，长于内嵌Redis用例的可见性超时
+const slowHandlerTime = 2500 * time.Millisecond
+
+// testSlowHandler 处理时间超过可见性超时的消息仍在处理中，不会再次投递给同一订阅者
+func testSlowHandler(ctx context.Context, s *suite) error {
+	pub, err := s.publisher()
+	if err != nil {
+		return err
+	}
+	sub, err := s.subscriber()
+	if err != nil {
+		return err
+	}
+
+	c := newCollector()
+	record := c.handler(1)
+	slow := func(ctx context.Context, env events.Envelope) error {
+		err := record(ctx, env)
+		time.Sleep(slowHandlerTime)
+		return err
+	}
+	rk := s.key("slow.event")
+	if err := sub.SubscribeWithOptions(s.queue("slow"), rk, slow, noRetry()); err != nil {
+		return err
+	}
+	if err := pub.PublishEvent(ctx, rk, message{Seq: 1}); err != nil {
+		return err
+	}
+
+	if _, err := c.wait(ctx, 1); err != nil {
+		return err
+	}
+	return c.expectNone(slowHandlerTime + quietPeriod)
+}
+
+// testRequestReply 请求/响应：成功响应、远程错误和无响应方
+func testRequestReply(ctx context.Context, s *suite) error {
+	sub, err := s.subscriber()
+	if err != nil {
+		return err
+	}
+	requester, err := s.requester()
+	if err != nil {
+		return err
+	}
+
+	rk := s.key("rpc.double")
+	responder := events.TypedResponse(func(ctx context.Context, env events.Envelope, req message) (interface{}, error) {
+		if req.Seq < 0 {
+			return nil, errors.New("seq不能为负数")
+		}
+		return message{Seq: req.Seq * 2, Key: env.RequestID}, nil
+	})
+	if err := sub.Respond(s.queue("rpc"), rk, responder, events.RespondOptions()); err != nil {
+		return err
+	}
+
+	reply, err := events.Call[message](ctx, requester, rk, message{Seq: 21})
+	if err != nil {
+		return fmt.Errorf("请求失败: %w", err)
+	}
+	if reply.Seq != 42 || reply.Key == "" {
+		return fmt.Errorf("响应不一致: %+v", reply)
+	}
+
+	_, err = events.Call[message](ctx, requester, rk, message{Seq: -1})
+	var remote *events.RemoteError
+	if !errors.As(err, &remote) {
+		return fmt.Errorf("期望远程错误, 实际为 %v", err)
+	}
+
+	_, err = events.Call[message](ctx, requester, s.key("rpc.nobody"), message{Seq: 1})
+	if !errors.Is(err, events.ErrNoResponder) {
+		return fmt.Errorf("期望ErrNoResponder, 实际为 %v", err)
+	}
+	return nil
+}
+
+// testDelayed 延迟消息在延迟之后按原路由键投递，已取消的延迟消息不会投递
+func testDelayed(ctx context.Context, s *suite) error {
+	pub, err := s.publisher()
+	if err != nil {
+		return err
+	}
+	sub, err := s.subscriber()
+	if err != nil {
+		return err
+	}
+
+	c := newCollector()
+	rk := s.key("delayed.event")
+	if err := sub.SubscribeWithOptions(s.queue("delayed"), rk, c.handler(0), noRetry()); err != nil {
+		return err
+	}
+
+	const delay = time.Second
+	started := time.Now()
+	cancelled, err := pub.PublishDelayed(ctx, rk, message{Seq: 1}, delay)
+	if err != nil {
+		return err
+	}
+	if _, err := pub.PublishDelayed(ctx, rk, message{Seq: 2}, delay); err != nil {
+		return err
+	}
+	if err := pub.CancelDelayed(ctx, cancelled); err != nil {
+		return fmt.Errorf("取消延迟消息失败: %w", err)
+	}
+
+	got, err := c.wait(ctx, 1)
+	if err != nil {
+		return err
+	}
+	if elapsed := time.Since(started); elapsed < delay {
+		return fmt.Errorf("延迟消息提前投递: %s", elapsed)
+	}
+	if got[0].payload.Seq != 2 || got[0].env.RoutingKey != rk {
+		return fmt.Errorf("投递了错误的延迟消息: %s seq=%d", got[0].env.RoutingKey, got[0].payload.Seq)
+	}
+	return c.expectNone(quietPeriod)
+}
+
+// testDelayedCancelRestart 订阅器离线期间取消的延迟消息，订阅器重新启动后也不会投递
+func testDelayedCancelRestart(ctx context.Context, s *suite) error {
+	pub, err := s.publisher()
+	if err != nil {
+		return err
+	}
+
+	queue, rk := s.queue("delayed-restart"), s.key("delayed.restart")
+
+	// 先订阅一次声明队列和绑定，然后订阅器下线
+	first, err := s.backend.NewSubscriber()
+	if err != nil {
+		return err
+	}
+	if err := first.SubscribeWithOptions(queue, rk, newCollector().handler(0), noRetry()); err != nil {
+		first.Close()
+		return err
+	}
+	if err := first.Close(); err != nil {
+		return err
+	}
+
+	const delay = 500 * time.Millisecond
+	cancelled, err := pub.PublishDelayed(ctx, rk, message{Seq: 1}, delay)
+	if err != nil {
+		return err
+	}
+	if _, err := pub.PublishDelayed(ctx, rk, message{Seq: 2}, delay); err != nil {
+		return err
+	}
+	if err := pub.CancelDelayed(ctx, cancelled); err != nil {
+		return fmt.Errorf("取消延迟消息失败: %w", err)
+	}
+	time.Sleep(delay)
+
+	// 重新启动的订阅器没有收到过取消，仍然不能处理已取消的消息
+	sub, err := s.subscriber()
+	if err != nil {
+		return err
+	}
+	c := newCollector()
+	if err := sub.SubscribeWithOptions(queue, rk, c.handler(1), noRetry()); err != nil {
+		return err
+	}
+
+	got, err := c.wait(ctx, 1)
+	if err != nil {
+		return err
+	}
+	if got[0].payload.Seq != 2 {
+		return fmt.Errorf("投递了已取消的延迟消息: seq=%d", got[0].payload.Seq)
+	}
+	return c.expectNone(quietPeriod)
+}
+
+// testBatch 批量发布的消息逐条交给处理函数，保持顺序和各自的事件ID
+func testBatch(ctx context.Context, s *suite) error {
+	pub, err := s.publisher()
+	if err != nil {
+		return err
+	}
+	sub, err := s.subscriber()
+	if err != nil {
+		return err
+	}
+
+	c := newCollector()
+	rk := s.key("batch.event")
+	if err := sub.SubscribeWithOptions(s.queue("batch"), rk, c.handler(0), noRetry()); err != nil {
+		return err
+	}
+
+	// 前三条达到数量上限立即发布，后两条由Close发布
+	batch := events.NewBatchPublisher(pub, events.BatchOptions{MaxMessages: 3, Window: time.Minute})
+	for i := 0; i < 5; i++ {
+		if err := batch.Add(ctx, rk, message{Seq: i}); err != nil {
+			return err
+		}
+	}
+	if err := batch.Close(); err != nil {
+		return fmt.Errorf("发布剩余批次失败: %w", err)
+	}
+
+	got, err := c.wait(ctx, 5)
+	if err != nil {
+		return err
+	}
+	ids := make(map[string]bool)
+	for i, r := range got {
+		if r.payload.Seq != i || r.env.RoutingKey != rk {
+			return fmt.Errorf("第%d条消息不一致: %s seq=%d", i+1, r.env.RoutingKey, r.payload.Seq)
+		}
+		if r.env.ContentType != events.ContentTypeJSON {
+			return fmt.Errorf("消息的内容类型为 %s", r.env.ContentType)
+		}
+		ids[r.env.EventID] = true
+	}
+	if len(ids) != 5 {
+		return fmt.Errorf("批量消息的事件ID重复: %d个不同的ID", len(ids))
+	}
+	return c.expectNone(quietPeriod)
+}
+
+// testOrdering 多个工作协程并发处理时，相同排序键的消息保持发布顺序
+func testOrdering(ctx context.Context, s *suite) error {
+	pub, err := s.publisher()
+	if err != nil {
+		return err
+	}
+	sub, err := s.subscriber()
+	if err != nil {
+		return err
+	}
+
+	c := newCollector()
+	record := c.handler(0)
+	handler := func(ctx context.Context, env events.Envelope) error {
+		// 让不同排序键的消息交错完成
+		time.Sleep(time.Millisecond)
+		return record(ctx, env)
+	}
+	opts := events.SubscribeOptions{Workers: 4, Prefetch: 16, OrderingKey: events.OrderByField("key")}
+	rk := s.key("ordering.event")
+	if err := sub.SubscribeWithOptions(s.queue("ordering"), rk, handler, opts); err != nil {
+		return err
+	}
+
+	const perKey = 20
+	keys := []string{"a", "b", "c"}
+	for i := 0; i < perKey; i++ {
+		for _, k := range keys {
+			if err := pub.PublishEvent(ctx, rk, message{Seq: i, Key: k}); err != nil {
+				return err
+			}
+		}
+	}
+
+	got, err := c.wait(ctx, perKey*len(keys))
+	if err != nil {
+		return err
+	}
+	sequences := make(map[string][]int)
+	for _, r := range got {
+		sequences[r.payload.Key] = append(sequences[r.payload.Key], r.payload.Seq)
+	}
+	for _, k := range keys {
+		if !sort.IntsAreSorted(sequences[k]) {
+			return fmt.Errorf("排序键 %s 的消息乱序: %v", k, sequences[k])
+		}
+	}
+	return nil
+}
+
+// testBatchOrdering 排序键不同的消息合并为一个批次时，各键由不同的工作协程并发处理并保持各自的顺序
+func testBatchOrdering(ctx context.Context, s *suite) error {
+	pub, err := s.publisher()
+	if err != nil {
+		return err
+	}
+	sub, err := s.subscriber()
+	if err != nil {
+		return err
+	}
+
+	const perKey = 5
+	c := newCollector()
+	record := c.handler(0)
+	// 键a的第一条消息等到键b的消息全部处理完才返回，两个键由同一个工作协程依次处理时会一直等待；
+	// 按FNV哈希，a和b分配到4个工作协程中的不同协程
+	bDone := make(chan struct{})
+	var bCount int
+	handler := events.Typed(func(hctx context.Context, env events.Envelope, payload message) error {
+		if payload.Key == "a" && payload.Seq == 0 {
+			select {
+			case <-bDone:
+			case <-ctx.Done():
+				return errors.New("键a的消息等待键b超时，批次中的排序键没有并发处理")
+			}
+		}
+		if err := record(hctx, env); err != nil {
+			return err
+		}
+		if payload.Key == "b" {
+			if bCount++; bCount == perKey {
+				close(bDone)
+			}
+		}
+		return nil
+	})
+	opts := events.SubscribeOptions{Workers: 4, Prefetch: 16, OrderingKey: events.OrderByField("key")}
+	rk := s.key("batch.ordering.event")
+	if err := sub.SubscribeWithOptions(s.queue("batch-ordering"), rk, handler, opts); err != nil {
+		return err
+	}
+
+	batch := events.NewBatchPublisher(pub, events.BatchOptions{MaxMessages: 2 * perKey, Window: time.Minute})
+	for i := 0; i < perKey; i++ {
+		for _, k := range []string{"a", "b"} {
+			if err := batch.Add(ctx, rk, message{Seq: i, Key: k}); err != nil {
+				return err
+			}
+		}
+	}
+	if err := batch.Close(); err != nil {
+		return fmt.Errorf("发布批次失败: %w", err)
+	}
+
+	got, err := c.wait(ctx, 2*perKey)
+	if err != nil {
+		return err
+	}
+	sequences := make(map[string][]int)
+	for _, r := range got {
+		sequences[r.payload.Key] = append(sequences[r.payload.Key], r.payload.Seq)
+	}
+	for _, k := range []string{"a", "b"} {
+		if len(sequences[k]) != perKey || !sort.IntsAreSorted(sequences[k]) {
+			return fmt.Errorf("排序键 %s 的消息乱序或缺失: %v", k, sequences[k])
+		}
+	}
+	return c.expectNone(quietPeriod)
+}
+
+// testGracefulShutdown 优雅关闭等待正在处理的消息完成后才返回
+func testGracefulShutdown(ctx context.Context, s *suite) error {
+	pub, err := s.publisher()
+	if err != nil {
+		return err
+	}
+	sub, err := s.backend.NewSubscriber()
+	if err != nil {
+		return err
+	}
+
+	started := make(chan struct{}, 1)
+	var mu sync.Mutex
+	finished := false
+	handler := func(ctx context.Context, env events.Envelope) error {
+		started <- struct{}{}
+		time.Sleep(300 * time.Millisecond)
+		mu.Lock()
+		finished = true
+		mu.Unlock()
+		return nil
+	}
+	rk := s.key("shutdown.event")
+	if err := sub.SubscribeWithOptions(s.queue("shutdown"), rk, handler, noRetry()); err != nil {
+		sub.Close()
+		return err
+	}
+	if err := pub.PublishEvent(ctx, rk, message{Seq: 1}); err != nil {
+		sub.Close()
+		return err
+	}
+	select {
+	case <-started:
+	case <-ctx.Done():
+		sub.Close()
+		return errors.New("等待消息开始处理超时")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := events.Shutdown(shutdownCtx, sub); err != nil {
+		return fmt.Errorf("优雅关闭失败: %w", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !finished {
+		return errors.New("优雅关闭在消息处理完成前返回")
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package conformance_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"ride-sharing/shared/events"
+	"ride-sharing/shared/events/conformance"
+	"ride-sharing/shared/miniredis"
+)
+
+const exchange = "conformance"
+
+func TestMemory(t *testing.T) {
+	broker := events.NewInMemoryBroker()
+	run(t, conformance.Backend{
+		Name: events.BackendMemory,
+		NewPublisher: func() (events.Publisher, error) {
+			return events.NewInMemoryPublisher(broker, exchange, "conformance"), nil
+		},
+		NewSubscriber: func() (events.Subscriber, error) {
+			return events.NewInMemorySubscriber(broker, exchange, "conformance"), nil
+		},
+		NewRequester: func() (events.Requester, error) {
+			return events.NewInMemoryRequester(broker, exchange, "conformance", events.DefaultRequesterOptions()), nil
+		},
+		CrashRedelivery: true,
+	})
+}
+
+// TestRedis 使用内嵌的miniredis，不依赖外部Redis
+func TestRedis(t *testing.T) {
+	server, err := miniredis.Run("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("启动内嵌Redis失败: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	url := server.URL()
+
+	// 较短的可见性超时让崩溃用例尽快完成
+	opts := events.DefaultRedisOptions()
+	opts.Prefix = "conformance"
+	opts.VisibilityTimeout = 1 * time.Second
+	opts.BlockTimeout = 200 * time.Millisecond
+
+	run(t, conformance.Backend{
+		Name: events.BackendRedis,
+		NewPublisher: func() (events.Publisher, error) {
+			return events.NewRedisPublisher(url, exchange, "conformance", opts)
+		},
+		NewSubscriber: func() (events.Subscriber, error) {
+			return events.NewRedisSubscriber(url, exchange, "conformance", opts)
+		},
+		NewRequester: func() (events.Requester, error) {
+			return events.NewRedisRequester(url, exchange, "conformance", events.DefaultRequesterOptions(), opts)
+		},
+		CrashRedelivery: true,
+	})
+}
+
+// TestRabbitMQ 需要通过RABBITMQ_URL指定RabbitMQ，未设置时跳过
+func TestRabbitMQ(t *testing.T) {
+	url := os.Getenv("RABBITMQ_URL")
+	if url == "" {
+		t.Skip("未设置RABBITMQ_URL")
+	}
+
+	run(t, conformance.Backend{
+		Name: events.BackendRabbitMQ,
+		NewPublisher: func() (events.Publisher, error) {
+			return events.NewRabbitMQPublisher(url, exchange, "conformance")
+		},
+		NewSubscriber: func() (events.Subscriber, error) {
+			return events.NewRabbitMQSubscriber(url, exchange, "conformance")
+		},
+		NewRequester: func() (events.Requester, error) {
+			return events.NewRabbitMQRequester(url, exchange, "conformance", events.DefaultRequesterOptions())
+		},
+		CrashRedelivery: true,
+	})
+}
+
+// run 运行一致性用例，每个用例作为一个子测试报告结果
+func run(t *testing.T, b conformance.Backend) {
+	t.Helper()
+	for _, r := range conformance.Run(context.Background(), b) {
+		t.Run(r.Case, func(t *testing.T) {
+			switch {
+			case r.Skipped:
+				t.Skip("后端不支持该用例")
+			case !r.Passed():
+				t.Fatalf("耗时 %v: %v", r.Duration, r.Err)
+			}
+		})
+	}
+}
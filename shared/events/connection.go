@@ -0,0 +1,309 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"ride-sharing/shared/metrics"
+	"ride-sharing/shared/retry"
+)
+
+// ConnectionState 连接状态
+type ConnectionState int
+
+const (
+	// StateConnected 连接可用
+	StateConnected ConnectionState = iota
+	// StateReconnecting 连接断开，正在按退避策略重连
+	StateReconnecting
+	// StateClosed 连接已被主动关闭，不再重连
+	StateClosed
+)
+
+// String 返回连接状态名称
+func (s ConnectionState) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateClosed:
+		return "closed"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+// ConnectionEvent 连接状态变化事件
+type ConnectionEvent struct {
+	State   ConnectionState
+	Err     error
+	Attempt int
+	At      time.Time
+}
+
+// ConnectionStateNotifier 可观察连接状态的组件
+type ConnectionStateNotifier interface {
+	// ConnectionState 返回当前连接状态
+	ConnectionState() ConnectionState
+	// NotifyConnectionState 注册状态变化监听通道，通道已满时事件会被丢弃
+	NotifyConnectionState(ch chan ConnectionEvent) chan ConnectionEvent
+}
+
+// ErrConnectionClosed 连接已被主动关闭
+var ErrConnectionClosed = errors.New("RabbitMQ连接已关闭")
+
+// DefaultReconnectConfig 返回默认的重连退避策略，MaxRetries不生效，连接会一直重试直到被关闭
+func DefaultReconnectConfig() retry.Config {
+	return retry.Config{
+		InitialWait: 1 * time.Second,
+		MaxWait:     30 * time.Second,
+	}
+}
+
+// Connection RabbitMQ连接监督器
+// 保存原始URL，连接断开后按退避策略重连，并在重连成功后依次执行注册的恢复函数以重建拓扑和消费者
+type Connection struct {
+	url     string
+	backoff retry.Config
+
+	mu        sync.RWMutex
+	conn      *amqp091.Connection
+	state     ConnectionState
+	listeners []chan ConnectionEvent
+	restorers []func() error
+
+	closing chan struct{}
+	done    chan struct{}
+}
+
+// NewConnection 连接到RabbitMQ并启动连接监督
+func NewConnection(amqpURL string) (*Connection, error) {
+	return NewConnectionWithBackoff(amqpURL, DefaultReconnectConfig())
+}
+
+// NewConnectionWithBackoff 使用指定的重连退避策略连接到RabbitMQ
+func NewConnectionWithBackoff(amqpURL string, backoff retry.Config) (*Connection, error) {
+	conn, err := amqp091.Dial(amqpURL)
+	if err != nil {
+		return nil, fmt.Errorf("连接RabbitMQ失败: %w", err)
+	}
+
+	c := &Connection{
+		url:     amqpURL,
+		backoff: backoff,
+		conn:    conn,
+		state:   StateConnected,
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go c.supervise(conn)
+
+	return c, nil
+}
+
+// Channel 在当前连接上创建新通道
+func (c *Connection) Channel() (*amqp091.Channel, error) {
+	c.mu.RLock()
+	conn, state := c.conn, c.state
+	c.mu.RUnlock()
+
+	if state == StateClosed {
+		return nil, ErrConnectionClosed
+	}
+	if conn == nil || conn.IsClosed() {
+		return nil, fmt.Errorf("RabbitMQ连接不可用，当前状态: %s", state)
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("创建通道失败: %w", err)
+	}
+	return ch, nil
+}
+
+// State 返回当前连接状态
+func (c *Connection) State() ConnectionState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.state
+}
+
+// NotifyState 注册状态变化监听通道
+// 状态变化不会阻塞在慢速监听者上，调用方应为通道预留缓冲
+func (c *Connection) NotifyState(ch chan ConnectionEvent) chan ConnectionEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listeners = append(c.listeners, ch)
+	return ch
+}
+
+// OnReconnect 注册重连成功后执行的恢复函数，按注册顺序执行
+// 任一恢复函数失败时会关闭新连接并重新进入重连流程
+func (c *Connection) OnReconnect(restore func() error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.restorers = append(c.restorers, restore)
+}
+
+// Close 关闭连接并停止重连
+func (c *Connection) Close() error {
+	c.mu.Lock()
+	if c.isClosing() {
+		c.mu.Unlock()
+		return nil
+	}
+	conn := c.conn
+	close(c.closing)
+	c.mu.Unlock()
+
+	var err error
+	if conn != nil && !conn.IsClosed() {
+		err = conn.Close()
+	}
+
+	<-c.done
+	c.setState(StateClosed, nil, 0)
+	return err
+}
+
+// supervise 等待连接断开并重连，直到连接被主动关闭
+func (c *Connection) supervise(conn *amqp091.Connection) {
+	defer close(c.done)
+
+	for {
+		closeErr, ok := <-conn.NotifyClose(make(chan *amqp091.Error, 1))
+		if c.isClosing() {
+			return
+		}
+
+		var cause error = errors.New("连接被关闭")
+		if ok && closeErr != nil {
+			cause = closeErr
+		}
+		log.Printf("RabbitMQ连接断开: %v", cause)
+
+		conn = c.reconnect(cause)
+		if conn == nil {
+			return
+		}
+	}
+}
+
+// reconnect 按退避策略重连并执行恢复函数，连接被主动关闭时返回nil
+func (c *Connection) reconnect(cause error) *amqp091.Connection {
+	c.setState(StateReconnecting, cause, 0)
+
+	for attempt := 1; ; attempt++ {
+		wait := retry.Backoff(c.backoff, attempt)
+		select {
+		case <-c.closing:
+			return nil
+		case <-time.After(wait):
+		}
+
+		log.Printf("尝试重新连接RabbitMQ(第%d次)...", attempt)
+
+		conn, err := amqp091.Dial(c.url)
+		if err != nil {
+			log.Printf("RabbitMQ重连失败(第%d次): %v", attempt, err)
+			c.setState(StateReconnecting, err, attempt)
+			continue
+		}
+
+		c.mu.Lock()
+		if c.isClosing() {
+			c.mu.Unlock()
+			conn.Close()
+			return nil
+		}
+		c.conn = conn
+		restorers := append([]func() error(nil), c.restorers...)
+		c.mu.Unlock()
+
+		if err := runRestorers(restorers); err != nil {
+			log.Printf("RabbitMQ重连后恢复拓扑失败(第%d次): %v", attempt, err)
+			conn.Close()
+			c.setState(StateReconnecting, err, attempt)
+			continue
+		}
+
+		log.Printf("RabbitMQ重连成功")
+		c.setState(StateConnected, nil, attempt)
+		return conn
+	}
+}
+
+// runRestorers 依次执行恢复函数
+func runRestorers(restorers []func() error) error {
+	for _, restore := range restorers {
+		if err := restore(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isClosing 判断连接是否正在被主动关闭
+func (c *Connection) isClosing() bool {
+	select {
+	case <-c.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+// setState 更新连接状态并通知监听者
+func (c *Connection) setState(state ConnectionState, err error, attempt int) {
+	c.mu.Lock()
+	c.state = state
+	listeners := append([]chan ConnectionEvent(nil), c.listeners...)
+	c.mu.Unlock()
+
+	event := ConnectionEvent{
+		State:   state,
+		Err:     err,
+		Attempt: attempt,
+		At:      time.Now(),
+	}
+	for _, ch := range listeners {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// LogConnectionState 若组件支持连接状态通知，则在后台记录其连接状态变化，导出为events_connection_up指标，
+// 并注册为 /healthz 的健康检查，连接不可用时服务不再就绪
+func LogConnectionState(name string, component interface{}) {
+	notifier, ok := component.(ConnectionStateNotifier)
+	if !ok {
+		return
+	}
+
+	metrics.RegisterHealthCheck(name, func() error {
+		if state := notifier.ConnectionState(); state != StateConnected {
+			return fmt.Errorf("连接状态: %s", state)
+		}
+		return nil
+	})
+	observeConnection(name, ConnectionEvent{State: notifier.ConnectionState()})
+	updates := notifier.NotifyConnectionState(make(chan ConnectionEvent, 16))
+	go func() {
+		for event := range updates {
+			observeConnection(name, event)
+			if event.Err != nil {
+				log.Printf("%s 连接状态: %s(第%d次尝试): %v", name, event.State, event.Attempt, event.Err)
+				continue
+			}
+			log.Printf("%s 连接状态: %s", name, event.State)
+		}
+	}()
+}
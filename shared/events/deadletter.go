@@ -0,0 +1,306 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// 重试与死信相关的消息头
+const (
+	HeaderRetryCount         = "x-retry-count"
+	HeaderDeathReason        = "x-death-reason"
+	HeaderOriginalExchange   = "x-original-exchange"
+	HeaderOriginalRoutingKey = "x-original-routing-key"
+	HeaderFailedAt           = "x-failed-at"
+)
+
+// headerInt 读取整数类型的消息头
+func headerInt(headers map[string]interface{}, key string) int {
+	switch v := headers[key].(type) {
+	case int:
+		return v
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	default:
+		return 0
+	}
+}
+
+// headerString 读取字符串类型的消息头
+func headerString(headers map[string]interface{}, key string) string {
+	if v, ok := headers[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+// copyHeaders 复制消息头，避免修改原始消息
+func copyHeaders(headers map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(headers)+4)
+	for k, v := range headers {
+		out[k] = v
+	}
+	return out
+}
+
+// DeadLetter 死信队列中的一条消息
+type DeadLetter struct {
+	Queue      string
+	EventID    string
+	Exchange   string
+	RoutingKey string
+	Reason     string
+	RetryCount int
+	FailedAt   time.Time
+	Body       []byte
+}
+
+// newDeadLetter 从消息头中还原死信信息
+func newDeadLetter(queue string, headers map[string]interface{}, body []byte) DeadLetter {
+	failedAt, _ := time.Parse(time.RFC3339Nano, headerString(headers, HeaderFailedAt))
+	return DeadLetter{
+		Queue:      queue,
+		EventID:    headerString(headers, HeaderEventID),
+		Exchange:   headerString(headers, HeaderOriginalExchange),
+		RoutingKey: headerString(headers, HeaderOriginalRoutingKey),
+		Reason:     headerString(headers, HeaderDeathReason),
+		RetryCount: headerInt(headers, HeaderRetryCount),
+		FailedAt:   failedAt,
+		Body:       body,
+	}
+}
+
+// DeadLetterManager 死信队列的查看与重新投递
+type DeadLetterManager interface {
+	// InspectDeadLetters 查看死信队列中最多limit条消息，不会将其移出队列
+	InspectDeadLetters(queueName string, limit int) ([]DeadLetter, error)
+	// RedriveDeadLetters 将最多limit条死信重新投递到原队列，返回投递数量
+	RedriveDeadLetters(queueName string, limit int) (int, error)
+}
+
+// deadLetterQueueName 订阅队列对应的死信队列名称
+func deadLetterQueueName(queueName string) string {
+	return queueName + ".dlq"
+}
+
+// deadLetterExchangeName 交换器对应的死信交换器名称
+func deadLetterExchangeName(exchange string) string {
+	return exchange + ".dlx"
+}
+
+// retryQueueName 指定延迟对应的重试队列名称
+func retryQueueName(queueName string, delay time.Duration) string {
+	return fmt.Sprintf("%s.retry.%d", queueName, delay.Milliseconds())
+}
+
+// declareRetryTopology 声明订阅所需的重试队列和死信队列，死信交换器由队列绑定的交换器推导
+// 重试队列中的消息过期后通过默认交换器回到原队列，只会被该订阅重新消费
+func declareRetryTopology(ch *amqp091.Channel, exchange, queueName string, opts SubscribeOptions) error {
+	for _, delay := range opts.retryDelays() {
+		_, err := ch.QueueDeclare(
+			retryQueueName(queueName, delay), // 队列名称
+			true,                             // 持久化
+			false,                            // 自动删除
+			false,                            // 独占
+			false,                            // 不等待
+			amqp091.Table{
+				"x-message-ttl":             delay.Milliseconds(),
+				"x-dead-letter-exchange":    "",
+				"x-dead-letter-routing-key": queueName,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("声明重试队列失败: %w", err)
+		}
+	}
+
+	if !opts.DeadLetter {
+		return nil
+	}
+
+	dlx := deadLetterExchangeName(exchange)
+	if err := ch.ExchangeDeclare(dlx, "direct", true, false, false, false, nil); err != nil {
+		return fmt.Errorf("声明死信交换器失败: %w", err)
+	}
+
+	dlq := deadLetterQueueName(queueName)
+	if _, err := ch.QueueDeclare(dlq, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("声明死信队列失败: %w", err)
+	}
+
+	if err := ch.QueueBind(dlq, queueName, dlx, false, nil); err != nil {
+		return fmt.Errorf("绑定死信队列失败: %w", err)
+	}
+
+	return nil
+}
+
+// handleFailure 处理失败的消息：未超过重试次数时投递到重试队列，否则投递到死信队列
+func (s *RabbitMQSubscriber) handleFailure(queueName string, msg amqp091.Delivery, handlerErr error, opts SubscribeOptions) {
+	retryCount := headerInt(msg.Headers, HeaderRetryCount)
+
+	// 记录消息最初的交换器和路由键，重试消息经过默认交换器后会丢失这些信息
+	headers := copyHeaders(msg.Headers)
+	if headerString(headers, HeaderOriginalRoutingKey) == "" {
+		headers[HeaderOriginalExchange] = msg.Exchange
+		headers[HeaderOriginalRoutingKey] = msg.RoutingKey
+	}
+
+	if delay, ok := nextRetry(opts, retryCount, handlerErr); ok {
+		headers[HeaderRetryCount] = int32(retryCount + 1)
+		if err := s.republish("", retryQueueName(queueName, delay), msg, headers); err != nil {
+			log.Printf("投递重试消息失败，消息重新入队: %v", err)
+			msg.Nack(false, true)
+			observeNack(queueName, nackRequeued)
+			return
+		}
+
+		log.Printf("消息将在 %v 后重试(%d/%d): 队列=%s", delay, retryCount+1, opts.Retry.MaxRetries, queueName)
+		msg.Ack(false)
+		observeNack(queueName, nackRetry)
+		return
+	}
+
+	if !opts.DeadLetter {
+		log.Printf("消息重试耗尽，已丢弃: 队列=%s, 原因=%v", queueName, handlerErr)
+		msg.Nack(false, false)
+		observeNack(queueName, nackDiscarded)
+		return
+	}
+
+	headers[HeaderRetryCount] = int32(retryCount)
+	headers[HeaderDeathReason] = handlerErr.Error()
+	headers[HeaderFailedAt] = time.Now().UTC().Format(time.RFC3339Nano)
+	if err := s.republish(deadLetterExchangeName(s.exchangeFor(opts)), queueName, msg, headers); err != nil {
+		log.Printf("投递死信消息失败，消息重新入队: %v", err)
+		msg.Nack(false, true)
+		observeNack(queueName, nackRequeued)
+		return
+	}
+
+	log.Printf("消息已投递到死信队列: 队列=%s, 原因=%v", deadLetterQueueName(queueName), handlerErr)
+	msg.Ack(false)
+	observeNack(queueName, nackDeadLetter)
+}
+
+// republishConfirmTimeout 等待重新发布的消息被代理确认的超时时间
+const republishConfirmTimeout = 5 * time.Second
+
+// republish 以新的消息头重新发布消息，并等待代理确认
+func (s *RabbitMQSubscriber) republish(exchange, routingKey string, msg amqp091.Delivery, headers amqp091.Table) error {
+	ch, err := s.currentChannel()
+	if err != nil {
+		return err
+	}
+
+	return publishAndConfirm(ch, exchange, routingKey, republishing(msg, headers))
+}
+
+// publishAndConfirm 在确认模式的通道上发布消息，代理确认前不返回
+// 拒绝或超时都返回错误，调用方据此把原消息重新入队，避免消息在确认原消息后丢失
+func publishAndConfirm(ch *amqp091.Channel, exchange, routingKey string, publishing amqp091.Publishing) error {
+	ctx, cancel := context.WithTimeout(context.Background(), republishConfirmTimeout)
+	defer cancel()
+
+	deferred, err := ch.PublishWithDeferredConfirm(
+		exchange,   // 交换器
+		routingKey, // 路由键
+		false,      // 强制
+		false,      // 立即
+		publishing, // 消息
+	)
+	if err != nil {
+		return err
+	}
+
+	confirmation := &Confirmation{done: deferred.Done(), acked: deferred.Acked}
+	return confirmation.Wait(ctx)
+}
+
+// republishing 复制消息体和信封属性，使用新的消息头
+func republishing(msg amqp091.Delivery, headers amqp091.Table) amqp091.Publishing {
+	return amqp091.Publishing{
+		Headers:       headers,
+		ContentType:   msg.ContentType,
+		DeliveryMode:  amqp091.Persistent,
+		MessageId:     msg.MessageId,
+		CorrelationId: msg.CorrelationId,
+		Type:          msg.Type,
+		AppId:         msg.AppId,
+		Body:          msg.Body,
+		Timestamp:     msg.Timestamp,
+	}
+}
+
+// InspectDeadLetters 查看死信队列中的消息
+// 使用独立通道读取，关闭通道时未确认的消息会自动回到死信队列
+func (s *RabbitMQSubscriber) InspectDeadLetters(queueName string, limit int) ([]DeadLetter, error) {
+	ch, err := s.conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("创建通道失败: %w", err)
+	}
+	defer ch.Close()
+
+	var letters []DeadLetter
+	for len(letters) < limit {
+		msg, ok, err := ch.Get(deadLetterQueueName(queueName), false)
+		if err != nil {
+			return letters, fmt.Errorf("读取死信队列失败: %w", err)
+		}
+		if !ok {
+			break
+		}
+		letters = append(letters, newDeadLetter(queueName, msg.Headers, msg.Body))
+	}
+
+	return letters, nil
+}
+
+// RedriveDeadLetters 将死信重新投递到原队列，重试次数清零
+func (s *RabbitMQSubscriber) RedriveDeadLetters(queueName string, limit int) (int, error) {
+	ch, err := s.conn.Channel()
+	if err != nil {
+		return 0, fmt.Errorf("创建通道失败: %w", err)
+	}
+	defer ch.Close()
+
+	// 死信副本收到代理确认后才从死信队列确认删除
+	if err := ch.Confirm(false); err != nil {
+		return 0, fmt.Errorf("开启发布确认失败: %w", err)
+	}
+
+	redriven := 0
+	for redriven < limit {
+		msg, ok, err := ch.Get(deadLetterQueueName(queueName), false)
+		if err != nil {
+			return redriven, fmt.Errorf("读取死信队列失败: %w", err)
+		}
+		if !ok {
+			break
+		}
+
+		headers := copyHeaders(msg.Headers)
+		delete(headers, HeaderDeathReason)
+		delete(headers, HeaderFailedAt)
+		headers[HeaderRetryCount] = int32(0)
+
+		if err := publishAndConfirm(ch, "", queueName, republishing(msg, headers)); err != nil {
+			msg.Nack(false, true)
+			return redriven, fmt.Errorf("重新投递死信失败: %w", err)
+		}
+
+		msg.Ack(false)
+		redriven++
+	}
+
+	log.Printf("已重新投递 %d 条死信: 队列=%s", redriven, queueName)
+	return redriven, nil
+}
@@ -0,0 +1,409 @@
+package events
+
+import (
+	"bufio"
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// ProcessingStatus 事件处理状态
+type ProcessingStatus string
+
+const (
+	StatusProcessing ProcessingStatus = "processing"
+	StatusSucceeded  ProcessingStatus = "succeeded"
+	StatusFailed     ProcessingStatus = "failed"
+)
+
+// ProcessingRecord 一个事件在某个队列上的处理记录
+type ProcessingRecord struct {
+	Key       string           `json:"key"`
+	Status    ProcessingStatus `json:"status"`
+	Attempts  int              `json:"attempts"`
+	Error     string           `json:"error,omitempty"`
+	UpdatedAt time.Time        `json:"updatedAt"`
+}
+
+// ErrEventInProgress 同一事件正在被其他消费者处理
+var ErrEventInProgress = errors.New("事件正在处理中")
+
+// DedupStore 去重存储接口
+type DedupStore interface {
+	// Begin 占用事件的处理权，已成功处理或正在处理时返回false和已有记录
+	Begin(ctx context.Context, key string) (*ProcessingRecord, bool, error)
+	// Complete 记录处理结果，失败的事件可以再次被占用
+	Complete(ctx context.Context, key string, handlerErr error) error
+	// Lookup 查询事件的处理记录
+	Lookup(ctx context.Context, key string) (*ProcessingRecord, bool, error)
+}
+
+// DedupConfig 去重存储配置
+type DedupConfig struct {
+	// Capacity 最多保留的记录数，超出后淘汰最久未使用的记录
+	Capacity int
+	// TTL 记录的保留时间
+	TTL time.Duration
+	// ProcessingTimeout 处理中状态的租约时间，超时后视为处理者已崩溃，允许重新处理
+	ProcessingTimeout time.Duration
+}
+
+// DefaultDedupConfig 返回默认的去重存储配置
+func DefaultDedupConfig() DedupConfig {
+	return DedupConfig{
+		Capacity:          10000,
+		TTL:               24 * time.Hour,
+		ProcessingTimeout: 30 * time.Second,
+	}
+}
+
+// dedupKey 去重键，同一事件在不同队列上分别处理
+func dedupKey(queueName, eventID string) string {
+	return queueName + "/" + eventID
+}
+
+// Idempotent 幂等消费中间件：按事件ID去重，已成功处理的事件直接确认而不再调用处理函数
+// 没有事件ID的旧版本消息不做去重
+func Idempotent(store DedupStore, queueName string, handler Handler) Handler {
+	return func(ctx context.Context, env Envelope) error {
+		if env.EventID == "" {
+			return handler(ctx, env)
+		}
+
+		key := dedupKey(queueName, env.EventID)
+		record, ok, err := store.Begin(ctx, key)
+		if err != nil {
+			return fmt.Errorf("读取去重记录失败: %w", err)
+		}
+		if !ok {
+			if record.Status == StatusSucceeded {
+				log.Printf("跳过重复事件: 队列=%s, 事件ID=%s, 类型=%s", queueName, env.EventID, env.EventType)
+				return nil
+			}
+			// 其他消费者仍在处理，稍后按重试策略再次投递
+			return fmt.Errorf("%w: %s", ErrEventInProgress, key)
+		}
+
+		handlerErr := handler(ctx, env)
+		if err := store.Complete(ctx, key, handlerErr); err != nil {
+			log.Printf("记录事件处理结果失败: %v", err)
+		}
+		return handlerErr
+	}
+}
+
+// InMemoryDedupStore 基于LRU和TTL的内存去重存储
+type InMemoryDedupStore struct {
+	cfg DedupConfig
+
+	mu      sync.Mutex
+	order   *list.List
+	records map[string]*list.Element
+}
+
+// NewInMemoryDedupStore 创建内存去重存储
+func NewInMemoryDedupStore(cfg DedupConfig) *InMemoryDedupStore {
+	return &InMemoryDedupStore{
+		cfg:     cfg,
+		order:   list.New(),
+		records: make(map[string]*list.Element),
+	}
+}
+
+// Begin 占用事件的处理权
+func (s *InMemoryDedupStore) Begin(ctx context.Context, key string) (*ProcessingRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	record := s.get(key, now)
+	if record != nil {
+		switch {
+		case record.Status == StatusSucceeded:
+			return copyRecord(record), false, nil
+		case record.Status == StatusProcessing && now.Sub(record.UpdatedAt) < s.cfg.ProcessingTimeout:
+			return copyRecord(record), false, nil
+		}
+	} else {
+		record = &ProcessingRecord{Key: key}
+	}
+
+	record.Status = StatusProcessing
+	record.Attempts++
+	record.UpdatedAt = now
+	s.put(record)
+	return copyRecord(record), true, nil
+}
+
+// Complete 记录处理结果
+func (s *InMemoryDedupStore) Complete(ctx context.Context, key string, handlerErr error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := s.get(key, time.Now())
+	if record == nil {
+		record = &ProcessingRecord{Key: key, Attempts: 1}
+	}
+	s.complete(record, handlerErr)
+	s.put(record)
+	return nil
+}
+
+// Lookup 查询事件的处理记录
+func (s *InMemoryDedupStore) Lookup(ctx context.Context, key string) (*ProcessingRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := s.get(key, time.Now())
+	if record == nil {
+		return nil, false, nil
+	}
+	return copyRecord(record), true, nil
+}
+
+// Len 返回当前保留的记录数
+func (s *InMemoryDedupStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+// complete 将处理结果写入记录
+func (s *InMemoryDedupStore) complete(record *ProcessingRecord, handlerErr error) {
+	record.UpdatedAt = time.Now()
+	if handlerErr != nil {
+		record.Status = StatusFailed
+		record.Error = handlerErr.Error()
+		return
+	}
+	record.Status = StatusSucceeded
+	record.Error = ""
+}
+
+// get 读取未过期的记录并将其标记为最近使用，调用方需持有锁
+func (s *InMemoryDedupStore) get(key string, now time.Time) *ProcessingRecord {
+	elem, ok := s.records[key]
+	if !ok {
+		return nil
+	}
+
+	record := elem.Value.(*ProcessingRecord)
+	if s.cfg.TTL > 0 && now.Sub(record.UpdatedAt) > s.cfg.TTL {
+		s.order.Remove(elem)
+		delete(s.records, key)
+		return nil
+	}
+
+	s.order.MoveToFront(elem)
+	return record
+}
+
+// put 写入记录并淘汰超出容量的最久未使用记录，调用方需持有锁
+func (s *InMemoryDedupStore) put(record *ProcessingRecord) {
+	if elem, ok := s.records[record.Key]; ok {
+		elem.Value = record
+		s.order.MoveToFront(elem)
+		return
+	}
+
+	s.records[record.Key] = s.order.PushFront(record)
+	for s.cfg.Capacity > 0 && s.order.Len() > s.cfg.Capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.records, oldest.Value.(*ProcessingRecord).Key)
+	}
+}
+
+// snapshot 按从旧到新的顺序返回所有未过期的记录，调用方需持有锁
+func (s *InMemoryDedupStore) snapshot(now time.Time) []*ProcessingRecord {
+	var records []*ProcessingRecord
+	for elem := s.order.Back(); elem != nil; elem = elem.Prev() {
+		record := elem.Value.(*ProcessingRecord)
+		if s.cfg.TTL > 0 && now.Sub(record.UpdatedAt) > s.cfg.TTL {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// copyRecord 复制记录，避免调用方修改存储中的数据
+func copyRecord(record *ProcessingRecord) *ProcessingRecord {
+	c := *record
+	return &c
+}
+
+// FileDedupStore 基于文件的去重存储，处理结果以JSON行追加写入，重启后可恢复
+// 文件行数超过容量的两倍时自动压缩，只保留仍在内存中的记录
+type FileDedupStore struct {
+	*InMemoryDedupStore
+
+	path  string
+	file  *os.File
+	lines int
+}
+
+// NewFileDedupStore 打开或创建文件去重存储并加载已有记录
+func NewFileDedupStore(path string, cfg DedupConfig) (*FileDedupStore, error) {
+	s := &FileDedupStore{
+		InMemoryDedupStore: NewInMemoryDedupStore(cfg),
+		path:               path,
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("打开去重文件失败: %w", err)
+	}
+	if err := terminateLastLine(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	s.file = file
+
+	return s, nil
+}
+
+// load 从文件中恢复记录，后写入的记录覆盖先写入的
+func (s *FileDedupStore) load() error {
+	file, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取去重文件失败: %w", err)
+	}
+	defer file.Close()
+
+	now := time.Now()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record ProcessingRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			log.Printf("跳过损坏的去重记录: %v", err)
+			continue
+		}
+		s.lines++
+		if s.cfg.TTL > 0 && now.Sub(record.UpdatedAt) > s.cfg.TTL {
+			continue
+		}
+		s.put(&record)
+	}
+	return scanner.Err()
+}
+
+// terminateLastLine 崩溃时文件末尾可能留下不完整的一行，补上换行，避免之后追加的记录与之拼在一起
+func terminateLastLine(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("读取去重文件失败: %w", err)
+	}
+	if info.Size() == 0 {
+		return nil
+	}
+
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+		return fmt.Errorf("读取去重文件失败: %w", err)
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	if _, err := file.Write([]byte{'\n'}); err != nil {
+		return fmt.Errorf("写入去重文件失败: %w", err)
+	}
+	return nil
+}
+
+// Complete 记录处理结果并追加写入文件
+func (s *FileDedupStore) Complete(ctx context.Context, key string, handlerErr error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := s.get(key, time.Now())
+	if record == nil {
+		record = &ProcessingRecord{Key: key, Attempts: 1}
+	}
+	s.complete(record, handlerErr)
+	s.put(record)
+
+	if err := s.append(record); err != nil {
+		return err
+	}
+	if s.cfg.Capacity > 0 && s.lines > 2*s.cfg.Capacity {
+		return s.compact()
+	}
+	return nil
+}
+
+// append 追加一条记录，调用方需持有锁
+func (s *FileDedupStore) append(record *ProcessingRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("序列化去重记录失败: %w", err)
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("写入去重文件失败: %w", err)
+	}
+	s.lines++
+	return nil
+}
+
+// compact 用内存中的记录重写文件，调用方需持有锁
+func (s *FileDedupStore) compact() error {
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("创建去重压缩文件失败: %w", err)
+	}
+
+	lines := 0
+	w := bufio.NewWriter(tmp)
+	for _, record := range s.snapshot(time.Now()) {
+		if record.Status == StatusProcessing {
+			continue
+		}
+		line, err := json.Marshal(record)
+		if err != nil {
+			tmp.Close()
+			return fmt.Errorf("序列化去重记录失败: %w", err)
+		}
+		w.Write(append(line, '\n'))
+		lines++
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入去重压缩文件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("关闭去重压缩文件失败: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("替换去重文件失败: %w", err)
+	}
+
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("重新打开去重文件失败: %w", err)
+	}
+	s.file.Close()
+	s.file = file
+	s.lines = lines
+	return nil
+}
+
+// Close 关闭去重文件
+func (s *FileDedupStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
@@ -0,0 +1,305 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// ageRecord 将记录的更新时间提前d，模拟时间流逝
+func ageRecord(s *InMemoryDedupStore, key string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record := s.records[key].Value.(*ProcessingRecord)
+	record.UpdatedAt = record.UpdatedAt.Add(-d)
+}
+
+func dedupEnvelope(eventID string) Envelope {
+	return Envelope{Metadata: Metadata{EventID: eventID, EventType: "trip.event.created"}}
+}
+
+func TestIdempotent(t *testing.T) {
+	ctx := context.Background()
+	errHandler := errors.New("handler failed")
+
+	tests := []struct {
+		name string
+		// results 依次投递同一事件时处理函数的返回值
+		results []error
+		// want 每次投递中间件的返回值
+		want []error
+		// calls 处理函数被调用的次数
+		calls  int
+		status ProcessingStatus
+	}{
+		{
+			name:    "duplicate after success is skipped",
+			results: []error{nil, nil},
+			want:    []error{nil, nil},
+			calls:   1,
+			status:  StatusSucceeded,
+		},
+		{
+			name:    "failed outcome allows retry",
+			results: []error{errHandler, nil, nil},
+			want:    []error{errHandler, nil, nil},
+			calls:   2,
+			status:  StatusSucceeded,
+		},
+		{
+			name:    "repeated failures are retried",
+			results: []error{errHandler, errHandler},
+			want:    []error{errHandler, errHandler},
+			calls:   2,
+			status:  StatusFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewInMemoryDedupStore(DefaultDedupConfig())
+			calls := 0
+			handler := Idempotent(store, "trip", func(ctx context.Context, env Envelope) error {
+				err := tt.results[calls]
+				calls++
+				return err
+			})
+
+			for i, want := range tt.want {
+				if err := handler(ctx, dedupEnvelope("event-1")); !errors.Is(err, want) {
+					t.Fatalf("delivery %d: got %v, want %v", i, err, want)
+				}
+			}
+			if calls != tt.calls {
+				t.Errorf("got %d handler calls, want %d", calls, tt.calls)
+			}
+
+			record, ok, _ := store.Lookup(ctx, dedupKey("trip", "event-1"))
+			if !ok || record.Status != tt.status || record.Attempts != tt.calls {
+				t.Errorf("got record %+v, want status %q after %d attempts", record, tt.status, tt.calls)
+			}
+		})
+	}
+}
+
+func TestIdempotentInProgress(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryDedupStore(DefaultDedupConfig())
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := Idempotent(store, "trip", func(ctx context.Context, env Envelope) error {
+		close(started)
+		<-release
+		return nil
+	})
+
+	first := make(chan error, 1)
+	go func() { first <- handler(ctx, dedupEnvelope("event-1")) }()
+	<-started
+
+	// 另一个消费者正在处理同一事件时不调用处理函数，交给重试策略稍后再投递
+	if err := handler(ctx, dedupEnvelope("event-1")); !errors.Is(err, ErrEventInProgress) {
+		t.Errorf("got %v, want ErrEventInProgress", err)
+	}
+
+	close(release)
+	if err := <-first; err != nil {
+		t.Fatalf("first delivery: %v", err)
+	}
+	if err := handler(ctx, dedupEnvelope("event-1")); err != nil {
+		t.Errorf("duplicate after success: %v", err)
+	}
+}
+
+func TestIdempotentQueuesAndLegacyMessages(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryDedupStore(DefaultDedupConfig())
+
+	calls := 0
+	count := func(ctx context.Context, env Envelope) error {
+		calls++
+		return nil
+	}
+
+	// 同一事件在不同队列上分别处理，没有事件ID的消息不去重
+	Idempotent(store, "trip", count)(ctx, dedupEnvelope("event-1"))
+	Idempotent(store, "driver", count)(ctx, dedupEnvelope("event-1"))
+	Idempotent(store, "trip", count)(ctx, dedupEnvelope(""))
+	Idempotent(store, "trip", count)(ctx, dedupEnvelope(""))
+	if calls != 4 {
+		t.Errorf("got %d handler calls, want 4", calls)
+	}
+	if store.Len() != 2 {
+		t.Errorf("got %d records, want 2", store.Len())
+	}
+}
+
+func TestInMemoryDedupStoreEviction(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryDedupStore(DedupConfig{Capacity: 2, TTL: time.Hour, ProcessingTimeout: time.Minute})
+
+	for _, key := range []string{"a", "b"} {
+		store.Begin(ctx, key)
+		store.Complete(ctx, key, nil)
+	}
+	// 读取a使其成为最近使用，写入c时淘汰b
+	store.Lookup(ctx, "a")
+	store.Begin(ctx, "c")
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok, _ := store.Lookup(ctx, key); ok != want {
+			t.Errorf("Lookup(%q): got %v, want %v", key, ok, want)
+		}
+	}
+
+	// 过期的记录视为不存在，事件可以再次处理
+	ageRecord(store, "a", 2*time.Hour)
+	if _, ok, _ := store.Lookup(ctx, "a"); ok {
+		t.Error("expired record is still returned")
+	}
+	record, ok, _ := store.Begin(ctx, "a")
+	if !ok || record.Attempts != 1 {
+		t.Errorf("Begin after expiry: got %+v, %v, want first attempt", record, ok)
+	}
+	if store.Len() != 2 {
+		t.Errorf("got %d records, want 2", store.Len())
+	}
+}
+
+func TestInMemoryDedupStoreProcessingTimeout(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryDedupStore(DedupConfig{Capacity: 10, TTL: time.Hour, ProcessingTimeout: time.Minute})
+
+	if _, ok, _ := store.Begin(ctx, "a"); !ok {
+		t.Fatal("first Begin did not take the event")
+	}
+	if record, ok, _ := store.Begin(ctx, "a"); ok || record.Status != StatusProcessing {
+		t.Fatalf("Begin while processing: got %+v, %v", record, ok)
+	}
+
+	// 处理者超过租约时间仍未完成，视为已崩溃，允许其他消费者接手
+	ageRecord(store, "a", 2*time.Minute)
+	record, ok, _ := store.Begin(ctx, "a")
+	if !ok || record.Attempts != 2 {
+		t.Errorf("Begin after processing timeout: got %+v, %v, want second attempt", record, ok)
+	}
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+	}
+	return lines
+}
+
+func TestFileDedupStoreCompaction(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "dedup.jsonl")
+	cfg := DedupConfig{Capacity: 2, TTL: time.Hour, ProcessingTimeout: time.Minute}
+
+	store, err := NewFileDedupStore(path, cfg)
+	if err != nil {
+		t.Fatalf("NewFileDedupStore: %v", err)
+	}
+	// 第5行超过容量的两倍，压缩后只保留内存中的d和e，随后追加f
+	for _, key := range []string{"a", "b", "c", "d", "e", "f"} {
+		store.Begin(ctx, key)
+		if err := store.Complete(ctx, key, nil); err != nil {
+			t.Fatalf("Complete(%q): %v", key, err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := countLines(t, path); got != 3 {
+		t.Errorf("got %d lines after compaction, want 3", got)
+	}
+
+	reloaded, err := NewFileDedupStore(path, cfg)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	defer reloaded.Close()
+
+	for key, want := range map[string]bool{"a": false, "d": false, "e": true, "f": true} {
+		record, ok, _ := reloaded.Lookup(ctx, key)
+		if ok != want || (ok && record.Status != StatusSucceeded) {
+			t.Errorf("Lookup(%q): got %+v, %v, want present %v", key, record, ok, want)
+		}
+	}
+}
+
+func TestFileDedupStoreCrashRecovery(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "dedup.jsonl")
+	cfg := DedupConfig{Capacity: 10, TTL: time.Hour, ProcessingTimeout: time.Minute}
+
+	store, err := NewFileDedupStore(path, cfg)
+	if err != nil {
+		t.Fatalf("NewFileDedupStore: %v", err)
+	}
+	store.Begin(ctx, "done")
+	store.Complete(ctx, "done", nil)
+	store.Begin(ctx, "failed")
+	store.Complete(ctx, "failed", errors.New("handler failed"))
+	store.Begin(ctx, "crashed")
+
+	// 模拟进程在写入一半时崩溃：不关闭存储，文件末尾留下不完整的一行
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	file.WriteString(`{"key":"partial","sta`)
+	file.Close()
+
+	recovered, err := NewFileDedupStore(path, cfg)
+	if err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	defer recovered.Close()
+	defer store.Close()
+
+	// 成功的事件不再处理；失败的事件和崩溃时仍在处理的事件可以重新处理
+	tests := []struct {
+		key   string
+		begin bool
+	}{
+		{"done", false},
+		{"failed", true},
+		{"crashed", true},
+		{"partial", true},
+	}
+	for _, tt := range tests {
+		if _, ok, err := recovered.Begin(ctx, tt.key); err != nil || ok != tt.begin {
+			t.Errorf("Begin(%q): got %v, %v, want %v", tt.key, ok, err, tt.begin)
+		}
+	}
+
+	// 恢复后追加的记录不能与不完整的行拼在一起
+	if err := recovered.Complete(ctx, "failed", nil); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	recovered.Close()
+	reloaded, err := NewFileDedupStore(path, cfg)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	defer reloaded.Close()
+	if record, ok, _ := reloaded.Lookup(ctx, "failed"); !ok || record.Status != StatusSucceeded {
+		t.Errorf("record appended after recovery: got %+v, %v", record, ok)
+	}
+}
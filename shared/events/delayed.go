@@ -0,0 +1,226 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// 延迟消息
+//
+// PublishDelayed 发布的消息在delay之后才按原路由键路由到绑定的队列：
+//   - RabbitMQ：消息先发布到 <exchange>.delay 头交换器，按延迟毫秒数进入对应的TTL队列 <exchange>.delay.<ms>，
+//     过期后经死信交换器回到原交换器，不依赖延迟消息插件
+//   - Redis：消息写入延迟有序集合，到期后由订阅器的轮询按交换器的绑定路由
+//   - 内存：由定时器在到期时路由
+//
+// RabbitMQ无法从TTL队列中删除消息，取消发布到 delay.cancel 扇出交换器，持久保存在流队列 delay.cancel.log 中。
+// 订阅器启动和重连时从头重放取消记录，重放到自己写入的同步标记之后才处理延迟消息，丢弃已取消的消息，
+// 因此订阅器离线期间的取消同样生效。取消记录保留delayCancelLogAge，到期时间更晚的延迟消息无法取消。
+// Redis和内存后端直接删除未到期的消息。
+
+// 延迟消息的消息头
+const (
+	// HeaderDelay 延迟的毫秒数，RabbitMQ头交换器据此选择TTL队列
+	HeaderDelay = "x-delay-ms"
+	// HeaderDelayToken 延迟消息的取消令牌ID
+	HeaderDelayToken = "x-delay-token"
+)
+
+// ErrDelayedNotFound 延迟消息已投递、已取消或不存在
+var ErrDelayedNotFound = errors.New("延迟消息已投递或不存在")
+
+// ErrDelayTooLongToCancel 延迟消息的到期时间超出取消记录的保留时间，无法保证取消生效
+var ErrDelayTooLongToCancel = errors.New("延迟消息的到期时间超出取消记录的保留时间")
+
+// DelayToken 延迟消息的取消令牌，可以序列化后保存，在之后的处理中取消消息
+type DelayToken struct {
+	// ID 延迟消息的事件ID
+	ID         string    `json:"id"`
+	RoutingKey string    `json:"routingKey"`
+	DeliverAt  time.Time `json:"deliverAt"`
+	// Ref 后端内部的消息引用，例如Redis延迟有序集合的成员
+	Ref string `json:"ref,omitempty"`
+}
+
+// delayCancelExchange 发布RabbitMQ延迟消息取消的扇出交换器
+const delayCancelExchange = "delay.cancel"
+
+// delayCancelLog 持久保存延迟消息取消的流队列
+const delayCancelLog = "delay.cancel.log"
+
+// delayCancelLogAge 取消记录在流队列中的保留时间
+const delayCancelLogAge = 7 * 24 * time.Hour
+
+// delayCancelPrefetch 重放取消记录时的预取数量，流队列的消费者必须设置
+const delayCancelPrefetch = 100
+
+// delayCancelSyncTimeout 处理延迟消息前等待取消记录重放完成的最长时间，超时的消息按重试策略稍后再处理
+const delayCancelSyncTimeout = 10 * time.Second
+
+// delayCancelRetention 订阅器在延迟消息到期之后继续记住取消的时间，覆盖消息在队列中积压和重试的时间
+const delayCancelRetention = time.Hour
+
+// delayExchangeName 交换器对应的延迟头交换器名称
+func delayExchangeName(exchange string) string {
+	return exchange + ".delay"
+}
+
+// delayQueueName 指定延迟对应的TTL队列名称
+func delayQueueName(exchange string, delay time.Duration) string {
+	return fmt.Sprintf("%s.delay.%d", exchange, delay.Milliseconds())
+}
+
+// delayHeaderValue 延迟毫秒数的消息头取值，头交换器按字符串精确匹配
+func delayHeaderValue(delay time.Duration) string {
+	return strconv.FormatInt(delay.Milliseconds(), 10)
+}
+
+// declareDelayQueue 声明延迟头交换器和指定延迟的TTL队列
+// TTL队列中的消息过期后投递到原交换器，死信保留消息原来的路由键
+func declareDelayQueue(ch *amqp091.Channel, exchange string, delay time.Duration) error {
+	delayExchange := delayExchangeName(exchange)
+	if err := ch.ExchangeDeclare(delayExchange, "headers", true, false, false, false, nil); err != nil {
+		return fmt.Errorf("声明延迟交换器失败: %w", err)
+	}
+
+	name := delayQueueName(exchange, delay)
+	_, err := ch.QueueDeclare(
+		name,  // 队列名称
+		true,  // 持久化
+		false, // 自动删除
+		false, // 独占
+		false, // 不等待
+		amqp091.Table{
+			"x-message-ttl":          delay.Milliseconds(),
+			"x-dead-letter-exchange": exchange,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("声明延迟队列失败: %w", err)
+	}
+
+	err = ch.QueueBind(name, "", delayExchange, false, amqp091.Table{
+		"x-match":   "all",
+		HeaderDelay: delayHeaderValue(delay),
+	})
+	if err != nil {
+		return fmt.Errorf("绑定延迟队列失败: %w", err)
+	}
+	return nil
+}
+
+// declareDelayCancelLog 声明延迟取消交换器和绑定到它的取消记录流队列
+func declareDelayCancelLog(ch *amqp091.Channel) error {
+	if err := ch.ExchangeDeclare(delayCancelExchange, "fanout", true, false, false, false, nil); err != nil {
+		return fmt.Errorf("声明延迟取消交换器失败: %w", err)
+	}
+
+	_, err := ch.QueueDeclare(
+		delayCancelLog, // 队列名称
+		true,           // 持久化，流队列必须持久化
+		false,          // 自动删除
+		false,          // 独占
+		false,          // 不等待
+		amqp091.Table{
+			"x-queue-type": "stream",
+			"x-max-age":    fmt.Sprintf("%ds", int64(delayCancelLogAge.Seconds())),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("声明延迟取消记录失败: %w", err)
+	}
+
+	if err := ch.QueueBind(delayCancelLog, "", delayCancelExchange, false, nil); err != nil {
+		return fmt.Errorf("绑定延迟取消记录失败: %w", err)
+	}
+	return nil
+}
+
+// delayCancellation 取消记录中的一条延迟消息取消，Marker非空时为订阅器写入的同步标记
+type delayCancellation struct {
+	ID        string    `json:"id,omitempty"`
+	DeliverAt time.Time `json:"deliverAt"`
+	Marker    string    `json:"marker,omitempty"`
+}
+
+// cancelledDelays 订阅器从取消记录中重放的延迟消息取消
+type cancelledDelays struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+	// marker 本次重放等待的同步标记，读到后关闭synced
+	marker string
+	synced chan struct{}
+}
+
+func newCancelledDelays() *cancelledDelays {
+	return &cancelledDelays{
+		until:  make(map[string]time.Time),
+		synced: make(chan struct{}),
+	}
+}
+
+// resync 开始新一轮重放，读到marker之前处理延迟消息都需要等待
+func (c *cancelledDelays) resync(marker string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.marker = marker
+	c.synced = make(chan struct{})
+}
+
+// add 记录取消，保留到消息到期之后的delayCancelRetention，同时清理已过期的记录
+// 读到本次重放的同步标记时表示之前写入的取消都已加载
+func (c *cancelledDelays) add(cancellation delayCancellation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cancellation.Marker != "" {
+		if cancellation.Marker == c.marker {
+			close(c.synced)
+			c.marker = ""
+		}
+		return
+	}
+
+	now := time.Now()
+	for id, until := range c.until {
+		if now.After(until) {
+			delete(c.until, id)
+		}
+	}
+	if until := cancellation.DeliverAt.Add(delayCancelRetention); now.Before(until) {
+		c.until[cancellation.ID] = until
+	}
+}
+
+// wait 等待取消记录重放到本次的同步标记，最多等待delayCancelSyncTimeout
+func (c *cancelledDelays) wait(ctx context.Context) error {
+	c.mu.Lock()
+	synced := c.synced
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, delayCancelSyncTimeout)
+	defer cancel()
+
+	select {
+	case <-synced:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("等待延迟取消记录重放超时: %w", ctx.Err())
+	}
+}
+
+// has 判断延迟消息是否已被取消
+func (c *cancelledDelays) has(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	until, ok := c.until[id]
+	return ok && time.Now().Before(until)
+}
@@ -0,0 +1,52 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCancelledDelaysReplay(t *testing.T) {
+	ctx := context.Background()
+	c := newCancelledDelays()
+	c.resync("marker-1")
+
+	// 读到同步标记之前处理延迟消息需要等待
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := c.wait(waitCtx); err == nil {
+		t.Fatal("wait returned before the marker was replayed")
+	}
+
+	now := time.Now()
+	c.add(delayCancellation{ID: "pending", DeliverAt: now.Add(time.Minute)})
+	c.add(delayCancellation{ID: "expired", DeliverAt: now.Add(-2 * delayCancelRetention)})
+	// 其他订阅器写入的标记不影响本次重放
+	c.add(delayCancellation{Marker: "marker-other"})
+	if err := c.wait(waitCtx); err == nil {
+		t.Fatal("wait returned on another subscriber's marker")
+	}
+
+	c.add(delayCancellation{Marker: "marker-1"})
+	if err := c.wait(ctx); err != nil {
+		t.Fatalf("wait after marker: %v", err)
+	}
+	if !c.has("pending") {
+		t.Error("replayed cancellation is missing")
+	}
+	if c.has("expired") {
+		t.Error("cancellation past its retention is kept")
+	}
+
+	// 重连后重新等待新的标记，之前加载的取消保留
+	c.resync("marker-2")
+	c.add(delayCancellation{Marker: "marker-1"})
+	waitCtx, cancel = context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := c.wait(waitCtx); err == nil {
+		t.Error("wait returned on the previous marker after resync")
+	}
+	if !c.has("pending") {
+		t.Error("cancellation lost on resync")
+	}
+}
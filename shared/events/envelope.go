@@ -0,0 +1,292 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"ride-sharing/shared/contracts"
+)
+
+// DefaultSchemaVersion 事件负载的默认模式版本
+const DefaultSchemaVersion = 1
+
+// 信封元数据在AMQP消息头中的镜像
+const (
+	HeaderEventID       = "x-event-id"
+	HeaderEventType     = "x-event-type"
+	HeaderSchemaVersion = "x-schema-version"
+	HeaderProducer      = "x-producer"
+	HeaderCorrelationID = "x-correlation-id"
+	HeaderCausationID   = "x-causation-id"
+	HeaderOccurredAt    = "x-occurred-at"
+	HeaderContentType   = "x-content-type"
+)
+
+// Metadata 事件元数据
+type Metadata struct {
+	EventID       string
+	EventType     string
+	SchemaVersion int
+	Producer      string
+	CorrelationID string
+	CausationID   string
+	OccurredAt    time.Time
+	// ContentType 负载的内容类型，决定使用哪个编解码器
+	ContentType string
+	// SigningKeyID 消息签名使用的密钥ID，未签名时为空
+	SigningKeyID string
+	// EncryptionKeyID 负载加密使用的密钥ID，未加密时为空
+	EncryptionKeyID string
+}
+
+// Envelope 传递给处理函数的事件信封
+type Envelope struct {
+	Metadata
+	// RoutingKey 消息最初发布时的路由键，重试消息也保持不变
+	RoutingKey string
+	// RetryCount 消息已被重试的次数
+	RetryCount int
+	// Redelivered 消息是否为重新投递
+	Redelivered bool
+	// ReplyTo 请求消息的响应队列，普通事件为空
+	ReplyTo string
+	// RequestID 请求消息或响应消息的请求ID
+	RequestID string
+	// Deadline 请求方等待响应的截止时间，未设置时为零值
+	Deadline time.Time
+	// Data 业务数据
+	Data []byte
+}
+
+// Handler 事件处理函数，ctx中携带当前信封，处理函数发布的后续事件会自动继承关联ID
+type Handler func(ctx context.Context, env Envelope) error
+
+type envelopeKey struct{}
+type correlationKey struct{}
+type metadataKey struct{}
+
+// ContextWithEnvelope 返回携带当前处理信封的上下文
+func ContextWithEnvelope(ctx context.Context, env Envelope) context.Context {
+	return context.WithValue(ctx, envelopeKey{}, env)
+}
+
+// EnvelopeFromContext 获取上下文中正在处理的信封
+func EnvelopeFromContext(ctx context.Context) (Envelope, bool) {
+	env, ok := ctx.Value(envelopeKey{}).(Envelope)
+	return env, ok
+}
+
+// WithCorrelationID 为上下文设置关联ID，通常在请求入口处调用
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, correlationID)
+}
+
+// CorrelationID 返回上下文中的关联ID，优先使用正在处理的信封
+func CorrelationID(ctx context.Context) string {
+	if env, ok := EnvelopeFromContext(ctx); ok {
+		if env.CorrelationID != "" {
+			return env.CorrelationID
+		}
+		return env.EventID
+	}
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// WithMetadata 为即将发布的消息指定元数据，非零字段会覆盖发布器生成的值
+// 用于需要保持事件ID稳定的场景，例如发件箱重投
+func WithMetadata(ctx context.Context, md Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, md)
+}
+
+// newMetadata 为一次发布生成元数据
+// 在事件处理函数中发布时，关联ID沿用当前信封，因果ID指向当前事件
+func newMetadata(ctx context.Context, producer, eventType string) Metadata {
+	md := Metadata{
+		EventID:       newMessageID(),
+		EventType:     eventType,
+		SchemaVersion: DefaultSchemaVersion,
+		Producer:      producer,
+		CorrelationID: CorrelationID(ctx),
+		OccurredAt:    time.Now().UTC(),
+	}
+	if env, ok := EnvelopeFromContext(ctx); ok {
+		md.CausationID = env.EventID
+	}
+
+	if override, ok := ctx.Value(metadataKey{}).(Metadata); ok {
+		if override.EventID != "" {
+			md.EventID = override.EventID
+		}
+		if override.SchemaVersion != 0 {
+			md.SchemaVersion = override.SchemaVersion
+		}
+		if override.CorrelationID != "" {
+			md.CorrelationID = override.CorrelationID
+		}
+		if override.CausationID != "" {
+			md.CausationID = override.CausationID
+		}
+		if !override.OccurredAt.IsZero() {
+			md.OccurredAt = override.OccurredAt
+		}
+	}
+
+	// 没有上游事件时，本事件开启新的关联链
+	if md.CorrelationID == "" {
+		md.CorrelationID = md.EventID
+	}
+	return md
+}
+
+// headers 返回镜像元数据的消息头
+func (m Metadata) headers() map[string]interface{} {
+	headers := map[string]interface{}{
+		HeaderEventID:       m.EventID,
+		HeaderEventType:     m.EventType,
+		HeaderSchemaVersion: int32(m.SchemaVersion),
+		HeaderProducer:      m.Producer,
+		HeaderCorrelationID: m.CorrelationID,
+		HeaderOccurredAt:    m.OccurredAt.Format(time.RFC3339Nano),
+		HeaderContentType:   m.ContentType,
+	}
+	if m.CausationID != "" {
+		headers[HeaderCausationID] = m.CausationID
+	}
+	if m.SigningKeyID != "" {
+		headers[HeaderSigningKeyID] = m.SigningKeyID
+	}
+	if m.EncryptionKeyID != "" {
+		headers[HeaderEncryptionKeyID] = m.EncryptionKeyID
+	}
+	return headers
+}
+
+// encodeMessage 按协商的内容类型编码业务数据，封装为信封并序列化，同时记录负载内容类型
+// 安全策略要求时加密负载并签名，使用的密钥ID记录到元数据
+func encodeMessage(ctx context.Context, md *Metadata, data interface{}) ([]byte, error) {
+	// 编码数据
+	payload, contentType, err := encodePayload(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	md.ContentType = contentType
+
+	// 创建AMQP消息
+	message := contracts.AmqpMessage{
+		EventID:       md.EventID,
+		EventType:     md.EventType,
+		SchemaVersion: md.SchemaVersion,
+		OwnerID:       md.Producer,
+		CorrelationID: md.CorrelationID,
+		CausationID:   md.CausationID,
+		OccurredAt:    md.OccurredAt,
+		ContentType:   md.ContentType,
+		Data:          payload,
+	}
+	if err := currentSecurity().seal(&message); err != nil {
+		return nil, err
+	}
+	md.SigningKeyID = message.SigningKeyID
+	md.EncryptionKeyID = message.EncryptionKeyID
+
+	// 序列化消息
+	messageData, err := json.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("序列化AMQP消息失败: %w", err)
+	}
+
+	return messageData, nil
+}
+
+// decodeEnvelope 解析消息体并结合消息头还原信封
+// 旧版本消息体缺少的元数据从消息头补齐，格式错误的消息无论重试多少次都无法处理
+// 不满足安全策略的消息同样永久拒绝
+func decodeEnvelope(body []byte, routingKey string, headers map[string]interface{}, redelivered bool) (Envelope, error) {
+	var msg contracts.AmqpMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return Envelope{}, Permanent(fmt.Errorf("解析AMQP消息失败: %w", err))
+	}
+	if err := currentSecurity().open(&msg, routingKey, headerString(headers, HeaderOriginalRoutingKey)); err != nil {
+		return Envelope{}, Permanent(err)
+	}
+
+	// 重试消息经过默认交换器后路由键变为队列名，使用记录的原始路由键
+	if original := headerString(headers, HeaderOriginalRoutingKey); original != "" {
+		routingKey = original
+	}
+
+	env := Envelope{
+		Metadata: Metadata{
+			EventID:       firstNonEmpty(msg.EventID, headerString(headers, HeaderEventID)),
+			EventType:     firstNonEmpty(msg.EventType, headerString(headers, HeaderEventType), routingKey),
+			SchemaVersion: msg.SchemaVersion,
+			Producer:      firstNonEmpty(msg.OwnerID, headerString(headers, HeaderProducer)),
+			CorrelationID: firstNonEmpty(msg.CorrelationID, headerString(headers, HeaderCorrelationID)),
+			CausationID:   firstNonEmpty(msg.CausationID, headerString(headers, HeaderCausationID)),
+			OccurredAt:    msg.OccurredAt,
+			ContentType:   firstNonEmpty(msg.ContentType, headerString(headers, HeaderContentType), ContentTypeJSON),
+			// 密钥ID只取自消息体，消息头中的镜像未经签名
+			SigningKeyID:    msg.SigningKeyID,
+			EncryptionKeyID: msg.EncryptionKeyID,
+		},
+		RoutingKey:  routingKey,
+		RetryCount:  headerInt(headers, HeaderRetryCount),
+		Redelivered: redelivered,
+		ReplyTo:     headerString(headers, HeaderReplyTo),
+		RequestID:   headerString(headers, HeaderRequestID),
+		Data:        msg.Data,
+	}
+	if env.SchemaVersion == 0 {
+		env.SchemaVersion = headerInt(headers, HeaderSchemaVersion)
+	}
+	if env.SchemaVersion == 0 {
+		env.SchemaVersion = DefaultSchemaVersion
+	}
+	if env.OccurredAt.IsZero() {
+		if t, err := time.Parse(time.RFC3339Nano, headerString(headers, HeaderOccurredAt)); err == nil {
+			env.OccurredAt = t
+		}
+	}
+	if deadline := headerString(headers, HeaderDeadline); deadline != "" {
+		if t, err := time.Parse(time.RFC3339Nano, deadline); err == nil {
+			env.Deadline = t
+		}
+	}
+
+	return env, nil
+}
+
+// dispatch 解析消息并调用处理函数，批量消息逐条调用
+func dispatch(ctx context.Context, body []byte, routingKey string, headers map[string]interface{}, redelivered bool, handler Handler) error {
+	started := time.Now()
+	env, err := decodeEnvelope(body, routingKey, headers, redelivered)
+	if err != nil {
+		observeConsume(Envelope{RoutingKey: routingKey, Redelivered: redelivered}, started, err)
+		return err
+	}
+	if env.ContentType == ContentTypeBatch {
+		return dispatchBatch(ctx, env, handler)
+	}
+
+	// 调用处理函数
+	err = handler(ContextWithEnvelope(ctx, env), env)
+	observeConsume(env, started, err)
+	if err != nil {
+		return fmt.Errorf("消息处理函数执行失败: %w", err)
+	}
+
+	return nil
+}
+
+// firstNonEmpty 返回第一个非空字符串
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,814 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InMemoryBroker 进程内消息代理，模拟RabbitMQ的topic交换器语义，用于测试和本地运行
+type InMemoryBroker struct {
+	mu        sync.Mutex
+	exchanges map[string][]memBinding
+	queues    map[string]*memQueue
+	// delayed 尚未到期的延迟消息定时器
+	delayed map[string]*time.Timer
+}
+
+// memBinding 队列与交换器之间的绑定
+type memBinding struct {
+	queue   string
+	pattern string
+}
+
+// memDelivery 投递到队列中的一条消息
+type memDelivery struct {
+	tag         uint64
+	exchange    string
+	routingKey  string
+	body        []byte
+	headers     map[string]interface{}
+	timestamp   time.Time
+	redelivered bool
+	// consumer 取出该消息、尚未确认的消费者
+	consumer *memConsumer
+}
+
+// memQueue 内存队列，支持多个竞争消费者
+type memQueue struct {
+	name     string
+	mu       sync.Mutex
+	cond     *sync.Cond
+	messages []*memDelivery
+	unacked  map[uint64]*memDelivery
+	nextTag  uint64
+}
+
+var (
+	defaultBrokerOnce sync.Once
+	defaultBroker     *InMemoryBroker
+)
+
+// NewInMemoryBroker 创建新的进程内消息代理
+func NewInMemoryBroker() *InMemoryBroker {
+	return &InMemoryBroker{
+		exchanges: make(map[string][]memBinding),
+		queues:    make(map[string]*memQueue),
+		delayed:   make(map[string]*time.Timer),
+	}
+}
+
+// DefaultInMemoryBroker 返回进程级共享的消息代理，使同一进程内的发布器和订阅器可以互通
+func DefaultInMemoryBroker() *InMemoryBroker {
+	defaultBrokerOnce.Do(func() {
+		defaultBroker = NewInMemoryBroker()
+	})
+	return defaultBroker
+}
+
+// DeclareExchange 声明交换器（幂等）
+func (b *InMemoryBroker) DeclareExchange(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.exchanges[name]; !ok {
+		b.exchanges[name] = nil
+	}
+}
+
+// DeclareQueue 声明队列（幂等）
+func (b *InMemoryBroker) DeclareQueue(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.declareQueueLocked(name)
+}
+
+func (b *InMemoryBroker) declareQueueLocked(name string) *memQueue {
+	q, ok := b.queues[name]
+	if !ok {
+		q = &memQueue{
+			name:    name,
+			unacked: make(map[uint64]*memDelivery),
+		}
+		q.cond = sync.NewCond(&q.mu)
+		b.queues[name] = q
+	}
+	return q
+}
+
+// BindQueue 将队列绑定到交换器，pattern支持 * 和 # 通配符
+func (b *InMemoryBroker) BindQueue(queue, pattern, exchange string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.declareQueueLocked(queue)
+	for _, binding := range b.exchanges[exchange] {
+		if binding.queue == queue && binding.pattern == pattern {
+			return
+		}
+	}
+	b.exchanges[exchange] = append(b.exchanges[exchange], memBinding{queue: queue, pattern: pattern})
+}
+
+// Publish 按照topic路由规则将消息投递到所有匹配的队列，每个队列最多投递一次
+func (b *InMemoryBroker) Publish(exchange, routingKey string, body []byte) error {
+	return b.PublishWithHeaders(exchange, routingKey, body, nil)
+}
+
+// PublishWithHeaders 携带消息头发布消息，每个队列持有独立的消息头副本
+func (b *InMemoryBroker) PublishWithHeaders(exchange, routingKey string, body []byte, headers map[string]interface{}) error {
+	_, err := b.route(exchange, routingKey, body, headers)
+	return err
+}
+
+// PublishDelayed 在delay之后按路由规则投递消息，id用于CancelDelayed取消
+func (b *InMemoryBroker) PublishDelayed(id, exchange, routingKey string, body []byte, headers map[string]interface{}, delay time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.exchanges[exchange]; !ok {
+		return fmt.Errorf("交换器不存在: %s", exchange)
+	}
+	if _, ok := b.delayed[id]; ok {
+		return fmt.Errorf("延迟消息已存在: %s", id)
+	}
+
+	b.delayed[id] = time.AfterFunc(delay, func() {
+		b.mu.Lock()
+		_, pending := b.delayed[id]
+		delete(b.delayed, id)
+		b.mu.Unlock()
+		if !pending {
+			return
+		}
+
+		if _, err := b.route(exchange, routingKey, body, headers); err != nil {
+			log.Printf("投递延迟消息失败: %s: %v", routingKey, err)
+		}
+	})
+	return nil
+}
+
+// CancelDelayed 取消尚未到期的延迟消息，消息已投递或不存在时返回false
+func (b *InMemoryBroker) CancelDelayed(id string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	timer, ok := b.delayed[id]
+	if !ok {
+		return false
+	}
+	delete(b.delayed, id)
+	timer.Stop()
+	return true
+}
+
+// route 将消息投递到所有匹配的队列，返回投递的队列数量
+func (b *InMemoryBroker) route(exchange, routingKey string, body []byte, headers map[string]interface{}) (int, error) {
+	b.mu.Lock()
+	bindings, ok := b.exchanges[exchange]
+	if !ok {
+		b.mu.Unlock()
+		return 0, fmt.Errorf("交换器不存在: %s", exchange)
+	}
+
+	var targets []*memQueue
+	seen := make(map[string]bool)
+	for _, binding := range bindings {
+		if seen[binding.queue] || !MatchRoutingKey(binding.pattern, routingKey) {
+			continue
+		}
+		seen[binding.queue] = true
+		targets = append(targets, b.queues[binding.queue])
+	}
+	b.mu.Unlock()
+
+	now := time.Now()
+	for _, q := range targets {
+		// 每个队列持有独立的消息副本
+		payload := make([]byte, len(body))
+		copy(payload, body)
+		q.push(&memDelivery{
+			exchange:   exchange,
+			routingKey: routingKey,
+			body:       payload,
+			headers:    copyHeaders(headers),
+			timestamp:  now,
+		})
+	}
+
+	return len(targets), nil
+}
+
+// QueueLength 返回队列中等待投递的消息数量
+func (b *InMemoryBroker) QueueLength(name string) int {
+	b.mu.Lock()
+	q, ok := b.queues[name]
+	b.mu.Unlock()
+	if !ok {
+		return 0
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.messages)
+}
+
+// queue 获取已声明的队列
+func (b *InMemoryBroker) queue(name string) (*memQueue, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[name]
+	if !ok {
+		return nil, fmt.Errorf("队列不存在: %s", name)
+	}
+	return q, nil
+}
+
+// push 将消息追加到队列尾部并唤醒消费者
+func (q *memQueue) push(d *memDelivery) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.messages = append(q.messages, d)
+	q.cond.Signal()
+}
+
+// pop 阻塞直到有消息可投递或消费者停止，返回的消息进入consumer的未确认状态
+func (q *memQueue) pop(consumer *memConsumer, stopped func() bool) (*memDelivery, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.messages) == 0 && !stopped() {
+		q.cond.Wait()
+	}
+	if stopped() {
+		return nil, false
+	}
+
+	d := q.messages[0]
+	q.messages = q.messages[1:]
+	q.nextTag++
+	d.tag = q.nextTag
+	d.consumer = consumer
+	q.unacked[d.tag] = d
+	return d, true
+}
+
+// ack 确认消息，将其从未确认集合中移除
+// 消息已随消费者关闭重新入队时返回false，与RabbitMQ在通道关闭后无法确认一致
+func (q *memQueue) ack(tag uint64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.unacked[tag]; !ok {
+		return false
+	}
+	delete(q.unacked, tag)
+	return true
+}
+
+// requeue 将消费者未确认的消息按原顺序放回队列头部并标记为重新投递
+func (q *memQueue) requeue(consumer *memConsumer) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var returned []*memDelivery
+	for tag, d := range q.unacked {
+		if d.consumer == consumer {
+			delete(q.unacked, tag)
+			returned = append(returned, d)
+		}
+	}
+	if len(returned) == 0 {
+		return 0
+	}
+
+	sort.Slice(returned, func(i, j int) bool { return returned[i].tag < returned[j].tag })
+	requeued := make([]*memDelivery, 0, len(returned)+len(q.messages))
+	for _, d := range returned {
+		// 重新入队的是新的投递，旧的投递标签不能再确认
+		requeued = append(requeued, &memDelivery{
+			exchange:    d.exchange,
+			routingKey:  d.routingKey,
+			body:        d.body,
+			headers:     d.headers,
+			timestamp:   d.timestamp,
+			redelivered: true,
+		})
+	}
+	q.messages = append(requeued, q.messages...)
+	q.cond.Broadcast()
+	return len(returned)
+}
+
+// snapshot 返回队列头部最多limit条消息，不移出队列
+func (q *memQueue) snapshot(limit int) []*memDelivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if limit > len(q.messages) {
+		limit = len(q.messages)
+	}
+	out := make([]*memDelivery, limit)
+	copy(out, q.messages[:limit])
+	return out
+}
+
+// take 非阻塞地移出队列头部最多limit条消息
+func (q *memQueue) take(limit int) []*memDelivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if limit > len(q.messages) {
+		limit = len(q.messages)
+	}
+	out := make([]*memDelivery, limit)
+	copy(out, q.messages[:limit])
+	q.messages = q.messages[limit:]
+	return out
+}
+
+// wakeAll 唤醒所有等待中的消费者，用于停止消费
+func (q *memQueue) wakeAll() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.cond.Broadcast()
+}
+
+// MatchRoutingKey 判断路由键是否匹配topic绑定模式
+// * 匹配恰好一个单词，# 匹配零个或多个单词
+func MatchRoutingKey(pattern, routingKey string) bool {
+	return matchWords(strings.Split(pattern, "."), strings.Split(routingKey, "."))
+}
+
+func matchWords(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+
+	switch pattern[0] {
+	case "#":
+		// # 可以吞掉任意数量的单词
+		for i := 0; i <= len(words); i++ {
+			if matchWords(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(words) > 0 && matchWords(pattern[1:], words[1:])
+	default:
+		return len(words) > 0 && pattern[0] == words[0] && matchWords(pattern[1:], words[1:])
+	}
+}
+
+// InMemoryPublisher 基于进程内消息代理的事件发布器实现
+type InMemoryPublisher struct {
+	broker   *InMemoryBroker
+	exchange string
+	producer string
+}
+
+// NewInMemoryPublisher 创建新的内存事件发布器，producer为写入信封的服务名称
+func NewInMemoryPublisher(broker *InMemoryBroker, exchange, producer string) *InMemoryPublisher {
+	broker.DeclareExchange(exchange)
+
+	return &InMemoryPublisher{
+		broker:   broker,
+		exchange: exchange,
+		producer: producer,
+	}
+}
+
+// PublishEvent 发布事件
+func (p *InMemoryPublisher) PublishEvent(ctx context.Context, eventType string, data interface{}) error {
+	if err := p.publish(ctx, eventType, data); err != nil {
+		return fmt.Errorf("发布事件失败: %w", err)
+	}
+
+	log.Printf("成功发布事件(内存): %s", eventType)
+	return nil
+}
+
+// PublishCommand 发布命令
+func (p *InMemoryPublisher) PublishCommand(ctx context.Context, commandType string, data interface{}) error {
+	if err := p.publish(ctx, commandType, data); err != nil {
+		return fmt.Errorf("发布命令失败: %w", err)
+	}
+
+	log.Printf("成功发布命令(内存): %s", commandType)
+	return nil
+}
+
+func (p *InMemoryPublisher) publish(ctx context.Context, routingKey string, data interface{}) (err error) {
+	defer observePublish(routingKey, time.Now(), &err)
+	md := newMetadata(ctx, p.producer, routingKey)
+	body, err := encodeMessage(ctx, &md, data)
+	if err != nil {
+		return err
+	}
+
+	return p.broker.PublishWithHeaders(p.exchange, routingKey, body, md.headers())
+}
+
+// PublishConfirmed 发布消息，内存代理同步投递，返回即表示已确认
+func (p *InMemoryPublisher) PublishConfirmed(ctx context.Context, routingKey string, data interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := p.publish(ctx, routingKey, data); err != nil {
+		return fmt.Errorf("发布消息失败: %w", err)
+	}
+	return nil
+}
+
+// PublishAsync 发布消息并返回已完成的确认结果
+func (p *InMemoryPublisher) PublishAsync(ctx context.Context, routingKey string, data interface{}) (*Confirmation, error) {
+	if err := p.publish(ctx, routingKey, data); err != nil {
+		return nil, fmt.Errorf("发布消息失败: %w", err)
+	}
+	return confirmedImmediately(), nil
+}
+
+// PublishToQueue 将消息直接放入指定队列，不经过交换器路由
+func (p *InMemoryPublisher) PublishToQueue(ctx context.Context, queueName, routingKey string, data interface{}) (err error) {
+	defer observePublish(routingKey, time.Now(), &err)
+	md := newMetadata(ctx, p.producer, routingKey)
+	body, err := encodeMessage(ctx, &md, data)
+	if err != nil {
+		return err
+	}
+
+	q, err := p.broker.queue(queueName)
+	if err != nil {
+		return err
+	}
+	q.push(&memDelivery{
+		exchange:   p.exchange,
+		routingKey: routingKey,
+		body:       body,
+		headers:    md.headers(),
+		timestamp:  time.Now(),
+	})
+	return nil
+}
+
+// PublishDelayed 由代理的定时器在delay之后路由消息
+func (p *InMemoryPublisher) PublishDelayed(ctx context.Context, routingKey string, data interface{}, delay time.Duration) (_ *DelayToken, err error) {
+	defer observePublish(routingKey, time.Now(), &err)
+	md := newMetadata(ctx, p.producer, routingKey)
+	body, err := encodeMessage(ctx, &md, data)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &DelayToken{ID: md.EventID, RoutingKey: routingKey, DeliverAt: md.OccurredAt.Add(delay)}
+	if delay <= 0 {
+		if err := p.broker.PublishWithHeaders(p.exchange, routingKey, body, md.headers()); err != nil {
+			return nil, fmt.Errorf("发布消息失败: %w", err)
+		}
+		return token, nil
+	}
+
+	headers := md.headers()
+	headers[HeaderDelayToken] = md.EventID
+	if err := p.broker.PublishDelayed(md.EventID, p.exchange, routingKey, body, headers, delay); err != nil {
+		return nil, fmt.Errorf("发布延迟消息失败: %w", err)
+	}
+	token.Ref = md.EventID
+	return token, nil
+}
+
+// CancelDelayed 停止延迟消息的定时器
+func (p *InMemoryPublisher) CancelDelayed(ctx context.Context, token *DelayToken) error {
+	if token.Ref == "" || !p.broker.CancelDelayed(token.Ref) {
+		return ErrDelayedNotFound
+	}
+	return nil
+}
+
+// Close 关闭发布器，内存代理本身不会被关闭
+func (p *InMemoryPublisher) Close() error {
+	return nil
+}
+
+// ConnectionState 进程内代理始终可用
+func (p *InMemoryPublisher) ConnectionState() ConnectionState {
+	return StateConnected
+}
+
+// NotifyConnectionState 进程内代理不会断开，不会产生状态变化事件
+func (p *InMemoryPublisher) NotifyConnectionState(ch chan ConnectionEvent) chan ConnectionEvent {
+	return ch
+}
+
+// InMemorySubscriber 基于进程内消息代理的事件订阅器实现
+type InMemorySubscriber struct {
+	broker   *InMemoryBroker
+	exchange string
+	producer string
+
+	mu        sync.Mutex
+	consumers []*memConsumer
+	closed    bool
+}
+
+// memConsumer 队列上的单个消费者
+type memConsumer struct {
+	broker  *InMemoryBroker
+	queue   *memQueue
+	handler Handler
+	opts    SubscribeOptions
+
+	mu      sync.Mutex
+	stopped bool
+	done    chan struct{}
+}
+
+// NewInMemorySubscriber 创建新的内存事件订阅器，producer为写入响应信封的服务名称
+func NewInMemorySubscriber(broker *InMemoryBroker, exchange, producer string) *InMemorySubscriber {
+	broker.DeclareExchange(exchange)
+
+	return &InMemorySubscriber{
+		broker:   broker,
+		exchange: exchange,
+		producer: producer,
+	}
+}
+
+// Subscribe 使用默认选项订阅事件
+func (s *InMemorySubscriber) Subscribe(queueName, routingKey string, handler Handler) error {
+	return s.SubscribeWithOptions(queueName, routingKey, handler, DefaultSubscribeOptions())
+}
+
+// SubscribeWithOptions 按指定选项订阅事件，重试与死信语义与RabbitMQ实现一致
+func (s *InMemorySubscriber) SubscribeWithOptions(queueName, routingKey string, handler Handler, opts SubscribeOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("订阅器已关闭")
+	}
+
+	exchange := s.exchange
+	if opts.Exchange != "" {
+		exchange = opts.Exchange
+		s.broker.DeclareExchange(exchange)
+	}
+	s.broker.BindQueue(queueName, routingKey, exchange)
+	q, err := s.broker.queue(queueName)
+	if err != nil {
+		return fmt.Errorf("声明队列失败: %w", err)
+	}
+
+	if opts.DeadLetter {
+		s.broker.DeclareQueue(deadLetterQueueName(queueName))
+	}
+
+	consumer := &memConsumer{
+		broker:  s.broker,
+		queue:   q,
+		handler: opts.wrapHandler(queueName, handler),
+		opts:    opts,
+		done:    make(chan struct{}),
+	}
+	s.consumers = append(s.consumers, consumer)
+
+	log.Printf("成功订阅队列(内存): %s, 路由键: %s", queueName, routingKey)
+
+	go consumer.run()
+
+	return nil
+}
+
+// Respond 订阅请求队列，Responder的结果直接放入请求方的响应队列
+func (s *InMemorySubscriber) Respond(queueName, routingKey string, responder Responder, opts SubscribeOptions) error {
+	return s.SubscribeWithOptions(queueName, routingKey, respondHandler(responder, s.producer, s.sendReply), opts)
+}
+
+// InspectDeadLetters 查看死信队列中的消息
+func (s *InMemorySubscriber) InspectDeadLetters(queueName string, limit int) ([]DeadLetter, error) {
+	dlq, err := s.broker.queue(deadLetterQueueName(queueName))
+	if err != nil {
+		return nil, err
+	}
+
+	var letters []DeadLetter
+	for _, d := range dlq.snapshot(limit) {
+		letters = append(letters, newDeadLetter(queueName, d.headers, d.body))
+	}
+	return letters, nil
+}
+
+// RedriveDeadLetters 将死信重新投递到原队列，重试次数清零
+func (s *InMemorySubscriber) RedriveDeadLetters(queueName string, limit int) (int, error) {
+	dlq, err := s.broker.queue(deadLetterQueueName(queueName))
+	if err != nil {
+		return 0, err
+	}
+	q, err := s.broker.queue(queueName)
+	if err != nil {
+		return 0, err
+	}
+
+	letters := dlq.take(limit)
+	for _, d := range letters {
+		headers := copyHeaders(d.headers)
+		delete(headers, HeaderDeathReason)
+		delete(headers, HeaderFailedAt)
+		headers[HeaderRetryCount] = 0
+		d.headers = headers
+		q.push(d)
+	}
+
+	log.Printf("已重新投递 %d 条死信(内存): 队列=%s", len(letters), queueName)
+	return len(letters), nil
+}
+
+// ConnectionState 返回订阅器状态，进程内代理在订阅器关闭前始终可用
+func (s *InMemorySubscriber) ConnectionState() ConnectionState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return StateClosed
+	}
+	return StateConnected
+}
+
+// NotifyConnectionState 进程内代理不会断开，不会产生状态变化事件
+func (s *InMemorySubscriber) NotifyConnectionState(ch chan ConnectionEvent) chan ConnectionEvent {
+	return ch
+}
+
+// Close 关闭订阅器，不等待正在处理的消息；未确认的消息重新入队并标记为重新投递，
+// 与RabbitMQ关闭通道时的行为一致，处理完成后的确认结果被忽略
+func (s *InMemorySubscriber) Close() error {
+	s.mu.Lock()
+	consumers := s.consumers
+	s.consumers = nil
+	s.closed = true
+	s.mu.Unlock()
+
+	for _, c := range consumers {
+		c.close()
+	}
+	return nil
+}
+
+// Shutdown 优雅关闭订阅器：停止取出新消息，等待正在处理的消息完成或ctx到期
+func (s *InMemorySubscriber) Shutdown(ctx context.Context) error {
+	started := time.Now()
+
+	s.mu.Lock()
+	consumers := s.consumers
+	s.consumers = nil
+	s.closed = true
+	s.mu.Unlock()
+
+	dones := make([]<-chan struct{}, 0, len(consumers))
+	for _, c := range consumers {
+		c.cancel()
+		dones = append(dones, c.done)
+	}
+
+	err := waitDone(ctx, dones)
+	if err != nil {
+		// 超时仍未处理完的消息重新入队，交给其他消费者
+		for _, c := range consumers {
+			c.queue.requeue(c)
+		}
+	}
+	logShutdown("事件订阅器(内存)", started, err)
+	return err
+}
+
+// run 消费循环
+func (c *memConsumer) run() {
+	defer close(c.done)
+
+	// inflight 模拟预取数量，限制未确认消息的数量
+	inflight := make(chan struct{}, c.opts.prefetch())
+	pool := newWorkerPool(c.opts.workers())
+	defer pool.close()
+
+	for {
+		inflight <- struct{}{}
+		d, ok := c.queue.pop(c, c.isStopped)
+		if !ok {
+			log.Printf("消息消费通道已关闭(内存): %s", c.queue.name)
+			return
+		}
+
+		pool.submitMessage(c.opts, d.body, d.routingKey, d.headers, d.redelivered, c.handler, func(ctx context.Context) {
+			defer func() { <-inflight }()
+			c.process(ctx, d)
+		})
+	}
+}
+
+// process 处理一条消息并确认，失败时按重试策略重投或投递到死信队列
+func (c *memConsumer) process(ctx context.Context, d *memDelivery) {
+	if err := dispatch(ctx, d.body, d.routingKey, d.headers, d.redelivered, c.handler); err != nil {
+		log.Printf("处理消息失败: %v", err)
+		c.handleFailure(d, err)
+		return
+	}
+
+	if !c.queue.ack(d.tag) {
+		log.Printf("消息处理完成时已重新入队，忽略确认: %s", d.routingKey)
+		return
+	}
+	log.Printf("成功处理消息: %s", d.routingKey)
+}
+
+// handleFailure 处理失败的消息：延迟后重新入队，重试耗尽后投递到死信队列
+func (c *memConsumer) handleFailure(d *memDelivery, handlerErr error) {
+	// 原消息已由重试或死信副本接管；已重新入队的消息由新的投递处理
+	if !c.queue.ack(d.tag) {
+		log.Printf("消息处理失败时已重新入队，不再重试: 队列=%s", c.queue.name)
+		return
+	}
+
+	retryCount := headerInt(d.headers, HeaderRetryCount)
+	headers := copyHeaders(d.headers)
+	if headerString(headers, HeaderOriginalRoutingKey) == "" {
+		headers[HeaderOriginalExchange] = d.exchange
+		headers[HeaderOriginalRoutingKey] = d.routingKey
+	}
+
+	if delay, ok := nextRetry(c.opts, retryCount, handlerErr); ok {
+		headers[HeaderRetryCount] = retryCount + 1
+		retried := &memDelivery{
+			exchange:    d.exchange,
+			routingKey:  d.routingKey,
+			body:        d.body,
+			headers:     headers,
+			timestamp:   d.timestamp,
+			redelivered: true,
+		}
+		time.AfterFunc(delay, func() { c.queue.push(retried) })
+		observeNack(c.queue.name, nackRetry)
+
+		log.Printf("消息将在 %v 后重试(%d/%d): 队列=%s", delay, retryCount+1, c.opts.Retry.MaxRetries, c.queue.name)
+		return
+	}
+
+	if !c.opts.DeadLetter {
+		log.Printf("消息重试耗尽，已丢弃: 队列=%s, 原因=%v", c.queue.name, handlerErr)
+		observeNack(c.queue.name, nackDiscarded)
+		return
+	}
+
+	dlq, err := c.broker.queue(deadLetterQueueName(c.queue.name))
+	if err != nil {
+		log.Printf("死信队列不存在，消息已丢弃: %v", err)
+		observeNack(c.queue.name, nackDiscarded)
+		return
+	}
+
+	headers[HeaderRetryCount] = retryCount
+	headers[HeaderDeathReason] = handlerErr.Error()
+	headers[HeaderFailedAt] = time.Now().UTC().Format(time.RFC3339Nano)
+	dlq.push(&memDelivery{
+		exchange:   d.exchange,
+		routingKey: d.routingKey,
+		body:       d.body,
+		headers:    headers,
+		timestamp:  d.timestamp,
+	})
+
+	observeNack(c.queue.name, nackDeadLetter)
+	log.Printf("消息已投递到死信队列: 队列=%s, 原因=%v", dlq.name, handlerErr)
+}
+
+func (c *memConsumer) isStopped() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stopped
+}
+
+// cancel 停止取出新消息，不等待正在处理的消息
+func (c *memConsumer) cancel() {
+	c.mu.Lock()
+	c.stopped = true
+	c.mu.Unlock()
+
+	c.queue.wakeAll()
+}
+
+// close 停止消费并将未确认的消息重新入队，不等待正在处理的消息
+func (c *memConsumer) close() {
+	c.cancel()
+	if n := c.queue.requeue(c); n > 0 {
+		log.Printf("消费者关闭，%d 条未确认消息已重新入队(内存): %s", n, c.queue.name)
+	}
+}
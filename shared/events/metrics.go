@@ -0,0 +1,82 @@
+package events
+
+import (
+	"time"
+
+	"ride-sharing/shared/metrics"
+)
+
+// 事件发布与消费的指标，注册在metrics.Default中，由各服务的 /metrics 端点导出
+// 所有后端在发布和处理消息时统一记录，标签使用路由键和队列名称，不包含事件ID等高基数取值
+var (
+	publishedTotal = metrics.NewCounterVec("events_published_total",
+		"Messages handed to the broker, by routing key.", "routing_key")
+	publishErrorsTotal = metrics.NewCounterVec("events_publish_errors_total",
+		"Messages that failed to encode or publish, by routing key.", "routing_key")
+	publishDuration = metrics.NewHistogramVec("events_publish_duration_seconds",
+		"Time to encode and hand a message to the broker.", nil, "routing_key")
+
+	consumedTotal = metrics.NewCounterVec("events_consumed_total",
+		"Messages dispatched to handlers, by routing key and result (success or error).", "routing_key", "result")
+	handlerDuration = metrics.NewHistogramVec("events_handler_duration_seconds",
+		"Time spent decoding a message and running its handler.", nil, "routing_key")
+	redeliveredTotal = metrics.NewCounterVec("events_redelivered_total",
+		"Messages received again after a crash, requeue or retry, by routing key.", "routing_key")
+	nackedTotal = metrics.NewCounterVec("events_nacked_total",
+		"Failed messages by queue and outcome (retry, dead_letter, discarded, requeued).", "queue", "outcome")
+
+	connectionUp = metrics.NewGaugeVec("events_connection_up",
+		"1 if the broker connection of the component is usable, 0 otherwise.", "component")
+	reconnectAttemptsTotal = metrics.NewCounterVec("events_reconnect_attempts_total",
+		"Failed broker reconnection attempts of the component.", "component")
+)
+
+// 处理失败消息的去向，作为events_nacked_total的outcome标签
+const (
+	nackRetry      = "retry"
+	nackDeadLetter = "dead_letter"
+	nackDiscarded  = "discarded"
+	// nackRequeued 重试或死信副本写入失败，原消息回到队列
+	nackRequeued = "requeued"
+)
+
+// observePublish 记录一次发布，在发布函数中以 defer observePublish(routingKey, time.Now(), &err) 调用
+func observePublish(routingKey string, started time.Time, err *error) {
+	publishDuration.WithLabelValues(routingKey).Observe(time.Since(started).Seconds())
+	if *err != nil {
+		publishErrorsTotal.WithLabelValues(routingKey).Inc()
+		return
+	}
+	publishedTotal.WithLabelValues(routingKey).Inc()
+}
+
+// observeConsume 记录一次消息处理
+func observeConsume(env Envelope, started time.Time, err error) {
+	handlerDuration.WithLabelValues(env.RoutingKey).Observe(time.Since(started).Seconds())
+	if env.Redelivered || env.RetryCount > 0 {
+		redeliveredTotal.WithLabelValues(env.RoutingKey).Inc()
+	}
+
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	consumedTotal.WithLabelValues(env.RoutingKey, result).Inc()
+}
+
+// observeNack 记录处理失败的消息的去向
+func observeNack(queueName, outcome string) {
+	nackedTotal.WithLabelValues(queueName, outcome).Inc()
+}
+
+// observeConnection 记录组件的连接状态变化
+func observeConnection(component string, event ConnectionEvent) {
+	up := 0.0
+	if event.State == StateConnected {
+		up = 1
+	}
+	connectionUp.WithLabelValues(component).Set(up)
+	if event.Err != nil && event.State == StateReconnecting {
+		reconnectAttemptsTotal.WithLabelValues(component).Inc()
+	}
+}
@@ -0,0 +1,110 @@
+package events
+
+import (
+	"errors"
+	"time"
+
+	"ride-sharing/shared/retry"
+)
+
+// SubscribeOptions 单个订阅的消费选项
+type SubscribeOptions struct {
+	// Retry 处理失败后的重试策略，MaxRetries为0表示不重试
+	Retry retry.Config
+	// DeadLetter 重试耗尽后是否将消息投递到死信队列，为false时直接丢弃
+	DeadLetter bool
+	// Dedup 幂等消费使用的去重存储，为nil时不去重
+	Dedup DedupStore
+	// Prefetch 预取数量，即未确认消息的上限，为0时与Workers相同
+	Prefetch int
+	// Workers 并发处理消息的工作协程数，为0时逐条处理
+	Workers int
+	// OrderingKey 返回消息的排序键，相同排序键的消息按到达顺序串行处理，不同排序键之间并行
+	// 为nil时消息在工作协程间轮流分配，不保证顺序
+	OrderingKey func(env Envelope) string
+	// DedicatedChannel 是否为该订阅打开独立的消费通道，避免与其他订阅共享通道的流量控制
+	// 内存后端没有通道的概念，忽略该选项
+	DedicatedChannel bool
+	// Exchange 队列绑定的交换器，为空时使用订阅器的交换器；通常由拓扑中的队列声明设置
+	Exchange string
+}
+
+// DefaultSubscribeOptions 返回默认订阅选项：最多重试3次并启用死信队列
+func DefaultSubscribeOptions() SubscribeOptions {
+	return SubscribeOptions{
+		Retry: retry.Config{
+			MaxRetries:  3,
+			InitialWait: 1 * time.Second,
+			MaxWait:     30 * time.Second,
+		},
+		DeadLetter: true,
+	}
+}
+
+// wrapHandler 按选项为处理函数添加中间件
+func (o SubscribeOptions) wrapHandler(queueName string, handler Handler) Handler {
+	if o.Dedup != nil {
+		handler = Idempotent(o.Dedup, queueName, handler)
+	}
+	return handler
+}
+
+// workers 返回工作协程数
+func (o SubscribeOptions) workers() int {
+	if o.Workers < 1 {
+		return 1
+	}
+	return o.Workers
+}
+
+// prefetch 返回预取数量，至少保证每个工作协程都有消息可处理
+func (o SubscribeOptions) prefetch() int {
+	if o.Prefetch < 1 {
+		return o.workers()
+	}
+	return o.Prefetch
+}
+
+// retryDelays 返回每次重试对应的延迟，用于预先声明重试队列
+func (o SubscribeOptions) retryDelays() []time.Duration {
+	var delays []time.Duration
+	seen := make(map[time.Duration]bool)
+	for attempt := 1; attempt <= o.Retry.MaxRetries; attempt++ {
+		delay := retry.Backoff(o.Retry, attempt)
+		if !seen[delay] {
+			seen[delay] = true
+			delays = append(delays, delay)
+		}
+	}
+	return delays
+}
+
+// permanentError 标记不应重试的错误
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 将错误标记为永久性错误，订阅器会跳过重试直接投递到死信队列
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent 判断错误是否为永久性错误
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// nextRetry 根据已重试次数和错误类型决定是否继续重试，以及重试前的等待时间
+func nextRetry(opts SubscribeOptions, retryCount int, err error) (time.Duration, bool) {
+	if IsPermanent(err) || retryCount >= opts.Retry.MaxRetries {
+		return 0, false
+	}
+	return retry.Backoff(opts.Retry, retryCount+1), true
+}
@@ -0,0 +1,286 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// OutboxMessage 发件箱中的待发布消息，与业务状态变更在同一事务中写入
+// ID即发布时的事件ID，重复投递时保持不变，消费者可据此去重
+type OutboxMessage struct {
+	ID            string    `json:"id"`
+	RoutingKey    string    `json:"routingKey"`
+	Payload       []byte    `json:"payload"`
+	ContentType   string    `json:"contentType"`
+	CorrelationID string    `json:"correlationId,omitempty"`
+	CausationID   string    `json:"causationId,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"lastError,omitempty"`
+}
+
+// NewOutboxMessage 按协商的内容类型编码业务数据并创建发件箱消息，关联ID和因果ID取自ctx
+func NewOutboxMessage(ctx context.Context, routingKey string, data interface{}) (*OutboxMessage, error) {
+	payload, contentType, err := encodePayload(ctx, data)
+	if err != nil {
+		return nil, fmt.Errorf("序列化发件箱消息失败: %w", err)
+	}
+
+	md := newMetadata(ctx, "", routingKey)
+	return &OutboxMessage{
+		ID:            md.EventID,
+		RoutingKey:    routingKey,
+		Payload:       payload,
+		ContentType:   contentType,
+		CorrelationID: md.CorrelationID,
+		CausationID:   md.CausationID,
+		CreatedAt:     md.OccurredAt,
+	}, nil
+}
+
+// metadata 返回发布时使用的信封元数据
+func (m *OutboxMessage) metadata() Metadata {
+	return Metadata{
+		EventID:       m.ID,
+		CorrelationID: m.CorrelationID,
+		CausationID:   m.CausationID,
+		OccurredAt:    m.CreatedAt,
+	}
+}
+
+// OutboxStore 发件箱存储接口，由业务存储库实现以保证消息与状态变更原子写入
+type OutboxStore interface {
+	// PendingOutbox 按写入顺序返回最多limit条待发布消息
+	PendingOutbox(ctx context.Context, limit int) ([]*OutboxMessage, error)
+	// MarkOutboxPublished 标记消息已被代理确认
+	MarkOutboxPublished(ctx context.Context, id string) error
+	// MarkOutboxFailed 记录发布失败，消息保留在发件箱中等待下次投递
+	MarkOutboxFailed(ctx context.Context, id string, cause error) error
+	// ParkOutbox 搁置多次投递失败的消息，搁置的消息不再由PendingOutbox返回
+	ParkOutbox(ctx context.Context, id string, cause error) error
+}
+
+// InMemoryOutbox 内存发件箱，供内存存储库嵌入使用
+// 存储库应在持有自身写锁时调用Append，使消息与状态变更同时可见
+type InMemoryOutbox struct {
+	mu       sync.Mutex
+	messages []*OutboxMessage
+	parked   []*OutboxMessage
+}
+
+// NewInMemoryOutbox 创建内存发件箱
+func NewInMemoryOutbox() *InMemoryOutbox {
+	return &InMemoryOutbox{}
+}
+
+// Append 写入待发布消息
+func (o *InMemoryOutbox) Append(messages ...*OutboxMessage) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, msg := range messages {
+		if msg != nil {
+			o.messages = append(o.messages, msg)
+		}
+	}
+}
+
+// PendingOutbox 按写入顺序返回待发布消息
+func (o *InMemoryOutbox) PendingOutbox(ctx context.Context, limit int) ([]*OutboxMessage, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if limit > len(o.messages) {
+		limit = len(o.messages)
+	}
+	pending := make([]*OutboxMessage, limit)
+	copy(pending, o.messages[:limit])
+	return pending, nil
+}
+
+// MarkOutboxPublished 移除已发布的消息
+func (o *InMemoryOutbox) MarkOutboxPublished(ctx context.Context, id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i, msg := range o.messages {
+		if msg.ID == id {
+			o.messages = append(o.messages[:i], o.messages[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("发件箱消息不存在: %s", id)
+}
+
+// MarkOutboxFailed 记录发布失败次数和原因
+func (o *InMemoryOutbox) MarkOutboxFailed(ctx context.Context, id string, cause error) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, msg := range o.messages {
+		if msg.ID == id {
+			msg.Attempts++
+			msg.LastError = cause.Error()
+			return nil
+		}
+	}
+	return fmt.Errorf("发件箱消息不存在: %s", id)
+}
+
+// ParkOutbox 将消息移出待发布队列并保留以供排查
+func (o *InMemoryOutbox) ParkOutbox(ctx context.Context, id string, cause error) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i, msg := range o.messages {
+		if msg.ID == id {
+			msg.LastError = cause.Error()
+			o.messages = append(o.messages[:i], o.messages[i+1:]...)
+			o.parked = append(o.parked, msg)
+			return nil
+		}
+	}
+	return fmt.Errorf("发件箱消息不存在: %s", id)
+}
+
+// ParkedOutbox 返回已搁置的消息
+func (o *InMemoryOutbox) ParkedOutbox(ctx context.Context) ([]*OutboxMessage, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	parked := make([]*OutboxMessage, len(o.parked))
+	copy(parked, o.parked)
+	return parked, nil
+}
+
+// OutboxRelayConfig 发件箱中继配置
+// MaxAttempts为单条消息的最大投递次数，达到后消息被搁置，不再阻塞后续消息；0表示不限次数
+type OutboxRelayConfig struct {
+	PollInterval   time.Duration
+	BatchSize      int
+	PublishTimeout time.Duration
+	MaxAttempts    int
+}
+
+// DefaultOutboxRelayConfig 返回默认的发件箱中继配置
+func DefaultOutboxRelayConfig() OutboxRelayConfig {
+	return OutboxRelayConfig{
+		PollInterval:   500 * time.Millisecond,
+		BatchSize:      100,
+		PublishTimeout: 5 * time.Second,
+		MaxAttempts:    10,
+	}
+}
+
+// OutboxRelay 将发件箱中的消息投递到代理
+// 只有收到代理确认后才会标记为已发布，保证至少一次投递；消费者需要自行去重
+type OutboxRelay struct {
+	store     OutboxStore
+	publisher Publisher
+	cfg       OutboxRelayConfig
+}
+
+// NewOutboxRelay 创建发件箱中继
+func NewOutboxRelay(store OutboxStore, publisher Publisher, cfg OutboxRelayConfig) *OutboxRelay {
+	return &OutboxRelay{
+		store:     store,
+		publisher: publisher,
+		cfg:       cfg,
+	}
+}
+
+// Run 周期性地投递发件箱中的消息，直到ctx被取消
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	log.Printf("发件箱中继已启动，轮询间隔: %v", r.cfg.PollInterval)
+
+	for {
+		if _, err := r.Flush(ctx); err != nil {
+			log.Printf("投递发件箱消息失败: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("发件箱中继已停止")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush 按顺序投递一批待发布消息，返回成功投递的数量和遇到的第一个错误
+// 某条消息投递失败时继续投递批次中的其他消息，但同一路由键的后续消息留到下一轮，以保持同一路由键的消息顺序；
+// 失败次数达到MaxAttempts的消息会被搁置
+func (r *OutboxRelay) Flush(ctx context.Context) (int, error) {
+	pending, err := r.store.PendingOutbox(ctx, r.cfg.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("读取发件箱失败: %w", err)
+	}
+
+	published := 0
+	var firstErr error
+	blocked := make(map[string]bool)
+	for _, msg := range pending {
+		if ctx.Err() != nil {
+			break
+		}
+		if blocked[msg.RoutingKey] {
+			continue
+		}
+
+		publishCtx, cancel := context.WithTimeout(WithMetadata(ctx, msg.metadata()), r.cfg.PublishTimeout)
+		err := r.publisher.PublishConfirmed(publishCtx, msg.RoutingKey, RawPayload{ContentType: msg.ContentType, Data: msg.Payload})
+		cancel()
+
+		if err != nil {
+			err = fmt.Errorf("投递发件箱消息 %s(%s) 失败: %w", msg.ID, msg.RoutingKey, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			r.markFailed(ctx, msg, err)
+			blocked[msg.RoutingKey] = true
+			continue
+		}
+
+		if err := r.store.MarkOutboxPublished(ctx, msg.ID); err != nil {
+			return published, fmt.Errorf("标记发件箱消息已发布失败: %w", err)
+		}
+		published++
+	}
+
+	return published, firstErr
+}
+
+// markFailed 记录投递失败，失败次数达到上限时搁置消息，使同一路由键的后续消息可以继续投递
+func (r *OutboxRelay) markFailed(ctx context.Context, msg *OutboxMessage, cause error) {
+	attempts := msg.Attempts + 1
+	if err := r.store.MarkOutboxFailed(ctx, msg.ID, cause); err != nil {
+		log.Printf("记录发件箱失败状态失败: %v", err)
+		return
+	}
+	if r.cfg.MaxAttempts <= 0 || attempts < r.cfg.MaxAttempts {
+		return
+	}
+
+	if err := r.store.ParkOutbox(ctx, msg.ID, cause); err != nil {
+		log.Printf("搁置发件箱消息失败: %v", err)
+		return
+	}
+	log.Printf("发件箱消息 %s(%s) 已投递失败%d次，已搁置: %v", msg.ID, msg.RoutingKey, attempts, cause)
+}
+
+// newMessageID 生成随机的消息ID
+func newMessageID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
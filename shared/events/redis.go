@@ -0,0 +1,1164 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ride-sharing/shared/resp"
+)
+
+// Redis Streams后端
+//
+// 交换器和队列语义与RabbitMQ实现保持一致：
+//   - 每个队列对应一个stream，订阅器在其上创建消费组，同一队列的多个订阅者竞争消费
+//   - 队列与交换器的绑定记录在集合中，发布时按topic规则匹配绑定，向每个匹配的队列stream追加一条消息
+//   - 处理成功后XACK；消费者崩溃时未确认的消息超过可见性超时后被其他消费者XAUTOCLAIM认领并重新投递
+//   - 重试消息按到期时间写入有序集合，到期后回到原队列；重试耗尽的消息写入死信队列stream
+
+// RedisOptions Redis后端选项
+type RedisOptions struct {
+	// Prefix 所有键的前缀，不同环境共用一个Redis时用于隔离
+	Prefix string
+	// VisibilityTimeout 消息被读取后超过该时间仍未确认时，视为消费者已崩溃并重新投递
+	VisibilityTimeout time.Duration
+	// BlockTimeout 阻塞读取的等待时间，也决定关闭消费者时的最长等待
+	BlockTimeout time.Duration
+	// PollInterval 检查到期重试消息的间隔
+	PollInterval time.Duration
+}
+
+// DefaultRedisOptions 返回默认Redis后端选项
+func DefaultRedisOptions() RedisOptions {
+	return RedisOptions{
+		Prefix:            "events",
+		VisibilityTimeout: 30 * time.Second,
+		BlockTimeout:      1 * time.Second,
+		PollInterval:      100 * time.Millisecond,
+	}
+}
+
+// redisGroup 每个队列stream上的消费组名称
+const redisGroup = "consumers"
+
+// stream条目中的字段
+const (
+	redisFieldExchange    = "exchange"
+	redisFieldRoutingKey  = "routing_key"
+	redisFieldHeaders     = "headers"
+	redisFieldBody        = "body"
+	redisFieldRedelivered = "redelivered"
+)
+
+// redisMessage stream中的一条消息
+type redisMessage struct {
+	id          string
+	exchange    string
+	routingKey  string
+	headers     map[string]interface{}
+	body        []byte
+	redelivered bool
+}
+
+// fields 将消息编码为stream条目字段
+func (m redisMessage) fields() ([]string, error) {
+	headers, err := json.Marshal(m.headers)
+	if err != nil {
+		return nil, fmt.Errorf("序列化消息头失败: %w", err)
+	}
+
+	fields := []string{
+		redisFieldExchange, m.exchange,
+		redisFieldRoutingKey, m.routingKey,
+		redisFieldHeaders, string(headers),
+		redisFieldBody, string(m.body),
+	}
+	if m.redelivered {
+		fields = append(fields, redisFieldRedelivered, "1")
+	}
+	return fields, nil
+}
+
+// parseRedisMessage 解析stream条目，条目格式为 [id, [field, value, ...]]
+func parseRedisMessage(v resp.Value) (redisMessage, error) {
+	if len(v.Array) != 2 {
+		return redisMessage{}, fmt.Errorf("stream条目格式错误")
+	}
+	return redisMessageFromFields(v.Array[0].Text(), v.Array[1].Strings())
+}
+
+// redisMessageFromFields 从stream条目字段还原消息
+func redisMessageFromFields(id string, fields []string) (redisMessage, error) {
+	m := redisMessage{id: id}
+	for i := 0; i+1 < len(fields); i += 2 {
+		switch fields[i] {
+		case redisFieldExchange:
+			m.exchange = fields[i+1]
+		case redisFieldRoutingKey:
+			m.routingKey = fields[i+1]
+		case redisFieldHeaders:
+			if err := json.Unmarshal([]byte(fields[i+1]), &m.headers); err != nil {
+				return m, fmt.Errorf("解析消息头失败: %w", err)
+			}
+		case redisFieldBody:
+			m.body = []byte(fields[i+1])
+		case redisFieldRedelivered:
+			m.redelivered = fields[i+1] == "1"
+		}
+	}
+	if m.headers == nil {
+		m.headers = map[string]interface{}{}
+	}
+	return m, nil
+}
+
+// parseStreamReply 解析XREADGROUP/XREAD的响应，返回各stream中的条目
+func parseStreamReply(v resp.Value) []resp.Value {
+	var entries []resp.Value
+	for _, s := range v.Array {
+		if len(s.Array) == 2 {
+			entries = append(entries, s.Array[1].Array...)
+		}
+	}
+	return entries
+}
+
+// redisBackend Redis发布器、订阅器和请求器共用的连接与键布局
+type redisBackend struct {
+	client *resp.Client
+	opts   RedisOptions
+
+	mu        sync.Mutex
+	state     ConnectionState
+	listeners []chan ConnectionEvent
+	failures  int
+}
+
+// newRedisBackend 连接到Redis并确认服务可用
+func newRedisBackend(redisURL string, opts RedisOptions) (*redisBackend, error) {
+	clientOpts, err := resp.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("解析Redis地址失败: %w", err)
+	}
+
+	b := &redisBackend{
+		client: resp.NewClient(clientOpts),
+		opts:   opts,
+		state:  StateConnected,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clientOpts.DialTimeout)
+	defer cancel()
+	if _, err := b.client.Do(ctx, "PING"); err != nil {
+		b.client.Close()
+		return nil, fmt.Errorf("连接Redis失败: %w", err)
+	}
+	return b, nil
+}
+
+// do 执行命令，并根据网络错误更新连接状态；服务端返回的错误不影响连接状态
+func (b *redisBackend) do(ctx context.Context, args ...string) (resp.Value, error) {
+	v, err := b.client.Do(ctx, args...)
+	b.observe(err)
+	return v, err
+}
+
+// observe 记录一次命令的结果，连接状态变化时通知监听者
+func (b *redisBackend) observe(err error) {
+	var serverErr resp.ServerError
+	if errors.As(err, &serverErr) || errors.Is(err, context.Canceled) {
+		return
+	}
+
+	b.mu.Lock()
+	if b.state == StateClosed {
+		b.mu.Unlock()
+		return
+	}
+	state, attempt := StateConnected, 0
+	if err != nil {
+		b.failures++
+		state, attempt = StateReconnecting, b.failures
+	} else {
+		b.failures = 0
+	}
+	changed := state != b.state || err != nil
+	b.state = state
+	listeners := append([]chan ConnectionEvent(nil), b.listeners...)
+	b.mu.Unlock()
+
+	if changed {
+		notifyListeners(listeners, ConnectionEvent{State: state, Err: err, Attempt: attempt, At: time.Now()})
+	}
+}
+
+// notifyListeners 向监听通道发送事件，通道已满时丢弃
+func notifyListeners(listeners []chan ConnectionEvent, event ConnectionEvent) {
+	for _, ch := range listeners {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// ConnectionState 返回最近一次命令反映的连接状态
+func (b *redisBackend) ConnectionState() ConnectionState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// NotifyConnectionState 注册连接状态变化监听通道
+func (b *redisBackend) NotifyConnectionState(ch chan ConnectionEvent) chan ConnectionEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listeners = append(b.listeners, ch)
+	return ch
+}
+
+// close 关闭连接池
+func (b *redisBackend) close() error {
+	b.mu.Lock()
+	b.state = StateClosed
+	listeners := append([]chan ConnectionEvent(nil), b.listeners...)
+	b.mu.Unlock()
+
+	notifyListeners(listeners, ConnectionEvent{State: StateClosed, At: time.Now()})
+	return b.client.Close()
+}
+
+// queueKey 队列对应的stream键
+func (b *redisBackend) queueKey(queueName string) string {
+	return b.opts.Prefix + ":queue:" + queueName
+}
+
+// bindingsKey 交换器的绑定集合键，成员为 "队列 绑定模式"
+func (b *redisBackend) bindingsKey(exchange string) string {
+	return b.opts.Prefix + ":bindings:" + exchange
+}
+
+// delayedKey 等待重试的消息和延迟消息的有序集合键，分数为到期时间的毫秒时间戳
+func (b *redisBackend) delayedKey() string {
+	return b.opts.Prefix + ":delayed"
+}
+
+// bind 将队列绑定到交换器
+func (b *redisBackend) bind(ctx context.Context, exchange, queueName, pattern string) error {
+	_, err := b.do(ctx, "SADD", b.bindingsKey(exchange), queueName+" "+pattern)
+	return err
+}
+
+// ensureGroup 创建队列stream及其消费组，新消费组只消费创建之后的消息
+func (b *redisBackend) ensureGroup(ctx context.Context, queueName string) error {
+	_, err := b.do(ctx, "XGROUP", "CREATE", b.queueKey(queueName), redisGroup, "$", "MKSTREAM")
+	if err != nil && !resp.IsServerError(err, "BUSYGROUP") {
+		return fmt.Errorf("创建消费组失败: %w", err)
+	}
+	return nil
+}
+
+// add 向队列stream追加一条消息
+func (b *redisBackend) add(ctx context.Context, queueName string, m redisMessage) error {
+	fields, err := m.fields()
+	if err != nil {
+		return err
+	}
+	_, err = b.do(ctx, append([]string{"XADD", b.queueKey(queueName), "*"}, fields...)...)
+	return err
+}
+
+// route 按topic规则把消息追加到所有绑定匹配的队列，返回投递的队列数量
+func (b *redisBackend) route(ctx context.Context, exchange, routingKey string, headers map[string]interface{}, body []byte) (int, error) {
+	members, err := b.do(ctx, "SMEMBERS", b.bindingsKey(exchange))
+	if err != nil {
+		return 0, fmt.Errorf("读取绑定失败: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, member := range members.Strings() {
+		queueName, pattern, _ := strings.Cut(member, " ")
+		if seen[queueName] || !MatchRoutingKey(pattern, routingKey) {
+			continue
+		}
+		seen[queueName] = true
+
+		m := redisMessage{exchange: exchange, routingKey: routingKey, headers: headers, body: body}
+		if err := b.add(ctx, queueName, m); err != nil {
+			return len(seen) - 1, fmt.Errorf("投递到队列 %s 失败: %w", queueName, err)
+		}
+	}
+	return len(seen), nil
+}
+
+// delayedMessage 等待重试的消息或延迟消息
+// 重试消息回到Queue；延迟消息的Queue为空，到期时按Exchange的绑定路由
+type delayedMessage struct {
+	// ID 使相同内容的消息在有序集合中互不覆盖
+	ID       string   `json:"id"`
+	Queue    string   `json:"queue,omitempty"`
+	Exchange string   `json:"exchange,omitempty"`
+	Fields   []string `json:"fields"`
+}
+
+// schedule 在delay之后将消息投递回队列
+func (b *redisBackend) schedule(ctx context.Context, queueName string, m redisMessage, delay time.Duration) error {
+	fields, err := m.fields()
+	if err != nil {
+		return err
+	}
+	member, err := json.Marshal(delayedMessage{ID: newMessageID(), Queue: queueName, Fields: fields})
+	if err != nil {
+		return err
+	}
+
+	due := time.Now().Add(delay).UnixMilli()
+	_, err = b.do(ctx, "ZADD", b.delayedKey(), strconv.FormatInt(due, 10), string(member))
+	return err
+}
+
+// scheduleRoute 在delay之后将消息按交换器的绑定路由，返回有序集合成员，用于取消
+func (b *redisBackend) scheduleRoute(ctx context.Context, m redisMessage, delay time.Duration) (string, error) {
+	fields, err := m.fields()
+	if err != nil {
+		return "", err
+	}
+	member, err := json.Marshal(delayedMessage{ID: newMessageID(), Exchange: m.exchange, Fields: fields})
+	if err != nil {
+		return "", err
+	}
+
+	due := time.Now().Add(delay).UnixMilli()
+	if _, err := b.do(ctx, "ZADD", b.delayedKey(), strconv.FormatInt(due, 10), string(member)); err != nil {
+		return "", err
+	}
+	return string(member), nil
+}
+
+// releaseDue 将到期的重试消息投递回原队列，到期的延迟消息按绑定路由
+// 先ZREM认领再XADD，多个订阅器同时轮询时每条消息只会被一个订阅器投递
+func (b *redisBackend) releaseDue(ctx context.Context) error {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	due, err := b.do(ctx, "ZRANGEBYSCORE", b.delayedKey(), "-inf", now, "LIMIT", "0", "100")
+	if err != nil {
+		return err
+	}
+
+	for _, member := range due.Strings() {
+		removed, err := b.do(ctx, "ZREM", b.delayedKey(), member)
+		if err != nil {
+			return err
+		}
+		if removed.Int == 0 {
+			continue
+		}
+
+		var d delayedMessage
+		if err := json.Unmarshal([]byte(member), &d); err != nil {
+			log.Printf("解析重试消息失败，已丢弃: %v", err)
+			continue
+		}
+		if d.Queue == "" {
+			b.releaseRoute(ctx, d)
+			continue
+		}
+		args := append([]string{"XADD", b.queueKey(d.Queue), "*"}, d.Fields...)
+		if _, err := b.do(ctx, args...); err != nil {
+			// 投递失败时放回有序集合，下次轮询重试
+			b.do(ctx, "ZADD", b.delayedKey(), now, member)
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseRoute 按绑定路由到期的延迟消息
+// 路由可能已投递到部分队列，失败时不放回有序集合，避免这些队列收到重复消息
+func (b *redisBackend) releaseRoute(ctx context.Context, d delayedMessage) {
+	m, err := redisMessageFromFields("", d.Fields)
+	if err != nil {
+		log.Printf("解析延迟消息失败，已丢弃: %v", err)
+		return
+	}
+	if _, err := b.route(ctx, d.Exchange, m.routingKey, m.headers, m.body); err != nil {
+		log.Printf("投递延迟消息失败: %s: %v", m.routingKey, err)
+	}
+}
+
+// RedisPublisher Redis Streams事件发布器实现
+type RedisPublisher struct {
+	*redisBackend
+	exchange string
+	producer string
+}
+
+// NewRedisPublisher 创建新的Redis事件发布器，producer为写入信封的服务名称
+func NewRedisPublisher(redisURL, exchange, producer string, opts RedisOptions) (*RedisPublisher, error) {
+	backend, err := newRedisBackend(redisURL, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisPublisher{
+		redisBackend: backend,
+		exchange:     exchange,
+		producer:     producer,
+	}, nil
+}
+
+// PublishEvent 发布事件
+func (p *RedisPublisher) PublishEvent(ctx context.Context, eventType string, data interface{}) error {
+	if err := p.publish(ctx, eventType, data); err != nil {
+		return fmt.Errorf("发布事件失败: %w", err)
+	}
+
+	log.Printf("成功发布事件(Redis): %s", eventType)
+	return nil
+}
+
+// PublishCommand 发布命令
+func (p *RedisPublisher) PublishCommand(ctx context.Context, commandType string, data interface{}) error {
+	if err := p.publish(ctx, commandType, data); err != nil {
+		return fmt.Errorf("发布命令失败: %w", err)
+	}
+
+	log.Printf("成功发布命令(Redis): %s", commandType)
+	return nil
+}
+
+// PublishConfirmed 发布消息，XADD返回即表示消息已被Redis接受
+func (p *RedisPublisher) PublishConfirmed(ctx context.Context, routingKey string, data interface{}) error {
+	if err := p.publish(ctx, routingKey, data); err != nil {
+		return fmt.Errorf("发布消息失败: %w", err)
+	}
+	return nil
+}
+
+// PublishAsync 发布消息并返回已完成的确认结果
+func (p *RedisPublisher) PublishAsync(ctx context.Context, routingKey string, data interface{}) (*Confirmation, error) {
+	if err := p.publish(ctx, routingKey, data); err != nil {
+		return nil, fmt.Errorf("发布消息失败: %w", err)
+	}
+	return confirmedImmediately(), nil
+}
+
+func (p *RedisPublisher) publish(ctx context.Context, routingKey string, data interface{}) (err error) {
+	defer observePublish(routingKey, time.Now(), &err)
+	md := newMetadata(ctx, p.producer, routingKey)
+	body, err := encodeMessage(ctx, &md, data)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.route(ctx, p.exchange, routingKey, md.headers(), body)
+	return err
+}
+
+// PublishToQueue 将消息直接追加到指定队列的stream，不经过绑定匹配
+func (p *RedisPublisher) PublishToQueue(ctx context.Context, queueName, routingKey string, data interface{}) (err error) {
+	defer observePublish(routingKey, time.Now(), &err)
+	md := newMetadata(ctx, p.producer, routingKey)
+	body, err := encodeMessage(ctx, &md, data)
+	if err != nil {
+		return err
+	}
+
+	// XADD会自动创建stream，先确认队列已被订阅过，避免消息写入无人消费的stream
+	exists, err := p.do(ctx, "EXISTS", p.queueKey(queueName))
+	if err != nil {
+		return err
+	}
+	if exists.Int == 0 {
+		return fmt.Errorf("队列不存在: %s", queueName)
+	}
+
+	return p.add(ctx, queueName, redisMessage{exchange: p.exchange, routingKey: routingKey, headers: md.headers(), body: body})
+}
+
+// PublishDelayed 将消息写入延迟有序集合，到期后由订阅器的轮询路由，没有运行中的订阅器时消息会一直等待
+func (p *RedisPublisher) PublishDelayed(ctx context.Context, routingKey string, data interface{}, delay time.Duration) (_ *DelayToken, err error) {
+	defer observePublish(routingKey, time.Now(), &err)
+	md := newMetadata(ctx, p.producer, routingKey)
+	body, err := encodeMessage(ctx, &md, data)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &DelayToken{ID: md.EventID, RoutingKey: routingKey, DeliverAt: md.OccurredAt.Add(delay)}
+	if delay <= 0 {
+		if _, err := p.route(ctx, p.exchange, routingKey, md.headers(), body); err != nil {
+			return nil, fmt.Errorf("发布消息失败: %w", err)
+		}
+		return token, nil
+	}
+
+	headers := md.headers()
+	headers[HeaderDelayToken] = md.EventID
+	m := redisMessage{exchange: p.exchange, routingKey: routingKey, headers: headers, body: body}
+	if token.Ref, err = p.scheduleRoute(ctx, m, delay); err != nil {
+		return nil, fmt.Errorf("发布延迟消息失败: %w", err)
+	}
+	return token, nil
+}
+
+// CancelDelayed 从延迟有序集合中删除消息，与releaseDue的ZREM互斥，消息只会被投递或取消其中之一
+func (p *RedisPublisher) CancelDelayed(ctx context.Context, token *DelayToken) error {
+	if token.Ref == "" {
+		return ErrDelayedNotFound
+	}
+	removed, err := p.do(ctx, "ZREM", p.delayedKey(), token.Ref)
+	if err != nil {
+		return fmt.Errorf("取消延迟消息失败: %w", err)
+	}
+	if removed.Int == 0 {
+		return ErrDelayedNotFound
+	}
+	return nil
+}
+
+// Close 关闭发布器
+func (p *RedisPublisher) Close() error {
+	return p.close()
+}
+
+// RedisSubscriber Redis Streams事件订阅器实现
+type RedisSubscriber struct {
+	*redisBackend
+	exchange string
+	producer string
+	// consumer 本订阅器在消费组中的消费者名称
+	consumer string
+
+	mu        sync.Mutex
+	consumers []*redisConsumer
+	closed    bool
+	stopPoll  chan struct{}
+	pollDone  chan struct{}
+}
+
+// redisConsumer 队列上的单个消费者
+type redisConsumer struct {
+	sub     *RedisSubscriber
+	queue   string
+	handler Handler
+	opts    SubscribeOptions
+
+	mu      sync.Mutex
+	conn    *resp.Conn
+	stopped bool
+	done    chan struct{}
+	// handling 本消费者已读取、尚未处理完的消息ID
+	// XAUTOCLAIM会返回本消费者自己仍在处理的超时消息，认领时跳过这些消息，避免重复处理
+	handling map[string]bool
+}
+
+// NewRedisSubscriber 创建新的Redis事件订阅器，producer为写入响应信封的服务名称
+func NewRedisSubscriber(redisURL, exchange, producer string, opts RedisOptions) (*RedisSubscriber, error) {
+	backend, err := newRedisBackend(redisURL, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	host, _ := os.Hostname()
+	s := &RedisSubscriber{
+		redisBackend: backend,
+		exchange:     exchange,
+		producer:     producer,
+		consumer:     fmt.Sprintf("%s-%d-%s", host, os.Getpid(), newMessageID()[:8]),
+		stopPoll:     make(chan struct{}),
+		pollDone:     make(chan struct{}),
+	}
+
+	go s.pollDelayed()
+
+	return s, nil
+}
+
+// pollDelayed 定期将到期的重试消息投递回原队列
+func (s *RedisSubscriber) pollDelayed() {
+	defer close(s.pollDone)
+
+	ticker := time.NewTicker(s.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopPoll:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := s.releaseDue(ctx); err != nil {
+				log.Printf("投递到期的重试消息失败: %v", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// Subscribe 使用默认选项订阅事件
+func (s *RedisSubscriber) Subscribe(queueName, routingKey string, handler Handler) error {
+	return s.SubscribeWithOptions(queueName, routingKey, handler, DefaultSubscribeOptions())
+}
+
+// SubscribeWithOptions 按指定选项订阅事件，重试与死信语义与RabbitMQ实现一致
+func (s *RedisSubscriber) SubscribeWithOptions(queueName, routingKey string, handler Handler, opts SubscribeOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("订阅器已关闭")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 先创建消费组再绑定，保证绑定生效后发布的消息都能被消费组读到
+	if err := s.ensureGroup(ctx, queueName); err != nil {
+		return err
+	}
+	exchange := s.exchange
+	if opts.Exchange != "" {
+		exchange = opts.Exchange
+	}
+	if err := s.bind(ctx, exchange, queueName, routingKey); err != nil {
+		return fmt.Errorf("绑定队列失败: %w", err)
+	}
+
+	consumer := &redisConsumer{
+		sub:      s,
+		queue:    queueName,
+		handler:  opts.wrapHandler(queueName, handler),
+		opts:     opts,
+		done:     make(chan struct{}),
+		handling: make(map[string]bool),
+	}
+	s.consumers = append(s.consumers, consumer)
+
+	log.Printf("成功订阅队列(Redis): %s, 路由键: %s", queueName, routingKey)
+
+	go consumer.run()
+
+	return nil
+}
+
+// Respond 订阅请求队列，Responder的结果追加到请求方的响应stream
+func (s *RedisSubscriber) Respond(queueName, routingKey string, responder Responder, opts SubscribeOptions) error {
+	return s.SubscribeWithOptions(queueName, routingKey, respondHandler(responder, s.producer, s.sendReply), opts)
+}
+
+// sendReply 将响应直接追加到请求方的响应stream，等同于通过默认交换器发布
+func (s *RedisSubscriber) sendReply(ctx context.Context, replyTo string, md Metadata, body []byte, headers map[string]interface{}) error {
+	return s.add(ctx, replyTo, redisMessage{routingKey: replyTo, headers: headers, body: body})
+}
+
+// InspectDeadLetters 查看死信队列中的消息
+func (s *RedisSubscriber) InspectDeadLetters(queueName string, limit int) ([]DeadLetter, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	entries, err := s.do(ctx, "XRANGE", s.queueKey(deadLetterQueueName(queueName)), "-", "+", "COUNT", strconv.Itoa(limit))
+	if err != nil {
+		return nil, fmt.Errorf("读取死信队列失败: %w", err)
+	}
+
+	var letters []DeadLetter
+	for _, e := range entries.Array {
+		m, err := parseRedisMessage(e)
+		if err != nil {
+			return letters, err
+		}
+		letters = append(letters, newDeadLetter(queueName, m.headers, m.body))
+	}
+	return letters, nil
+}
+
+// RedriveDeadLetters 将死信重新投递到原队列，重试次数清零
+func (s *RedisSubscriber) RedriveDeadLetters(queueName string, limit int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	dlq := s.queueKey(deadLetterQueueName(queueName))
+	entries, err := s.do(ctx, "XRANGE", dlq, "-", "+", "COUNT", strconv.Itoa(limit))
+	if err != nil {
+		return 0, fmt.Errorf("读取死信队列失败: %w", err)
+	}
+
+	redriven := 0
+	for _, e := range entries.Array {
+		m, err := parseRedisMessage(e)
+		if err != nil {
+			return redriven, err
+		}
+
+		delete(m.headers, HeaderDeathReason)
+		delete(m.headers, HeaderFailedAt)
+		m.headers[HeaderRetryCount] = 0
+		if err := s.add(ctx, queueName, m); err != nil {
+			return redriven, fmt.Errorf("重新投递死信失败: %w", err)
+		}
+		if _, err := s.do(ctx, "XDEL", dlq, m.id); err != nil {
+			return redriven, fmt.Errorf("删除死信失败: %w", err)
+		}
+		redriven++
+	}
+
+	log.Printf("已重新投递 %d 条死信(Redis): 队列=%s", redriven, queueName)
+	return redriven, nil
+}
+
+// Shutdown 优雅关闭订阅器：停止读取新消息，等待已读取的消息处理完成或ctx到期
+// 未处理完的消息保持未确认状态，超过可见性超时后由其他消费者重新认领
+func (s *RedisSubscriber) Shutdown(ctx context.Context) error {
+	started := time.Now()
+
+	consumers := s.stop()
+	dones := make([]<-chan struct{}, 0, len(consumers))
+	for _, c := range consumers {
+		c.cancel(false)
+		dones = append(dones, c.done)
+	}
+
+	err := waitDone(ctx, dones)
+	logShutdown("事件订阅器(Redis)", started, err)
+
+	if closeErr := s.close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Close 立即关闭订阅器，正在处理的消息不会被确认
+func (s *RedisSubscriber) Close() error {
+	for _, c := range s.stop() {
+		c.cancel(true)
+	}
+	return s.close()
+}
+
+// stop 停止接受新订阅和轮询重试消息，返回所有消费者
+func (s *RedisSubscriber) stop() []*redisConsumer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	consumers := s.consumers
+	s.consumers = nil
+	if !s.closed {
+		s.closed = true
+		close(s.stopPoll)
+	}
+	return consumers
+}
+
+// run 消费循环：定期认领超时未确认的消息，阻塞读取新消息并交给工作协程处理
+func (c *redisConsumer) run() {
+	defer close(c.done)
+
+	// inflight 模拟预取数量，限制已读取但未确认的消息数量
+	inflight := make(chan struct{}, c.opts.prefetch())
+	pool := newWorkerPool(c.opts.workers())
+	defer pool.close()
+
+	dispatchAll := func(entries []resp.Value, redelivered bool) {
+		for _, e := range entries {
+			m, err := parseRedisMessage(e)
+			if err != nil {
+				log.Printf("解析消息失败: 队列=%s, %v", c.queue, err)
+				continue
+			}
+			m.redelivered = m.redelivered || redelivered
+			if !c.startHandling(m.id) {
+				continue
+			}
+
+			inflight <- struct{}{}
+			pool.submitMessage(c.opts, m.body, m.routingKey, m.headers, m.redelivered, c.handler, func(ctx context.Context) {
+				defer func() { <-inflight }()
+				defer c.finishHandling(m.id)
+				c.process(ctx, m)
+			})
+		}
+	}
+
+	var lastClaim time.Time
+	backoff := DefaultReconnectConfig().InitialWait
+	for !c.isStopped() {
+		if time.Since(lastClaim) >= c.sub.opts.VisibilityTimeout/2 {
+			lastClaim = time.Now()
+			claimed, err := c.claim()
+			if err == nil {
+				dispatchAll(claimed, true)
+			}
+		}
+
+		entries, err := c.read()
+		if err != nil {
+			if c.isStopped() {
+				break
+			}
+			log.Printf("读取队列失败(Redis): 队列=%s, %v", c.queue, err)
+			if resp.IsServerError(err, "NOGROUP") {
+				// stream被删除时重建消费组
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				c.sub.ensureGroup(ctx, c.queue)
+				cancel()
+			}
+			time.Sleep(backoff)
+			continue
+		}
+		dispatchAll(entries, false)
+	}
+
+	log.Printf("消息消费通道已关闭(Redis): %s", c.queue)
+}
+
+// read 阻塞读取新消息，使用独立连接避免阻塞连接池
+func (c *redisConsumer) read() ([]resp.Value, error) {
+	conn, err := c.connection()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := c.sub.opts
+	ctx, cancel := context.WithTimeout(context.Background(), opts.BlockTimeout+5*time.Second)
+	defer cancel()
+
+	v, err := conn.Do(ctx,
+		"XREADGROUP", "GROUP", redisGroup, c.sub.consumer,
+		"COUNT", strconv.Itoa(c.opts.prefetch()),
+		"BLOCK", strconv.FormatInt(opts.BlockTimeout.Milliseconds(), 10),
+		"STREAMS", c.sub.queueKey(c.queue), ">",
+	)
+	c.sub.observe(err)
+	if err != nil {
+		c.resetConnection()
+		return nil, err
+	}
+	return parseStreamReply(v), nil
+}
+
+// claim 认领超过可见性超时仍未确认的消息，包括已崩溃的消费者读取的消息
+// 本消费者仍在处理的消息同样会被认领，认领同时重置了它们的空闲时间，由dispatchAll跳过
+func (c *redisConsumer) claim() ([]resp.Value, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 正在处理的消息最多为预取数量，多认领一倍保证仍能取到其他消费者遗留的消息
+	v, err := c.sub.do(ctx,
+		"XAUTOCLAIM", c.sub.queueKey(c.queue), redisGroup, c.sub.consumer,
+		strconv.FormatInt(c.sub.opts.VisibilityTimeout.Milliseconds(), 10), "0-0",
+		"COUNT", strconv.Itoa(2*c.opts.prefetch()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(v.Array) < 2 {
+		return nil, nil
+	}
+	if n := len(v.Array[1].Array); n > 0 {
+		log.Printf("认领了 %d 条超时未确认的消息: 队列=%s", n, c.queue)
+	}
+	return v.Array[1].Array, nil
+}
+
+// startHandling 记录开始处理的消息，消息已在处理中时返回false
+func (c *redisConsumer) startHandling(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.handling[id] {
+		return false
+	}
+	c.handling[id] = true
+	return true
+}
+
+// finishHandling 消息处理完成，之后再被认领时重新投递
+func (c *redisConsumer) finishHandling(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.handling, id)
+}
+
+// connection 返回阻塞读取使用的独立连接，断开后重新建立
+func (c *redisConsumer) connection() (*resp.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != nil {
+		return c.conn, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := c.sub.client.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	return conn, nil
+}
+
+func (c *redisConsumer) resetConnection() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// process 处理一条消息并确认，失败时按重试策略重投或投递到死信队列
+func (c *redisConsumer) process(ctx context.Context, m redisMessage) {
+	if err := dispatch(ctx, m.body, m.routingKey, m.headers, m.redelivered, c.handler); err != nil {
+		log.Printf("处理消息失败: %v", err)
+		c.handleFailure(m, err)
+		return
+	}
+
+	c.ack(m)
+	log.Printf("成功处理消息: %s", m.routingKey)
+}
+
+// ack 确认消息，确认失败的消息在可见性超时后会被重新投递
+func (c *redisConsumer) ack(m redisMessage) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := c.sub.do(ctx, "XACK", c.sub.queueKey(c.queue), redisGroup, m.id); err != nil {
+		log.Printf("确认消息失败: 队列=%s, ID=%s, %v", c.queue, m.id, err)
+	}
+}
+
+// handleFailure 处理失败的消息：延迟后重新入队，重试耗尽后投递到死信队列
+// 重试或死信副本写入成功后才确认原消息，写入失败时原消息在可见性超时后重新投递
+func (c *redisConsumer) handleFailure(m redisMessage, handlerErr error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	retryCount := headerInt(m.headers, HeaderRetryCount)
+
+	headers := copyHeaders(m.headers)
+	if headerString(headers, HeaderOriginalRoutingKey) == "" {
+		headers[HeaderOriginalExchange] = m.exchange
+		headers[HeaderOriginalRoutingKey] = m.routingKey
+	}
+
+	if delay, ok := nextRetry(c.opts, retryCount, handlerErr); ok {
+		headers[HeaderRetryCount] = retryCount + 1
+		retried := redisMessage{exchange: m.exchange, routingKey: m.routingKey, headers: headers, body: m.body, redelivered: true}
+		if err := c.sub.schedule(ctx, c.queue, retried, delay); err != nil {
+			log.Printf("安排重试失败: 队列=%s, %v", c.queue, err)
+			observeNack(c.queue, nackRequeued)
+			return
+		}
+		c.ack(m)
+		observeNack(c.queue, nackRetry)
+
+		log.Printf("消息将在 %v 后重试(%d/%d): 队列=%s", delay, retryCount+1, c.opts.Retry.MaxRetries, c.queue)
+		return
+	}
+
+	if !c.opts.DeadLetter {
+		c.ack(m)
+		observeNack(c.queue, nackDiscarded)
+		log.Printf("消息重试耗尽，已丢弃: 队列=%s, 原因=%v", c.queue, handlerErr)
+		return
+	}
+
+	headers[HeaderRetryCount] = retryCount
+	headers[HeaderDeathReason] = handlerErr.Error()
+	headers[HeaderFailedAt] = time.Now().UTC().Format(time.RFC3339Nano)
+	dead := redisMessage{exchange: m.exchange, routingKey: m.routingKey, headers: headers, body: m.body}
+	dlq := deadLetterQueueName(c.queue)
+	if err := c.sub.add(ctx, dlq, dead); err != nil {
+		log.Printf("投递到死信队列失败: 队列=%s, %v", dlq, err)
+		observeNack(c.queue, nackRequeued)
+		return
+	}
+	c.ack(m)
+	observeNack(c.queue, nackDeadLetter)
+
+	log.Printf("消息已投递到死信队列: 队列=%s, 原因=%v", dlq, handlerErr)
+}
+
+func (c *redisConsumer) isStopped() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stopped
+}
+
+// cancel 停止读取新消息；interrupt为true时立即断开阻塞读取的连接
+func (c *redisConsumer) cancel(interrupt bool) {
+	c.mu.Lock()
+	c.stopped = true
+	conn := c.conn
+	c.conn = nil
+	c.mu.Unlock()
+
+	// 优雅关闭时等待阻塞读取自然超时，避免丢弃已被读取但尚未返回的消息
+	if interrupt && conn != nil {
+		conn.Close()
+	}
+}
+
+// RedisRequester Redis Streams请求器实现，每个请求器拥有独立的响应stream
+type RedisRequester struct {
+	*redisBackend
+	exchange   string
+	producer   string
+	opts       RequesterOptions
+	pending    *pendingReplies
+	replyQueue string
+
+	mu     sync.Mutex
+	conn   *resp.Conn
+	closed bool
+	done   chan struct{}
+}
+
+// NewRedisRequester 创建新的Redis请求器，producer为写入信封的服务名称
+func NewRedisRequester(redisURL, exchange, producer string, opts RequesterOptions, redisOpts RedisOptions) (*RedisRequester, error) {
+	backend, err := newRedisBackend(redisURL, redisOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := backend.client.Conn(ctx)
+	if err != nil {
+		backend.close()
+		return nil, fmt.Errorf("连接Redis失败: %w", err)
+	}
+
+	r := &RedisRequester{
+		redisBackend: backend,
+		exchange:     exchange,
+		producer:     producer,
+		opts:         opts,
+		pending:      newPendingReplies(),
+		replyQueue:   "amq.gen-" + newMessageID(),
+		conn:         conn,
+		done:         make(chan struct{}),
+	}
+
+	go r.receive()
+
+	return r, nil
+}
+
+// receive 读取响应stream，只有本请求器读取，因此不使用消费组
+func (r *RedisRequester) receive() {
+	defer close(r.done)
+
+	key := r.queueKey(r.replyQueue)
+	lastID := "0-0"
+	for {
+		r.mu.Lock()
+		conn, closed := r.conn, r.closed
+		r.mu.Unlock()
+		if closed {
+			return
+		}
+
+		if conn == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			c, err := r.client.Conn(ctx)
+			cancel()
+			if err != nil {
+				time.Sleep(DefaultReconnectConfig().InitialWait)
+				continue
+			}
+			r.mu.Lock()
+			r.conn, conn = c, c
+			r.mu.Unlock()
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), r.redisBackend.opts.BlockTimeout+5*time.Second)
+		v, err := conn.Do(ctx, "XREAD", "BLOCK", strconv.FormatInt(r.redisBackend.opts.BlockTimeout.Milliseconds(), 10), "STREAMS", key, lastID)
+		cancel()
+		if err != nil {
+			r.mu.Lock()
+			if r.conn == conn {
+				r.conn = nil
+			}
+			r.mu.Unlock()
+			conn.Close()
+			continue
+		}
+
+		for _, e := range parseStreamReply(v) {
+			m, err := parseRedisMessage(e)
+			if err != nil {
+				log.Printf("解析响应失败: %v", err)
+				continue
+			}
+			lastID = m.id
+			r.pending.deliverReply(m.body, m.routingKey, m.headers)
+		}
+	}
+}
+
+// Request 发送请求并等待响应
+func (r *RedisRequester) Request(ctx context.Context, routingKey string, data interface{}, reply interface{}) error {
+	r.mu.Lock()
+	closed := r.closed
+	r.mu.Unlock()
+	if closed {
+		return ErrRequesterClosed
+	}
+
+	ctx, cancel := r.opts.withTimeout(ctx)
+	defer cancel()
+
+	md := newMetadata(ctx, r.producer, routingKey)
+	body, err := encodeMessage(ctx, &md, data)
+	if err != nil {
+		return err
+	}
+
+	deadline, _ := ctx.Deadline()
+	replies := r.pending.register(md.EventID)
+
+	routed, err := r.route(ctx, r.exchange, routingKey, requestHeaders(md, r.replyQueue, deadline), body)
+	if err == nil && routed == 0 {
+		err = fmt.Errorf("%w: %s", ErrNoResponder, routingKey)
+	}
+	if err != nil {
+		r.pending.remove(md.EventID)
+		return fmt.Errorf("发送请求失败: %w", err)
+	}
+
+	return r.pending.wait(ctx, routingKey, md.EventID, replies, reply)
+}
+
+// Close 关闭请求器并删除响应stream，等待中的请求以ErrRequesterClosed结束
+func (r *RedisRequester) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	conn := r.conn
+	r.conn = nil
+	r.mu.Unlock()
+
+	if conn != nil {
+		conn.Close()
+	}
+	<-r.done
+	r.pending.failAll(ErrRequesterClosed)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	r.do(ctx, "DEL", r.queueKey(r.replyQueue))
+	return r.close()
+}
@@ -0,0 +1,680 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// 请求/响应消息头
+const (
+	// HeaderReplyTo 请求的响应队列，与AMQP的reply_to属性一致
+	HeaderReplyTo = "x-reply-to"
+	// HeaderRequestID 请求ID，响应消息携带相同的值，与AMQP的correlation_id属性一致
+	HeaderRequestID = "x-request-id"
+	// HeaderDeadline 请求方等待响应的截止时间，过期的请求不再处理
+	HeaderDeadline = "x-deadline"
+	// HeaderReplyError 响应方处理失败时的错误信息
+	HeaderReplyError = "x-reply-error"
+)
+
+// DirectReplyToQueue RabbitMQ的直接响应伪队列，无需为每个请求方声明响应队列
+const DirectReplyToQueue = "amq.rabbitmq.reply-to"
+
+// ReplySuffix 响应消息的事件类型后缀
+const ReplySuffix = ".reply"
+
+var (
+	// ErrRequestTimeout 在截止时间内没有收到响应
+	ErrRequestTimeout = errors.New("等待响应超时")
+	// ErrNoResponder 请求的路由键没有绑定任何响应方
+	ErrNoResponder = errors.New("没有响应方处理该请求")
+	// ErrRequesterClosed 请求器已关闭或响应通道已断开，等待中的请求无法再收到响应
+	ErrRequesterClosed = errors.New("请求器已关闭")
+)
+
+// RemoteError 响应方返回的错误
+type RemoteError struct {
+	RoutingKey string
+	Message    string
+}
+
+func (e *RemoteError) Error() string {
+	return fmt.Sprintf("请求 %s 处理失败: %s", e.RoutingKey, e.Message)
+}
+
+// Responder 请求处理函数，返回值作为响应负载发回请求方，返回错误时请求方收到RemoteError
+// ctx在请求方设置了截止时间时随之到期
+type Responder func(ctx context.Context, env Envelope) (interface{}, error)
+
+// TypedResponder 类型化请求处理函数
+type TypedResponder[T any] func(ctx context.Context, env Envelope, request T) (interface{}, error)
+
+// TypedResponse 将类型化请求处理函数适配为Responder，请求负载解码或校验失败时向请求方返回错误
+func TypedResponse[T any](responder TypedResponder[T]) Responder {
+	return func(ctx context.Context, env Envelope) (interface{}, error) {
+		request, err := decodePayload[T](env)
+		if err != nil {
+			return nil, err
+		}
+		return responder(ctx, env, request)
+	}
+}
+
+// RespondOptions 返回响应方的默认订阅选项
+// 请求方只在截止时间内等待，失败的请求以错误响应返回，不重试也不进入死信队列
+func RespondOptions() SubscribeOptions {
+	return SubscribeOptions{}
+}
+
+// replySender 将编码好的响应发送到请求方的响应队列
+type replySender func(ctx context.Context, replyTo string, md Metadata, body []byte, headers map[string]interface{}) error
+
+// respondHandler 将Responder适配为Handler，处理完成后把结果或错误发送到请求的响应队列
+// producer为响应方的服务名称，与发布的消息一样写入响应信封
+func respondHandler(responder Responder, producer string, send replySender) Handler {
+	return func(ctx context.Context, env Envelope) error {
+		if env.ReplyTo == "" {
+			return Permanent(fmt.Errorf("请求缺少响应队列: %s", env.EventType))
+		}
+
+		if !env.Deadline.IsZero() {
+			if time.Now().After(env.Deadline) {
+				log.Printf("请求已过期，不再处理: %s, 请求ID=%s", env.EventType, env.RequestID)
+				return nil
+			}
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, env.Deadline)
+			defer cancel()
+		}
+
+		result, handlerErr := responder(ctx, env)
+		if handlerErr != nil {
+			result = nil
+		}
+
+		// 响应与请求使用相同的内容类型，响应沿用请求的关联ID；错误响应没有负载，按默认类型编码
+		md := newMetadata(ctx, producer, env.EventType+ReplySuffix)
+		replyCtx := ctx
+		if result != nil {
+			replyCtx = WithContentType(ctx, env.ContentType)
+		}
+		body, err := encodeMessage(replyCtx, &md, result)
+		if err != nil {
+			handlerErr = err
+			if body, err = encodeMessage(ctx, &md, nil); err != nil {
+				return err
+			}
+		}
+
+		headers := md.headers()
+		headers[HeaderRequestID] = env.RequestID
+		if handlerErr != nil {
+			headers[HeaderReplyError] = handlerErr.Error()
+			log.Printf("请求处理失败，已返回错误响应: %s, 请求ID=%s, %v", env.EventType, env.RequestID, handlerErr)
+		}
+
+		if err := send(ctx, env.ReplyTo, md, body, headers); err != nil {
+			return fmt.Errorf("发送响应失败: %w", err)
+		}
+		return nil
+	}
+}
+
+// RespondTyped 使用默认响应选项和类型化请求处理函数响应请求
+func RespondTyped[T any](s Subscriber, queueName, routingKey string, responder TypedResponder[T]) error {
+	return s.Respond(queueName, routingKey, TypedResponse(responder), RespondOptions())
+}
+
+// Requester 请求器接口，通过AMQP发送请求并等待响应方的响应
+type Requester interface {
+	// Request 发送请求并等待响应，reply为nil时只等待响应而不解码负载
+	// ctx没有截止时间时使用请求器的默认超时
+	Request(ctx context.Context, routingKey string, data interface{}, reply interface{}) error
+	Close() error
+}
+
+// Call 发送类型化请求并返回解码后的响应
+func Call[T any](ctx context.Context, r Requester, routingKey string, data interface{}) (T, error) {
+	var reply T
+	err := r.Request(ctx, routingKey, data, &reply)
+	return reply, err
+}
+
+// RequesterOptions 请求器选项
+type RequesterOptions struct {
+	// DirectReplyTo 是否使用RabbitMQ的直接响应，为false时为请求器声明独占的临时响应队列
+	// 内存后端忽略该选项
+	DirectReplyTo bool
+	// Timeout ctx没有截止时间时等待响应的时间
+	Timeout time.Duration
+}
+
+// DefaultRequesterOptions 返回默认请求器选项：使用直接响应，等待5秒
+func DefaultRequesterOptions() RequesterOptions {
+	return RequesterOptions{
+		DirectReplyTo: true,
+		Timeout:       5 * time.Second,
+	}
+}
+
+// withTimeout 在ctx没有截止时间时附加默认超时
+func (o RequesterOptions) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || o.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, o.Timeout)
+}
+
+// replyResult 一次请求收到的响应
+type replyResult struct {
+	env      Envelope
+	replyErr string
+	err      error
+}
+
+// pendingReplies 按请求ID记录等待响应的请求
+type pendingReplies struct {
+	mu    sync.Mutex
+	calls map[string]chan replyResult
+}
+
+func newPendingReplies() *pendingReplies {
+	return &pendingReplies{calls: make(map[string]chan replyResult)}
+}
+
+// register 登记等待响应的请求，必须在发送请求之前调用，避免响应先于登记到达
+func (p *pendingReplies) register(requestID string) chan replyResult {
+	ch := make(chan replyResult, 1)
+	p.mu.Lock()
+	p.calls[requestID] = ch
+	p.mu.Unlock()
+	return ch
+}
+
+// remove 移除请求的登记
+func (p *pendingReplies) remove(requestID string) {
+	p.mu.Lock()
+	delete(p.calls, requestID)
+	p.mu.Unlock()
+}
+
+// resolve 将响应交给等待中的请求，请求已超时或响应重复时返回false
+func (p *pendingReplies) resolve(requestID string, result replyResult) bool {
+	p.mu.Lock()
+	ch, ok := p.calls[requestID]
+	delete(p.calls, requestID)
+	p.mu.Unlock()
+
+	if ok {
+		ch <- result
+	}
+	return ok
+}
+
+// failAll 以错误结束所有等待中的请求
+func (p *pendingReplies) failAll(err error) {
+	p.mu.Lock()
+	calls := p.calls
+	p.calls = make(map[string]chan replyResult)
+	p.mu.Unlock()
+
+	for _, ch := range calls {
+		ch <- replyResult{err: err}
+	}
+}
+
+// deliverReply 解析响应消息并交给对应的请求
+func (p *pendingReplies) deliverReply(body []byte, routingKey string, headers map[string]interface{}) {
+	env, err := decodeEnvelope(body, routingKey, headers, false)
+	if err != nil {
+		log.Printf("解析响应失败: %v", err)
+		return
+	}
+
+	result := replyResult{env: env, replyErr: headerString(headers, HeaderReplyError)}
+	if !p.resolve(env.RequestID, result) {
+		log.Printf("收到无人等待的响应，可能已超时: 请求ID=%s", env.RequestID)
+	}
+}
+
+// wait 等待请求的响应并解码到reply
+func (p *pendingReplies) wait(ctx context.Context, routingKey, requestID string, replies chan replyResult, reply interface{}) error {
+	select {
+	case result := <-replies:
+		if result.err != nil {
+			return result.err
+		}
+		if result.replyErr != "" {
+			return &RemoteError{RoutingKey: routingKey, Message: result.replyErr}
+		}
+		if reply == nil {
+			return nil
+		}
+		codec, err := CodecFor(result.env.ContentType)
+		if err != nil {
+			return err
+		}
+		if err := codec.Unmarshal(result.env.Data, reply); err != nil {
+			return fmt.Errorf("解析响应负载失败: %s(%T): %w", routingKey, reply, err)
+		}
+		return nil
+	case <-ctx.Done():
+		p.remove(requestID)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("%w: %s, 请求ID=%s", ErrRequestTimeout, routingKey, requestID)
+		}
+		return ctx.Err()
+	}
+}
+
+// requestHeaders 返回请求消息的消息头
+func requestHeaders(md Metadata, replyTo string, deadline time.Time) map[string]interface{} {
+	headers := md.headers()
+	headers[HeaderReplyTo] = replyTo
+	headers[HeaderRequestID] = md.EventID
+	headers[HeaderDeadline] = deadline.UTC().Format(time.RFC3339Nano)
+	return headers
+}
+
+// RabbitMQRequester RabbitMQ请求器实现
+// 请求和响应使用同一个通道：直接响应要求在发布请求的通道上消费响应
+type RabbitMQRequester struct {
+	conn     *Connection
+	exchange string
+	producer string
+	opts     RequesterOptions
+
+	mu      sync.RWMutex
+	current *replyChannel
+	closed  bool
+}
+
+// replyChannel 请求通道及其响应队列，通道关闭时只结束在该通道上发出的请求
+type replyChannel struct {
+	channel *amqp091.Channel
+	queue   string
+	pending *pendingReplies
+}
+
+// NewRabbitMQRequester 创建新的RabbitMQ请求器，producer为写入信封的服务名称
+func NewRabbitMQRequester(amqpURL, exchange, producer string, opts RequesterOptions) (*RabbitMQRequester, error) {
+	// 连接到RabbitMQ服务器
+	conn, err := NewConnection(amqpURL)
+	if err != nil {
+		return nil, err
+	}
+
+	requester := &RabbitMQRequester{
+		conn:     conn,
+		exchange: exchange,
+		producer: producer,
+		opts:     opts,
+	}
+
+	if err := requester.setupChannel(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// 重连后重建通道和响应消费者
+	conn.OnReconnect(requester.setupChannel)
+
+	return requester, nil
+}
+
+// setupChannel 创建通道，声明交换器并开始消费响应队列
+func (r *RabbitMQRequester) setupChannel() error {
+	ch, err := r.conn.Channel()
+	if err != nil {
+		return err
+	}
+
+	// 声明交换器
+	if err := ch.ExchangeDeclare(r.exchange, "topic", true, false, false, false, nil); err != nil {
+		ch.Close()
+		return fmt.Errorf("声明交换器失败: %w", err)
+	}
+
+	// 直接响应无需声明队列，否则声明由代理命名的独占临时队列，连接断开后自动删除
+	replyQueue := DirectReplyToQueue
+	if !r.opts.DirectReplyTo {
+		q, err := ch.QueueDeclare(
+			"",    // 由代理生成队列名称
+			false, // 持久化
+			true,  // 自动删除
+			true,  // 独占
+			false, // 不等待
+			nil,   // 参数
+		)
+		if err != nil {
+			ch.Close()
+			return fmt.Errorf("声明响应队列失败: %w", err)
+		}
+		replyQueue = q.Name
+	}
+
+	// 直接响应要求自动确认
+	replies, err := ch.Consume(replyQueue, "", true, true, false, false, nil)
+	if err != nil {
+		ch.Close()
+		return fmt.Errorf("消费响应队列失败: %w", err)
+	}
+
+	// 没有队列绑定请求的路由键时，代理退回请求
+	returns := ch.NotifyReturn(make(chan amqp091.Return, 16))
+
+	rc := &replyChannel{channel: ch, queue: replyQueue, pending: newPendingReplies()}
+
+	r.mu.Lock()
+	old := r.current
+	r.current = rc
+	r.mu.Unlock()
+
+	if old != nil && !old.channel.IsClosed() {
+		old.channel.Close()
+	}
+
+	go rc.receive(replies, returns)
+
+	return nil
+}
+
+// receive 分发响应和被退回的请求，通道关闭后在该通道上等待的请求全部失败
+func (rc *replyChannel) receive(replies <-chan amqp091.Delivery, returns <-chan amqp091.Return) {
+	for replies != nil || returns != nil {
+		select {
+		case msg, ok := <-replies:
+			if !ok {
+				replies = nil
+				continue
+			}
+			headers := map[string]interface{}(msg.Headers)
+			if headerString(headers, HeaderRequestID) == "" {
+				headers = copyHeaders(headers)
+				headers[HeaderRequestID] = msg.CorrelationId
+			}
+			rc.pending.deliverReply(msg.Body, msg.RoutingKey, headers)
+		case ret, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			rc.pending.resolve(ret.CorrelationId, replyResult{
+				err: fmt.Errorf("%w: %s", ErrNoResponder, ret.RoutingKey),
+			})
+		}
+	}
+
+	// 通道关闭后此前的请求不会再收到响应，新请求使用重建的通道
+	rc.pending.failAll(ErrRequesterClosed)
+	log.Printf("响应消费通道已关闭")
+}
+
+// currentChannel 返回可用的请求通道
+// 通道因异常被代理关闭而连接仍然可用时，就地重建通道
+func (r *RabbitMQRequester) currentChannel() (*replyChannel, error) {
+	r.mu.RLock()
+	rc, closed := r.current, r.closed
+	r.mu.RUnlock()
+
+	if closed {
+		return nil, ErrRequesterClosed
+	}
+	if rc != nil && !rc.channel.IsClosed() {
+		return rc, nil
+	}
+	if state := r.conn.State(); state != StateConnected {
+		return nil, fmt.Errorf("RabbitMQ连接不可用，当前状态: %s", state)
+	}
+	if err := r.setupChannel(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current, nil
+}
+
+// ConnectionState 返回当前连接状态
+func (r *RabbitMQRequester) ConnectionState() ConnectionState {
+	return r.conn.State()
+}
+
+// NotifyConnectionState 注册连接状态变化监听通道
+func (r *RabbitMQRequester) NotifyConnectionState(ch chan ConnectionEvent) chan ConnectionEvent {
+	return r.conn.NotifyState(ch)
+}
+
+// Request 发送请求并等待响应
+func (r *RabbitMQRequester) Request(ctx context.Context, routingKey string, data interface{}, reply interface{}) error {
+	ctx, cancel := r.opts.withTimeout(ctx)
+	defer cancel()
+
+	md := newMetadata(ctx, r.producer, routingKey)
+	body, err := encodeMessage(ctx, &md, data)
+	if err != nil {
+		return err
+	}
+
+	rc, err := r.currentChannel()
+	if err != nil {
+		return err
+	}
+
+	deadline, _ := ctx.Deadline()
+	expiration := time.Until(deadline).Milliseconds()
+	if expiration < 1 {
+		expiration = 1
+	}
+
+	replies := rc.pending.register(md.EventID)
+
+	// mandatory使没有响应方的请求被代理退回，请求方无需等到超时
+	err = rc.channel.PublishWithContext(ctx,
+		r.exchange, // 交换器
+		routingKey, // 路由键
+		true,       // 强制
+		false,      // 立即
+		amqp091.Publishing{
+			Headers:       requestHeaders(md, rc.queue, deadline),
+			ContentType:   "application/json",
+			DeliveryMode:  amqp091.Transient,
+			MessageId:     md.EventID,
+			CorrelationId: md.EventID,
+			ReplyTo:       rc.queue,
+			Expiration:    strconv.FormatInt(expiration, 10),
+			Type:          md.EventType,
+			AppId:         md.Producer,
+			Body:          body,
+			Timestamp:     md.OccurredAt,
+		},
+	)
+	if err != nil {
+		rc.pending.remove(md.EventID)
+		return fmt.Errorf("发送请求失败: %w", err)
+	}
+
+	return rc.pending.wait(ctx, routingKey, md.EventID, replies, reply)
+}
+
+// Close 关闭请求器，等待中的请求以ErrRequesterClosed结束
+func (r *RabbitMQRequester) Close() error {
+	r.mu.Lock()
+	rc := r.current
+	r.current = nil
+	r.closed = true
+	r.mu.Unlock()
+
+	if rc != nil {
+		if !rc.channel.IsClosed() {
+			rc.channel.Close()
+		}
+		rc.pending.failAll(ErrRequesterClosed)
+	}
+	return r.conn.Close()
+}
+
+// sendReply 通过默认交换器将响应直接发送到请求方的响应队列
+func (s *RabbitMQSubscriber) sendReply(ctx context.Context, replyTo string, md Metadata, body []byte, headers map[string]interface{}) error {
+	ch, err := s.currentChannel()
+	if err != nil {
+		return err
+	}
+
+	return ch.PublishWithContext(ctx,
+		"",      // 默认交换器
+		replyTo, // 响应队列
+		false,   // 强制
+		false,   // 立即
+		amqp091.Publishing{
+			Headers:       headers,
+			ContentType:   "application/json",
+			DeliveryMode:  amqp091.Transient,
+			MessageId:     md.EventID,
+			CorrelationId: headerString(headers, HeaderRequestID),
+			Type:          md.EventType,
+			AppId:         md.Producer,
+			Body:          body,
+			Timestamp:     md.OccurredAt,
+		},
+	)
+}
+
+// InMemoryRequester 基于进程内消息代理的请求器实现，每个请求器拥有独立的响应队列
+type InMemoryRequester struct {
+	broker     *InMemoryBroker
+	exchange   string
+	producer   string
+	opts       RequesterOptions
+	pending    *pendingReplies
+	replyQueue *memQueue
+
+	mu     sync.Mutex
+	closed bool
+	done   chan struct{}
+}
+
+// NewInMemoryRequester 创建新的内存请求器
+func NewInMemoryRequester(broker *InMemoryBroker, exchange, producer string, opts RequesterOptions) *InMemoryRequester {
+	broker.DeclareExchange(exchange)
+
+	name := "amq.gen-" + newMessageID()
+	broker.DeclareQueue(name)
+	q, _ := broker.queue(name)
+
+	r := &InMemoryRequester{
+		broker:     broker,
+		exchange:   exchange,
+		producer:   producer,
+		opts:       opts,
+		pending:    newPendingReplies(),
+		replyQueue: q,
+		done:       make(chan struct{}),
+	}
+
+	go r.receive()
+
+	return r
+}
+
+// receive 消费响应队列
+func (r *InMemoryRequester) receive() {
+	defer close(r.done)
+
+	for {
+		d, ok := r.replyQueue.pop(nil, r.isClosed)
+		if !ok {
+			return
+		}
+		r.replyQueue.ack(d.tag)
+		r.pending.deliverReply(d.body, d.routingKey, d.headers)
+	}
+}
+
+func (r *InMemoryRequester) isClosed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closed
+}
+
+// Request 发送请求并等待响应
+func (r *InMemoryRequester) Request(ctx context.Context, routingKey string, data interface{}, reply interface{}) error {
+	if r.isClosed() {
+		return ErrRequesterClosed
+	}
+
+	ctx, cancel := r.opts.withTimeout(ctx)
+	defer cancel()
+
+	md := newMetadata(ctx, r.producer, routingKey)
+	body, err := encodeMessage(ctx, &md, data)
+	if err != nil {
+		return err
+	}
+
+	deadline, _ := ctx.Deadline()
+	replies := r.pending.register(md.EventID)
+
+	routed, err := r.broker.route(r.exchange, routingKey, body, requestHeaders(md, r.replyQueue.name, deadline))
+	if err == nil && routed == 0 {
+		err = fmt.Errorf("%w: %s", ErrNoResponder, routingKey)
+	}
+	if err != nil {
+		r.pending.remove(md.EventID)
+		return fmt.Errorf("发送请求失败: %w", err)
+	}
+
+	return r.pending.wait(ctx, routingKey, md.EventID, replies, reply)
+}
+
+// Close 关闭请求器并停止消费响应队列，等待中的请求以ErrRequesterClosed结束
+func (r *InMemoryRequester) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	r.mu.Unlock()
+
+	r.replyQueue.wakeAll()
+	<-r.done
+	r.pending.failAll(ErrRequesterClosed)
+	return nil
+}
+
+// ConnectionState 返回请求器状态，进程内代理在请求器关闭前始终可用
+func (r *InMemoryRequester) ConnectionState() ConnectionState {
+	if r.isClosed() {
+		return StateClosed
+	}
+	return StateConnected
+}
+
+// NotifyConnectionState 进程内代理不会断开，不会产生状态变化事件
+func (r *InMemoryRequester) NotifyConnectionState(ch chan ConnectionEvent) chan ConnectionEvent {
+	return ch
+}
+
+// sendReply 将响应直接放入请求方的响应队列，等同于通过默认交换器发布
+func (s *InMemorySubscriber) sendReply(ctx context.Context, replyTo string, md Metadata, body []byte, headers map[string]interface{}) error {
+	q, err := s.broker.queue(replyTo)
+	if err != nil {
+		return err
+	}
+
+	q.push(&memDelivery{
+		routingKey: replyTo,
+		body:       body,
+		headers:    headers,
+		timestamp:  md.OccurredAt,
+	})
+	return nil
+}
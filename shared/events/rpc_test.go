@@ -0,0 +1,154 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"ride-sharing/shared/contracts"
+	eventspb "ride-sharing/shared/proto/events"
+)
+
+// sentReply 记录respondHandler发送的响应
+type sentReply struct {
+	replyTo string
+	md      Metadata
+	body    []byte
+	headers map[string]interface{}
+}
+
+func TestRespondHandlerReplies(t *testing.T) {
+	summary := &eventspb.TripSummary{TripID: "trip-1", UserID: "rider-1", Status: "searching"}
+	request := Envelope{
+		Metadata: Metadata{
+			EventID:     "request-1",
+			EventType:   contracts.TripQueryGet,
+			Producer:    "api-gateway",
+			ContentType: ContentTypeProtobuf,
+		},
+		ReplyTo:   "reply-queue",
+		RequestID: "request-1",
+	}
+
+	tests := []struct {
+		name        string
+		responder   Responder
+		contentType string
+		replyError  string
+	}{
+		{
+			name: "reply uses request content type",
+			responder: func(ctx context.Context, env Envelope) (interface{}, error) {
+				return summary, nil
+			},
+			contentType: ContentTypeProtobuf,
+		},
+		{
+			name: "handler error keeps its message",
+			responder: func(ctx context.Context, env Envelope) (interface{}, error) {
+				return nil, errors.New("行程不存在")
+			},
+			contentType: ContentTypeJSON,
+			replyError:  "行程不存在",
+		},
+		{
+			name: "result that cannot use request content type",
+			responder: func(ctx context.Context, env Envelope) (interface{}, error) {
+				return contracts.TripSummary{TripID: "trip-1"}, nil
+			},
+			contentType: ContentTypeJSON,
+			replyError:  ErrNotProtoMessage.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent []sentReply
+			send := func(ctx context.Context, replyTo string, md Metadata, body []byte, headers map[string]interface{}) error {
+				sent = append(sent, sentReply{replyTo, md, body, headers})
+				return nil
+			}
+
+			handler := respondHandler(tt.responder, "trip-service", send)
+			if err := handler(context.Background(), request); err != nil {
+				t.Fatalf("handler: %v", err)
+			}
+			if len(sent) != 1 {
+				t.Fatalf("got %d replies, want 1", len(sent))
+			}
+
+			reply := sent[0]
+			if reply.replyTo != "reply-queue" {
+				t.Errorf("got reply queue %q", reply.replyTo)
+			}
+			if reply.md.Producer != "trip-service" || reply.headers[HeaderProducer] != "trip-service" {
+				t.Errorf("reply producer: metadata %q, header %v", reply.md.Producer, reply.headers[HeaderProducer])
+			}
+			if reply.md.EventType != contracts.TripQueryGet+ReplySuffix {
+				t.Errorf("got event type %q", reply.md.EventType)
+			}
+			if reply.headers[HeaderRequestID] != "request-1" {
+				t.Errorf("got request ID %v", reply.headers[HeaderRequestID])
+			}
+			if reply.md.ContentType != tt.contentType {
+				t.Errorf("got content type %q, want %q", reply.md.ContentType, tt.contentType)
+			}
+
+			replyErr, _ := reply.headers[HeaderReplyError].(string)
+			if tt.replyError == "" && replyErr != "" {
+				t.Errorf("unexpected reply error %q", replyErr)
+			}
+			if !strings.Contains(replyErr, tt.replyError) {
+				t.Errorf("got reply error %q, want it to contain %q", replyErr, tt.replyError)
+			}
+
+			var msg contracts.AmqpMessage
+			if err := json.Unmarshal(reply.body, &msg); err != nil {
+				t.Fatalf("decode reply: %v", err)
+			}
+			if msg.OwnerID != "trip-service" {
+				t.Errorf("got envelope owner %q", msg.OwnerID)
+			}
+		})
+	}
+}
+
+func TestInMemoryRequestReply(t *testing.T) {
+	broker := NewInMemoryBroker()
+	subscriber := NewInMemorySubscriber(broker, contracts.TripExchange, "trip-service")
+	defer subscriber.Close()
+
+	err := RespondTyped(subscriber, contracts.TripQueryQueue, contracts.TripQueryGet,
+		func(ctx context.Context, env Envelope, query *eventspb.TripQuery) (interface{}, error) {
+			if query.GetTripID() != "trip-1" {
+				return nil, errors.New("行程不存在")
+			}
+			return &eventspb.TripSummary{TripID: query.GetTripID(), UserID: "rider-1", Status: "searching"}, nil
+		})
+	if err != nil {
+		t.Fatalf("Respond: %v", err)
+	}
+
+	requester := NewInMemoryRequester(broker, contracts.TripExchange, "api-gateway", DefaultRequesterOptions())
+	defer requester.Close()
+
+	ctx := WithContentType(context.Background(), ContentTypeProtobuf)
+	reply, err := Call[*eventspb.TripSummary](ctx, requester, contracts.TripQueryGet, &eventspb.TripQuery{TripID: "trip-1"})
+	if err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	want := &eventspb.TripSummary{TripID: "trip-1", UserID: "rider-1", Status: "searching"}
+	if !proto.Equal(reply, want) {
+		t.Errorf("got %v, want %v", reply, want)
+	}
+
+	_, err = Call[*eventspb.TripSummary](ctx, requester, contracts.TripQueryGet, &eventspb.TripQuery{TripID: "trip-2"})
+	var remote *RemoteError
+	if !errors.As(err, &remote) || remote.Message != "行程不存在" {
+		t.Errorf("got %v, want RemoteError 行程不存在", err)
+	}
+}
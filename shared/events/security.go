@@ -0,0 +1,476 @@
+package events
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ride-sharing/shared/contracts"
+)
+
+// 消息签名与加密
+//
+// 安全策略按路由键模式要求消息签名或加密负载，所有后端在编码和解析信封时统一处理：
+//   - 签名：HMAC-SHA256，覆盖信封元数据和负载，负载加密时对密文签名
+//   - 加密：AES-GCM，只加密负载，元数据保持明文以便路由、重试和归档
+//
+// 使用的密钥ID写入信封并镜像到消息头，每个密钥环保存多个密钥以支持轮换：
+// 先将新密钥加入所有消费者的密钥环，再在生产者中设为当前密钥，在途消息全部消费后删除旧密钥。
+//
+// 要求签名的路由键上缺少签名或签名无效的消息、要求加密的路由键上的明文消息会被永久拒绝，
+// 按订阅选项进入死信队列。未配置签名密钥的消费者不验证签名。
+
+// 签名和加密使用的密钥ID在消息头中的镜像
+const (
+	HeaderSigningKeyID    = "x-signing-key-id"
+	HeaderEncryptionKeyID = "x-encryption-key-id"
+)
+
+var (
+	// ErrUnsigned 要求签名的消息缺少签名
+	ErrUnsigned = errors.New("消息缺少签名")
+	// ErrBadSignature 消息签名与内容不一致，消息可能被篡改
+	ErrBadSignature = errors.New("消息签名无效")
+	// ErrUnencrypted 要求加密的消息负载为明文
+	ErrUnencrypted = errors.New("消息负载未加密")
+	// ErrUnknownKey 密钥环中没有消息使用的密钥
+	ErrUnknownKey = errors.New("未知的密钥ID")
+)
+
+// Keyring 按ID保存的密钥，新消息使用当前密钥，验证和解密时可使用其中任一密钥
+type Keyring struct {
+	mu     sync.RWMutex
+	active string
+	keys   map[string][]byte
+}
+
+// NewKeyring 创建空的密钥环
+func NewKeyring() *Keyring {
+	return &Keyring{keys: make(map[string][]byte)}
+}
+
+// Add 添加密钥，第一个添加的密钥成为当前密钥，其余密钥只用于验证和解密
+func (k *Keyring) Add(id string, key []byte) error {
+	if id == "" || len(key) == 0 {
+		return errors.New("密钥ID和密钥不能为空")
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[id] = append([]byte(nil), key...)
+	if k.active == "" {
+		k.active = id
+	}
+	return nil
+}
+
+// Rotate 添加密钥并设为当前密钥，原来的密钥保留，用于处理在途消息
+func (k *Keyring) Rotate(id string, key []byte) error {
+	if err := k.Add(id, key); err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.active = id
+	return nil
+}
+
+// Retire 删除不再使用的密钥，当前密钥不能删除
+func (k *Keyring) Retire(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if id == k.active {
+		return fmt.Errorf("不能删除当前密钥: %s", id)
+	}
+	delete(k.keys, id)
+	return nil
+}
+
+// Active 返回当前密钥的ID
+func (k *Keyring) Active() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// activeKey 返回当前密钥
+func (k *Keyring) activeKey() (string, []byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if k.active == "" {
+		return "", nil, errors.New("密钥环为空")
+	}
+	return k.active, k.keys[k.active], nil
+}
+
+// key 按ID查找密钥
+func (k *Keyring) key(id string) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	return key, nil
+}
+
+// each 依次检查每个密钥
+func (k *Keyring) each(fn func(id string, key []byte) error) error {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	for id, key := range k.keys {
+		if err := fn(id, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SecurityPolicy 消息签名与加密策略
+type SecurityPolicy struct {
+	// SigningKeys HMAC签名密钥，每个密钥至少32字节
+	SigningKeys *Keyring
+	// EncryptionKeys AES-GCM加密密钥，每个密钥为16、24或32字节
+	EncryptionKeys *Keyring
+	// Signed 必须签名的路由键模式，支持 * 和 # 通配符
+	Signed []string
+	// Encrypted 必须加密负载的路由键模式，加密的消息同时签名
+	// 请求/响应的响应路由键为请求路由键加 .reply 后缀，需要单独配置
+	Encrypted []string
+}
+
+// Validate 检查策略配置的路由键是否有可用的密钥
+func (p *SecurityPolicy) Validate() error {
+	if len(p.Signed) > 0 || len(p.Encrypted) > 0 {
+		if p.SigningKeys == nil || p.SigningKeys.Active() == "" {
+			return errors.New("要求签名或加密的路由键缺少签名密钥")
+		}
+	}
+	if len(p.Encrypted) > 0 && (p.EncryptionKeys == nil || p.EncryptionKeys.Active() == "") {
+		return errors.New("要求加密的路由键缺少加密密钥")
+	}
+
+	if p.SigningKeys != nil {
+		err := p.SigningKeys.each(func(id string, key []byte) error {
+			if len(key) < sha256.Size {
+				return fmt.Errorf("签名密钥 %s 长度不足%d字节", id, sha256.Size)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if p.EncryptionKeys != nil {
+		err := p.EncryptionKeys.each(func(id string, key []byte) error {
+			if _, err := aes.NewCipher(key); err != nil {
+				return fmt.Errorf("加密密钥 %s 无效: %w", id, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requires 判断路由键是否要求签名或加密，任一路由键匹配即要求
+func (p *SecurityPolicy) requires(routingKeys ...string) (signed, encrypted bool) {
+	if p == nil {
+		return false, false
+	}
+	for _, rk := range routingKeys {
+		if rk == "" {
+			continue
+		}
+		if matchAny(p.Encrypted, rk) {
+			return true, true
+		}
+		if matchAny(p.Signed, rk) {
+			signed = true
+		}
+	}
+	return signed, false
+}
+
+// seal 按消息的事件类型加密负载并签名
+func (p *SecurityPolicy) seal(msg *contracts.AmqpMessage) error {
+	signed, encrypted := p.requires(msg.EventType)
+	if encrypted {
+		id, key, err := p.EncryptionKeys.activeKey()
+		if err != nil {
+			return fmt.Errorf("加密消息负载失败: %w", err)
+		}
+		if msg.Data, err = encryptPayload(key, msg.EventID, msg.Data); err != nil {
+			return fmt.Errorf("加密消息负载失败: %w", err)
+		}
+		msg.EncryptionKeyID = id
+	}
+
+	if signed {
+		id, key, err := p.SigningKeys.activeKey()
+		if err != nil {
+			return fmt.Errorf("签名消息失败: %w", err)
+		}
+		msg.SigningKeyID = id
+		msg.Signature = signMessage(key, msg)
+	}
+	return nil
+}
+
+// open 验证签名并解密负载，routingKeys为消息投递和记录的路由键
+// 消息中的事件类型同样参与判断，伪造的路由键消息头或事件类型不能绕过策略
+func (p *SecurityPolicy) open(msg *contracts.AmqpMessage, routingKeys ...string) error {
+	signed, encrypted := p.requires(append(routingKeys, msg.EventType)...)
+
+	switch {
+	case msg.Signature == nil:
+		if signed {
+			return fmt.Errorf("%w: %s", ErrUnsigned, msg.EventType)
+		}
+	case p != nil && p.SigningKeys != nil:
+		key, err := p.SigningKeys.key(msg.SigningKeyID)
+		if err != nil {
+			return fmt.Errorf("验证消息签名失败: %w", err)
+		}
+		if !hmac.Equal(msg.Signature, signMessage(key, msg)) {
+			return fmt.Errorf("%w: %s, 事件ID=%s", ErrBadSignature, msg.EventType, msg.EventID)
+		}
+	}
+
+	if msg.EncryptionKeyID == "" {
+		if encrypted {
+			return fmt.Errorf("%w: %s", ErrUnencrypted, msg.EventType)
+		}
+		return nil
+	}
+	if p == nil || p.EncryptionKeys == nil {
+		return fmt.Errorf("解密消息负载失败: %w: %s", ErrUnknownKey, msg.EncryptionKeyID)
+	}
+	key, err := p.EncryptionKeys.key(msg.EncryptionKeyID)
+	if err != nil {
+		return fmt.Errorf("解密消息负载失败: %w", err)
+	}
+	if msg.Data, err = decryptPayload(key, msg.EventID, msg.Data); err != nil {
+		return fmt.Errorf("解密消息负载失败: %w", err)
+	}
+	return nil
+}
+
+// SealPayload 使用当前安全策略的加密密钥加密需要落盘的负载，例如归档记录，返回使用的密钥ID
+// 事件ID作为附加数据，解密时必须使用同一事件ID
+func SealPayload(eventID string, plaintext []byte) (string, []byte, error) {
+	p := currentSecurity()
+	if p == nil || p.EncryptionKeys == nil {
+		return "", nil, errors.New("未配置加密密钥")
+	}
+	id, key, err := p.EncryptionKeys.activeKey()
+	if err != nil {
+		return "", nil, err
+	}
+	sealed, err := encryptPayload(key, eventID, plaintext)
+	if err != nil {
+		return "", nil, err
+	}
+	return id, sealed, nil
+}
+
+// OpenPayload 解密SealPayload加密的负载，密钥环中需要仍保留加密时使用的密钥
+func OpenPayload(keyID, eventID string, ciphertext []byte) ([]byte, error) {
+	p := currentSecurity()
+	if p == nil || p.EncryptionKeys == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+	key, err := p.EncryptionKeys.key(keyID)
+	if err != nil {
+		return nil, err
+	}
+	return decryptPayload(key, eventID, ciphertext)
+}
+
+// signMessage 计算信封的HMAC-SHA256，每个字段带长度前缀，避免字段边界被移动
+func signMessage(key []byte, msg *contracts.AmqpMessage) []byte {
+	mac := hmac.New(sha256.New, key)
+	var size [4]byte
+	for _, field := range []string{
+		"v1",
+		msg.EventID,
+		msg.EventType,
+		strconv.Itoa(msg.SchemaVersion),
+		msg.OwnerID,
+		msg.CorrelationID,
+		msg.CausationID,
+		msg.OccurredAt.UTC().Format(time.RFC3339Nano),
+		msg.ContentType,
+		msg.EncryptionKeyID,
+		msg.SigningKeyID,
+		string(msg.Data),
+	} {
+		binary.BigEndian.PutUint32(size[:], uint32(len(field)))
+		mac.Write(size[:])
+		mac.Write([]byte(field))
+	}
+	return mac.Sum(nil)
+}
+
+// encryptPayload 使用AES-GCM加密负载，随机nonce写在密文之前，事件ID作为附加数据
+func encryptPayload(key []byte, eventID string, plaintext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, []byte(eventID)), nil
+}
+
+// decryptPayload 解密encryptPayload生成的负载
+func decryptPayload(key []byte, eventID string, ciphertext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("密文长度不足")
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, []byte(eventID))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// matchAny 判断路由键是否匹配任一模式
+func matchAny(patterns []string, routingKey string) bool {
+	for _, pattern := range patterns {
+		if MatchRoutingKey(pattern, routingKey) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	securityMu sync.RWMutex
+	security   *SecurityPolicy
+)
+
+// SetSecurityPolicy 设置进程内所有发布器和订阅器使用的安全策略，nil表示不签名也不加密
+func SetSecurityPolicy(p *SecurityPolicy) error {
+	if p != nil {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+
+	securityMu.Lock()
+	defer securityMu.Unlock()
+	security = p
+	return nil
+}
+
+// currentSecurity 返回当前的安全策略，未设置时为nil
+func currentSecurity() *SecurityPolicy {
+	securityMu.RLock()
+	defer securityMu.RUnlock()
+	return security
+}
+
+// SecurityPolicyFromEnv 从环境变量读取安全策略，没有配置任何密钥时返回nil
+//
+//	EVENTS_SIGNING_KEYS            签名密钥，格式为 id:base64密钥，逗号分隔，第一个为当前密钥
+//	EVENTS_ENCRYPTION_KEYS         加密密钥，格式同上
+//	EVENTS_SIGNED_ROUTING_KEYS     必须签名的路由键模式，逗号分隔
+//	EVENTS_ENCRYPTED_ROUTING_KEYS  必须加密的路由键模式，逗号分隔
+func SecurityPolicyFromEnv() (*SecurityPolicy, error) {
+	signing, err := parseKeyring(getEnv("EVENTS_SIGNING_KEYS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("解析EVENTS_SIGNING_KEYS失败: %w", err)
+	}
+	encryption, err := parseKeyring(getEnv("EVENTS_ENCRYPTION_KEYS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("解析EVENTS_ENCRYPTION_KEYS失败: %w", err)
+	}
+
+	p := &SecurityPolicy{
+		SigningKeys:    signing,
+		EncryptionKeys: encryption,
+		Signed:         splitList(getEnv("EVENTS_SIGNED_ROUTING_KEYS", "")),
+		Encrypted:      splitList(getEnv("EVENTS_ENCRYPTED_ROUTING_KEYS", "")),
+	}
+	if p.SigningKeys == nil && p.EncryptionKeys == nil && len(p.Signed) == 0 && len(p.Encrypted) == 0 {
+		return nil, nil
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ConfigureSecurityFromEnv 从环境变量读取并设置安全策略，应在创建发布器和订阅器之前调用
+func ConfigureSecurityFromEnv() error {
+	p, err := SecurityPolicyFromEnv()
+	if err != nil {
+		return err
+	}
+	return SetSecurityPolicy(p)
+}
+
+// parseKeyring 解析 id:base64密钥 列表，为空时返回nil
+func parseKeyring(value string) (*Keyring, error) {
+	entries := splitList(value)
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	keyring := NewKeyring()
+	for _, entry := range entries {
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, errors.New("密钥格式应为 id:base64密钥")
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("密钥 %s 不是有效的base64: %w", id, err)
+		}
+		if err := keyring.Add(id, key); err != nil {
+			return nil, err
+		}
+	}
+	return keyring, nil
+}
+
+// splitList 拆分逗号分隔的列表，忽略空白项
+func splitList(value string) []string {
+	var out []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
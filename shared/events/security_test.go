@@ -0,0 +1,333 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"ride-sharing/shared/contracts"
+)
+
+func testKey(seed byte, size int) []byte {
+	return bytes.Repeat([]byte{seed}, size)
+}
+
+// testPolicy trip.event.* 必须签名，payment.event.* 必须加密
+func testPolicy(t *testing.T) *SecurityPolicy {
+	t.Helper()
+	signing := NewKeyring()
+	encryption := NewKeyring()
+	if err := signing.Add("sign-1", testKey(1, 32)); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := encryption.Add("enc-1", testKey(2, 32)); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	return &SecurityPolicy{
+		SigningKeys:    signing,
+		EncryptionKeys: encryption,
+		Signed:         []string{"trip.event.*"},
+		Encrypted:      []string{"payment.event.*"},
+	}
+}
+
+// withSecurity 在测试期间使用指定的安全策略
+func withSecurity(t *testing.T, p *SecurityPolicy) {
+	t.Helper()
+	if err := SetSecurityPolicy(p); err != nil {
+		t.Fatalf("SetSecurityPolicy: %v", err)
+	}
+	t.Cleanup(func() { SetSecurityPolicy(nil) })
+}
+
+// sealedMessage 按当前策略编码消息并解析出AMQP消息
+func sealedMessage(t *testing.T, routingKey string, data interface{}) contracts.AmqpMessage {
+	t.Helper()
+	md := newMetadata(context.Background(), "security-test", routingKey)
+	body, err := encodeMessage(context.Background(), &md, data)
+	if err != nil {
+		t.Fatalf("encodeMessage: %v", err)
+	}
+	var msg contracts.AmqpMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	return msg
+}
+
+func decodeMessage(msg contracts.AmqpMessage, routingKey string, headers map[string]interface{}) (Envelope, error) {
+	body, _ := json.Marshal(msg)
+	return decodeEnvelope(body, routingKey, headers, false)
+}
+
+func TestSecurityRoundTrip(t *testing.T) {
+	withSecurity(t, testPolicy(t))
+	payload := map[string]string{"tripID": "trip-1"}
+
+	tests := []struct {
+		routingKey string
+		signed     bool
+		encrypted  bool
+	}{
+		{"trip.event.created", true, false},
+		{"payment.event.success", true, true},
+		{"driver.cmd.trip_request", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.routingKey, func(t *testing.T) {
+			msg := sealedMessage(t, tt.routingKey, payload)
+			if (msg.Signature != nil) != tt.signed || (msg.EncryptionKeyID != "") != tt.encrypted {
+				t.Fatalf("got signature %v and encryption key %q", msg.Signature != nil, msg.EncryptionKeyID)
+			}
+			if tt.encrypted && bytes.Contains(msg.Data, []byte("trip-1")) {
+				t.Errorf("encrypted payload contains plaintext: %s", msg.Data)
+			}
+
+			env, err := decodeMessage(msg, tt.routingKey, nil)
+			if err != nil {
+				t.Fatalf("decodeEnvelope: %v", err)
+			}
+			var got map[string]string
+			if err := json.Unmarshal(env.Data, &got); err != nil || got["tripID"] != "trip-1" {
+				t.Errorf("got payload %s, %v", env.Data, err)
+			}
+			if env.SigningKeyID != msg.SigningKeyID || env.EncryptionKeyID != msg.EncryptionKeyID {
+				t.Errorf("got key IDs %q/%q, want %q/%q", env.SigningKeyID, env.EncryptionKeyID, msg.SigningKeyID, msg.EncryptionKeyID)
+			}
+		})
+	}
+}
+
+func TestSecurityTampering(t *testing.T) {
+	withSecurity(t, testPolicy(t))
+
+	tests := []struct {
+		name       string
+		routingKey string
+		tamper     func(msg *contracts.AmqpMessage)
+	}{
+		{"data", "trip.event.created", func(msg *contracts.AmqpMessage) { msg.Data = []byte(`{"tripID":"trip-2"}`) }},
+		{"event type", "trip.event.created", func(msg *contracts.AmqpMessage) { msg.EventType = "trip.event.cancelled" }},
+		{"event id", "trip.event.created", func(msg *contracts.AmqpMessage) { msg.EventID = "other" }},
+		{"producer", "trip.event.created", func(msg *contracts.AmqpMessage) { msg.OwnerID = "attacker" }},
+		{"correlation id", "trip.event.created", func(msg *contracts.AmqpMessage) { msg.CorrelationID = "other" }},
+		{"content type", "trip.event.created", func(msg *contracts.AmqpMessage) { msg.ContentType = ContentTypeProtobuf }},
+		{"ciphertext", "payment.event.success", func(msg *contracts.AmqpMessage) { msg.Data[len(msg.Data)-1] ^= 1 }},
+		{"encryption key id removed", "payment.event.success", func(msg *contracts.AmqpMessage) { msg.EncryptionKeyID = "" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := sealedMessage(t, tt.routingKey, map[string]string{"tripID": "trip-1"})
+			tt.tamper(&msg)
+
+			_, err := decodeMessage(msg, tt.routingKey, nil)
+			if !errors.Is(err, ErrBadSignature) {
+				t.Errorf("got %v, want ErrBadSignature", err)
+			}
+			if !IsPermanent(err) {
+				t.Errorf("tampered message is not rejected permanently: %v", err)
+			}
+		})
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	withSecurity(t, testPolicy(t))
+	msg := sealedMessage(t, "trip.event.created", map[string]string{"tripID": "trip-1"})
+
+	// 消息头中的密钥ID镜像未经签名，验证只使用消息体中的密钥ID
+	env, err := decodeMessage(msg, "trip.event.created", map[string]interface{}{
+		HeaderSigningKeyID: "forged",
+		HeaderEventType:    "trip.event.cancelled",
+	})
+	if err != nil {
+		t.Fatalf("decodeEnvelope: %v", err)
+	}
+	if env.SigningKeyID != "sign-1" || env.EventType != "trip.event.created" {
+		t.Errorf("got key %q and type %q from forged headers", env.SigningKeyID, env.EventType)
+	}
+
+	// 重试消息记录的原始路由键同样参与策略判断，伪造的路由键不能绕过签名要求
+	unsigned := contracts.AmqpMessage{EventID: "event-1", EventType: "driver.cmd.trip_request", Data: []byte(`{}`)}
+	_, err = decodeMessage(unsigned, "trip", map[string]interface{}{HeaderOriginalRoutingKey: "trip.event.created"})
+	if !errors.Is(err, ErrUnsigned) {
+		t.Errorf("got %v, want ErrUnsigned", err)
+	}
+}
+
+func TestSecurityRejectsUnprotectedMessages(t *testing.T) {
+	// 生产者未配置策略时发出的明文消息
+	plain := sealedMessage(t, "payment.event.success", map[string]string{"tripID": "trip-1"})
+	unsignedTrip := sealedMessage(t, "trip.event.created", map[string]string{"tripID": "trip-1"})
+
+	withSecurity(t, testPolicy(t))
+
+	tests := []struct {
+		name       string
+		msg        contracts.AmqpMessage
+		routingKey string
+		err        error
+	}{
+		{"unsigned", unsignedTrip, "trip.event.created", ErrUnsigned},
+		{"plaintext on encrypted key", plain, "payment.event.success", ErrUnsigned},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeMessage(tt.msg, tt.routingKey, nil); !errors.Is(err, tt.err) {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	// 签名有效但负载为明文的消息同样拒绝
+	signedPlain := contracts.AmqpMessage{EventID: "event-1", EventType: "payment.event.success", Data: []byte(`{}`)}
+	if err := (&SecurityPolicy{SigningKeys: testPolicy(t).SigningKeys, Signed: []string{"#"}}).seal(&signedPlain); err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if _, err := decodeMessage(signedPlain, "payment.event.success", nil); !errors.Is(err, ErrUnencrypted) {
+		t.Errorf("got %v, want ErrUnencrypted", err)
+	}
+
+	// 未知密钥签名的消息
+	foreign := testPolicy(t)
+	foreign.SigningKeys = NewKeyring()
+	foreign.SigningKeys.Add("sign-other", testKey(9, 32))
+	msg := contracts.AmqpMessage{EventID: "event-1", EventType: "trip.event.created", Data: []byte(`{}`)}
+	if err := foreign.seal(&msg); err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if _, err := decodeMessage(msg, "trip.event.created", nil); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("got %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestSecurityKeyRotation(t *testing.T) {
+	p := testPolicy(t)
+	withSecurity(t, p)
+	old := sealedMessage(t, "payment.event.success", map[string]string{"tripID": "trip-1"})
+
+	if err := p.SigningKeys.Rotate("sign-2", testKey(3, 32)); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if err := p.EncryptionKeys.Rotate("enc-2", testKey(4, 32)); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	current := sealedMessage(t, "payment.event.success", map[string]string{"tripID": "trip-1"})
+	if current.SigningKeyID != "sign-2" || current.EncryptionKeyID != "enc-2" {
+		t.Errorf("got keys %q/%q after rotation, want sign-2/enc-2", current.SigningKeyID, current.EncryptionKeyID)
+	}
+
+	// 轮换后在途的旧消息仍可验证和解密
+	for _, msg := range []contracts.AmqpMessage{old, current} {
+		env, err := decodeMessage(msg, "payment.event.success", nil)
+		if err != nil {
+			t.Fatalf("decode message signed with %s: %v", msg.SigningKeyID, err)
+		}
+		if !bytes.Contains(env.Data, []byte("trip-1")) {
+			t.Errorf("got payload %s", env.Data)
+		}
+	}
+
+	// 当前密钥不能删除；删除旧密钥后旧消息无法验证
+	if err := p.SigningKeys.Retire("sign-2"); err == nil {
+		t.Error("retiring the active signing key succeeded")
+	}
+	if err := p.EncryptionKeys.Retire("enc-2"); err == nil {
+		t.Error("retiring the active encryption key succeeded")
+	}
+	if err := p.SigningKeys.Retire("sign-1"); err != nil {
+		t.Fatalf("Retire: %v", err)
+	}
+	if _, err := decodeMessage(old, "payment.event.success", nil); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("got %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestSealPayload(t *testing.T) {
+	if _, _, err := SealPayload("event-1", []byte("secret")); err == nil {
+		t.Error("SealPayload without encryption keys succeeded")
+	}
+
+	withSecurity(t, testPolicy(t))
+	keyID, sealed, err := SealPayload("event-1", []byte("secret"))
+	if err != nil {
+		t.Fatalf("SealPayload: %v", err)
+	}
+	if keyID != "enc-1" || bytes.Contains(sealed, []byte("secret")) {
+		t.Fatalf("got key %q and payload %q", keyID, sealed)
+	}
+	if got, err := OpenPayload(keyID, "event-1", sealed); err != nil || string(got) != "secret" {
+		t.Errorf("OpenPayload = %q, %v", got, err)
+	}
+	// 事件ID作为附加数据，记录被移到其他事件下时无法解密
+	if _, err := OpenPayload(keyID, "event-2", sealed); err == nil {
+		t.Error("OpenPayload with another event ID succeeded")
+	}
+}
+
+func TestSecurityPolicyFromEnv(t *testing.T) {
+	signingKey := base64.StdEncoding.EncodeToString(testKey(1, 32))
+	encryptionKey := base64.StdEncoding.EncodeToString(testKey(2, 32))
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantNil bool
+		err     string
+	}{
+		{name: "not configured", wantNil: true},
+		{
+			name: "signing and encryption",
+			env: map[string]string{
+				"EVENTS_SIGNING_KEYS":           "sign-1:" + signingKey + ", sign-0:" + signingKey,
+				"EVENTS_ENCRYPTION_KEYS":        "enc-1:" + encryptionKey,
+				"EVENTS_SIGNED_ROUTING_KEYS":    "trip.event.*, driver.cmd.#",
+				"EVENTS_ENCRYPTED_ROUTING_KEYS": "payment.event.*",
+			},
+		},
+		{name: "missing separator", env: map[string]string{"EVENTS_SIGNING_KEYS": signingKey}, err: "EVENTS_SIGNING_KEYS"},
+		{name: "invalid base64", env: map[string]string{"EVENTS_ENCRYPTION_KEYS": "enc-1:not base64!"}, err: "EVENTS_ENCRYPTION_KEYS"},
+		{name: "empty key id", env: map[string]string{"EVENTS_SIGNING_KEYS": ":" + signingKey}, err: "EVENTS_SIGNING_KEYS"},
+		{name: "short signing key", env: map[string]string{"EVENTS_SIGNING_KEYS": "sign-1:" + encryptionKey[:8]}, err: "长度不足"},
+		{name: "invalid encryption key", env: map[string]string{"EVENTS_SIGNING_KEYS": "sign-1:" + signingKey, "EVENTS_ENCRYPTION_KEYS": "enc-1:" + base64.StdEncoding.EncodeToString(testKey(2, 20))}, err: "加密密钥"},
+		{name: "signed keys without signing key", env: map[string]string{"EVENTS_SIGNED_ROUTING_KEYS": "trip.event.*"}, err: "签名密钥"},
+		{name: "encrypted keys without encryption key", env: map[string]string{"EVENTS_SIGNING_KEYS": "sign-1:" + signingKey, "EVENTS_ENCRYPTED_ROUTING_KEYS": "payment.event.*"}, err: "加密密钥"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"EVENTS_SIGNING_KEYS", "EVENTS_ENCRYPTION_KEYS", "EVENTS_SIGNED_ROUTING_KEYS", "EVENTS_ENCRYPTED_ROUTING_KEYS"} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			p, err := SecurityPolicyFromEnv()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want error containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SecurityPolicyFromEnv: %v", err)
+			}
+			if (p == nil) != tt.wantNil {
+				t.Fatalf("got policy %+v, want nil %v", p, tt.wantNil)
+			}
+			if p == nil {
+				return
+			}
+			if p.SigningKeys.Active() != "sign-1" || p.EncryptionKeys.Active() != "enc-1" {
+				t.Errorf("got active keys %q/%q", p.SigningKeys.Active(), p.EncryptionKeys.Active())
+			}
+			if len(p.Signed) != 2 || p.Signed[1] != "driver.cmd.#" || len(p.Encrypted) != 1 {
+				t.Errorf("got routing keys %v / %v", p.Signed, p.Encrypted)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// DefaultShutdownTimeout 优雅关闭时等待在途消息处理完成的默认时长
+const DefaultShutdownTimeout = 15 * time.Second
+
+// GracefulShutdowner 支持优雅关闭的组件
+type GracefulShutdowner interface {
+	// Shutdown 停止接收新消息，等待在途消息处理完成或ctx到期后关闭通道和连接
+	Shutdown(ctx context.Context) error
+}
+
+// Shutdown 优雅关闭事件组件，不支持优雅关闭的组件直接关闭
+// 超时后仍未处理完的消息没有被确认，代理会在连接关闭后将其重新投递给其他消费者
+func Shutdown(ctx context.Context, component interface{ Close() error }) error {
+	if s, ok := component.(GracefulShutdowner); ok {
+		return s.Shutdown(ctx)
+	}
+	return component.Close()
+}
+
+// waitDone 等待所有消费者的处理协程退出，ctx到期时返回错误
+func waitDone(ctx context.Context, dones []<-chan struct{}) error {
+	for _, done := range dones {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return fmt.Errorf("等待在途消息处理完成超时: %w", ctx.Err())
+		}
+	}
+	return nil
+}
+
+// logShutdown 记录优雅关闭的结果
+func logShutdown(name string, started time.Time, err error) {
+	if err != nil {
+		log.Printf("%s未能在期限内处理完在途消息: %v", name, err)
+		return
+	}
+	log.Printf("%s已处理完在途消息并关闭，耗时 %v", name, time.Since(started))
+}
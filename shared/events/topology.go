@@ -0,0 +1,434 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/rabbitmq/amqp091-go"
+
+	"ride-sharing/shared/contracts"
+	"ride-sharing/shared/retry"
+)
+
+// ExchangeSpec 交换器声明
+type ExchangeSpec struct {
+	Name string
+	// Kind 交换器类型，业务交换器均为topic
+	Kind string
+}
+
+// RouteSpec 一个路由键的发布方，用于校验每个绑定都能收到消息
+type RouteSpec struct {
+	RoutingKey string
+	Exchange   string
+	Producers  []string
+}
+
+// QueueSpec 订阅队列声明，重试队列、死信交换器和死信队列由重试策略推导
+type QueueSpec struct {
+	Name       string
+	Exchange   string
+	RoutingKey string
+	// Consumer 消费该队列的服务
+	Consumer   string
+	Retry      retry.Config
+	DeadLetter bool
+	// Tap 旁路队列以 # 绑定接收交换器上的全部消息，例如事件归档，不算作路由的消费方
+	Tap bool
+}
+
+// Options 返回该队列的订阅选项，调用方可以在此基础上设置去重、并发等消费参数
+func (q QueueSpec) Options() SubscribeOptions {
+	opts := DefaultSubscribeOptions()
+	opts.Retry = q.Retry
+	opts.DeadLetter = q.DeadLetter
+	opts.Exchange = q.Exchange
+	return opts
+}
+
+// Topology 整个系统的交换器、队列和绑定声明
+type Topology struct {
+	Exchanges []ExchangeSpec
+	Routes    []RouteSpec
+	Queues    []QueueSpec
+}
+
+// DefaultTopology 返回系统的消息拓扑，新增路由键或队列时在这里声明
+func DefaultTopology() *Topology {
+	retryPolicy := DefaultSubscribeOptions().Retry
+	queue := func(name, exchange, routingKey, consumer string) QueueSpec {
+		return QueueSpec{
+			Name:       name,
+			Exchange:   exchange,
+			RoutingKey: routingKey,
+			Consumer:   consumer,
+			Retry:      retryPolicy,
+			DeadLetter: true,
+		}
+	}
+	// 请求队列不重试也不进入死信队列，失败的请求以错误响应返回
+	request := func(name, exchange, routingKey, consumer string) QueueSpec {
+		return QueueSpec{
+			Name:       name,
+			Exchange:   exchange,
+			RoutingKey: routingKey,
+			Consumer:   consumer,
+		}
+	}
+	// 旁路队列接收交换器上的全部消息，不改变其他队列的路由
+	tap := func(name, exchange, consumer string) QueueSpec {
+		q := queue(name, exchange, "#", consumer)
+		q.Tap = true
+		return q
+	}
+
+	return &Topology{
+		Exchanges: []ExchangeSpec{
+			{Name: contracts.TripExchange, Kind: "topic"},
+			{Name: contracts.PaymentExchange, Kind: "topic"},
+		},
+		Routes: []RouteSpec{
+			{RoutingKey: contracts.TripEventCreated, Exchange: contracts.TripExchange, Producers: []string{"trip-service"}},
+			{RoutingKey: contracts.TripEventDriverAssigned, Exchange: contracts.TripExchange, Producers: []string{"trip-service"}},
+			{RoutingKey: contracts.TripEventNoDriversFound, Exchange: contracts.TripExchange, Producers: []string{"trip-service"}},
+			{RoutingKey: contracts.TripEventDriverNotInterested, Exchange: contracts.TripExchange, Producers: []string{"trip-service"}},
+			{RoutingKey: contracts.TripEventStatusChanged, Exchange: contracts.TripExchange, Producers: []string{"trip-service"}},
+			{RoutingKey: contracts.TripEventCancelled, Exchange: contracts.TripExchange, Producers: []string{"trip-service"}},
+			{RoutingKey: contracts.TripEventCompleted, Exchange: contracts.TripExchange, Producers: []string{"trip-service"}},
+			{RoutingKey: contracts.DriverCmdTripRequest, Exchange: contracts.TripExchange, Producers: []string{"driver-service"}},
+			{RoutingKey: contracts.DriverCmdTripAccept, Exchange: contracts.TripExchange, Producers: []string{"api-gateway"}},
+			{RoutingKey: contracts.DriverCmdTripDecline, Exchange: contracts.TripExchange, Producers: []string{"api-gateway"}},
+			{RoutingKey: contracts.DriverCmdLocation, Exchange: contracts.TripExchange, Producers: []string{"api-gateway", "driver-service"}},
+			{RoutingKey: contracts.DriverCmdTripRelease, Exchange: contracts.TripExchange, Producers: []string{"trip-service"}},
+			{RoutingKey: contracts.PaymentCmdCancelSession, Exchange: contracts.TripExchange, Producers: []string{"trip-service"}},
+			{RoutingKey: contracts.PaymentEventSessionCreated, Exchange: contracts.PaymentExchange, Producers: []string{"payment-service"}},
+			{RoutingKey: contracts.PaymentEventSuccess, Exchange: contracts.PaymentExchange, Producers: []string{"payment-service"}},
+			{RoutingKey: contracts.PaymentEventFailed, Exchange: contracts.PaymentExchange, Producers: []string{"payment-service"}},
+			{RoutingKey: contracts.PaymentEventCancelled, Exchange: contracts.PaymentExchange, Producers: []string{"payment-service"}},
+			{RoutingKey: contracts.TripQueryGet, Exchange: contracts.TripExchange, Producers: []string{"api-gateway"}},
+		},
+		Queues: []QueueSpec{
+			// driver-service
+			queue(contracts.FindAvailableDriversQueue, contracts.TripExchange, contracts.TripEventCreated, "driver-service"),
+			queue(contracts.DriverLocationUpdateQueue, contracts.TripExchange, contracts.DriverCmdLocation, "driver-service"),
+			queue(contracts.DriverAssignmentQueue, contracts.TripExchange, contracts.TripEventDriverAssigned, "driver-service"),
+			queue(contracts.DriverTripReleaseQueue, contracts.TripExchange, contracts.DriverCmdTripRelease, "driver-service"),
+
+			// trip-service
+			queue(contracts.DriverTripResponseQueue, contracts.TripExchange, contracts.DriverCmdTripAccept, "trip-service"),
+			queue(contracts.DriverTripDeclineQueue, contracts.TripExchange, contracts.DriverCmdTripDecline, "trip-service"),
+			queue(contracts.PaymentSuccessQueue, contracts.PaymentExchange, contracts.PaymentEventSuccess, "trip-service"),
+			queue(contracts.PaymentFailedQueue, contracts.PaymentExchange, contracts.PaymentEventFailed, "trip-service"),
+			request(contracts.TripQueryQueue, contracts.TripExchange, contracts.TripQueryGet, "trip-service"),
+			queue(contracts.TripDriverLocationQueue, contracts.TripExchange, contracts.DriverCmdLocation, "trip-service"),
+			queue(contracts.TripSagaCreatedQueue, contracts.TripExchange, contracts.TripEventCreated, "trip-service"),
+			queue(contracts.TripSagaDriverAssignedQueue, contracts.TripExchange, contracts.TripEventDriverAssigned, "trip-service"),
+			queue(contracts.TripSagaPaymentSessionQueue, contracts.PaymentExchange, contracts.PaymentEventSessionCreated, "trip-service"),
+			queue(contracts.TripSagaPaymentCancelledQueue, contracts.PaymentExchange, contracts.PaymentEventCancelled, "trip-service"),
+			queue(contracts.TripSagaCancelledQueue, contracts.TripExchange, contracts.TripEventCancelled, "trip-service"),
+
+			// payment-service
+			queue(contracts.CreatePaymentSessionQueue, contracts.TripExchange, contracts.TripEventDriverAssigned, "payment-service"),
+			queue(contracts.CancelPaymentSessionQueue, contracts.TripExchange, contracts.PaymentCmdCancelSession, "payment-service"),
+
+			// api-gateway
+			queue(contracts.NotifyNewTripQueue, contracts.TripExchange, contracts.TripEventCreated, "api-gateway"),
+			queue(contracts.NotifyDriverAssignmentQueue, contracts.TripExchange, contracts.TripEventDriverAssigned, "api-gateway"),
+			queue(contracts.NotifyNoDriversFoundQueue, contracts.TripExchange, contracts.TripEventNoDriversFound, "api-gateway"),
+			queue(contracts.NotifyDriversQueue, contracts.TripExchange, contracts.DriverCmdTripRequest, "api-gateway"),
+			queue(contracts.NotifyPaymentStatusQueue, contracts.PaymentExchange, contracts.PaymentEventSessionCreated, "api-gateway"),
+			queue(contracts.NotifyPaymentSuccessQueue, contracts.PaymentExchange, contracts.PaymentEventSuccess, "api-gateway"),
+			queue(contracts.NotifyPaymentFailedQueue, contracts.PaymentExchange, contracts.PaymentEventFailed, "api-gateway"),
+			queue(contracts.NotifyTripCancelledQueue, contracts.TripExchange, contracts.TripEventCancelled, "api-gateway"),
+			queue(contracts.NotifyTripCompletedQueue, contracts.TripExchange, contracts.TripEventCompleted, "api-gateway"),
+
+			// event-archiver
+			tap(contracts.TripArchiveQueue, contracts.TripExchange, "event-archiver"),
+			tap(contracts.PaymentArchiveQueue, contracts.PaymentExchange, "event-archiver"),
+		},
+	}
+}
+
+// Queue 按名称查找队列声明
+func (t *Topology) Queue(name string) (QueueSpec, bool) {
+	for _, q := range t.Queues {
+		if q.Name == name {
+			return q, true
+		}
+	}
+	return QueueSpec{}, false
+}
+
+// Exchange 按名称查找交换器声明
+func (t *Topology) Exchange(name string) (ExchangeSpec, bool) {
+	for _, e := range t.Exchanges {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return ExchangeSpec{}, false
+}
+
+// Validate 校验拓扑的一致性：名称不重复、引用的交换器已声明、
+// 每个绑定都有发布方在同一交换器上发布匹配的路由键
+func (t *Topology) Validate() error {
+	var errs []error
+
+	exchanges := make(map[string]bool)
+	for _, e := range t.Exchanges {
+		if e.Name == "" || e.Kind == "" {
+			errs = append(errs, fmt.Errorf("交换器声明缺少名称或类型: %+v", e))
+			continue
+		}
+		if exchanges[e.Name] {
+			errs = append(errs, fmt.Errorf("交换器重复声明: %s", e.Name))
+		}
+		exchanges[e.Name] = true
+	}
+
+	for _, r := range t.Routes {
+		if !exchanges[r.Exchange] {
+			errs = append(errs, fmt.Errorf("路由键 %s 发布到未声明的交换器: %s", r.RoutingKey, r.Exchange))
+		}
+	}
+
+	// 派生的重试队列和死信队列与订阅队列共用命名空间
+	queues := make(map[string]string)
+	claim := func(name, owner string) {
+		if prev, ok := queues[name]; ok {
+			errs = append(errs, fmt.Errorf("队列名称冲突: %s 同时属于 %s 和 %s", name, prev, owner))
+			return
+		}
+		queues[name] = owner
+	}
+
+	for _, q := range t.Queues {
+		if q.Name == "" || q.RoutingKey == "" {
+			errs = append(errs, fmt.Errorf("队列声明缺少名称或路由键: %+v", q))
+			continue
+		}
+		claim(q.Name, q.Name)
+		for _, delay := range q.Options().retryDelays() {
+			claim(retryQueueName(q.Name, delay), q.Name)
+		}
+		if q.DeadLetter {
+			claim(deadLetterQueueName(q.Name), q.Name)
+		}
+
+		if !exchanges[q.Exchange] {
+			errs = append(errs, fmt.Errorf("队列 %s 绑定到未声明的交换器: %s", q.Name, q.Exchange))
+			continue
+		}
+		if len(t.producersFor(q)) == 0 {
+			errs = append(errs, fmt.Errorf("队列 %s 在交换器 %s 上绑定 %s，但没有服务在该交换器上发布匹配的路由键", q.Name, q.Exchange, q.RoutingKey))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// producersFor 返回在队列绑定的交换器上发布匹配路由键的服务
+func (t *Topology) producersFor(q QueueSpec) []string {
+	var producers []string
+	for _, r := range t.Routes {
+		if r.Exchange == q.Exchange && MatchRoutingKey(q.RoutingKey, r.RoutingKey) {
+			producers = append(producers, r.Producers...)
+		}
+	}
+	return producers
+}
+
+// Unconsumed 返回除旁路队列外没有任何队列绑定的路由，这些消息不会被任何服务处理
+func (t *Topology) Unconsumed() []RouteSpec {
+	var routes []RouteSpec
+	for _, r := range t.Routes {
+		consumed := false
+		for _, q := range t.Queues {
+			if !q.Tap && q.Exchange == r.Exchange && MatchRoutingKey(q.RoutingKey, r.RoutingKey) {
+				consumed = true
+				break
+			}
+		}
+		if !consumed {
+			routes = append(routes, r)
+		}
+	}
+	return routes
+}
+
+// deadLetterExchanges 返回启用死信的队列所需的死信交换器，按名称排序
+func (t *Topology) deadLetterExchanges() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, q := range t.Queues {
+		if q.DeadLetter && !seen[q.Exchange] {
+			seen[q.Exchange] = true
+			names = append(names, deadLetterExchangeName(q.Exchange))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Declare 在通道上声明完整的拓扑，包括重试队列、死信交换器和死信队列
+func (t *Topology) Declare(ch *amqp091.Channel) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	for _, e := range t.Exchanges {
+		if err := ch.ExchangeDeclare(e.Name, e.Kind, true, false, false, false, nil); err != nil {
+			return fmt.Errorf("声明交换器 %s 失败: %w", e.Name, err)
+		}
+	}
+	for _, dlx := range t.deadLetterExchanges() {
+		if err := ch.ExchangeDeclare(dlx, "direct", true, false, false, false, nil); err != nil {
+			return fmt.Errorf("声明死信交换器 %s 失败: %w", dlx, err)
+		}
+	}
+
+	for _, q := range t.Queues {
+		if _, err := ch.QueueDeclare(q.Name, true, false, false, false, nil); err != nil {
+			return fmt.Errorf("声明队列 %s 失败: %w", q.Name, err)
+		}
+		if err := ch.QueueBind(q.Name, q.RoutingKey, q.Exchange, false, nil); err != nil {
+			return fmt.Errorf("绑定队列 %s 失败: %w", q.Name, err)
+		}
+		if err := declareRetryTopology(ch, q.Exchange, q.Name, q.Options()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateTopology 启动时校验系统拓扑，并确认配置的交换器已在拓扑中声明
+func ValidateTopology(cfg *Config) error {
+	topology := DefaultTopology()
+	if err := topology.Validate(); err != nil {
+		return fmt.Errorf("消息拓扑校验失败: %w", err)
+	}
+	if _, ok := topology.Exchange(cfg.Exchange); !ok {
+		return fmt.Errorf("交换器 %s 未在消息拓扑中声明", cfg.Exchange)
+	}
+	return nil
+}
+
+// SubscribeQueue 按拓扑中的声明订阅队列，队列未声明时返回错误
+func SubscribeQueue(s Subscriber, queueName string, handler Handler) error {
+	return SubscribeQueueWithOptions(s, queueName, handler, nil)
+}
+
+// SubscribeQueueWithOptions 按拓扑中的声明订阅队列，customize可以在声明的选项上调整消费参数
+func SubscribeQueueWithOptions(s Subscriber, queueName string, handler Handler, customize func(opts *SubscribeOptions)) error {
+	q, ok := DefaultTopology().Queue(queueName)
+	if !ok {
+		return fmt.Errorf("队列 %s 未在消息拓扑中声明", queueName)
+	}
+
+	opts := q.Options()
+	if customize != nil {
+		customize(&opts)
+	}
+	return s.SubscribeWithOptions(q.Name, q.RoutingKey, handler, opts)
+}
+
+// RespondQueue 按拓扑中的声明订阅请求队列并响应请求，队列未声明时返回错误
+func RespondQueue(s Subscriber, queueName string, responder Responder) error {
+	q, ok := DefaultTopology().Queue(queueName)
+	if !ok {
+		return fmt.Errorf("队列 %s 未在消息拓扑中声明", queueName)
+	}
+	return s.Respond(q.Name, q.RoutingKey, responder, q.Options())
+}
+
+// BrokerDefinitions RabbitMQ管理插件的定义文件格式，可通过 rabbitmqctl import_definitions 或 load_definitions 导入
+type BrokerDefinitions struct {
+	Exchanges []ExchangeDefinition `json:"exchanges"`
+	Queues    []QueueDefinition    `json:"queues"`
+	Bindings  []BindingDefinition  `json:"bindings"`
+}
+
+// ExchangeDefinition 定义文件中的交换器
+type ExchangeDefinition struct {
+	Name       string                 `json:"name"`
+	VHost      string                 `json:"vhost"`
+	Type       string                 `json:"type"`
+	Durable    bool                   `json:"durable"`
+	AutoDelete bool                   `json:"auto_delete"`
+	Internal   bool                   `json:"internal"`
+	Arguments  map[string]interface{} `json:"arguments"`
+}
+
+// QueueDefinition 定义文件中的队列
+type QueueDefinition struct {
+	Name       string                 `json:"name"`
+	VHost      string                 `json:"vhost"`
+	Durable    bool                   `json:"durable"`
+	AutoDelete bool                   `json:"auto_delete"`
+	Arguments  map[string]interface{} `json:"arguments"`
+}
+
+// BindingDefinition 定义文件中的绑定
+type BindingDefinition struct {
+	Source          string                 `json:"source"`
+	VHost           string                 `json:"vhost"`
+	Destination     string                 `json:"destination"`
+	DestinationType string                 `json:"destination_type"`
+	RoutingKey      string                 `json:"routing_key"`
+	Arguments       map[string]interface{} `json:"arguments"`
+}
+
+// Definitions 生成与Declare等价的代理定义，便于在服务启动前预先创建拓扑
+func (t *Topology) Definitions(vhost string) (*BrokerDefinitions, error) {
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+
+	defs := &BrokerDefinitions{}
+	exchange := func(name, kind string) {
+		defs.Exchanges = append(defs.Exchanges, ExchangeDefinition{
+			Name: name, VHost: vhost, Type: kind, Durable: true, Arguments: map[string]interface{}{},
+		})
+	}
+	queue := func(name string, args map[string]interface{}) {
+		defs.Queues = append(defs.Queues, QueueDefinition{
+			Name: name, VHost: vhost, Durable: true, Arguments: args,
+		})
+	}
+	binding := func(source, destination, routingKey string) {
+		defs.Bindings = append(defs.Bindings, BindingDefinition{
+			Source: source, VHost: vhost, Destination: destination, DestinationType: "queue",
+			RoutingKey: routingKey, Arguments: map[string]interface{}{},
+		})
+	}
+
+	for _, e := range t.Exchanges {
+		exchange(e.Name, e.Kind)
+	}
+	for _, dlx := range t.deadLetterExchanges() {
+		exchange(dlx, "direct")
+	}
+
+	for _, q := range t.Queues {
+		queue(q.Name, map[string]interface{}{})
+		binding(q.Exchange, q.Name, q.RoutingKey)
+
+		for _, delay := range q.Options().retryDelays() {
+			queue(retryQueueName(q.Name, delay), map[string]interface{}{
+				"x-message-ttl":             delay.Milliseconds(),
+				"x-dead-letter-exchange":    "",
+				"x-dead-letter-routing-key": q.Name,
+			})
+		}
+
+		if q.DeadLetter {
+			dlq := deadLetterQueueName(q.Name)
+			queue(dlq, map[string]interface{}{})
+			binding(deadLetterExchangeName(q.Exchange), dlq, q.Name)
+		}
+	}
+
+	return defs, nil
+}
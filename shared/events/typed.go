@@ -0,0 +1,60 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+)
+
+// Validator 可自校验的事件负载，校验失败的消息不会被重试
+type Validator interface {
+	Validate() error
+}
+
+// TypedHandler 类型化事件处理函数
+type TypedHandler[T any] func(ctx context.Context, env Envelope, payload T) error
+
+// Typed 将类型化处理函数适配为Handler
+// 负载解码失败或校验失败视为永久性错误，直接进入死信队列
+func Typed[T any](handler TypedHandler[T]) Handler {
+	return func(ctx context.Context, env Envelope) error {
+		payload, err := decodePayload[T](env)
+		if err != nil {
+			return err
+		}
+		return handler(ctx, env, payload)
+	}
+}
+
+// SubscribeTyped 使用默认选项和类型化处理函数订阅事件
+func SubscribeTyped[T any](s Subscriber, queueName, routingKey string, handler TypedHandler[T]) error {
+	return s.Subscribe(queueName, routingKey, Typed(handler))
+}
+
+// SubscribeTypedWithOptions 按指定选项使用类型化处理函数订阅事件
+func SubscribeTypedWithOptions[T any](s Subscriber, queueName, routingKey string, handler TypedHandler[T], opts SubscribeOptions) error {
+	return s.SubscribeWithOptions(queueName, routingKey, Typed(handler), opts)
+}
+
+// decodePayload 解码并校验信封中的业务数据
+func decodePayload[T any](env Envelope) (T, error) {
+	var payload T
+	if data := bytes.TrimSpace(env.Data); len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return payload, Permanent(fmt.Errorf("事件负载为空: %s", env.EventType))
+	}
+	codec, err := CodecFor(env.ContentType)
+	if err != nil {
+		return payload, Permanent(err)
+	}
+	if err := codec.Unmarshal(env.Data, &payload); err != nil {
+		return payload, Permanent(fmt.Errorf("解析事件负载失败: %s(%T): %w", env.EventType, payload, err))
+	}
+
+	if v, ok := any(payload).(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return payload, Permanent(fmt.Errorf("事件负载校验失败: %s: %w", env.EventType, err))
+		}
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,127 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+)
+
+// workerPool 固定数量的消息处理工作协程
+// 相同排序键的任务总是交给同一个工作协程，因此按提交顺序执行；没有排序键的任务轮流分配
+type workerPool struct {
+	queues []chan func()
+	next   uint32
+	wg     sync.WaitGroup
+}
+
+// newWorkerPool 创建并启动工作协程
+func newWorkerPool(workers int) *workerPool {
+	if workers < 1 {
+		workers = 1
+	}
+
+	p := &workerPool{queues: make([]chan func(), workers)}
+	for i := range p.queues {
+		// 每个工作协程只缓冲一个任务，积压由预取数量限制在代理侧
+		queue := make(chan func(), 1)
+		p.queues[i] = queue
+
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for task := range queue {
+				task()
+			}
+		}()
+	}
+	return p
+}
+
+// submit 提交任务，目标工作协程繁忙时阻塞
+func (p *workerPool) submit(key string, task func()) {
+	var i uint32
+	if key == "" {
+		i = atomic.AddUint32(&p.next, 1)
+	} else {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		i = h.Sum32()
+	}
+	p.queues[i%uint32(len(p.queues))] <- task
+}
+
+// close 停止接收任务并等待已提交的任务执行完成
+func (p *workerPool) close() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
+
+// submitMessage 按排序键提交一条消息，process处理并确认消息，每条消息只调用一次
+// 批量消息中的排序键不同时，各组消息分别交给对应键的工作协程调用handler，最后完成的一组以合并的结果调用process，
+// process中的dispatch直接返回该结果，因此整批消息一起确认或按重试策略重投
+func (p *workerPool) submitMessage(opts SubscribeOptions, body []byte, routingKey string, headers map[string]interface{}, redelivered bool, handler Handler, process func(ctx context.Context)) {
+	key, groups := orderingKey(opts, body, routingKey, headers, redelivered)
+	if len(groups) == 0 {
+		p.submit(key, func() { process(context.Background()) })
+		return
+	}
+
+	errs := make([]error, len(groups))
+	remaining := int32(len(groups))
+	for i, group := range groups {
+		p.submit(group.key, func() {
+			errs[i] = dispatchEnvelopes(context.Background(), group.envs, handler)
+			if atomic.AddInt32(&remaining, -1) == 0 {
+				result := &batchResult{err: errors.Join(errs...)}
+				process(context.WithValue(context.Background(), batchResultKey{}, result))
+			}
+		})
+	}
+}
+
+// orderingKey 按订阅选项计算消息的排序键，未配置排序或消息无法解析时返回空
+// 批量消息中的排序键不同时返回按排序键拆分的各组
+func orderingKey(opts SubscribeOptions, body []byte, routingKey string, headers map[string]interface{}, redelivered bool) (string, []batchGroup) {
+	if opts.OrderingKey == nil {
+		return "", nil
+	}
+
+	env, err := decodeEnvelope(body, routingKey, headers, redelivered)
+	if err != nil {
+		return "", nil
+	}
+	if env.ContentType == ContentTypeBatch {
+		return batchGroups(opts, env)
+	}
+	return opts.OrderingKey(env), nil
+}
+
+// OrderByField 返回按负载中指定字段排序的排序键函数，例如 OrderByField("driverID")
+// 只支持JSON和protojson负载，其他内容类型的消息不保证顺序
+func OrderByField(field string) func(env Envelope) string {
+	return func(env Envelope) string {
+		if env.ContentType != ContentTypeJSON && env.ContentType != ContentTypeProtoJSON {
+			return ""
+		}
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(env.Data, &fields); err != nil {
+			return ""
+		}
+
+		raw, ok := fields[field]
+		if !ok {
+			return ""
+		}
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			return s
+		}
+		return string(raw)
+	}
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// HealthCheck reports whether a dependency is usable; a non-nil error marks
+// the service unhealthy and is shown in the /healthz response.
+type HealthCheck func() error
+
+// healthChecks holds the named checks of a registry.
+type healthChecks struct {
+	mu     sync.RWMutex
+	checks map[string]HealthCheck
+}
+
+// RegisterHealthCheck adds a named check served by HealthHandler. Registering
+// a name again replaces the previous check, so components recreated on
+// restart of a subsystem do not panic.
+func (r *Registry) RegisterHealthCheck(name string, check HealthCheck) {
+	r.health.mu.Lock()
+	defer r.health.mu.Unlock()
+
+	if r.health.checks == nil {
+		r.health.checks = make(map[string]HealthCheck)
+	}
+	r.health.checks[name] = check
+}
+
+// healthResponse is the JSON body of /healthz.
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// HealthHandler returns an HTTP handler running every registered check. It
+// answers 200 when all checks pass and 503 otherwise, which makes it usable as
+// a Kubernetes readiness probe.
+func (r *Registry) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		r.health.mu.RLock()
+		checks := make(map[string]HealthCheck, len(r.health.checks))
+		for name, check := range r.health.checks {
+			checks[name] = check
+		}
+		r.health.mu.RUnlock()
+
+		resp := healthResponse{Status: "ok", Checks: make(map[string]string, len(checks))}
+		code := http.StatusOK
+		for name, check := range checks {
+			if err := check(); err != nil {
+				resp.Checks[name] = err.Error()
+				resp.Status = "unavailable"
+				code = http.StatusServiceUnavailable
+				continue
+			}
+			resp.Checks[name] = "ok"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// RegisterHealthCheck adds a named check to the Default registry.
+func RegisterHealthCheck(name string, check HealthCheck) {
+	Default.RegisterHealthCheck(name, check)
+}
+
+// HealthHandler returns an HTTP handler running the checks of the Default
+// registry.
+func HealthHandler() http.Handler {
+	return Default.HealthHandler()
+}
@@ -0,0 +1,388 @@
+/*
+Package metrics provides Prometheus-style counters, gauges and histograms and
+an HTTP handler serving them in the Prometheus text exposition format, next to
+a /healthz handler reporting named health checks.
+
+It implements the small subset of the Prometheus client the services need,
+without the dependency. Metrics are created once, usually as package level
+variables, and registered in a Registry; Default is used by the package level
+constructors and by Handler.
+*/
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefBuckets are the default histogram buckets, in seconds, suited to
+// network and handler latencies.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// collector is a metric family that can write itself in text format.
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry holds metric families by name.
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]collector
+
+	health healthChecks
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+// Default is the registry used by the package level constructors.
+var Default = NewRegistry()
+
+// register adds a family; registering the same name twice is a programming
+// error and panics, like the Prometheus client's MustRegister.
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.collectors[c.name()]; ok {
+		panic(fmt.Sprintf("metrics: duplicate metric %q", c.name()))
+	}
+	r.collectors[c.name()] = c
+}
+
+// WriteText writes every family sorted by name in the text exposition format.
+func (r *Registry) WriteText(w io.Writer) {
+	r.mu.RLock()
+	families := make([]collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		families = append(families, c)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(families, func(i, j int) bool { return families[i].name() < families[j].name() })
+	for _, c := range families {
+		c.write(w)
+	}
+}
+
+// Handler returns an HTTP handler serving the registry.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+// Handler returns an HTTP handler serving the Default registry.
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// ListenAndServe serves the Default registry on addr at /metrics and its
+// health checks at /healthz. It blocks like http.ListenAndServe and is meant
+// to be run in its own goroutine.
+func ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	mux.Handle("/healthz", HealthHandler())
+	return http.ListenAndServe(addr, mux)
+}
+
+// family is the label handling shared by all vector types.
+type family[T any] struct {
+	metricName string
+	help       string
+	kind       string
+	labels     []string
+	newChild   func() *T
+
+	mu       sync.RWMutex
+	children map[string]*labeledChild[T]
+}
+
+type labeledChild[T any] struct {
+	values []string
+	metric *T
+}
+
+func newFamily[T any](name, help, kind string, labels []string, newChild func() *T) *family[T] {
+	return &family[T]{
+		metricName: name,
+		help:       help,
+		kind:       kind,
+		labels:     labels,
+		newChild:   newChild,
+		children:   make(map[string]*labeledChild[T]),
+	}
+}
+
+func (f *family[T]) name() string { return f.metricName }
+
+// with returns the child for the label values, creating it on first use.
+func (f *family[T]) with(values []string) *T {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.metricName, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	f.mu.RLock()
+	child, ok := f.children[key]
+	f.mu.RUnlock()
+	if ok {
+		return child.metric
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if child, ok := f.children[key]; ok {
+		return child.metric
+	}
+	child = &labeledChild[T]{values: append([]string(nil), values...), metric: f.newChild()}
+	f.children[key] = child
+	return child.metric
+}
+
+// each calls fn for every child sorted by label values.
+func (f *family[T]) each(fn func(values []string, metric *T)) {
+	f.mu.RLock()
+	children := make([]*labeledChild[T], 0, len(f.children))
+	for _, child := range f.children {
+		children = append(children, child)
+	}
+	f.mu.RUnlock()
+
+	sort.Slice(children, func(i, j int) bool {
+		return strings.Join(children[i].values, "\xff") < strings.Join(children[j].values, "\xff")
+	})
+	for _, child := range children {
+		fn(child.values, child.metric)
+	}
+}
+
+func (f *family[T]) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.metricName, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.metricName, f.kind)
+}
+
+// labelPairs formats {name="value",...}, with extra pairs appended.
+func (f *family[T]) labelPairs(values []string, extra ...string) string {
+	if len(values) == 0 && len(extra) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(values)+len(extra)/2)
+	for i, v := range values {
+		pairs = append(pairs, f.labels[i]+`="`+escapeLabel(v)+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Counter is a monotonically increasing value.
+type Counter struct {
+	bits uint64
+}
+
+// Inc adds one.
+func (c *Counter) Inc() { c.Add(1) }
+
+// Add adds v, which must not be negative.
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	addFloat(&c.bits, v)
+}
+
+// Value returns the current value.
+func (c *Counter) Value() float64 { return math.Float64frombits(atomic.LoadUint64(&c.bits)) }
+
+// CounterVec is a counter family partitioned by labels.
+type CounterVec struct {
+	*family[Counter]
+}
+
+// NewCounterVec creates a counter family in the Default registry.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return Default.NewCounterVec(name, help, labels...)
+}
+
+// NewCounterVec creates a counter family in the registry.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{newFamily(name, help, "counter", labels, func() *Counter { return &Counter{} })}
+	r.register(v)
+	return v
+}
+
+// WithLabelValues returns the counter for the label values, in label order.
+func (v *CounterVec) WithLabelValues(values ...string) *Counter { return v.with(values) }
+
+func (v *CounterVec) write(w io.Writer) {
+	v.writeHeader(w)
+	v.each(func(values []string, c *Counter) {
+		fmt.Fprintf(w, "%s%s %s\n", v.metricName, v.labelPairs(values), formatFloat(c.Value()))
+	})
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	bits uint64
+}
+
+// Set sets the gauge to v.
+func (g *Gauge) Set(v float64) { atomic.StoreUint64(&g.bits, math.Float64bits(v)) }
+
+// Inc adds one.
+func (g *Gauge) Inc() { addFloat(&g.bits, 1) }
+
+// Dec subtracts one.
+func (g *Gauge) Dec() { addFloat(&g.bits, -1) }
+
+// Add adds v, which may be negative.
+func (g *Gauge) Add(v float64) { addFloat(&g.bits, v) }
+
+// Value returns the current value.
+func (g *Gauge) Value() float64 { return math.Float64frombits(atomic.LoadUint64(&g.bits)) }
+
+// GaugeVec is a gauge family partitioned by labels.
+type GaugeVec struct {
+	*family[Gauge]
+}
+
+// NewGaugeVec creates a gauge family in the Default registry.
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return Default.NewGaugeVec(name, help, labels...)
+}
+
+// NewGaugeVec creates a gauge family in the registry.
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	v := &GaugeVec{newFamily(name, help, "gauge", labels, func() *Gauge { return &Gauge{} })}
+	r.register(v)
+	return v
+}
+
+// WithLabelValues returns the gauge for the label values, in label order.
+func (v *GaugeVec) WithLabelValues(values ...string) *Gauge { return v.with(values) }
+
+func (v *GaugeVec) write(w io.Writer) {
+	v.writeHeader(w)
+	v.each(func(values []string, g *Gauge) {
+		fmt.Fprintf(w, "%s%s %s\n", v.metricName, v.labelPairs(values), formatFloat(g.Value()))
+	})
+}
+
+// Histogram counts observations in cumulative buckets.
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+// Observe records one observation, e.g. a duration in seconds.
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// snapshot returns the cumulative bucket counts, total count and sum.
+func (h *Histogram) snapshot() ([]uint64, uint64, float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]uint64(nil), h.counts...), h.count, h.sum
+}
+
+// HistogramVec is a histogram family partitioned by labels.
+type HistogramVec struct {
+	*family[Histogram]
+	buckets []float64
+}
+
+// NewHistogramVec creates a histogram family in the Default registry; nil
+// buckets means DefBuckets.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return Default.NewHistogramVec(name, help, buckets, labels...)
+}
+
+// NewHistogramVec creates a histogram family in the registry; nil buckets
+// means DefBuckets.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	newHistogram := func() *Histogram {
+		return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+	}
+	v := &HistogramVec{family: newFamily(name, help, "histogram", labels, newHistogram), buckets: buckets}
+	r.register(v)
+	return v
+}
+
+// WithLabelValues returns the histogram for the label values, in label order.
+func (v *HistogramVec) WithLabelValues(values ...string) *Histogram { return v.with(values) }
+
+func (v *HistogramVec) write(w io.Writer) {
+	v.writeHeader(w)
+	v.each(func(values []string, h *Histogram) {
+		counts, count, sum := h.snapshot()
+		for i, upper := range v.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", v.metricName, v.labelPairs(values, "le", formatFloat(upper)), counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", v.metricName, v.labelPairs(values, "le", "+Inf"), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", v.metricName, v.labelPairs(values), formatFloat(sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", v.metricName, v.labelPairs(values), count)
+	})
+}
+
+// addFloat atomically adds v to the float64 stored in bits.
+func addFloat(bits *uint64, v float64) {
+	for {
+		old := atomic.LoadUint64(bits)
+		updated := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(bits, old, updated) {
+			return
+		}
+	}
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
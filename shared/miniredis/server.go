@@ -0,0 +1,261 @@
+/*
+Package miniredis is a small in-process Redis server. It implements the
+commands used by the Redis Streams event backend (streams with consumer
+groups, sets and sorted sets) so that the backend can run, and be checked by
+the conformance suite, without an external Redis. Data is kept in memory only.
+*/
+package miniredis
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+
+	"ride-sharing/shared/resp"
+)
+
+// Server is an in-process Redis server.
+type Server struct {
+	ln net.Listener
+
+	mu      sync.Mutex
+	streams map[string]*stream
+	sets    map[string]map[string]struct{}
+	zsets   map[string]map[string]float64
+	// changed is closed and replaced whenever a stream gets a new entry,
+	// waking up blocked XREAD and XREADGROUP calls.
+	changed chan struct{}
+	conns   map[net.Conn]struct{}
+	closed  chan struct{}
+
+	wg sync.WaitGroup
+}
+
+// Run starts a server listening on addr, e.g. 127.0.0.1:0 for a random port.
+func Run(addr string) (*Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		ln:      ln,
+		streams: make(map[string]*stream),
+		sets:    make(map[string]map[string]struct{}),
+		zsets:   make(map[string]map[string]float64),
+		changed: make(chan struct{}),
+		conns:   make(map[net.Conn]struct{}),
+		closed:  make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// URL returns a redis:// URL for the server.
+func (s *Server) URL() string {
+	return "redis://" + s.Addr()
+}
+
+// Close stops the server and closes all client connections.
+func (s *Server) Close() error {
+	select {
+	case <-s.closed:
+		return nil
+	default:
+	}
+	close(s.closed)
+	err := s.ln.Close()
+
+	s.mu.Lock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("miniredis: accept: %v", err)
+			}
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.handle(c)
+	}
+}
+
+func (s *Server) handle(c net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.Close()
+	}()
+
+	r, w := resp.NewReader(c), resp.NewWriter(c)
+	for {
+		v, err := r.ReadValue()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				w.WriteValue(resp.ErrorValue("ERR " + err.Error()))
+				w.Flush()
+			}
+			return
+		}
+		if v.Kind != resp.KindArray || len(v.Array) == 0 {
+			continue
+		}
+
+		args := v.Strings()
+		reply := s.exec(args)
+		if err := w.WriteValue(reply); err != nil {
+			return
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+		if strings.EqualFold(args[0], "QUIT") {
+			return
+		}
+	}
+}
+
+// exec runs one command and returns its reply.
+func (s *Server) exec(args []string) resp.Value {
+	name := strings.ToUpper(args[0])
+	cmd, ok := commands[name]
+	if !ok {
+		return resp.ErrorValue("ERR unknown command '" + args[0] + "'")
+	}
+	if len(args)-1 < cmd.minArgs {
+		return resp.ErrorValue("ERR wrong number of arguments for '" + strings.ToLower(name) + "' command")
+	}
+	return cmd.fn(s, args[1:])
+}
+
+type command struct {
+	minArgs int
+	fn      func(s *Server, args []string) resp.Value
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"PING":   {0, cmdPing},
+		"ECHO":   {1, func(s *Server, args []string) resp.Value { return resp.Bulk(args[0]) }},
+		"QUIT":   {0, func(s *Server, args []string) resp.Value { return resp.Simple("OK") }},
+		"AUTH":   {1, func(s *Server, args []string) resp.Value { return resp.Simple("OK") }},
+		"SELECT": {1, func(s *Server, args []string) resp.Value { return resp.Simple("OK") }},
+		"DEL":    {1, cmdDel},
+		"EXISTS": {1, cmdExists},
+
+		"SADD":     {2, cmdSAdd},
+		"SREM":     {2, cmdSRem},
+		"SMEMBERS": {1, cmdSMembers},
+
+		"ZADD":          {3, cmdZAdd},
+		"ZREM":          {2, cmdZRem},
+		"ZCARD":         {1, cmdZCard},
+		"ZRANGEBYSCORE": {3, cmdZRangeByScore},
+
+		"XADD":       {4, cmdXAdd},
+		"XLEN":       {1, cmdXLen},
+		"XRANGE":     {3, cmdXRange},
+		"XDEL":       {2, cmdXDel},
+		"XREAD":      {3, cmdXRead},
+		"XGROUP":     {2, cmdXGroup},
+		"XREADGROUP": {6, cmdXReadGroup},
+		"XACK":       {3, cmdXAck},
+		"XPENDING":   {2, cmdXPending},
+		"XAUTOCLAIM": {5, cmdXAutoClaim},
+	}
+}
+
+func cmdPing(s *Server, args []string) resp.Value {
+	if len(args) > 0 {
+		return resp.Bulk(args[0])
+	}
+	return resp.Simple("PONG")
+}
+
+func cmdDel(s *Server, args []string) resp.Value {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for _, key := range args {
+		if s.exists(key) {
+			n++
+		}
+		delete(s.streams, key)
+		delete(s.sets, key)
+		delete(s.zsets, key)
+	}
+	return resp.Int(n)
+}
+
+func cmdExists(s *Server, args []string) resp.Value {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for _, key := range args {
+		if s.exists(key) {
+			n++
+		}
+	}
+	return resp.Int(n)
+}
+
+func (s *Server) exists(key string) bool {
+	_, stream := s.streams[key]
+	_, set := s.sets[key]
+	_, zset := s.zsets[key]
+	return stream || set || zset
+}
+
+// wrongType reports whether key holds a value of a different type than want.
+func (s *Server) wrongType(key, want string) bool {
+	if _, ok := s.streams[key]; ok && want != "stream" {
+		return true
+	}
+	if _, ok := s.sets[key]; ok && want != "set" {
+		return true
+	}
+	if _, ok := s.zsets[key]; ok && want != "zset" {
+		return true
+	}
+	return false
+}
+
+var errWrongType = resp.ErrorValue("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+func errSyntax() resp.Value {
+	return resp.ErrorValue("ERR syntax error")
+}
@@ -0,0 +1,230 @@
+package miniredis
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"ride-sharing/shared/resp"
+)
+
+func cmdSAdd(s *Server, args []string) resp.Value {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := args[0]
+	if s.wrongType(key, "set") {
+		return errWrongType
+	}
+	set, ok := s.sets[key]
+	if !ok {
+		set = make(map[string]struct{})
+		s.sets[key] = set
+	}
+
+	var added int64
+	for _, m := range args[1:] {
+		if _, ok := set[m]; !ok {
+			set[m] = struct{}{}
+			added++
+		}
+	}
+	return resp.Int(added)
+}
+
+func cmdSRem(s *Server, args []string) resp.Value {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := args[0]
+	if s.wrongType(key, "set") {
+		return errWrongType
+	}
+	set := s.sets[key]
+
+	var removed int64
+	for _, m := range args[1:] {
+		if _, ok := set[m]; ok {
+			delete(set, m)
+			removed++
+		}
+	}
+	if len(set) == 0 {
+		delete(s.sets, key)
+	}
+	return resp.Int(removed)
+}
+
+func cmdSMembers(s *Server, args []string) resp.Value {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := args[0]
+	if s.wrongType(key, "set") {
+		return errWrongType
+	}
+
+	members := make([]string, 0, len(s.sets[key]))
+	for m := range s.sets[key] {
+		members = append(members, m)
+	}
+	sort.Strings(members)
+	return resp.BulkStrings(members...)
+}
+
+func cmdZAdd(s *Server, args []string) resp.Value {
+	if len(args)%2 != 1 {
+		return errSyntax()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := args[0]
+	if s.wrongType(key, "zset") {
+		return errWrongType
+	}
+	zset, ok := s.zsets[key]
+	if !ok {
+		zset = make(map[string]float64)
+		s.zsets[key] = zset
+	}
+
+	var added int64
+	for i := 1; i < len(args); i += 2 {
+		score, err := strconv.ParseFloat(args[i], 64)
+		if err != nil {
+			return resp.ErrorValue("ERR value is not a valid float")
+		}
+		if _, ok := zset[args[i+1]]; !ok {
+			added++
+		}
+		zset[args[i+1]] = score
+	}
+	return resp.Int(added)
+}
+
+func cmdZRem(s *Server, args []string) resp.Value {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := args[0]
+	if s.wrongType(key, "zset") {
+		return errWrongType
+	}
+	zset := s.zsets[key]
+
+	var removed int64
+	for _, m := range args[1:] {
+		if _, ok := zset[m]; ok {
+			delete(zset, m)
+			removed++
+		}
+	}
+	if len(zset) == 0 {
+		delete(s.zsets, key)
+	}
+	return resp.Int(removed)
+}
+
+func cmdZCard(s *Server, args []string) resp.Value {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.wrongType(args[0], "zset") {
+		return errWrongType
+	}
+	return resp.Int(int64(len(s.zsets[args[0]])))
+}
+
+// cmdZRangeByScore implements ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count].
+func cmdZRangeByScore(s *Server, args []string) resp.Value {
+	min, minExcl, err := parseScore(args[1])
+	if err != nil {
+		return resp.ErrorValue("ERR min or max is not a float")
+	}
+	max, maxExcl, err := parseScore(args[2])
+	if err != nil {
+		return resp.ErrorValue("ERR min or max is not a float")
+	}
+
+	withScores, offset, count := false, 0, -1
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "WITHSCORES":
+			withScores = true
+		case "LIMIT":
+			if i+2 >= len(args) {
+				return errSyntax()
+			}
+			offset, err = strconv.Atoi(args[i+1])
+			if err != nil {
+				return errSyntax()
+			}
+			count, err = strconv.Atoi(args[i+2])
+			if err != nil {
+				return errSyntax()
+			}
+			i += 2
+		default:
+			return errSyntax()
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.wrongType(args[0], "zset") {
+		return errWrongType
+	}
+
+	type member struct {
+		name  string
+		score float64
+	}
+	var members []member
+	for name, score := range s.zsets[args[0]] {
+		if score < min || (minExcl && score == min) || score > max || (maxExcl && score == max) {
+			continue
+		}
+		members = append(members, member{name, score})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].score != members[j].score {
+			return members[i].score < members[j].score
+		}
+		return members[i].name < members[j].name
+	})
+
+	if offset > len(members) {
+		offset = len(members)
+	}
+	members = members[offset:]
+	if count >= 0 && count < len(members) {
+		members = members[:count]
+	}
+
+	var out []string
+	for _, m := range members {
+		out = append(out, m.name)
+		if withScores {
+			out = append(out, strconv.FormatFloat(m.score, 'f', -1, 64))
+		}
+	}
+	return resp.BulkStrings(out...)
+}
+
+// parseScore parses a score bound such as 5, (5, -inf or +inf.
+func parseScore(s string) (float64, bool, error) {
+	exclusive := strings.HasPrefix(s, "(")
+	s = strings.TrimPrefix(s, "(")
+	switch strings.ToLower(s) {
+	case "-inf":
+		return math.Inf(-1), exclusive, nil
+	case "+inf", "inf":
+		return math.Inf(1), exclusive, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return f, exclusive, err
+}
@@ -0,0 +1,807 @@
+package miniredis
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ride-sharing/shared/resp"
+)
+
+// streamID is the ID of a stream entry, <milliseconds>-<sequence>.
+type streamID struct {
+	ms, seq uint64
+}
+
+var maxID = streamID{math.MaxUint64, math.MaxUint64}
+
+func (id streamID) String() string {
+	return fmt.Sprintf("%d-%d", id.ms, id.seq)
+}
+
+func (id streamID) less(o streamID) bool {
+	return id.ms < o.ms || (id.ms == o.ms && id.seq < o.seq)
+}
+
+// parseID parses a full or partial ID. A missing sequence defaults to
+// missingSeq, i.e. 0 for start bounds and the maximum for end bounds.
+func parseID(s string, missingSeq uint64) (streamID, error) {
+	ms, seq, hasSeq := strings.Cut(s, "-")
+	var id streamID
+	var err error
+	if id.ms, err = strconv.ParseUint(ms, 10, 64); err != nil {
+		return id, fmt.Errorf("ERR Invalid stream ID specified as stream command argument")
+	}
+	id.seq = missingSeq
+	if hasSeq {
+		if id.seq, err = strconv.ParseUint(seq, 10, 64); err != nil {
+			return id, fmt.Errorf("ERR Invalid stream ID specified as stream command argument")
+		}
+	}
+	return id, nil
+}
+
+// parseRange parses an XRANGE bound: -, +, an ID, or an exclusive (ID.
+func parseRange(s string, end bool) (streamID, error) {
+	switch s {
+	case "-":
+		return streamID{}, nil
+	case "+":
+		return maxID, nil
+	}
+
+	missing := uint64(0)
+	if end {
+		missing = math.MaxUint64
+	}
+	if !strings.HasPrefix(s, "(") {
+		return parseID(s, missing)
+	}
+
+	id, err := parseID(s[1:], missing)
+	if err != nil {
+		return id, err
+	}
+	if end {
+		if id.seq == 0 {
+			id.ms, id.seq = id.ms-1, math.MaxUint64
+		} else {
+			id.seq--
+		}
+	} else {
+		if id.seq == math.MaxUint64 {
+			id.ms, id.seq = id.ms+1, 0
+		} else {
+			id.seq++
+		}
+	}
+	return id, nil
+}
+
+type entry struct {
+	id     streamID
+	fields []string
+}
+
+func (e entry) value() resp.Value {
+	return resp.Array(resp.Bulk(e.id.String()), resp.BulkStrings(e.fields...))
+}
+
+type stream struct {
+	entries []entry
+	lastID  streamID
+	groups  map[string]*group
+}
+
+type pendingEntry struct {
+	consumer    string
+	deliveredAt time.Time
+	deliveries  int64
+}
+
+type group struct {
+	lastDelivered streamID
+	pending       map[streamID]*pendingEntry
+}
+
+func newStream() *stream {
+	return &stream{groups: make(map[string]*group)}
+}
+
+// after returns up to count entries with an ID greater than id.
+func (st *stream) after(id streamID, count int) []entry {
+	i := sort.Search(len(st.entries), func(i int) bool { return id.less(st.entries[i].id) })
+	end := len(st.entries)
+	if count > 0 && i+count < end {
+		end = i + count
+	}
+	return st.entries[i:end]
+}
+
+// find returns the entry with the given ID.
+func (st *stream) find(id streamID) (entry, bool) {
+	i := sort.Search(len(st.entries), func(i int) bool { return !st.entries[i].id.less(id) })
+	if i < len(st.entries) && st.entries[i].id == id {
+		return st.entries[i], true
+	}
+	return entry{}, false
+}
+
+// sortedPending returns the pending IDs of g in ascending order.
+func (g *group) sortedPending() []streamID {
+	ids := make([]streamID, 0, len(g.pending))
+	for id := range g.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].less(ids[j]) })
+	return ids
+}
+
+// notifyLocked wakes up blocked readers. s.mu must be held.
+func (s *Server) notifyLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// stream returns the stream at key, nil if it does not exist.
+func (s *Server) stream(key string) (*stream, resp.Value, bool) {
+	if s.wrongType(key, "stream") {
+		return nil, errWrongType, false
+	}
+	return s.streams[key], resp.Value{}, true
+}
+
+// cmdXAdd implements XADD key [NOMKSTREAM] [MAXLEN [=|~] n] <*|id> field value [field value ...].
+func cmdXAdd(s *Server, args []string) resp.Value {
+	key, args := args[0], args[1:]
+	noMkStream, maxLen := false, -1
+
+	for len(args) > 0 {
+		switch strings.ToUpper(args[0]) {
+		case "NOMKSTREAM":
+			noMkStream = true
+			args = args[1:]
+			continue
+		case "MAXLEN":
+			args = args[1:]
+			if len(args) > 0 && (args[0] == "~" || args[0] == "=") {
+				args = args[1:]
+			}
+			if len(args) == 0 {
+				return errSyntax()
+			}
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < 0 {
+				return resp.ErrorValue("ERR The MAXLEN argument must be >= 0.")
+			}
+			maxLen, args = n, args[1:]
+			continue
+		}
+		break
+	}
+	if len(args) < 3 || len(args)%2 != 1 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'xadd' command")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, errValue, ok := s.stream(key)
+	if !ok {
+		return errValue
+	}
+	if st == nil {
+		if noMkStream {
+			return resp.NullBulk()
+		}
+		st = newStream()
+		s.streams[key] = st
+	}
+
+	var id streamID
+	if args[0] == "*" {
+		now := uint64(time.Now().UnixMilli())
+		if now > st.lastID.ms {
+			id = streamID{ms: now}
+		} else {
+			id = streamID{ms: st.lastID.ms, seq: st.lastID.seq + 1}
+		}
+	} else {
+		var err error
+		if id, err = parseID(args[0], 0); err != nil {
+			return resp.ErrorValue(err.Error())
+		}
+		if !st.lastID.less(id) {
+			return resp.ErrorValue("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+		}
+	}
+
+	st.entries = append(st.entries, entry{id: id, fields: append([]string(nil), args[1:]...)})
+	st.lastID = id
+	if maxLen >= 0 && len(st.entries) > maxLen {
+		st.entries = append([]entry(nil), st.entries[len(st.entries)-maxLen:]...)
+	}
+
+	s.notifyLocked()
+	return resp.Bulk(id.String())
+}
+
+func cmdXLen(s *Server, args []string) resp.Value {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, errValue, ok := s.stream(args[0])
+	if !ok {
+		return errValue
+	}
+	if st == nil {
+		return resp.Int(0)
+	}
+	return resp.Int(int64(len(st.entries)))
+}
+
+// cmdXRange implements XRANGE key start end [COUNT n].
+func cmdXRange(s *Server, args []string) resp.Value {
+	start, err := parseRange(args[1], false)
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+	end, err := parseRange(args[2], true)
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+	count := -1
+	if len(args) == 5 && strings.EqualFold(args[3], "COUNT") {
+		if count, err = strconv.Atoi(args[4]); err != nil {
+			return errSyntax()
+		}
+	} else if len(args) != 3 {
+		return errSyntax()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, errValue, ok := s.stream(args[0])
+	if !ok {
+		return errValue
+	}
+
+	out := []resp.Value{}
+	if st != nil {
+		for _, e := range st.entries {
+			if count >= 0 && len(out) >= count {
+				break
+			}
+			if e.id.less(start) || end.less(e.id) {
+				continue
+			}
+			out = append(out, e.value())
+		}
+	}
+	return resp.Array(out...)
+}
+
+func cmdXDel(s *Server, args []string) resp.Value {
+	ids := make(map[streamID]bool)
+	for _, raw := range args[1:] {
+		id, err := parseID(raw, 0)
+		if err != nil {
+			return resp.ErrorValue(err.Error())
+		}
+		ids[id] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, errValue, ok := s.stream(args[0])
+	if !ok {
+		return errValue
+	}
+	if st == nil {
+		return resp.Int(0)
+	}
+
+	kept := st.entries[:0]
+	var deleted int64
+	for _, e := range st.entries {
+		if ids[e.id] {
+			deleted++
+			continue
+		}
+		kept = append(kept, e)
+	}
+	st.entries = kept
+	return resp.Int(deleted)
+}
+
+// readOptions are the options shared by XREAD and XREADGROUP.
+type readOptions struct {
+	count    int
+	block    time.Duration
+	blocking bool
+	noAck    bool
+	keys     []string
+	ids      []string
+}
+
+func parseReadOptions(args []string, group bool) (readOptions, resp.Value, bool) {
+	var opts readOptions
+	for i := 0; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "COUNT":
+			if i+1 >= len(args) {
+				return opts, errSyntax(), false
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return opts, resp.ErrorValue("ERR value is not an integer or out of range"), false
+			}
+			opts.count, i = n, i+1
+		case "BLOCK":
+			if i+1 >= len(args) {
+				return opts, errSyntax(), false
+			}
+			ms, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || ms < 0 {
+				return opts, resp.ErrorValue("ERR timeout is not an integer or out of range"), false
+			}
+			opts.block, opts.blocking, i = time.Duration(ms)*time.Millisecond, true, i+1
+		case "NOACK":
+			if !group {
+				return opts, errSyntax(), false
+			}
+			opts.noAck = true
+		case "STREAMS":
+			rest := args[i+1:]
+			if len(rest) == 0 || len(rest)%2 != 0 {
+				return opts, resp.ErrorValue("ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified."), false
+			}
+			opts.keys, opts.ids = rest[:len(rest)/2], rest[len(rest)/2:]
+			return opts, resp.Value{}, true
+		default:
+			return opts, errSyntax(), false
+		}
+	}
+	return opts, errSyntax(), false
+}
+
+// blockingRead runs read under the server lock until it reports a result,
+// waiting for new entries between attempts while the block timeout allows.
+// BLOCK 0 waits forever.
+func (s *Server) blockingRead(opts readOptions, read func() (resp.Value, bool)) resp.Value {
+	var timeout <-chan time.Time
+	if opts.blocking && opts.block > 0 {
+		timer := time.NewTimer(opts.block)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		s.mu.Lock()
+		v, done := read()
+		changed := s.changed
+		s.mu.Unlock()
+
+		if done || !opts.blocking {
+			return v
+		}
+		select {
+		case <-changed:
+		case <-timeout:
+			return resp.NullArray()
+		case <-s.closed:
+			return resp.NullArray()
+		}
+	}
+}
+
+// cmdXRead implements XREAD [COUNT n] [BLOCK ms] STREAMS key [key ...] id [id ...].
+func cmdXRead(s *Server, args []string) resp.Value {
+	opts, errValue, ok := parseReadOptions(args, false)
+	if !ok {
+		return errValue
+	}
+
+	// $ means entries added after the call, resolved once up front.
+	s.mu.Lock()
+	from := make([]streamID, len(opts.keys))
+	for i, raw := range opts.ids {
+		if raw == "$" {
+			if st := s.streams[opts.keys[i]]; st != nil {
+				from[i] = st.lastID
+			}
+			continue
+		}
+		id, err := parseID(raw, 0)
+		if err != nil {
+			s.mu.Unlock()
+			return resp.ErrorValue(err.Error())
+		}
+		from[i] = id
+	}
+	s.mu.Unlock()
+
+	return s.blockingRead(opts, func() (resp.Value, bool) {
+		var out []resp.Value
+		for i, key := range opts.keys {
+			st, errValue, ok := s.stream(key)
+			if !ok {
+				return errValue, true
+			}
+			if st == nil {
+				continue
+			}
+			if entries := st.after(from[i], opts.count); len(entries) > 0 {
+				out = append(out, resp.Array(resp.Bulk(key), entriesValue(entries)))
+			}
+		}
+		if len(out) == 0 {
+			return resp.NullArray(), false
+		}
+		return resp.Array(out...), true
+	})
+}
+
+func entriesValue(entries []entry) resp.Value {
+	out := make([]resp.Value, len(entries))
+	for i, e := range entries {
+		out[i] = e.value()
+	}
+	return resp.Array(out...)
+}
+
+// cmdXGroup implements XGROUP CREATE|DESTROY|SETID.
+func cmdXGroup(s *Server, args []string) resp.Value {
+	sub := strings.ToUpper(args[0])
+	if len(args) < 3 {
+		return resp.ErrorValue("ERR wrong number of arguments for 'xgroup|" + strings.ToLower(sub) + "' command")
+	}
+	key, name := args[1], args[2]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, errValue, ok := s.stream(key)
+	if !ok {
+		return errValue
+	}
+
+	switch sub {
+	case "CREATE":
+		if len(args) < 4 {
+			return errSyntax()
+		}
+		if st == nil {
+			if len(args) < 5 || !strings.EqualFold(args[4], "MKSTREAM") {
+				return resp.ErrorValue("ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+			}
+			st = newStream()
+			s.streams[key] = st
+		}
+		if _, exists := st.groups[name]; exists {
+			return resp.ErrorValue("BUSYGROUP Consumer Group name already exists")
+		}
+		start, errValue, ok := groupStart(st, args[3])
+		if !ok {
+			return errValue
+		}
+		st.groups[name] = &group{lastDelivered: start, pending: make(map[streamID]*pendingEntry)}
+		return resp.Simple("OK")
+	case "SETID":
+		if st == nil || st.groups[name] == nil {
+			return noGroup(key, name)
+		}
+		if len(args) < 4 {
+			return errSyntax()
+		}
+		start, errValue, ok := groupStart(st, args[3])
+		if !ok {
+			return errValue
+		}
+		st.groups[name].lastDelivered = start
+		return resp.Simple("OK")
+	case "DESTROY":
+		if st == nil || st.groups[name] == nil {
+			return resp.Int(0)
+		}
+		delete(st.groups, name)
+		return resp.Int(1)
+	default:
+		return resp.ErrorValue("ERR unknown subcommand '" + args[0] + "'")
+	}
+}
+
+func groupStart(st *stream, raw string) (streamID, resp.Value, bool) {
+	if raw == "$" {
+		return st.lastID, resp.Value{}, true
+	}
+	id, err := parseID(raw, 0)
+	if err != nil {
+		return id, resp.ErrorValue(err.Error()), false
+	}
+	return id, resp.Value{}, true
+}
+
+func noGroup(key, name string) resp.Value {
+	return resp.ErrorValue(fmt.Sprintf("NOGROUP No such key '%s' or consumer group '%s'", key, name))
+}
+
+// cmdXReadGroup implements
+// XREADGROUP GROUP group consumer [COUNT n] [BLOCK ms] [NOACK] STREAMS key [key ...] id [id ...].
+func cmdXReadGroup(s *Server, args []string) resp.Value {
+	if !strings.EqualFold(args[0], "GROUP") {
+		return errSyntax()
+	}
+	groupName, consumer := args[1], args[2]
+	opts, errValue, ok := parseReadOptions(args[3:], true)
+	if !ok {
+		return errValue
+	}
+
+	// Reading history (an explicit ID) never blocks.
+	for _, raw := range opts.ids {
+		if raw != ">" {
+			opts.blocking = false
+		}
+	}
+
+	return s.blockingRead(opts, func() (resp.Value, bool) {
+		var out []resp.Value
+		for i, key := range opts.keys {
+			st, errValue, ok := s.stream(key)
+			if !ok {
+				return errValue, true
+			}
+			if st == nil || st.groups[groupName] == nil {
+				return noGroup(key, groupName), true
+			}
+			g := st.groups[groupName]
+
+			if opts.ids[i] == ">" {
+				entries := st.after(g.lastDelivered, opts.count)
+				if len(entries) == 0 {
+					continue
+				}
+				now := time.Now()
+				for _, e := range entries {
+					if !opts.noAck {
+						g.pending[e.id] = &pendingEntry{consumer: consumer, deliveredAt: now, deliveries: 1}
+					}
+				}
+				g.lastDelivered = entries[len(entries)-1].id
+				out = append(out, resp.Array(resp.Bulk(key), entriesValue(entries)))
+				continue
+			}
+
+			// History: this consumer's pending entries after the given ID.
+			from, err := parseID(opts.ids[i], 0)
+			if err != nil {
+				return resp.ErrorValue(err.Error()), true
+			}
+			var history []resp.Value
+			for _, id := range g.sortedPending() {
+				if !from.less(id) || g.pending[id].consumer != consumer {
+					continue
+				}
+				if opts.count > 0 && len(history) >= opts.count {
+					break
+				}
+				if e, ok := st.find(id); ok {
+					history = append(history, e.value())
+				} else {
+					history = append(history, resp.Array(resp.Bulk(id.String()), resp.NullArray()))
+				}
+			}
+			out = append(out, resp.Array(resp.Bulk(key), resp.Array(history...)))
+		}
+
+		if len(out) == 0 {
+			return resp.NullArray(), false
+		}
+		return resp.Array(out...), true
+	})
+}
+
+func cmdXAck(s *Server, args []string) resp.Value {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, errValue, ok := s.stream(args[0])
+	if !ok {
+		return errValue
+	}
+	if st == nil || st.groups[args[1]] == nil {
+		return resp.Int(0)
+	}
+	g := st.groups[args[1]]
+
+	var acked int64
+	for _, raw := range args[2:] {
+		id, err := parseID(raw, 0)
+		if err != nil {
+			return resp.ErrorValue(err.Error())
+		}
+		if _, ok := g.pending[id]; ok {
+			delete(g.pending, id)
+			acked++
+		}
+	}
+	return resp.Int(acked)
+}
+
+// cmdXPending implements the summary form XPENDING key group and the
+// extended form XPENDING key group [IDLE ms] start end count [consumer].
+func cmdXPending(s *Server, args []string) resp.Value {
+	key, name := args[0], args[1]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, errValue, ok := s.stream(key)
+	if !ok {
+		return errValue
+	}
+	if st == nil || st.groups[name] == nil {
+		return noGroup(key, name)
+	}
+	g := st.groups[name]
+	ids := g.sortedPending()
+
+	if len(args) == 2 {
+		if len(ids) == 0 {
+			return resp.Array(resp.Int(0), resp.NullBulk(), resp.NullBulk(), resp.NullArray())
+		}
+		perConsumer := make(map[string]int64)
+		for _, id := range ids {
+			perConsumer[g.pending[id].consumer]++
+		}
+		names := make([]string, 0, len(perConsumer))
+		for c := range perConsumer {
+			names = append(names, c)
+		}
+		sort.Strings(names)
+		consumers := make([]resp.Value, len(names))
+		for i, c := range names {
+			consumers[i] = resp.Array(resp.Bulk(c), resp.Bulk(strconv.FormatInt(perConsumer[c], 10)))
+		}
+		return resp.Array(
+			resp.Int(int64(len(ids))),
+			resp.Bulk(ids[0].String()),
+			resp.Bulk(ids[len(ids)-1].String()),
+			resp.Array(consumers...),
+		)
+	}
+
+	rest := args[2:]
+	var minIdle time.Duration
+	if len(rest) >= 2 && strings.EqualFold(rest[0], "IDLE") {
+		ms, err := strconv.ParseInt(rest[1], 10, 64)
+		if err != nil {
+			return errSyntax()
+		}
+		minIdle, rest = time.Duration(ms)*time.Millisecond, rest[2:]
+	}
+	if len(rest) < 3 {
+		return errSyntax()
+	}
+	start, err := parseRange(rest[0], false)
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+	end, err := parseRange(rest[1], true)
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+	count, err := strconv.Atoi(rest[2])
+	if err != nil {
+		return errSyntax()
+	}
+	consumer := ""
+	if len(rest) > 3 {
+		consumer = rest[3]
+	}
+
+	now := time.Now()
+	out := []resp.Value{}
+	for _, id := range ids {
+		if len(out) >= count {
+			break
+		}
+		p := g.pending[id]
+		if id.less(start) || end.less(id) || (consumer != "" && p.consumer != consumer) || now.Sub(p.deliveredAt) < minIdle {
+			continue
+		}
+		out = append(out, resp.Array(
+			resp.Bulk(id.String()),
+			resp.Bulk(p.consumer),
+			resp.Int(now.Sub(p.deliveredAt).Milliseconds()),
+			resp.Int(p.deliveries),
+		))
+	}
+	return resp.Array(out...)
+}
+
+// cmdXAutoClaim implements
+// XAUTOCLAIM key group consumer min-idle-time start [COUNT n] [JUSTID].
+// The reply has the Redis 7 layout: next start ID, claimed entries, deleted IDs.
+func cmdXAutoClaim(s *Server, args []string) resp.Value {
+	key, name, consumer := args[0], args[1], args[2]
+	idleMs, err := strconv.ParseInt(args[3], 10, 64)
+	if err != nil || idleMs < 0 {
+		return resp.ErrorValue("ERR Invalid min-idle-time argument for XAUTOCLAIM")
+	}
+	start, err := parseRange(args[4], false)
+	if err != nil {
+		return resp.ErrorValue(err.Error())
+	}
+
+	count, justID := 100, false
+	for i := 5; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "COUNT":
+			if i+1 >= len(args) {
+				return errSyntax()
+			}
+			if count, err = strconv.Atoi(args[i+1]); err != nil || count < 1 {
+				return resp.ErrorValue("ERR COUNT must be > 0")
+			}
+			i++
+		case "JUSTID":
+			justID = true
+		default:
+			return errSyntax()
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, errValue, ok := s.stream(key)
+	if !ok {
+		return errValue
+	}
+	if st == nil || st.groups[name] == nil {
+		return noGroup(key, name)
+	}
+	g := st.groups[name]
+
+	now := time.Now()
+	minIdle := time.Duration(idleMs) * time.Millisecond
+	next := streamID{}
+	claimed, deleted := []resp.Value{}, []resp.Value{}
+	for _, id := range g.sortedPending() {
+		if id.less(start) {
+			continue
+		}
+		if len(claimed)+len(deleted) >= count {
+			next = id
+			break
+		}
+		p := g.pending[id]
+		if now.Sub(p.deliveredAt) < minIdle {
+			continue
+		}
+
+		e, exists := st.find(id)
+		if !exists {
+			delete(g.pending, id)
+			deleted = append(deleted, resp.Bulk(id.String()))
+			continue
+		}
+
+		p.consumer, p.deliveredAt = consumer, now
+		if justID {
+			claimed = append(claimed, resp.Bulk(id.String()))
+			continue
+		}
+		p.deliveries++
+		claimed = append(claimed, e.value())
+	}
+
+	return resp.Array(resp.Bulk(next.String()), resp.Array(claimed...), resp.Array(deleted...))
+}
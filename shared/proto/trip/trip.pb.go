@@ -21,6 +21,147 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type TripUpdateKind int32
+
+const (
+	TripUpdateKind_TRIP_UPDATE_KIND_UNSPECIFIED TripUpdateKind = 0
+	// The whole trip, sent when the stream starts or cannot resume.
+	TripUpdateKind_TRIP_UPDATE_KIND_SNAPSHOT TripUpdateKind = 1
+	TripUpdateKind_TRIP_UPDATE_KIND_STATUS   TripUpdateKind = 2
+	TripUpdateKind_TRIP_UPDATE_KIND_DRIVER   TripUpdateKind = 3
+	// Position of the assigned driver; does not change the version.
+	TripUpdateKind_TRIP_UPDATE_KIND_LOCATION TripUpdateKind = 4
+)
+
+// Enum value maps for TripUpdateKind.
+var (
+	TripUpdateKind_name = map[int32]string{
+		0: "TRIP_UPDATE_KIND_UNSPECIFIED",
+		1: "TRIP_UPDATE_KIND_SNAPSHOT",
+		2: "TRIP_UPDATE_KIND_STATUS",
+		3: "TRIP_UPDATE_KIND_DRIVER",
+		4: "TRIP_UPDATE_KIND_LOCATION",
+	}
+	TripUpdateKind_value = map[string]int32{
+		"TRIP_UPDATE_KIND_UNSPECIFIED": 0,
+		"TRIP_UPDATE_KIND_SNAPSHOT":    1,
+		"TRIP_UPDATE_KIND_STATUS":      2,
+		"TRIP_UPDATE_KIND_DRIVER":      3,
+		"TRIP_UPDATE_KIND_LOCATION":    4,
+	}
+)
+
+func (x TripUpdateKind) Enum() *TripUpdateKind {
+	p := new(TripUpdateKind)
+	*p = x
+	return p
+}
+
+func (x TripUpdateKind) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (TripUpdateKind) Descriptor() protoreflect.EnumDescriptor {
+	return file_trip_proto_enumTypes[0].Descriptor()
+}
+
+func (TripUpdateKind) Type() protoreflect.EnumType {
+	return &file_trip_proto_enumTypes[0]
+}
+
+func (x TripUpdateKind) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use TripUpdateKind.Descriptor instead.
+func (TripUpdateKind) EnumDescriptor() ([]byte, []int) {
+	return file_trip_proto_rawDescGZIP(), []int{0}
+}
+
+// TripStatus is the lifecycle of a trip. Trip.status carries the value name
+// in lowercase without the prefix, e.g. TRIP_STATUS_DRIVER_ASSIGNED is sent
+// as "driver_assigned", so that JSON consumers read it as plain text.
+//
+//	requested -> searching -> driver_assigned -> driver_arrived -> in_progress -> completed
+//
+// searching may end in no_drivers_found. paid and payment_failed are reached
+// from driver_assigned or driver_arrived, and a paid trip continues to
+// driver_arrived or in_progress. cancelled is reached from any status before
+// in_progress. completed, cancelled, no_drivers_found and payment_failed are
+// final.
+type TripStatus int32
+
+const (
+	TripStatus_TRIP_STATUS_UNSPECIFIED      TripStatus = 0
+	TripStatus_TRIP_STATUS_REQUESTED        TripStatus = 1
+	TripStatus_TRIP_STATUS_SEARCHING        TripStatus = 2
+	TripStatus_TRIP_STATUS_DRIVER_ASSIGNED  TripStatus = 3
+	TripStatus_TRIP_STATUS_DRIVER_ARRIVED   TripStatus = 4
+	TripStatus_TRIP_STATUS_IN_PROGRESS      TripStatus = 5
+	TripStatus_TRIP_STATUS_COMPLETED        TripStatus = 6
+	TripStatus_TRIP_STATUS_CANCELLED        TripStatus = 7
+	TripStatus_TRIP_STATUS_NO_DRIVERS_FOUND TripStatus = 8
+	TripStatus_TRIP_STATUS_PAID             TripStatus = 9
+	TripStatus_TRIP_STATUS_PAYMENT_FAILED   TripStatus = 10
+)
+
+// Enum value maps for TripStatus.
+var (
+	TripStatus_name = map[int32]string{
+		0:  "TRIP_STATUS_UNSPECIFIED",
+		1:  "TRIP_STATUS_REQUESTED",
+		2:  "TRIP_STATUS_SEARCHING",
+		3:  "TRIP_STATUS_DRIVER_ASSIGNED",
+		4:  "TRIP_STATUS_DRIVER_ARRIVED",
+		5:  "TRIP_STATUS_IN_PROGRESS",
+		6:  "TRIP_STATUS_COMPLETED",
+		7:  "TRIP_STATUS_CANCELLED",
+		8:  "TRIP_STATUS_NO_DRIVERS_FOUND",
+		9:  "TRIP_STATUS_PAID",
+		10: "TRIP_STATUS_PAYMENT_FAILED",
+	}
+	TripStatus_value = map[string]int32{
+		"TRIP_STATUS_UNSPECIFIED":      0,
+		"TRIP_STATUS_REQUESTED":        1,
+		"TRIP_STATUS_SEARCHING":        2,
+		"TRIP_STATUS_DRIVER_ASSIGNED":  3,
+		"TRIP_STATUS_DRIVER_ARRIVED":   4,
+		"TRIP_STATUS_IN_PROGRESS":      5,
+		"TRIP_STATUS_COMPLETED":        6,
+		"TRIP_STATUS_CANCELLED":        7,
+		"TRIP_STATUS_NO_DRIVERS_FOUND": 8,
+		"TRIP_STATUS_PAID":             9,
+		"TRIP_STATUS_PAYMENT_FAILED":   10,
+	}
+)
+
+func (x TripStatus) Enum() *TripStatus {
+	p := new(TripStatus)
+	*p = x
+	return p
+}
+
+func (x TripStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (TripStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_trip_proto_enumTypes[1].Descriptor()
+}
+
+func (TripStatus) Type() protoreflect.EnumType {
+	return &file_trip_proto_enumTypes[1]
+}
+
+func (x TripStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use TripStatus.Descriptor instead.
+func (TripStatus) EnumDescriptor() ([]byte, []int) {
+	return file_trip_proto_rawDescGZIP(), []int{1}
+}
+
 type PreviewTripRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	UserID        string                 `protobuf:"bytes,1,opt,name=userID,proto3" json:"userID,omitempty"`
@@ -365,7 +506,7 @@ func (x *RideFare) GetTotalPriceInCents() float64 {
 	return 0
 }
 
-type CreteTripRequest struct {
+type CreateTripRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	RideFareID    string                 `protobuf:"bytes,1,opt,name=rideFareID,proto3" json:"rideFareID,omitempty"`
 	UserID        string                 `protobuf:"bytes,2,opt,name=userID,proto3" json:"userID,omitempty"`
@@ -373,20 +514,20 @@ type CreteTripRequest struct {
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *CreteTripRequest) Reset() {
-	*x = CreteTripRequest{}
+func (x *CreateTripRequest) Reset() {
+	*x = CreateTripRequest{}
 	mi := &file_trip_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *CreteTripRequest) String() string {
+func (x *CreateTripRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*CreteTripRequest) ProtoMessage() {}
+func (*CreateTripRequest) ProtoMessage() {}
 
-func (x *CreteTripRequest) ProtoReflect() protoreflect.Message {
+func (x *CreateTripRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_trip_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -398,19 +539,19 @@ func (x *CreteTripRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use CreteTripRequest.ProtoReflect.Descriptor instead.
-func (*CreteTripRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use CreateTripRequest.ProtoReflect.Descriptor instead.
+func (*CreateTripRequest) Descriptor() ([]byte, []int) {
 	return file_trip_proto_rawDescGZIP(), []int{6}
 }
 
-func (x *CreteTripRequest) GetRideFareID() string {
+func (x *CreateTripRequest) GetRideFareID() string {
 	if x != nil {
 		return x.RideFareID
 	}
 	return ""
 }
 
-func (x *CreteTripRequest) GetUserID() string {
+func (x *CreateTripRequest) GetUserID() string {
 	if x != nil {
 		return x.UserID
 	}
@@ -469,32 +610,27 @@ func (x *CreateTripResponse) GetTrip() *Trip {
 	return nil
 }
 
-type Trip struct {
+type GetTripRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	SelectedFare  *RideFare              `protobuf:"bytes,2,opt,name=selectedFare,proto3" json:"selectedFare,omitempty"`
-	Route         *Route                 `protobuf:"bytes,3,opt,name=route,proto3" json:"route,omitempty"`
-	Status        string                 `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
-	UserID        string                 `protobuf:"bytes,5,opt,name=userID,proto3" json:"userID,omitempty"`
-	Driver        *TripDriver            `protobuf:"bytes,6,opt,name=driver,proto3" json:"driver,omitempty"`
+	TripID        string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Trip) Reset() {
-	*x = Trip{}
+func (x *GetTripRequest) Reset() {
+	*x = GetTripRequest{}
 	mi := &file_trip_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Trip) String() string {
+func (x *GetTripRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Trip) ProtoMessage() {}
+func (*GetTripRequest) ProtoMessage() {}
 
-func (x *Trip) ProtoReflect() protoreflect.Message {
+func (x *GetTripRequest) ProtoReflect() protoreflect.Message {
 	mi := &file_trip_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -506,79 +642,167 @@ func (x *Trip) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Trip.ProtoReflect.Descriptor instead.
-func (*Trip) Descriptor() ([]byte, []int) {
+// Deprecated: Use GetTripRequest.ProtoReflect.Descriptor instead.
+func (*GetTripRequest) Descriptor() ([]byte, []int) {
 	return file_trip_proto_rawDescGZIP(), []int{8}
 }
 
-func (x *Trip) GetId() string {
+func (x *GetTripRequest) GetTripID() string {
 	if x != nil {
-		return x.Id
+		return x.TripID
 	}
 	return ""
 }
 
-func (x *Trip) GetSelectedFare() *RideFare {
+type GetTripResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Trip          *Trip                  `protobuf:"bytes,1,opt,name=trip,proto3" json:"trip,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTripResponse) Reset() {
+	*x = GetTripResponse{}
+	mi := &file_trip_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTripResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTripResponse) ProtoMessage() {}
+
+func (x *GetTripResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_proto_msgTypes[9]
 	if x != nil {
-		return x.SelectedFare
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return nil
+	return mi.MessageOf(x)
 }
 
-func (x *Trip) GetRoute() *Route {
+// Deprecated: Use GetTripResponse.ProtoReflect.Descriptor instead.
+func (*GetTripResponse) Descriptor() ([]byte, []int) {
+	return file_trip_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *GetTripResponse) GetTrip() *Trip {
 	if x != nil {
-		return x.Route
+		return x.Trip
 	}
 	return nil
 }
 
-func (x *Trip) GetStatus() string {
+// Lists the trips of a rider or of a driver, newest first. Exactly one of
+// userID and driverID is set.
+type ListTripsRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	UserID   string                 `protobuf:"bytes,1,opt,name=userID,proto3" json:"userID,omitempty"`
+	DriverID string                 `protobuf:"bytes,2,opt,name=driverID,proto3" json:"driverID,omitempty"`
+	// Only trips in one of these statuses are returned; empty means all.
+	Statuses []TripStatus `protobuf:"varint,3,rep,packed,name=statuses,proto3,enum=trip.TripStatus" json:"statuses,omitempty"`
+	// At most 100; 0 means 20.
+	PageSize int32 `protobuf:"varint,4,opt,name=pageSize,proto3" json:"pageSize,omitempty"`
+	// nextPageToken of the previous response.
+	PageToken     string `protobuf:"bytes,5,opt,name=pageToken,proto3" json:"pageToken,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTripsRequest) Reset() {
+	*x = ListTripsRequest{}
+	mi := &file_trip_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTripsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTripsRequest) ProtoMessage() {}
+
+func (x *ListTripsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_proto_msgTypes[10]
 	if x != nil {
-		return x.Status
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return ""
+	return mi.MessageOf(x)
 }
 
-func (x *Trip) GetUserID() string {
+// Deprecated: Use ListTripsRequest.ProtoReflect.Descriptor instead.
+func (*ListTripsRequest) Descriptor() ([]byte, []int) {
+	return file_trip_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ListTripsRequest) GetUserID() string {
 	if x != nil {
 		return x.UserID
 	}
 	return ""
 }
 
-func (x *Trip) GetDriver() *TripDriver {
+func (x *ListTripsRequest) GetDriverID() string {
 	if x != nil {
-		return x.Driver
+		return x.DriverID
+	}
+	return ""
+}
+
+func (x *ListTripsRequest) GetStatuses() []TripStatus {
+	if x != nil {
+		return x.Statuses
 	}
 	return nil
 }
 
-// Static driver object that is used to store the driver info
-type TripDriver struct {
-	state          protoimpl.MessageState `protogen:"open.v1"`
-	Id             string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Name           string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
-	ProfilePicture string                 `protobuf:"bytes,3,opt,name=profilePicture,proto3" json:"profilePicture,omitempty"`
-	carPlate      string                 `protobuf:"bytes,4,opt,name=carPlate,proto3" json:"carPlate,omitempty"`
-	unknownFields  protoimpl.UnknownFields
-	sizeCache      protoimpl.SizeCache
+func (x *ListTripsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
 }
 
-func (x *TripDriver) Reset() {
-	*x = TripDriver{}
-	mi := &file_trip_proto_msgTypes[9]
+func (x *ListTripsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+type ListTripsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Trips []*Trip                `protobuf:"bytes,1,rep,name=trips,proto3" json:"trips,omitempty"`
+	// Empty on the last page.
+	NextPageToken string `protobuf:"bytes,2,opt,name=nextPageToken,proto3" json:"nextPageToken,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTripsResponse) Reset() {
+	*x = ListTripsResponse{}
+	mi := &file_trip_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *TripDriver) String() string {
+func (x *ListTripsResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*TripDriver) ProtoMessage() {}
+func (*ListTripsResponse) ProtoMessage() {}
 
-func (x *TripDriver) ProtoReflect() protoreflect.Message {
-	mi := &file_trip_proto_msgTypes[9]
+func (x *ListTripsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -589,94 +813,844 @@ func (x *TripDriver) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use TripDriver.ProtoReflect.Descriptor instead.
-func (*TripDriver) Descriptor() ([]byte, []int) {
-	return file_trip_proto_rawDescGZIP(), []int{9}
+// Deprecated: Use ListTripsResponse.ProtoReflect.Descriptor instead.
+func (*ListTripsResponse) Descriptor() ([]byte, []int) {
+	return file_trip_proto_rawDescGZIP(), []int{11}
 }
 
-func (x *TripDriver) GetId() string {
+func (x *ListTripsResponse) GetTrips() []*Trip {
 	if x != nil {
-		return x.Id
+		return x.Trips
+	}
+	return nil
+}
+
+func (x *ListTripsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
 	}
 	return ""
 }
 
-func (x *TripDriver) GetName() string {
+// Cancels a trip on behalf of its rider (userID) or of its assigned driver
+// (driverID). Exactly one of them is set.
+type CancelTripRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TripID        string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	UserID        string                 `protobuf:"bytes,2,opt,name=userID,proto3" json:"userID,omitempty"`
+	DriverID      string                 `protobuf:"bytes,3,opt,name=driverID,proto3" json:"driverID,omitempty"`
+	Reason        string                 `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelTripRequest) Reset() {
+	*x = CancelTripRequest{}
+	mi := &file_trip_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelTripRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelTripRequest) ProtoMessage() {}
+
+func (x *CancelTripRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_proto_msgTypes[12]
 	if x != nil {
-		return x.Name
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelTripRequest.ProtoReflect.Descriptor instead.
+func (*CancelTripRequest) Descriptor() ([]byte, []int) {
+	return file_trip_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *CancelTripRequest) GetTripID() string {
+	if x != nil {
+		return x.TripID
 	}
 	return ""
 }
 
-func (x *TripDriver) GetProfilePicture() string {
+func (x *CancelTripRequest) GetUserID() string {
 	if x != nil {
-		return x.ProfilePicture
+		return x.UserID
 	}
 	return ""
 }
 
-func (x *TripDriver) GetcarPlate() string {
+func (x *CancelTripRequest) GetDriverID() string {
 	if x != nil {
-		return x.carPlate
+		return x.DriverID
 	}
 	return ""
 }
 
-var File_trip_proto protoreflect.FileDescriptor
+func (x *CancelTripRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
 
-const file_trip_proto_rawDesc = "" +
-	"\n" +
-	"\n" +
-	"trip.proto\x12\x04trip\"\x98\x01\n" +
-	"\x12PreviewTripRequest\x12\x16\n" +
-	"\x06userID\x18\x01 \x01(\tR\x06userID\x126\n" +
-	"\rstartLocation\x18\x02 \x01(\v2\x10.trip.CoordinateR\rstartLocation\x122\n" +
-	"\vendLocation\x18\x03 \x01(\v2\x10.trip.CoordinateR\vendLocation\"~\n" +
-	"\x13PreviewTripResponse\x12\x16\n" +
-	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12!\n" +
-	"\x05route\x18\x02 \x01(\v2\v.trip.RouteR\x05route\x12,\n" +
-	"\trideFares\x18\x03 \x03(\v2\x0e.trip.RideFareR\trideFares\"k\n" +
-	"\x05Route\x12*\n" +
-	"\bgeometry\x18\x01 \x03(\v2\x0e.trip.GeometryR\bgeometry\x12\x1a\n" +
-	"\bdistance\x18\x02 \x01(\x01R\bdistance\x12\x1a\n" +
-	"\bduration\x18\x03 \x01(\x01R\bduration\">\n" +
-	"\bGeometry\x122\n" +
-	"\vcoordinates\x18\x01 \x03(\v2\x10.trip.CoordinateR\vcoordinates\"F\n" +
-	"\n" +
-	"Coordinate\x12\x1a\n" +
-	"\blatitude\x18\x01 \x01(\x01R\blatitude\x12\x1c\n" +
-	"\tlongitude\x18\x02 \x01(\x01R\tlongitude\"\x82\x01\n" +
-	"\bRideFare\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
-	"\x06userID\x18\x02 \x01(\tR\x06userID\x12 \n" +
-	"\vpackageSlug\x18\x03 \x01(\tR\vpackageSlug\x12,\n" +
-	"\x11totalPriceInCents\x18\x04 \x01(\x01R\x11totalPriceInCents\"J\n" +
-	"\x10CreteTripRequest\x12\x1e\n" +
-	"\n" +
-	"rideFareID\x18\x01 \x01(\tR\n" +
-	"rideFareID\x12\x16\n" +
-	"\x06userID\x18\x02 \x01(\tR\x06userID\"L\n" +
-	"\x12CreateTripResponse\x12\x16\n" +
-	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12\x1e\n" +
-	"\x04trip\x18\x02 \x01(\v2\n" +
-	".trip.TripR\x04trip\"\xc7\x01\n" +
-	"\x04Trip\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\tR\x02id\x122\n" +
-	"\fselectedFare\x18\x02 \x01(\v2\x0e.trip.RideFareR\fselectedFare\x12!\n" +
-	"\x05route\x18\x03 \x01(\v2\v.trip.RouteR\x05route\x12\x16\n" +
-	"\x06status\x18\x04 \x01(\tR\x06status\x12\x16\n" +
-	"\x06userID\x18\x05 \x01(\tR\x06userID\x12(\n" +
-	"\x06driver\x18\x06 \x01(\v2\x10.trip.TripDriverR\x06driver\"v\n" +
-	"\n" +
-	"TripDriver\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
-	"\x04name\x18\x02 \x01(\tR\x04name\x12&\n" +
-	"\x0eprofilePicture\x18\x03 \x01(\tR\x0eprofilePicture\x12\x1c\n" +
-	"\tcarPlate\x18\x04 \x01(\tR\tcarPlate2\x91\x01\n" +
-	"\vTripService\x12B\n" +
-	"\vPreviewTrip\x12\x18.trip.PreviewTripRequest\x1a\x19.trip.PreviewTripResponse\x12>\n" +
+type CancelTripResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Trip          *Trip                  `protobuf:"bytes,1,opt,name=trip,proto3" json:"trip,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelTripResponse) Reset() {
+	*x = CancelTripResponse{}
+	mi := &file_trip_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelTripResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelTripResponse) ProtoMessage() {}
+
+func (x *CancelTripResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelTripResponse.ProtoReflect.Descriptor instead.
+func (*CancelTripResponse) Descriptor() ([]byte, []int) {
+	return file_trip_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *CancelTripResponse) GetTrip() *Trip {
+	if x != nil {
+		return x.Trip
+	}
+	return nil
+}
+
+// The assigned driver reached the pickup location.
+type ArriveAtPickupRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TripID        string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	DriverID      string                 `protobuf:"bytes,2,opt,name=driverID,proto3" json:"driverID,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ArriveAtPickupRequest) Reset() {
+	*x = ArriveAtPickupRequest{}
+	mi := &file_trip_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ArriveAtPickupRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ArriveAtPickupRequest) ProtoMessage() {}
+
+func (x *ArriveAtPickupRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ArriveAtPickupRequest.ProtoReflect.Descriptor instead.
+func (*ArriveAtPickupRequest) Descriptor() ([]byte, []int) {
+	return file_trip_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ArriveAtPickupRequest) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *ArriveAtPickupRequest) GetDriverID() string {
+	if x != nil {
+		return x.DriverID
+	}
+	return ""
+}
+
+type ArriveAtPickupResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Trip          *Trip                  `protobuf:"bytes,1,opt,name=trip,proto3" json:"trip,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ArriveAtPickupResponse) Reset() {
+	*x = ArriveAtPickupResponse{}
+	mi := &file_trip_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ArriveAtPickupResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ArriveAtPickupResponse) ProtoMessage() {}
+
+func (x *ArriveAtPickupResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ArriveAtPickupResponse.ProtoReflect.Descriptor instead.
+func (*ArriveAtPickupResponse) Descriptor() ([]byte, []int) {
+	return file_trip_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ArriveAtPickupResponse) GetTrip() *Trip {
+	if x != nil {
+		return x.Trip
+	}
+	return nil
+}
+
+// The assigned driver picked the rider up.
+type StartRideRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TripID        string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	DriverID      string                 `protobuf:"bytes,2,opt,name=driverID,proto3" json:"driverID,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StartRideRequest) Reset() {
+	*x = StartRideRequest{}
+	mi := &file_trip_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartRideRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartRideRequest) ProtoMessage() {}
+
+func (x *StartRideRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartRideRequest.ProtoReflect.Descriptor instead.
+func (*StartRideRequest) Descriptor() ([]byte, []int) {
+	return file_trip_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *StartRideRequest) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *StartRideRequest) GetDriverID() string {
+	if x != nil {
+		return x.DriverID
+	}
+	return ""
+}
+
+type StartRideResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Trip          *Trip                  `protobuf:"bytes,1,opt,name=trip,proto3" json:"trip,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StartRideResponse) Reset() {
+	*x = StartRideResponse{}
+	mi := &file_trip_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartRideResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartRideResponse) ProtoMessage() {}
+
+func (x *StartRideResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartRideResponse.ProtoReflect.Descriptor instead.
+func (*StartRideResponse) Descriptor() ([]byte, []int) {
+	return file_trip_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *StartRideResponse) GetTrip() *Trip {
+	if x != nil {
+		return x.Trip
+	}
+	return nil
+}
+
+// The assigned driver dropped the rider off.
+type CompleteTripRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TripID        string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	DriverID      string                 `protobuf:"bytes,2,opt,name=driverID,proto3" json:"driverID,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CompleteTripRequest) Reset() {
+	*x = CompleteTripRequest{}
+	mi := &file_trip_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CompleteTripRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CompleteTripRequest) ProtoMessage() {}
+
+func (x *CompleteTripRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CompleteTripRequest.ProtoReflect.Descriptor instead.
+func (*CompleteTripRequest) Descriptor() ([]byte, []int) {
+	return file_trip_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *CompleteTripRequest) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *CompleteTripRequest) GetDriverID() string {
+	if x != nil {
+		return x.DriverID
+	}
+	return ""
+}
+
+type CompleteTripResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Trip          *Trip                  `protobuf:"bytes,1,opt,name=trip,proto3" json:"trip,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CompleteTripResponse) Reset() {
+	*x = CompleteTripResponse{}
+	mi := &file_trip_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CompleteTripResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CompleteTripResponse) ProtoMessage() {}
+
+func (x *CompleteTripResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CompleteTripResponse.ProtoReflect.Descriptor instead.
+func (*CompleteTripResponse) Descriptor() ([]byte, []int) {
+	return file_trip_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *CompleteTripResponse) GetTrip() *Trip {
+	if x != nil {
+		return x.Trip
+	}
+	return nil
+}
+
+// Streams the updates of a trip. The stream starts with a snapshot, or with
+// the updates after fromVersion when the server still buffers them, and ends
+// once the trip reaches a final status. A stream aborted because the client
+// read too slowly can be resumed with the last version it received.
+type WatchTripRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	TripID string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	// Last version the client received; 0 starts with a snapshot.
+	FromVersion   int64 `protobuf:"varint,2,opt,name=fromVersion,proto3" json:"fromVersion,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchTripRequest) Reset() {
+	*x = WatchTripRequest{}
+	mi := &file_trip_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchTripRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchTripRequest) ProtoMessage() {}
+
+func (x *WatchTripRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchTripRequest.ProtoReflect.Descriptor instead.
+func (*WatchTripRequest) Descriptor() ([]byte, []int) {
+	return file_trip_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *WatchTripRequest) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *WatchTripRequest) GetFromVersion() int64 {
+	if x != nil {
+		return x.FromVersion
+	}
+	return 0
+}
+
+type TripUpdate struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Kind    TripUpdateKind         `protobuf:"varint,1,opt,name=kind,proto3,enum=trip.TripUpdateKind" json:"kind,omitempty"`
+	Version int64                  `protobuf:"varint,2,opt,name=version,proto3" json:"version,omitempty"`
+	Trip    *Trip                  `protobuf:"bytes,3,opt,name=trip,proto3" json:"trip,omitempty"`
+	// Set on TRIP_UPDATE_KIND_LOCATION only.
+	DriverLocation *Coordinate `protobuf:"bytes,4,opt,name=driverLocation,proto3" json:"driverLocation,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *TripUpdate) Reset() {
+	*x = TripUpdate{}
+	mi := &file_trip_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TripUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TripUpdate) ProtoMessage() {}
+
+func (x *TripUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TripUpdate.ProtoReflect.Descriptor instead.
+func (*TripUpdate) Descriptor() ([]byte, []int) {
+	return file_trip_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *TripUpdate) GetKind() TripUpdateKind {
+	if x != nil {
+		return x.Kind
+	}
+	return TripUpdateKind_TRIP_UPDATE_KIND_UNSPECIFIED
+}
+
+func (x *TripUpdate) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *TripUpdate) GetTrip() *Trip {
+	if x != nil {
+		return x.Trip
+	}
+	return nil
+}
+
+func (x *TripUpdate) GetDriverLocation() *Coordinate {
+	if x != nil {
+		return x.DriverLocation
+	}
+	return nil
+}
+
+type Trip struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Id           string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	SelectedFare *RideFare              `protobuf:"bytes,2,opt,name=selectedFare,proto3" json:"selectedFare,omitempty"`
+	Route        *Route                 `protobuf:"bytes,3,opt,name=route,proto3" json:"route,omitempty"`
+	// status is a TripStatus in lowercase form, see TripStatus.
+	Status        string      `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
+	UserID        string      `protobuf:"bytes,5,opt,name=userID,proto3" json:"userID,omitempty"`
+	Driver        *TripDriver `protobuf:"bytes,6,opt,name=driver,proto3" json:"driver,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Trip) Reset() {
+	*x = Trip{}
+	mi := &file_trip_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Trip) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Trip) ProtoMessage() {}
+
+func (x *Trip) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Trip.ProtoReflect.Descriptor instead.
+func (*Trip) Descriptor() ([]byte, []int) {
+	return file_trip_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *Trip) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Trip) GetSelectedFare() *RideFare {
+	if x != nil {
+		return x.SelectedFare
+	}
+	return nil
+}
+
+func (x *Trip) GetRoute() *Route {
+	if x != nil {
+		return x.Route
+	}
+	return nil
+}
+
+func (x *Trip) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Trip) GetUserID() string {
+	if x != nil {
+		return x.UserID
+	}
+	return ""
+}
+
+func (x *Trip) GetDriver() *TripDriver {
+	if x != nil {
+		return x.Driver
+	}
+	return nil
+}
+
+// Static driver object that is used to store the driver info
+type TripDriver struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Id             string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name           string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	ProfilePicture string                 `protobuf:"bytes,3,opt,name=profilePicture,proto3" json:"profilePicture,omitempty"`
+	CarPlate       string                 `protobuf:"bytes,4,opt,name=carPlate,proto3" json:"carPlate,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *TripDriver) Reset() {
+	*x = TripDriver{}
+	mi := &file_trip_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TripDriver) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TripDriver) ProtoMessage() {}
+
+func (x *TripDriver) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TripDriver.ProtoReflect.Descriptor instead.
+func (*TripDriver) Descriptor() ([]byte, []int) {
+	return file_trip_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *TripDriver) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *TripDriver) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *TripDriver) GetProfilePicture() string {
+	if x != nil {
+		return x.ProfilePicture
+	}
+	return ""
+}
+
+func (x *TripDriver) GetCarPlate() string {
+	if x != nil {
+		return x.CarPlate
+	}
+	return ""
+}
+
+var File_trip_proto protoreflect.FileDescriptor
+
+const file_trip_proto_rawDesc = "" +
+	"\n" +
+	"\n" +
+	"trip.proto\x12\x04trip\"\x98\x01\n" +
+	"\x12PreviewTripRequest\x12\x16\n" +
+	"\x06userID\x18\x01 \x01(\tR\x06userID\x126\n" +
+	"\rstartLocation\x18\x02 \x01(\v2\x10.trip.CoordinateR\rstartLocation\x122\n" +
+	"\vendLocation\x18\x03 \x01(\v2\x10.trip.CoordinateR\vendLocation\"~\n" +
+	"\x13PreviewTripResponse\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12!\n" +
+	"\x05route\x18\x02 \x01(\v2\v.trip.RouteR\x05route\x12,\n" +
+	"\trideFares\x18\x03 \x03(\v2\x0e.trip.RideFareR\trideFares\"k\n" +
+	"\x05Route\x12*\n" +
+	"\bgeometry\x18\x01 \x03(\v2\x0e.trip.GeometryR\bgeometry\x12\x1a\n" +
+	"\bdistance\x18\x02 \x01(\x01R\bdistance\x12\x1a\n" +
+	"\bduration\x18\x03 \x01(\x01R\bduration\">\n" +
+	"\bGeometry\x122\n" +
+	"\vcoordinates\x18\x01 \x03(\v2\x10.trip.CoordinateR\vcoordinates\"F\n" +
+	"\n" +
+	"Coordinate\x12\x1a\n" +
+	"\blatitude\x18\x01 \x01(\x01R\blatitude\x12\x1c\n" +
+	"\tlongitude\x18\x02 \x01(\x01R\tlongitude\"\x82\x01\n" +
+	"\bRideFare\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
+	"\x06userID\x18\x02 \x01(\tR\x06userID\x12 \n" +
+	"\vpackageSlug\x18\x03 \x01(\tR\vpackageSlug\x12,\n" +
+	"\x11totalPriceInCents\x18\x04 \x01(\x01R\x11totalPriceInCents\"K\n" +
+	"\x11CreateTripRequest\x12\x1e\n" +
+	"\n" +
+	"rideFareID\x18\x01 \x01(\tR\n" +
+	"rideFareID\x12\x16\n" +
+	"\x06userID\x18\x02 \x01(\tR\x06userID\"L\n" +
+	"\x12CreateTripResponse\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12\x1e\n" +
+	"\x04trip\x18\x02 \x01(\v2\n" +
+	".trip.TripR\x04trip\"(\n" +
+	"\x0eGetTripRequest\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\"1\n" +
+	"\x0fGetTripResponse\x12\x1e\n" +
+	"\x04trip\x18\x01 \x01(\v2\n" +
+	".trip.TripR\x04trip\"\xae\x01\n" +
+	"\x10ListTripsRequest\x12\x16\n" +
+	"\x06userID\x18\x01 \x01(\tR\x06userID\x12\x1a\n" +
+	"\bdriverID\x18\x02 \x01(\tR\bdriverID\x12,\n" +
+	"\bstatuses\x18\x03 \x03(\x0e2\x10.trip.TripStatusR\bstatuses\x12\x1a\n" +
+	"\bpageSize\x18\x04 \x01(\x05R\bpageSize\x12\x1c\n" +
+	"\tpageToken\x18\x05 \x01(\tR\tpageToken\"[\n" +
+	"\x11ListTripsResponse\x12 \n" +
+	"\x05trips\x18\x01 \x03(\v2\n" +
+	".trip.TripR\x05trips\x12$\n" +
+	"\rnextPageToken\x18\x02 \x01(\tR\rnextPageToken\"w\n" +
+	"\x11CancelTripRequest\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12\x16\n" +
+	"\x06userID\x18\x02 \x01(\tR\x06userID\x12\x1a\n" +
+	"\bdriverID\x18\x03 \x01(\tR\bdriverID\x12\x16\n" +
+	"\x06reason\x18\x04 \x01(\tR\x06reason\"4\n" +
+	"\x12CancelTripResponse\x12\x1e\n" +
+	"\x04trip\x18\x01 \x01(\v2\n" +
+	".trip.TripR\x04trip\"K\n" +
+	"\x15ArriveAtPickupRequest\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12\x1a\n" +
+	"\bdriverID\x18\x02 \x01(\tR\bdriverID\"8\n" +
+	"\x16ArriveAtPickupResponse\x12\x1e\n" +
+	"\x04trip\x18\x01 \x01(\v2\n" +
+	".trip.TripR\x04trip\"F\n" +
+	"\x10StartRideRequest\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12\x1a\n" +
+	"\bdriverID\x18\x02 \x01(\tR\bdriverID\"3\n" +
+	"\x11StartRideResponse\x12\x1e\n" +
+	"\x04trip\x18\x01 \x01(\v2\n" +
+	".trip.TripR\x04trip\"I\n" +
+	"\x13CompleteTripRequest\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12\x1a\n" +
+	"\bdriverID\x18\x02 \x01(\tR\bdriverID\"6\n" +
+	"\x14CompleteTripResponse\x12\x1e\n" +
+	"\x04trip\x18\x01 \x01(\v2\n" +
+	".trip.TripR\x04trip\"L\n" +
+	"\x10WatchTripRequest\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12 \n" +
+	"\vfromVersion\x18\x02 \x01(\x03R\vfromVersion\"\xaa\x01\n" +
+	"\n" +
+	"TripUpdate\x12(\n" +
+	"\x04kind\x18\x01 \x01(\x0e2\x14.trip.TripUpdateKindR\x04kind\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\x03R\aversion\x12\x1e\n" +
+	"\x04trip\x18\x03 \x01(\v2\n" +
+	".trip.TripR\x04trip\x128\n" +
+	"\x0edriverLocation\x18\x04 \x01(\v2\x10.trip.CoordinateR\x0edriverLocation\"\xc7\x01\n" +
+	"\x04Trip\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x122\n" +
+	"\fselectedFare\x18\x02 \x01(\v2\x0e.trip.RideFareR\fselectedFare\x12!\n" +
+	"\x05route\x18\x03 \x01(\v2\v.trip.RouteR\x05route\x12\x16\n" +
+	"\x06status\x18\x04 \x01(\tR\x06status\x12\x16\n" +
+	"\x06userID\x18\x05 \x01(\tR\x06userID\x12(\n" +
+	"\x06driver\x18\x06 \x01(\v2\x10.trip.TripDriverR\x06driver\"t\n" +
+	"\n" +
+	"TripDriver\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12&\n" +
+	"\x0eprofilePicture\x18\x03 \x01(\tR\x0eprofilePicture\x12\x1a\n" +
+	"\bcarPlate\x18\x04 \x01(\tR\bcarPlate*\xaa\x01\n" +
+	"\x0eTripUpdateKind\x12 \n" +
+	"\x1cTRIP_UPDATE_KIND_UNSPECIFIED\x10\x00\x12\x1d\n" +
+	"\x19TRIP_UPDATE_KIND_SNAPSHOT\x10\x01\x12\x1b\n" +
+	"\x17TRIP_UPDATE_KIND_STATUS\x10\x02\x12\x1b\n" +
+	"\x17TRIP_UPDATE_KIND_DRIVER\x10\x03\x12\x1d\n" +
+	"\x19TRIP_UPDATE_KIND_LOCATION\x10\x04*\xcb\x02\n" +
+	"\n" +
+	"TripStatus\x12\x1b\n" +
+	"\x17TRIP_STATUS_UNSPECIFIED\x10\x00\x12\x19\n" +
+	"\x15TRIP_STATUS_REQUESTED\x10\x01\x12\x19\n" +
+	"\x15TRIP_STATUS_SEARCHING\x10\x02\x12\x1f\n" +
+	"\x1bTRIP_STATUS_DRIVER_ASSIGNED\x10\x03\x12\x1e\n" +
+	"\x1aTRIP_STATUS_DRIVER_ARRIVED\x10\x04\x12\x1b\n" +
+	"\x17TRIP_STATUS_IN_PROGRESS\x10\x05\x12\x19\n" +
+	"\x15TRIP_STATUS_COMPLETED\x10\x06\x12\x19\n" +
+	"\x15TRIP_STATUS_CANCELLED\x10\a\x12 \n" +
+	"\x1cTRIP_STATUS_NO_DRIVERS_FOUND\x10\b\x12\x14\n" +
+	"\x10TRIP_STATUS_PAID\x10\t\x12\x1e\n" +
+	"\x1aTRIP_STATUS_PAYMENT_FAILED\x10\n" +
+	"2\xd4\x04\n" +
+	"\vTripService\x12B\n" +
+	"\vPreviewTrip\x12\x18.trip.PreviewTripRequest\x1a\x19.trip.PreviewTripResponse\x12?\n" +
+	"\n" +
+	"CreateTrip\x12\x17.trip.CreateTripRequest\x1a\x18.trip.CreateTripResponse\x126\n" +
+	"\aGetTrip\x12\x14.trip.GetTripRequest\x1a\x15.trip.GetTripResponse\x12<\n" +
+	"\tListTrips\x12\x16.trip.ListTripsRequest\x1a\x17.trip.ListTripsResponse\x12?\n" +
 	"\n" +
-	"CreateTrip\x12\x16.trip.CreteTripRequest\x1a\x18.trip.CreateTripResponseB\x18Z\x16shared/proto/trip;tripb\x06proto3"
+	"CancelTrip\x12\x17.trip.CancelTripRequest\x1a\x18.trip.CancelTripResponse\x12K\n" +
+	"\x0eArriveAtPickup\x12\x1b.trip.ArriveAtPickupRequest\x1a\x1c.trip.ArriveAtPickupResponse\x12<\n" +
+	"\tStartRide\x12\x16.trip.StartRideRequest\x1a\x17.trip.StartRideResponse\x12E\n" +
+	"\fCompleteTrip\x12\x19.trip.CompleteTripRequest\x1a\x1a.trip.CompleteTripResponse\x127\n" +
+	"\tWatchTrip\x12\x16.trip.WatchTripRequest\x1a\x10.trip.TripUpdate0\x01B\x18Z\x16shared/proto/trip;tripb\x06proto3"
 
 var (
 	file_trip_proto_rawDescOnce sync.Once
@@ -690,39 +1664,80 @@ func file_trip_proto_rawDescGZIP() []byte {
 	return file_trip_proto_rawDescData
 }
 
-var file_trip_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_trip_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_trip_proto_msgTypes = make([]protoimpl.MessageInfo, 24)
 var file_trip_proto_goTypes = []any{
-	(*PreviewTripRequest)(nil),  // 0: trip.PreviewTripRequest
-	(*PreviewTripResponse)(nil), // 1: trip.PreviewTripResponse
-	(*Route)(nil),               // 2: trip.Route
-	(*Geometry)(nil),            // 3: trip.Geometry
-	(*Coordinate)(nil),          // 4: trip.Coordinate
-	(*RideFare)(nil),            // 5: trip.RideFare
-	(*CreteTripRequest)(nil),    // 6: trip.CreteTripRequest
-	(*CreateTripResponse)(nil),  // 7: trip.CreateTripResponse
-	(*Trip)(nil),                // 8: trip.Trip
-	(*TripDriver)(nil),          // 9: trip.TripDriver
+	(TripUpdateKind)(0),            // 0: trip.TripUpdateKind
+	(TripStatus)(0),                // 1: trip.TripStatus
+	(*PreviewTripRequest)(nil),     // 2: trip.PreviewTripRequest
+	(*PreviewTripResponse)(nil),    // 3: trip.PreviewTripResponse
+	(*Route)(nil),                  // 4: trip.Route
+	(*Geometry)(nil),               // 5: trip.Geometry
+	(*Coordinate)(nil),             // 6: trip.Coordinate
+	(*RideFare)(nil),               // 7: trip.RideFare
+	(*CreateTripRequest)(nil),      // 8: trip.CreateTripRequest
+	(*CreateTripResponse)(nil),     // 9: trip.CreateTripResponse
+	(*GetTripRequest)(nil),         // 10: trip.GetTripRequest
+	(*GetTripResponse)(nil),        // 11: trip.GetTripResponse
+	(*ListTripsRequest)(nil),       // 12: trip.ListTripsRequest
+	(*ListTripsResponse)(nil),      // 13: trip.ListTripsResponse
+	(*CancelTripRequest)(nil),      // 14: trip.CancelTripRequest
+	(*CancelTripResponse)(nil),     // 15: trip.CancelTripResponse
+	(*ArriveAtPickupRequest)(nil),  // 16: trip.ArriveAtPickupRequest
+	(*ArriveAtPickupResponse)(nil), // 17: trip.ArriveAtPickupResponse
+	(*StartRideRequest)(nil),       // 18: trip.StartRideRequest
+	(*StartRideResponse)(nil),      // 19: trip.StartRideResponse
+	(*CompleteTripRequest)(nil),    // 20: trip.CompleteTripRequest
+	(*CompleteTripResponse)(nil),   // 21: trip.CompleteTripResponse
+	(*WatchTripRequest)(nil),       // 22: trip.WatchTripRequest
+	(*TripUpdate)(nil),             // 23: trip.TripUpdate
+	(*Trip)(nil),                   // 24: trip.Trip
+	(*TripDriver)(nil),             // 25: trip.TripDriver
 }
 var file_trip_proto_depIdxs = []int32{
-	4,  // 0: trip.PreviewTripRequest.startLocation:type_name -> trip.Coordinate
-	4,  // 1: trip.PreviewTripRequest.endLocation:type_name -> trip.Coordinate
-	2,  // 2: trip.PreviewTripResponse.route:type_name -> trip.Route
-	5,  // 3: trip.PreviewTripResponse.rideFares:type_name -> trip.RideFare
-	3,  // 4: trip.Route.geometry:type_name -> trip.Geometry
-	4,  // 5: trip.Geometry.coordinates:type_name -> trip.Coordinate
-	8,  // 6: trip.CreateTripResponse.trip:type_name -> trip.Trip
-	5,  // 7: trip.Trip.selectedFare:type_name -> trip.RideFare
-	2,  // 8: trip.Trip.route:type_name -> trip.Route
-	9,  // 9: trip.Trip.driver:type_name -> trip.TripDriver
-	0,  // 10: trip.TripService.PreviewTrip:input_type -> trip.PreviewTripRequest
-	6,  // 11: trip.TripService.CreateTrip:input_type -> trip.CreteTripRequest
-	1,  // 12: trip.TripService.PreviewTrip:output_type -> trip.PreviewTripResponse
-	7,  // 13: trip.TripService.CreateTrip:output_type -> trip.CreateTripResponse
-	12, // [12:14] is the sub-list for method output_type
-	10, // [10:12] is the sub-list for method input_type
-	10, // [10:10] is the sub-list for extension type_name
-	10, // [10:10] is the sub-list for extension extendee
-	0,  // [0:10] is the sub-list for field type_name
+	6,  // 0: trip.PreviewTripRequest.startLocation:type_name -> trip.Coordinate
+	6,  // 1: trip.PreviewTripRequest.endLocation:type_name -> trip.Coordinate
+	4,  // 2: trip.PreviewTripResponse.route:type_name -> trip.Route
+	7,  // 3: trip.PreviewTripResponse.rideFares:type_name -> trip.RideFare
+	5,  // 4: trip.Route.geometry:type_name -> trip.Geometry
+	6,  // 5: trip.Geometry.coordinates:type_name -> trip.Coordinate
+	24, // 6: trip.CreateTripResponse.trip:type_name -> trip.Trip
+	24, // 7: trip.GetTripResponse.trip:type_name -> trip.Trip
+	1,  // 8: trip.ListTripsRequest.statuses:type_name -> trip.TripStatus
+	24, // 9: trip.ListTripsResponse.trips:type_name -> trip.Trip
+	24, // 10: trip.CancelTripResponse.trip:type_name -> trip.Trip
+	24, // 11: trip.ArriveAtPickupResponse.trip:type_name -> trip.Trip
+	24, // 12: trip.StartRideResponse.trip:type_name -> trip.Trip
+	24, // 13: trip.CompleteTripResponse.trip:type_name -> trip.Trip
+	0,  // 14: trip.TripUpdate.kind:type_name -> trip.TripUpdateKind
+	24, // 15: trip.TripUpdate.trip:type_name -> trip.Trip
+	6,  // 16: trip.TripUpdate.driverLocation:type_name -> trip.Coordinate
+	7,  // 17: trip.Trip.selectedFare:type_name -> trip.RideFare
+	4,  // 18: trip.Trip.route:type_name -> trip.Route
+	25, // 19: trip.Trip.driver:type_name -> trip.TripDriver
+	2,  // 20: trip.TripService.PreviewTrip:input_type -> trip.PreviewTripRequest
+	8,  // 21: trip.TripService.CreateTrip:input_type -> trip.CreateTripRequest
+	10, // 22: trip.TripService.GetTrip:input_type -> trip.GetTripRequest
+	12, // 23: trip.TripService.ListTrips:input_type -> trip.ListTripsRequest
+	14, // 24: trip.TripService.CancelTrip:input_type -> trip.CancelTripRequest
+	16, // 25: trip.TripService.ArriveAtPickup:input_type -> trip.ArriveAtPickupRequest
+	18, // 26: trip.TripService.StartRide:input_type -> trip.StartRideRequest
+	20, // 27: trip.TripService.CompleteTrip:input_type -> trip.CompleteTripRequest
+	22, // 28: trip.TripService.WatchTrip:input_type -> trip.WatchTripRequest
+	3,  // 29: trip.TripService.PreviewTrip:output_type -> trip.PreviewTripResponse
+	9,  // 30: trip.TripService.CreateTrip:output_type -> trip.CreateTripResponse
+	11, // 31: trip.TripService.GetTrip:output_type -> trip.GetTripResponse
+	13, // 32: trip.TripService.ListTrips:output_type -> trip.ListTripsResponse
+	15, // 33: trip.TripService.CancelTrip:output_type -> trip.CancelTripResponse
+	17, // 34: trip.TripService.ArriveAtPickup:output_type -> trip.ArriveAtPickupResponse
+	19, // 35: trip.TripService.StartRide:output_type -> trip.StartRideResponse
+	21, // 36: trip.TripService.CompleteTrip:output_type -> trip.CompleteTripResponse
+	23, // 37: trip.TripService.WatchTrip:output_type -> trip.TripUpdate
+	29, // [29:38] is the sub-list for method output_type
+	20, // [20:29] is the sub-list for method input_type
+	20, // [20:20] is the sub-list for extension type_name
+	20, // [20:20] is the sub-list for extension extendee
+	0,  // [0:20] is the sub-list for field type_name
 }
 
 func init() { file_trip_proto_init() }
@@ -735,13 +1750,14 @@ func file_trip_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_trip_proto_rawDesc), len(file_trip_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   10,
+			NumEnums:      2,
+			NumMessages:   24,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_trip_proto_goTypes,
 		DependencyIndexes: file_trip_proto_depIdxs,
+		EnumInfos:         file_trip_proto_enumTypes,
 		MessageInfos:      file_trip_proto_msgTypes,
 	}.Build()
 	File_trip_proto = out.File
@@ -0,0 +1,228 @@
+package resp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Options configures a Client.
+type Options struct {
+	Addr     string
+	Username string
+	Password string
+	DB       int
+	// DialTimeout bounds connecting and the AUTH/SELECT handshake.
+	DialTimeout time.Duration
+	// PoolSize is the maximum number of idle connections kept for reuse.
+	PoolSize int
+}
+
+// ParseURL parses redis://[[user]:password@]host[:port][/db].
+func ParseURL(rawURL string) (Options, error) {
+	opts := Options{DialTimeout: 5 * time.Second, PoolSize: 8}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return opts, fmt.Errorf("resp: invalid url: %w", err)
+	}
+	if u.Scheme != "redis" {
+		return opts, fmt.Errorf("resp: unsupported scheme %q", u.Scheme)
+	}
+
+	opts.Addr = u.Host
+	if u.Port() == "" {
+		opts.Addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		opts.Username = u.User.Username()
+		opts.Password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if opts.DB, err = strconv.Atoi(db); err != nil {
+			return opts, fmt.Errorf("resp: invalid database %q", db)
+		}
+	}
+	return opts, nil
+}
+
+// ErrClosed is returned by a closed Client.
+var ErrClosed = errors.New("resp: client closed")
+
+// Client is a pool of connections to one server. It is safe for concurrent use.
+type Client struct {
+	opts Options
+
+	mu     sync.Mutex
+	idle   []*Conn
+	closed bool
+}
+
+// NewClient returns a client; connections are dialed on demand.
+func NewClient(opts Options) *Client {
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = 8
+	}
+	return &Client{opts: opts}
+}
+
+// Do runs a command on a pooled connection. Error replies are returned as
+// ServerError together with the reply value.
+func (c *Client) Do(ctx context.Context, args ...string) (Value, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return Value{}, err
+	}
+	v, err := cn.Do(ctx, args...)
+	c.put(cn)
+	return v, err
+}
+
+// Conn returns a dedicated connection, e.g. for blocking reads. The caller
+// must Close it.
+func (c *Client) Conn(ctx context.Context) (*Conn, error) {
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return nil, ErrClosed
+	}
+	return Dial(ctx, c.opts)
+}
+
+// Close closes idle connections; connections in use are closed when returned.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	idle := c.idle
+	c.idle = nil
+	c.closed = true
+	c.mu.Unlock()
+
+	for _, cn := range idle {
+		cn.Close()
+	}
+	return nil
+}
+
+func (c *Client) get(ctx context.Context) (*Conn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if n := len(c.idle); n > 0 {
+		cn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return cn, nil
+	}
+	c.mu.Unlock()
+
+	return Dial(ctx, c.opts)
+}
+
+func (c *Client) put(cn *Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cn.broken.Load() || c.closed || len(c.idle) >= c.opts.PoolSize {
+		cn.Close()
+		return
+	}
+	c.idle = append(c.idle, cn)
+}
+
+// Conn is a single connection. It is not safe for concurrent use, except
+// that Close may be called while another goroutine is blocked in Do.
+type Conn struct {
+	nc     net.Conn
+	r      *Reader
+	w      *Writer
+	broken atomic.Bool
+}
+
+// Dial connects to the server and runs AUTH and SELECT as configured.
+func Dial(ctx context.Context, opts Options) (*Conn, error) {
+	dialer := net.Dialer{Timeout: opts.DialTimeout}
+	nc, err := dialer.DialContext(ctx, "tcp", opts.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	cn := &Conn{nc: nc, r: NewReader(nc), w: NewWriter(nc)}
+
+	hctx := ctx
+	if opts.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		hctx, cancel = context.WithTimeout(ctx, opts.DialTimeout)
+		defer cancel()
+	}
+	if opts.Password != "" {
+		args := []string{"AUTH", opts.Password}
+		if opts.Username != "" {
+			args = []string{"AUTH", opts.Username, opts.Password}
+		}
+		if _, err := cn.Do(hctx, args...); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	if opts.DB != 0 {
+		if _, err := cn.Do(hctx, "SELECT", strconv.Itoa(opts.DB)); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+// Do sends a command and reads its reply. The context deadline applies to
+// the whole round trip; a network error marks the connection broken.
+func (cn *Conn) Do(ctx context.Context, args ...string) (Value, error) {
+	if cn.broken.Load() {
+		return Value{}, fmt.Errorf("resp: connection broken")
+	}
+
+	deadline, _ := ctx.Deadline()
+	cn.nc.SetDeadline(deadline)
+
+	// Expiring the deadline interrupts a blocked read when ctx is cancelled.
+	stop := context.AfterFunc(ctx, func() { cn.nc.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	if err := cn.w.WriteCommand(args...); err != nil {
+		return Value{}, cn.fail(ctx, err)
+	}
+	if err := cn.w.Flush(); err != nil {
+		return Value{}, cn.fail(ctx, err)
+	}
+	v, err := cn.r.ReadValue()
+	if err != nil {
+		return Value{}, cn.fail(ctx, err)
+	}
+	if v.Kind == KindError {
+		return v, ServerError(v.Str)
+	}
+	return v, nil
+}
+
+func (cn *Conn) fail(ctx context.Context, err error) error {
+	cn.broken.Store(true)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// Close closes the connection.
+func (cn *Conn) Close() error {
+	cn.broken.Store(true)
+	return cn.nc.Close()
+}
@@ -0,0 +1,247 @@
+/*
+Package resp implements the Redis serialization protocol (RESP2) and a small
+pooled client. It covers what the Redis Streams event backend needs and is
+shared with the embedded server in shared/miniredis.
+*/
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Kind is the RESP type marker of a value.
+type Kind byte
+
+const (
+	KindSimple  Kind = '+'
+	KindError   Kind = '-'
+	KindInteger Kind = ':'
+	KindBulk    Kind = '$'
+	KindArray   Kind = '*'
+)
+
+// Value is a decoded RESP value. Null bulk strings and null arrays have Null set.
+type Value struct {
+	Kind  Kind
+	Str   string
+	Int   int64
+	Array []Value
+	Null  bool
+}
+
+// Simple returns a simple string reply such as OK.
+func Simple(s string) Value { return Value{Kind: KindSimple, Str: s} }
+
+// ErrorValue returns an error reply. By convention msg starts with an
+// upper case error code, e.g. "ERR unknown command".
+func ErrorValue(msg string) Value { return Value{Kind: KindError, Str: msg} }
+
+// Int returns an integer reply.
+func Int(n int64) Value { return Value{Kind: KindInteger, Int: n} }
+
+// Bulk returns a bulk string reply.
+func Bulk(s string) Value { return Value{Kind: KindBulk, Str: s} }
+
+// NullBulk returns the null bulk string.
+func NullBulk() Value { return Value{Kind: KindBulk, Null: true} }
+
+// Array returns an array reply.
+func Array(values ...Value) Value {
+	if values == nil {
+		values = []Value{}
+	}
+	return Value{Kind: KindArray, Array: values}
+}
+
+// NullArray returns the null array, used e.g. when a blocking read times out.
+func NullArray() Value { return Value{Kind: KindArray, Null: true} }
+
+// BulkStrings returns an array of bulk strings.
+func BulkStrings(values ...string) Value {
+	out := make([]Value, len(values))
+	for i, v := range values {
+		out[i] = Bulk(v)
+	}
+	return Array(out...)
+}
+
+// Text returns the string content of a simple or bulk string.
+func (v Value) Text() string {
+	if v.Kind == KindInteger {
+		return strconv.FormatInt(v.Int, 10)
+	}
+	return v.Str
+}
+
+// Strings returns the elements of an array of strings.
+func (v Value) Strings() []string {
+	out := make([]string, len(v.Array))
+	for i, e := range v.Array {
+		out[i] = e.Text()
+	}
+	return out
+}
+
+// ServerError is an error reply returned by the server.
+type ServerError string
+
+func (e ServerError) Error() string { return string(e) }
+
+// Code returns the error code, the first word of the message, e.g. BUSYGROUP.
+func (e ServerError) Code() string {
+	code, _, _ := strings.Cut(string(e), " ")
+	return code
+}
+
+// IsServerError reports whether err is an error reply with the given code.
+func IsServerError(err error, code string) bool {
+	var se ServerError
+	return errors.As(err, &se) && se.Code() == code
+}
+
+// ErrProtocol is returned when the peer sends malformed data.
+var ErrProtocol = errors.New("resp: protocol error")
+
+// maxBulkLength bounds bulk strings so that a corrupt length cannot exhaust memory.
+const maxBulkLength = 512 << 20
+
+// Reader decodes RESP values.
+type Reader struct {
+	br *bufio.Reader
+}
+
+// NewReader returns a Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{br: bufio.NewReader(r)}
+}
+
+// ReadValue reads the next value. Lines that do not start with a type marker
+// are parsed as inline commands, as sent by telnet or redis-cli --pipe.
+func (r *Reader) ReadValue() (Value, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return Value{}, err
+	}
+	if line == "" {
+		return Value{}, fmt.Errorf("%w: empty line", ErrProtocol)
+	}
+
+	kind, rest := Kind(line[0]), line[1:]
+	switch kind {
+	case KindSimple:
+		return Simple(rest), nil
+	case KindError:
+		return ErrorValue(rest), nil
+	case KindInteger:
+		n, err := strconv.ParseInt(rest, 10, 64)
+		if err != nil {
+			return Value{}, fmt.Errorf("%w: bad integer %q", ErrProtocol, rest)
+		}
+		return Int(n), nil
+	case KindBulk:
+		n, err := r.length(rest)
+		if err != nil || n < 0 {
+			return NullBulk(), err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r.br, buf); err != nil {
+			return Value{}, err
+		}
+		if buf[n] != '\r' || buf[n+1] != '\n' {
+			return Value{}, fmt.Errorf("%w: bulk string not terminated", ErrProtocol)
+		}
+		return Bulk(string(buf[:n])), nil
+	case KindArray:
+		n, err := r.length(rest)
+		if err != nil || n < 0 {
+			return NullArray(), err
+		}
+		values := make([]Value, n)
+		for i := range values {
+			if values[i], err = r.ReadValue(); err != nil {
+				return Value{}, err
+			}
+		}
+		return Array(values...), nil
+	default:
+		return BulkStrings(strings.Fields(line)...), nil
+	}
+}
+
+func (r *Reader) readLine() (string, error) {
+	line, err := r.br.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
+
+func (r *Reader) length(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n > maxBulkLength {
+		return 0, fmt.Errorf("%w: bad length %q", ErrProtocol, s)
+	}
+	return n, nil
+}
+
+// Writer encodes RESP values. Call Flush to send buffered data.
+type Writer struct {
+	bw *bufio.Writer
+}
+
+// NewWriter returns a Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{bw: bufio.NewWriter(w)}
+}
+
+// WriteCommand writes a command as an array of bulk strings.
+func (w *Writer) WriteCommand(args ...string) error {
+	return w.WriteValue(BulkStrings(args...))
+}
+
+// WriteValue writes v.
+func (w *Writer) WriteValue(v Value) error {
+	switch v.Kind {
+	case KindSimple, KindError:
+		w.bw.WriteByte(byte(v.Kind))
+		w.bw.WriteString(strings.NewReplacer("\r", " ", "\n", " ").Replace(v.Str))
+		_, err := w.bw.WriteString("\r\n")
+		return err
+	case KindInteger:
+		_, err := fmt.Fprintf(w.bw, ":%d\r\n", v.Int)
+		return err
+	case KindBulk:
+		if v.Null {
+			_, err := w.bw.WriteString("$-1\r\n")
+			return err
+		}
+		fmt.Fprintf(w.bw, "$%d\r\n", len(v.Str))
+		w.bw.WriteString(v.Str)
+		_, err := w.bw.WriteString("\r\n")
+		return err
+	case KindArray:
+		if v.Null {
+			_, err := w.bw.WriteString("*-1\r\n")
+			return err
+		}
+		fmt.Fprintf(w.bw, "*%d\r\n", len(v.Array))
+		for _, e := range v.Array {
+			if err := w.WriteValue(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("resp: unknown kind %q", v.Kind)
+	}
+}
+
+// Flush sends buffered data to the underlying writer.
+func (w *Writer) Flush() error {
+	return w.bw.Flush()
+}
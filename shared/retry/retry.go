@@ -56,3 +56,19 @@ func WithBackoff(ctx context.Context, cfg Config, operation func() error) error
 
 	return err
 }
+
+// Backoff returns the wait before the given retry attempt (1-based) using the
+// same exponential schedule as WithBackoff
+func Backoff(cfg Config, attempt int) time.Duration {
+	wait := cfg.InitialWait
+	for i := 1; i < attempt; i++ {
+		wait *= 2
+		if wait > cfg.MaxWait {
+			return cfg.MaxWait
+		}
+	}
+	if wait > cfg.MaxWait {
+		wait = cfg.MaxWait
+	}
+	return wait
+}
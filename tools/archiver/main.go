@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"ride-sharing/shared/archive"
+	"ride-sharing/shared/events"
+)
+
+func main() {
+	dir := flag.String("dir", "data/archive", "Directory of the archive segment log")
+	segmentMB := flag.Int64("segment-mb", 64, "Roll over to a new segment after this many megabytes")
+	flag.Parse()
+
+	// The archiver verifies and decrypts like any consumer and re-encrypts sealed payloads before writing them,
+	// so it needs the same EVENTS_SIGNING_KEYS and EVENTS_ENCRYPTION_KEYS as the services.
+	if err := events.ConfigureSecurityFromEnv(); err != nil {
+		fmt.Printf("Error loading event security settings: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := archive.DefaultOptions()
+	opts.SegmentBytes = *segmentMB << 20
+	archiveLog, err := archive.Open(*dir, opts)
+	if err != nil {
+		fmt.Printf("Error opening archive: %v\n", err)
+		os.Exit(1)
+	}
+	defer archiveLog.Close()
+
+	// Each tap queue names its own exchange, so one subscriber covers them all.
+	cfg := events.NewTripExchangeConfig().ForService("event-archiver")
+	subscriber, err := events.NewSubscriber(cfg)
+	if err != nil {
+		fmt.Printf("Error connecting to %s: %v\n", cfg.Backend, err)
+		os.Exit(1)
+	}
+
+	archiver := archive.NewArchiver(archiveLog, subscriber)
+	if err := archiver.Start(events.DefaultTopology()); err != nil {
+		subscriber.Close()
+		fmt.Printf("Error starting archiver: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Archiving events into %s, press Ctrl+C to stop\n", *dir)
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	<-sigCh
+
+	ctx, cancel := context.WithTimeout(context.Background(), events.DefaultShutdownTimeout)
+	defer cancel()
+	if err := events.Shutdown(ctx, subscriber); err != nil {
+		fmt.Printf("Error shutting down subscriber: %v\n", err)
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+
+	"ride-sharing/shared/contracts"
+	"ride-sharing/shared/events"
+)
+
+func main() {
+	service := flag.String("service", "", "Only include operations of this service (e.g., trip-service)")
+	out := flag.String("out", "", "Write the document to this file instead of stdout")
+	version := flag.String("version", "1.0.0", "Version of the event catalog")
+	protoDir := flag.String("proto", "proto", "Directory with the .proto files of protobuf payloads")
+	flag.Parse()
+
+	topology := events.DefaultTopology()
+	if err := topology.Validate(); err != nil {
+		fmt.Printf("Invalid topology:\n%v\n", err)
+		os.Exit(1)
+	}
+
+	protos, err := loadProtoCatalog(*protoDir)
+	if err != nil {
+		fmt.Printf("Error reading proto files: %v\n", err)
+		os.Exit(1)
+	}
+
+	doc, err := buildDocument(topology, protos, *service, *version)
+	if err != nil {
+		fmt.Printf("Error building document: %v\n", err)
+		os.Exit(1)
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		fmt.Printf("Error encoding document: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *out == "" {
+		fmt.Println(string(data))
+		return
+	}
+	if err := os.WriteFile(*out, append(data, '\n'), 0o644); err != nil {
+		fmt.Printf("Error writing %s: %v\n", *out, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote AsyncAPI document to %s\n", *out)
+}
+
+// buildDocument assembles an AsyncAPI 3.0 document: one channel per routing
+// key, one message per channel, a send operation per producer and a receive
+// operation per subscription queue.
+func buildDocument(topology *events.Topology, protos *protoCatalog, service, version string) (map[string]interface{}, error) {
+	schemas := newSchemaBuilder()
+	channels := map[string]interface{}{}
+	messages := map[string]interface{}{}
+	operations := map[string]interface{}{}
+
+	routes := map[string]events.RouteSpec{}
+	for _, r := range topology.Routes {
+		routes[r.RoutingKey] = r
+	}
+
+	for _, d := range contracts.Catalog() {
+		rk := d.RoutingKey
+
+		message := map[string]interface{}{
+			"name":        rk,
+			"title":       rk,
+			"summary":     d.Summary,
+			"contentType": events.ContentTypeJSON,
+			"payload":     Schema{},
+			"traits":      []interface{}{ref("#/components/messageTraits/envelope")},
+		}
+		switch {
+		case d.Payload != nil:
+			message["payload"] = schemas.schemaFor(d.Payload)
+		case d.ProtoMessage != "":
+			// protobuf messages are published with protojson by default
+			payload, err := protos.schemaFor(schemas, d.ProtoMessage)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", rk, err)
+			}
+			message["payload"] = payload
+			message["contentType"] = events.ContentTypeProtoJSON
+		}
+		messages[rk] = message
+
+		channel := map[string]interface{}{
+			"address":  rk,
+			"messages": map[string]interface{}{rk: ref("#/components/messages/" + rk)},
+		}
+		if r, ok := routes[rk]; ok {
+			channel["bindings"] = map[string]interface{}{
+				"amqp": map[string]interface{}{
+					"is": "routingKey",
+					"exchange": map[string]interface{}{
+						"name":       r.Exchange,
+						"type":       exchangeKind(topology, r.Exchange),
+						"durable":    true,
+						"autoDelete": false,
+						"vhost":      "/",
+					},
+					"bindingVersion": "0.3.0",
+				},
+			}
+		} else {
+			channel["description"] = "Not published on any exchange yet."
+		}
+		channels[rk] = channel
+	}
+
+	for _, r := range topology.Routes {
+		for _, producer := range r.Producers {
+			if service != "" && producer != service {
+				continue
+			}
+			operations[producer+".send."+r.RoutingKey] = map[string]interface{}{
+				"action":   "send",
+				"summary":  fmt.Sprintf("%s publishes %s to the %s exchange.", producer, r.RoutingKey, r.Exchange),
+				"channel":  ref("#/channels/" + r.RoutingKey),
+				"messages": []interface{}{ref("#/channels/" + r.RoutingKey + "/messages/" + r.RoutingKey)},
+				"tags":     []interface{}{map[string]interface{}{"name": producer}},
+			}
+		}
+	}
+
+	for _, q := range topology.Queues {
+		if service != "" && q.Consumer != service {
+			continue
+		}
+
+		var matched []events.RouteSpec
+		for _, r := range topology.Routes {
+			if r.Exchange == q.Exchange && events.MatchRoutingKey(q.RoutingKey, r.RoutingKey) {
+				matched = append(matched, r)
+			}
+		}
+
+		for _, r := range matched {
+			id := q.Consumer + ".receive." + q.Name
+			if len(matched) > 1 {
+				id += "." + r.RoutingKey
+			}
+			operations[id] = map[string]interface{}{
+				"action":   "receive",
+				"summary":  fmt.Sprintf("%s consumes %s from queue %s.", q.Consumer, r.RoutingKey, q.Name),
+				"channel":  ref("#/channels/" + r.RoutingKey),
+				"messages": []interface{}{ref("#/channels/" + r.RoutingKey + "/messages/" + r.RoutingKey)},
+				"tags":     []interface{}{map[string]interface{}{"name": q.Consumer}},
+				"x-queue": map[string]interface{}{
+					"name":       q.Name,
+					"binding":    q.RoutingKey,
+					"maxRetries": q.Retry.MaxRetries,
+					"deadLetter": q.DeadLetter,
+				},
+			}
+		}
+	}
+
+	// The envelope that wraps every payload, documented for consumers that
+	// read raw messages.
+	schemas.schemaFor(reflect.TypeOf(contracts.AmqpMessage{}))
+	schemas.schemaFor(reflect.TypeOf(contracts.AmqpBatchItem{}))
+
+	title := "Ride Sharing Event Catalog"
+	if service != "" {
+		title = fmt.Sprintf("%s events", service)
+	}
+
+	return map[string]interface{}{
+		"asyncapi": "3.0.0",
+		"info": map[string]interface{}{
+			"title":   title,
+			"version": version,
+			"description": "Events and commands exchanged over RabbitMQ. Every payload is encoded according to " +
+				"the message content type and carried in the data field of the contracts.AmqpMessage envelope; " +
+				"the envelope metadata is mirrored into the AMQP headers. High-volume commands may be published as " +
+				"batches with content type " + events.ContentTypeBatch + ", whose data is an array of contracts.AmqpBatchItem " +
+				"delivered to handlers one by one.",
+		},
+		"defaultContentType": events.ContentTypeJSON,
+		"servers": map[string]interface{}{
+			"rabbitmq": map[string]interface{}{
+				"host":     brokerHost(events.NewConfig().URL),
+				"protocol": "amqp",
+			},
+		},
+		"channels":   channels,
+		"operations": operations,
+		"components": map[string]interface{}{
+			"messages": messages,
+			"schemas":  schemas.components,
+			"messageTraits": map[string]interface{}{
+				"envelope": map[string]interface{}{
+					"headers": envelopeHeaders(),
+				},
+			},
+		},
+	}, nil
+}
+
+// envelopeHeaders describes the AMQP headers that mirror the envelope metadata.
+func envelopeHeaders() Schema {
+	str := func(description string) Schema { return Schema{"type": "string", "description": description} }
+	return Schema{
+		"type": "object",
+		"properties": map[string]interface{}{
+			events.HeaderEventID:       str("Unique ID of the event, stable across retries."),
+			events.HeaderEventType:     str("Routing key the event was published with."),
+			events.HeaderSchemaVersion: Schema{"type": "integer", "description": "Version of the payload schema."},
+			events.HeaderProducer:      str("Service that published the event."),
+			events.HeaderCorrelationID: str("Shared by every event caused by the same originating request."),
+			events.HeaderCausationID:   str("EventID of the event whose handling produced this one."),
+			events.HeaderOccurredAt:    Schema{"type": "string", "format": "date-time"},
+			events.HeaderContentType:   str("Encoding of the payload."),
+		},
+		"required": []string{events.HeaderEventID, events.HeaderEventType, events.HeaderProducer},
+	}
+}
+
+// exchangeKind returns the declared type of an exchange.
+func exchangeKind(topology *events.Topology, name string) string {
+	if e, ok := topology.Exchange(name); ok {
+		return e.Kind
+	}
+	return "topic"
+}
+
+// brokerHost strips credentials and scheme from the AMQP URL.
+func brokerHost(amqpURL string) string {
+	u, err := url.Parse(amqpURL)
+	if err != nil || u.Host == "" {
+		return "localhost:5672"
+	}
+	return u.Host
+}
+
+func ref(target string) map[string]interface{} {
+	return map[string]interface{}{"$ref": target}
+}
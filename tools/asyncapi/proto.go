@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// protoField is a field of a message declared in a .proto file.
+type protoField struct {
+	name     string
+	typ      string
+	repeated bool
+}
+
+// protoCatalog holds the messages and enums of every .proto file in a
+// directory, keyed by their fully qualified name (package.Name). Only the
+// flat proto3 subset used in proto/ is understood: top level messages and
+// enums, scalar, message, repeated and map fields.
+type protoCatalog struct {
+	messages map[string][]protoField
+	enums    map[string]bool
+}
+
+var (
+	protoComment   = regexp.MustCompile(`(?s)//[^\n]*|/\*.*?\*/`)
+	protoPackage   = regexp.MustCompile(`\bpackage\s+([\w.]+)\s*;`)
+	protoBlock     = regexp.MustCompile(`\b(message|enum)\s+(\w+)\s*\{([^{}]*)\}`)
+	protoFieldDecl = regexp.MustCompile(`^(repeated\s+|optional\s+)?(map\s*<\s*[\w.]+\s*,\s*[\w.]+\s*>|[\w.]+)\s+(\w+)\s*=\s*\d+`)
+)
+
+// loadProtoCatalog parses all .proto files in dir.
+func loadProtoCatalog(dir string) (*protoCatalog, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.proto"))
+	if err != nil {
+		return nil, err
+	}
+
+	c := &protoCatalog{messages: map[string][]protoField{}, enums: map[string]bool{}}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		c.parse(string(data))
+	}
+	return c, nil
+}
+
+func (c *protoCatalog) parse(src string) {
+	src = protoComment.ReplaceAllString(src, "")
+
+	pkg := ""
+	if m := protoPackage.FindStringSubmatch(src); m != nil {
+		pkg = m[1] + "."
+	}
+
+	for _, block := range protoBlock.FindAllStringSubmatch(src, -1) {
+		name := pkg + block[2]
+		if block[1] == "enum" {
+			c.enums[name] = true
+			continue
+		}
+
+		var fields []protoField
+		for _, stmt := range strings.Split(block[3], ";") {
+			m := protoFieldDecl.FindStringSubmatch(strings.TrimSpace(stmt))
+			if m == nil {
+				continue
+			}
+			fields = append(fields, protoField{
+				name:     m[3],
+				typ:      strings.Join(strings.Fields(m[2]), ""),
+				repeated: strings.HasPrefix(m[1], "repeated"),
+			})
+		}
+		c.messages[name] = fields
+	}
+}
+
+// resolve qualifies a type name used inside package pkg.
+func (c *protoCatalog) resolve(pkg, typ string) string {
+	if _, ok := c.messages[pkg+"."+typ]; ok {
+		return pkg + "." + typ
+	}
+	if c.enums[pkg+"."+typ] {
+		return pkg + "." + typ
+	}
+	return typ
+}
+
+// schemaFor registers the schema of a message, and of every message it
+// references, under components.schemas and returns a $ref to it.
+func (c *protoCatalog) schemaFor(b *schemaBuilder, name string) (Schema, error) {
+	fields, ok := c.messages[name]
+	if !ok {
+		return nil, fmt.Errorf("protobuf message %s not found", name)
+	}
+
+	ref := Schema{"$ref": "#/components/schemas/" + name}
+	if _, ok := b.components[name]; ok {
+		return ref, nil
+	}
+	b.components[name] = Schema{}
+
+	pkg := name[:strings.LastIndex(name, ".")]
+	properties := map[string]interface{}{}
+	for _, f := range fields {
+		s, err := c.fieldSchema(b, pkg, f.typ)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", name, f.name, err)
+		}
+		if f.repeated {
+			s = Schema{"type": "array", "items": s}
+		}
+		properties[protoJSONName(f.name)] = s
+	}
+
+	// proto3 fields are optional on the wire, so nothing is required.
+	b.components[name] = Schema{"type": "object", "properties": properties}
+	return ref, nil
+}
+
+// fieldSchema maps a protobuf field type to its protojson representation.
+func (c *protoCatalog) fieldSchema(b *schemaBuilder, pkg, typ string) (Schema, error) {
+	switch typ {
+	case "double", "float":
+		return Schema{"type": "number"}, nil
+	case "int32", "uint32", "sint32", "fixed32", "sfixed32":
+		return Schema{"type": "integer"}, nil
+	case "int64", "uint64", "sint64", "fixed64", "sfixed64":
+		// protojson encodes 64-bit integers as strings
+		return Schema{"type": "string", "format": "int64"}, nil
+	case "bool":
+		return Schema{"type": "boolean"}, nil
+	case "string":
+		return Schema{"type": "string"}, nil
+	case "bytes":
+		return Schema{"type": "string", "contentEncoding": "base64"}, nil
+	}
+
+	if strings.HasPrefix(typ, "map<") {
+		kv := strings.SplitN(strings.TrimSuffix(strings.TrimPrefix(typ, "map<"), ">"), ",", 2)
+		value, err := c.fieldSchema(b, pkg, kv[1])
+		if err != nil {
+			return nil, err
+		}
+		return Schema{"type": "object", "additionalProperties": value}, nil
+	}
+
+	qualified := c.resolve(pkg, typ)
+	if c.enums[qualified] {
+		return Schema{"type": "string", "description": "enum " + qualified}, nil
+	}
+	return c.schemaFor(b, qualified)
+}
+
+// protoJSONName is the lowerCamelCase JSON name protojson uses for a field.
+func protoJSONName(name string) string {
+	var sb strings.Builder
+	upper := false
+	for _, r := range name {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			sb.WriteString(strings.ToUpper(string(r)))
+			upper = false
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
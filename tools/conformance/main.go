@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"ride-sharing/shared/events"
+	"ride-sharing/shared/events/conformance"
+	"ride-sharing/shared/miniredis"
+)
+
+const exchange = "conformance"
+
+func main() {
+	backends := flag.String("backends", "memory,redis,rabbitmq", "Comma-separated list of backends to check")
+	redisURL := flag.String("redis-url", "", "Redis to run against (defaults to an embedded server)")
+	amqpURL := flag.String("amqp-url", os.Getenv("RABBITMQ_URL"), "RabbitMQ to run against (the rabbitmq backend is skipped when empty)")
+	flag.Parse()
+
+	failed := false
+	for _, name := range strings.Split(*backends, ",") {
+		name = strings.TrimSpace(name)
+
+		var backend conformance.Backend
+		var cleanup func()
+		switch name {
+		case events.BackendMemory:
+			backend = memoryBackend()
+		case events.BackendRedis:
+			url := *redisURL
+			if url == "" {
+				server, err := miniredis.Run("127.0.0.1:0")
+				if err != nil {
+					fmt.Printf("Error starting embedded Redis: %v\n", err)
+					os.Exit(1)
+				}
+				url, cleanup = server.URL(), func() { server.Close() }
+			}
+			backend = redisBackend(url)
+		case events.BackendRabbitMQ:
+			if *amqpURL == "" {
+				fmt.Printf("SKIP  %s (no -amqp-url)\n", name)
+				continue
+			}
+			backend = rabbitMQBackend(*amqpURL)
+		default:
+			fmt.Printf("Unknown backend %q\n", name)
+			os.Exit(1)
+		}
+
+		if !report(conformance.Run(context.Background(), backend)) {
+			failed = true
+		}
+		if cleanup != nil {
+			cleanup()
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// report prints the results of one backend and returns whether all cases passed.
+func report(results []conformance.Result) bool {
+	passed := true
+	for _, r := range results {
+		switch {
+		case r.Skipped:
+			fmt.Printf("SKIP  %-8s %s\n", r.Backend, r.Case)
+		case r.Passed():
+			fmt.Printf("PASS  %-8s %-20s %v\n", r.Backend, r.Case, r.Duration.Round(time.Millisecond))
+		default:
+			passed = false
+			fmt.Printf("FAIL  %-8s %-20s %v\n      %v\n", r.Backend, r.Case, r.Duration.Round(time.Millisecond), r.Err)
+		}
+	}
+	return passed
+}
+
+func memoryBackend() conformance.Backend {
+	broker := events.NewInMemoryBroker()
+	return conformance.Backend{
+		Name: events.BackendMemory,
+		NewPublisher: func() (events.Publisher, error) {
+			return events.NewInMemoryPublisher(broker, exchange, "conformance"), nil
+		},
+		NewSubscriber: func() (events.Subscriber, error) {
+			return events.NewInMemorySubscriber(broker, exchange, "conformance"), nil
+		},
+		NewRequester: func() (events.Requester, error) {
+			return events.NewInMemoryRequester(broker, exchange, "conformance", events.DefaultRequesterOptions()), nil
+		},
+		CrashRedelivery: true,
+	}
+}
+
+func redisBackend(url string) conformance.Backend {
+	// A short visibility timeout lets the crash case finish quickly.
+	opts := events.DefaultRedisOptions()
+	opts.Prefix = "conformance"
+	opts.VisibilityTimeout = 1 * time.Second
+	opts.BlockTimeout = 200 * time.Millisecond
+
+	return conformance.Backend{
+		Name: events.BackendRedis,
+		NewPublisher: func() (events.Publisher, error) {
+			return events.NewRedisPublisher(url, exchange, "conformance", opts)
+		},
+		NewSubscriber: func() (events.Subscriber, error) {
+			return events.NewRedisSubscriber(url, exchange, "conformance", opts)
+		},
+		NewRequester: func() (events.Requester, error) {
+			return events.NewRedisRequester(url, exchange, "conformance", events.DefaultRequesterOptions(), opts)
+		},
+		CrashRedelivery: true,
+	}
+}
+
+func rabbitMQBackend(url string) conformance.Backend {
+	return conformance.Backend{
+		Name: events.BackendRabbitMQ,
+		NewPublisher: func() (events.Publisher, error) {
+			return events.NewRabbitMQPublisher(url, exchange, "conformance")
+		},
+		NewSubscriber: func() (events.Subscriber, error) {
+			return events.NewRabbitMQSubscriber(url, exchange, "conformance")
+		},
+		NewRequester: func() (events.Requester, error) {
+			return events.NewRabbitMQRequester(url, exchange, "conformance", events.DefaultRequesterOptions())
+		},
+		CrashRedelivery: true,
+	}
+}
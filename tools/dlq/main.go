@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"ride-sharing/shared/events"
+)
+
+func main() {
+	queue := flag.String("queue", "", "Name of the subscription queue (e.g., payment_success_queue)")
+	exchange := flag.String("exchange", "", "Exchange the queue is bound to (defaults to the exchange declared in the topology)")
+	action := flag.String("action", "inspect", "Action to perform: inspect or redrive")
+	limit := flag.Int("limit", 10, "Maximum number of dead letters to process")
+	flag.Parse()
+
+	if *queue == "" {
+		fmt.Println("Please provide a queue name using -queue flag")
+		os.Exit(1)
+	}
+
+	if *exchange == "" {
+		q, ok := events.DefaultTopology().Queue(*queue)
+		if !ok {
+			fmt.Printf("Queue %s is not declared in the topology, please provide -exchange\n", *queue)
+			os.Exit(1)
+		}
+		*exchange = q.Exchange
+	}
+
+	cfg := events.NewConfig()
+	cfg.Exchange = *exchange
+	subscriber, err := events.NewSubscriber(cfg)
+	if err != nil {
+		fmt.Printf("Error connecting to %s: %v\n", cfg.Backend, err)
+		os.Exit(1)
+	}
+	defer subscriber.Close()
+
+	manager, ok := subscriber.(events.DeadLetterManager)
+	if !ok {
+		fmt.Printf("Backend %s does not support dead letter management\n", cfg.Backend)
+		os.Exit(1)
+	}
+
+	switch *action {
+	case "inspect":
+		letters, err := manager.InspectDeadLetters(*queue, *limit)
+		if err != nil {
+			fmt.Printf("Error inspecting dead letters: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Found %d dead letters in %s.dlq\n", len(letters), *queue)
+		for i, l := range letters {
+			fmt.Printf("[%d] eventID=%s routingKey=%s retries=%d failedAt=%s\n    reason: %s\n    body: %s\n",
+				i+1, l.EventID, l.RoutingKey, l.RetryCount, l.FailedAt.Format("2006-01-02T15:04:05Z07:00"), l.Reason, l.Body)
+		}
+	case "redrive":
+		count, err := manager.RedriveDeadLetters(*queue, *limit)
+		if err != nil {
+			fmt.Printf("Error redriving dead letters: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Redrove %d dead letters back to %s\n", count, *queue)
+	default:
+		fmt.Printf("Unknown action %q, expected inspect or redrive\n", *action)
+		os.Exit(1)
+	}
+}
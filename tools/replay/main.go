@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"ride-sharing/shared/archive"
+	"ride-sharing/shared/events"
+)
+
+func main() {
+	dir := flag.String("dir", "data/archive", "Directory of the archive segment log")
+	from := flag.String("from", "", "Replay events archived at or after this time (RFC3339)")
+	to := flag.String("to", "", "Replay events archived before this time (RFC3339)")
+	since := flag.Duration("since", 0, "Replay events archived within this duration before now, instead of -from")
+	trip := flag.String("trip", "", "Only replay events of this trip ID")
+	routingKey := flag.String("routing-key", "", "Only replay routing keys matching this pattern (e.g., payment.event.*)")
+	sourceExchange := flag.String("source-exchange", "", "Only replay events originally published on this exchange")
+	exchange := flag.String("exchange", "", "Publish to this exchange (defaults to each event's original exchange)")
+	queue := flag.String("queue", "", "Deliver directly to this queue instead of routing through the exchange")
+	newIDs := flag.Bool("new-ids", false, "Assign new event IDs so idempotent consumers do not drop the replayed events")
+	rate := flag.Float64("rate", 0, "Maximum events per second (0 means unlimited)")
+	limit := flag.Int("limit", 0, "Maximum number of events to replay (0 means no limit)")
+	dryRun := flag.Bool("dry-run", false, "Only list the matching events")
+	flag.Parse()
+
+	// Encrypted records are decrypted with the archiver's keys and re-sealed by the publisher.
+	if err := events.ConfigureSecurityFromEnv(); err != nil {
+		fmt.Printf("Error loading event security settings: %v\n", err)
+		os.Exit(1)
+	}
+
+	filter := archive.Filter{
+		TripID:     *trip,
+		Exchange:   *sourceExchange,
+		RoutingKey: *routingKey,
+		Limit:      *limit,
+	}
+	var err error
+	if filter.From, err = parseTime(*from); err != nil {
+		fmt.Printf("Invalid -from: %v\n", err)
+		os.Exit(1)
+	}
+	if filter.To, err = parseTime(*to); err != nil {
+		fmt.Printf("Invalid -to: %v\n", err)
+		os.Exit(1)
+	}
+	if *since > 0 {
+		filter.From = time.Now().Add(-*since)
+	}
+
+	reader, err := archive.OpenReader(*dir)
+	if err != nil {
+		fmt.Printf("Error opening archive: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *dryRun {
+		count := 0
+		err := reader.Scan(filter, func(r archive.Record) error {
+			count++
+			printRecord(r)
+			return nil
+		})
+		if err != nil {
+			fmt.Printf("Error reading archive: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%d events match\n", count)
+		return
+	}
+
+	publishers := make(map[string]events.Publisher)
+	defer func() {
+		for _, p := range publishers {
+			p.Close()
+		}
+	}()
+
+	replayer := &archive.Replayer{
+		Publisher: func(exchange string) (events.Publisher, error) {
+			if p, ok := publishers[exchange]; ok {
+				return p, nil
+			}
+			cfg := events.NewConfig().ForService("event-replay")
+			cfg.Exchange = exchange
+			p, err := events.NewPublisher(cfg)
+			if err != nil {
+				return nil, err
+			}
+			publishers[exchange] = p
+			return p, nil
+		},
+		Exchange: *exchange,
+		Queue:    *queue,
+		NewIDs:   *newIDs,
+		Rate:     *rate,
+		OnRecord: printRecord,
+	}
+
+	stats, err := replayer.Replay(context.Background(), reader, filter)
+	fmt.Printf("Replayed %d of %d matching events (%d duplicates skipped)\n", stats.Published, stats.Matched, stats.Duplicates)
+	if err != nil {
+		fmt.Printf("Error replaying events: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func printRecord(r archive.Record) {
+	fmt.Printf("[%d] %s %s/%s eventID=%s trip=%s\n",
+		r.Seq, r.ArchivedAt.Format(time.RFC3339), r.Exchange, r.RoutingKey, r.EventID, r.TripID)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"ride-sharing/shared/events"
+)
+
+func main() {
+	vhost := flag.String("vhost", "/", "Virtual host the definitions are generated for")
+	out := flag.String("out", "", "Write the definitions to this file instead of stdout")
+	check := flag.Bool("check", false, "Only validate the topology, do not print definitions")
+	flag.Parse()
+
+	topology := events.DefaultTopology()
+	if err := topology.Validate(); err != nil {
+		fmt.Printf("Invalid topology:\n%v\n", err)
+		os.Exit(1)
+	}
+
+	for _, r := range topology.Unconsumed() {
+		fmt.Fprintf(os.Stderr, "warning: %s on exchange %s has no consumers\n", r.RoutingKey, r.Exchange)
+	}
+
+	if *check {
+		fmt.Printf("Topology is valid: %d exchanges, %d queues\n", len(topology.Exchanges), len(topology.Queues))
+		return
+	}
+
+	defs, err := topology.Definitions(*vhost)
+	if err != nil {
+		fmt.Printf("Error generating definitions: %v\n", err)
+		os.Exit(1)
+	}
+
+	data, err := json.MarshalIndent(defs, "", "  ")
+	if err != nil {
+		fmt.Printf("Error encoding definitions: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *out == "" {
+		fmt.Println(string(data))
+		return
+	}
+	if err := os.WriteFile(*out, append(data, '\n'), 0o644); err != nil {
+		fmt.Printf("Error writing %s: %v\n", *out, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote broker definitions to %s\n", *out)
+}